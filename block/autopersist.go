@@ -0,0 +1,84 @@
+package block
+
+import "time"
+
+// DefaultAutoPersistInterval is default interval EnableAutoPersist saves the chain at.
+const DefaultAutoPersistInterval = time.Minute
+
+// AutoPersistDebounce is delay the save after a new block waits, so a burst
+// of blocks, such as a sync or a reorg, is saved once.
+const AutoPersistDebounce = time.Second
+
+// EnableAutoPersist is to SaveToFile path every interval and shortly after
+// new blocks, so a killed process loses little more than the pool.
+// interval 0 only saves after new blocks. Saves are serialized. Calling it
+// again replaces path and interval, and an empty path stops saving once
+// a save in progress is done.
+func (bc *Blockchain) EnableAutoPersist(path string, interval time.Duration) {
+	bc.muxSave.Lock()
+	bc.muxPersist.Lock()
+	bc.persistPath = path
+	bc.persistGeneration++
+	generation := bc.persistGeneration
+	hooked := bc.persistHooked
+	bc.persistHooked = true
+	bc.muxPersist.Unlock()
+	bc.muxSave.Unlock()
+
+	if !hooked {
+		bc.OnNewBlock(func(*Block) { bc.schedulePersist() })
+		bc.OnReorg(func(int, int) { bc.schedulePersist() })
+	}
+	if path == "" || interval <= 0 {
+		return
+	}
+	var tick func()
+	tick = func() {
+		if bc.persist(generation) {
+			_ = time.AfterFunc(interval, tick)
+		}
+	}
+	_ = time.AfterFunc(interval, tick)
+}
+
+// schedulePersist is to save the chain after AutoPersistDebounce unless a
+// save is already scheduled or auto persistence is off.
+func (bc *Blockchain) schedulePersist() {
+	bc.muxPersist.Lock()
+	defer bc.muxPersist.Unlock()
+	if bc.persistPath == "" || bc.persistScheduled {
+		return
+	}
+	bc.persistScheduled = true
+	_ = time.AfterFunc(AutoPersistDebounce, func() {
+		bc.muxPersist.Lock()
+		bc.persistScheduled = false
+		generation := bc.persistGeneration
+		bc.muxPersist.Unlock()
+		bc.persist(generation)
+	})
+}
+
+// persist is to save the chain to the auto persist path, logging the
+// outcome, unless EnableAutoPersist was called again since generation. It
+// reports whether it was still current.
+func (bc *Blockchain) persist(generation int) bool {
+	bc.muxSave.Lock()
+	defer bc.muxSave.Unlock()
+	bc.muxPersist.Lock()
+	path, current := bc.persistPath, bc.persistGeneration == generation
+	bc.muxPersist.Unlock()
+	if !current || path == "" {
+		return false
+	}
+
+	if err := bc.saveToFile(path); err != nil {
+		bc.logger.Error("persist", "action", "persist", "status", "fail", "path", path, "error", err)
+		return true
+	}
+	bc.mux.RLock()
+	height := bc.Height()
+	bc.mux.RUnlock()
+	bc.logger.Debug("persist", "action", "persist", "status", "success", "path", path, "height", height)
+	return true
+}
@@ -0,0 +1,95 @@
+package block
+
+import (
+	"goblockchain/wallet"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// waitForSave is to wait until the chain saved at path matches bc's chain
+// and pool, failing after timeout.
+func waitForSave(tb testing.TB, bc *Blockchain, path string, timeout time.Duration) {
+	tb.Helper()
+	deadline := time.Now().Add(timeout)
+	for {
+		loaded, err := LoadFromFile(path, WithDifficulty(0))
+		if err == nil && sameChain(loaded.Chain(), bc.Chain()) && loaded.PoolSize() == bc.PoolSize() {
+			return
+		}
+		if time.Now().After(deadline) {
+			tb.Fatalf("saved chain doesn't match memory after %v (load error %v)", timeout, err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func sameChain(a, b []*Block) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Hash() != b[i].Hash() {
+			return false
+		}
+	}
+	return true
+}
+
+func TestAutoPersistInterval(t *testing.T) {
+	alice := wallet.NewWallet()
+	bc := newTestBlockchain(t, WithDifficulty(0), WithGenesisAllocations(map[string]float32{alice.BlockchainAddress(): 10}))
+	path := filepath.Join(t.TempDir(), "chain.json")
+	bc.EnableAutoPersist(path, 20*time.Millisecond)
+	t.Cleanup(func() { bc.EnableAutoPersist("", 0) })
+
+	bc.Mining()
+	bc.Mining()
+	if !sendTransaction(bc, alice, "1Bob", 1) {
+		t.Fatal("transaction rejected")
+	}
+	// The interval saves well before the debounce after the blocks would.
+	waitForSave(t, bc, path, AutoPersistDebounce/2)
+
+	bc.EnableAutoPersist("", 0)
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("chain saved after auto persistence stopped: %v", err)
+	}
+}
+
+func TestAutoPersistAfterBlocks(t *testing.T) {
+	bc := newTestBlockchain(t, WithDifficulty(0))
+	path := filepath.Join(t.TempDir(), "chain.json")
+	bc.EnableAutoPersist(path, 0)
+	t.Cleanup(func() { bc.EnableAutoPersist("", 0) })
+
+	for i := 0; i < 3; i++ {
+		bc.Mining()
+	}
+	waitForSave(t, bc, path, 3*AutoPersistDebounce)
+}
+
+func TestSaveToFileConcurrent(t *testing.T) {
+	bc := newTestBlockchain(t, WithDifficulty(0))
+	path := filepath.Join(t.TempDir(), "chain.json")
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			bc.Mining()
+			if err := bc.SaveToFile(path); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	// Saves don't interleave, so the last one has the whole chain.
+	waitForSave(t, bc, path, 0)
+}
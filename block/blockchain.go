@@ -2,14 +2,18 @@ package block
 
 import (
 	"bytes"
+	"context"
 	"crypto/ecdsa"
 	"crypto/sha256"
-	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"goblockchain/utils"
-	"log"
+	"math"
+	"net"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -27,69 +31,238 @@ const (
 	NeighborIPRangeStart          = 0
 	NeighborIPRangeEnd            = 1
 	BlockchainNeighborSyncTimeSec = 20
+
+	ProofOfWorkProgressInterval = 1000
+
+	OriginNodeHeader = "X-Origin-Node"
+)
+
+// Errors returned by AddBlock.
+var (
+	ErrBlockNotExtendTip = errors.New("block does not extend the chain tip")
+	ErrInvalidBlock      = errors.New("invalid block")
+	ErrPeerBanned        = errors.New("peer is banned")
+)
+
+// Errors returned for rejected transactions.
+var (
+	ErrMissingFields        = errors.New("missing field(s)")
+	ErrInvalidSignature     = errors.New("invalid signature")
+	ErrInsufficientBalance  = errors.New("insufficient balance")
+	ErrDuplicateTransaction = errors.New("duplicate transaction")
+	ErrUnknownRecipient     = errors.New("unknown recipient")
+	ErrDustValue            = errors.New("value below dust threshold")
+	ErrSenderKeyMismatch    = errors.New("sender address doesn't match public key")
+	ErrSenderPoolLimit      = errors.New("sender's pending transactions exceed pool limit")
 )
 
+// ErrEmptyChain is returned when a chain has no blocks, such as a bad chain json.
+var ErrEmptyChain = errors.New("chain has no blocks")
+
+// ErrNoNeighbors is returned by Bootstrap when no neighbor is found.
+var ErrNoNeighbors = errors.New("no neighbors found")
+
+// ErrNonceExhausted is returned when proof of work finds no valid nonce.
+var ErrNonceExhausted = errors.New("proof of work nonce exhausted")
+
 // Block is block struct.
 type Block struct {
 	timestamp    int64
-	nonce        int
+	difficulty   int
+	nonce        uint64
+	extraNonce   uint64
 	previousHash [32]byte
 	transactions []*Transaction
 }
 
-// NewBlock is to return new Block struct.
-func NewBlock(nonce int, previousHash [32]byte, transactions []*Transaction) *Block {
+// NewBlock is to return new Block struct mined at difficulty. Its timestamp
+// is left 0; Blockchain stamps the blocks it creates with its clock.
+func NewBlock(nonce uint64, previousHash [32]byte, transactions []*Transaction, difficulty int) *Block {
 	return &Block{
+		difficulty:   difficulty,
 		nonce:        nonce,
 		previousHash: previousHash,
-		timestamp:    time.Now().UnixNano(),
 		transactions: transactions,
 	}
 }
 
+// Timestamp is to return Block's Timestamp.
+func (b *Block) Timestamp() int64 {
+	return b.timestamp
+}
+
+// Difficulty is to return difficulty Block was mined at.
+func (b *Block) Difficulty() int {
+	return b.difficulty
+}
+
 // PreviousHash is to return Block's PreviousHash.
 func (b *Block) PreviousHash() [32]byte {
 	return b.previousHash
 }
 
 // Nonce is to return Block's Nonce.
-func (b *Block) Nonce() int {
+func (b *Block) Nonce() uint64 {
 	return b.nonce
 }
 
+// ExtraNonce is to return Block's ExtraNonce.
+func (b *Block) ExtraNonce() uint64 {
+	return b.extraNonce
+}
+
+// copy is to return copy of block with its own transactions slice.
+func (b *Block) copy() *Block {
+	c := *b
+	c.transactions = make([]*Transaction, len(b.transactions))
+	for i, t := range b.transactions {
+		tc := *t
+		c.transactions[i] = &tc
+	}
+	return &c
+}
+
 // Transactions is to return Block's Transactions.
 func (b *Block) Transactions() []*Transaction {
 	return b.transactions
 }
 
+// TotalValue is to return sum of Block's transaction values, mining rewards included.
+func (b *Block) TotalValue() float32 {
+	var total float32
+	for _, t := range b.transactions {
+		total += t.value
+	}
+	return total
+}
+
 // Print is print block data.
 func (b *Block) Print() {
 	fmt.Printf("timestamp             %d\n", b.timestamp)
+	fmt.Printf("difficulty            %d\n", b.difficulty)
 	fmt.Printf("nonce                 %d\n", b.nonce)
+	fmt.Printf("extraNonce            %d\n", b.extraNonce)
 	fmt.Printf("previousHash          %x\n", b.previousHash)
 	for _, t := range b.transactions {
 		t.Print()
 	}
 }
 
-// Hash is to return sha256.Sum256 hash.
+// Hash is to return hash of Block's header.
 func (b *Block) Hash() [32]byte {
-	m, _ := json.Marshal(b)
-	return sha256.Sum256([]byte(m))
+	return b.Header().Hash()
+}
+
+// Header is to return Block's header committing to its transactions.
+func (b *Block) Header() *BlockHeader {
+	return &BlockHeader{
+		timestamp:    b.timestamp,
+		difficulty:   b.difficulty,
+		nonce:        b.nonce,
+		extraNonce:   b.extraNonce,
+		previousHash: b.previousHash,
+		merkleRoot:   MerkleRoot(b.transactions),
+	}
+}
+
+// MerkleRoot is to return merkle root of Block's transactions.
+func (b *Block) MerkleRoot() [32]byte {
+	return MerkleRoot(b.transactions)
+}
+
+// BlockHeader is block header struct.
+// extraNonce lets miners of the same block template search disjoint nonces.
+type BlockHeader struct {
+	timestamp    int64
+	difficulty   int
+	nonce        uint64
+	extraNonce   uint64
+	previousHash [32]byte
+	merkleRoot   [32]byte
+}
+
+// Timestamp is to return BlockHeader's Timestamp.
+func (h *BlockHeader) Timestamp() int64 {
+	return h.timestamp
+}
+
+// Difficulty is to return BlockHeader's Difficulty.
+func (h *BlockHeader) Difficulty() int {
+	return h.difficulty
+}
+
+// Nonce is to return BlockHeader's Nonce.
+func (h *BlockHeader) Nonce() uint64 {
+	return h.nonce
+}
+
+// ExtraNonce is to return BlockHeader's ExtraNonce.
+func (h *BlockHeader) ExtraNonce() uint64 {
+	return h.extraNonce
+}
+
+// PreviousHash is to return BlockHeader's PreviousHash.
+func (h *BlockHeader) PreviousHash() [32]byte {
+	return h.previousHash
+}
+
+// MerkleRoot is to return BlockHeader's MerkleRoot.
+func (h *BlockHeader) MerkleRoot() [32]byte {
+	return h.merkleRoot
+}
+
+// Hash is to return sha256.Sum256 hash of canonical bytes.
+func (h *BlockHeader) Hash() [32]byte {
+	return sha256.Sum256(h.CanonicalBytes())
+}
+
+// CanonicalBytes is to return header's canonical serialization for hashing.
+// extra_nonce is only written when it's set, so hashes of blocks mined
+// without one are unchanged.
+func (h *BlockHeader) CanonicalBytes() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(`{"timestamp":`)
+	buf.WriteString(strconv.FormatInt(h.timestamp, 10))
+	buf.WriteString(`,"difficulty":`)
+	buf.WriteString(strconv.Itoa(h.difficulty))
+	buf.WriteString(`,"nonce":`)
+	buf.WriteString(strconv.FormatUint(h.nonce, 10))
+	if h.extraNonce != 0 {
+		buf.WriteString(`,"extra_nonce":`)
+		buf.WriteString(strconv.FormatUint(h.extraNonce, 10))
+	}
+	buf.WriteString(`,"previous_hash":"`)
+	buf.WriteString(utils.HashToHex(h.previousHash))
+	buf.WriteString(`","merkle_root":"`)
+	buf.WriteString(utils.HashToHex(h.merkleRoot))
+	buf.WriteString(`"}`)
+	return buf.Bytes()
 }
 
 // MarshalJSON is override Block's marshaljson.
+// hash, tx_count and total_value are derived for explorers and ignored by
+// UnmarshalJSON; the hash covers only the header's canonical bytes.
 func (b *Block) MarshalJSON() ([]byte, error) {
 	return json.Marshal(struct {
 		Timestamp    int64          `json:"timestamp"`
-		Nonce        int            `json:"nonce"`
+		Difficulty   int            `json:"difficulty"`
+		Nonce        uint64         `json:"nonce"`
+		ExtraNonce   uint64         `json:"extra_nonce,omitempty"`
 		PreviousHash string         `json:"previous_hash"`
 		Transaction  []*Transaction `json:"transaction"`
+		Hash         string         `json:"hash"`
+		TxCount      int            `json:"tx_count"`
+		TotalValue   float32        `json:"total_value"`
 	}{
 		Timestamp:    b.timestamp,
+		Difficulty:   b.difficulty,
 		Nonce:        b.nonce,
-		PreviousHash: fmt.Sprintf("%x", b.previousHash),
+		ExtraNonce:   b.extraNonce,
+		PreviousHash: utils.HashToHex(b.previousHash),
 		Transaction:  b.transactions,
+		Hash:         utils.HashToHex(b.Hash()),
+		TxCount:      len(b.transactions),
+		TotalValue:   b.TotalValue(),
 	})
 }
 
@@ -98,64 +271,341 @@ func (b *Block) UnmarshalJSON(data []byte) error {
 	var previousHash string
 	v := &struct {
 		Timestamp    *int64          `json:"timestamp"`
-		Nonce        *int            `json:"nonce"`
-		PreviousHash *string         `json:"previoud_hash"`
-		Transactions *[]*Transaction `json:"transactions"`
+		Difficulty   *int            `json:"difficulty"`
+		Nonce        *uint64         `json:"nonce"`
+		ExtraNonce   *uint64         `json:"extra_nonce"`
+		PreviousHash *string         `json:"previous_hash"`
+		Transactions *[]*Transaction `json:"transaction"`
 	}{
 		Timestamp:    &b.timestamp,
+		Difficulty:   &b.difficulty,
 		Nonce:        &b.nonce,
+		ExtraNonce:   &b.extraNonce,
 		PreviousHash: &previousHash,
 		Transactions: &b.transactions,
 	}
 	if err := json.Unmarshal(data, &v); err != nil {
 		return err
 	}
-	ph, _ := hex.DecodeString(*v.PreviousHash)
-	copy(b.previousHash[:], ph[:32])
+	if v == nil {
+		return nil
+	}
+	if v.PreviousHash == nil {
+		return errors.New("invalid previous_hash: null")
+	}
+	ph, err := utils.HexToHash(*v.PreviousHash)
+	if err != nil {
+		return fmt.Errorf("invalid previous_hash: %w", err)
+	}
+	b.previousHash = ph
 	return nil
 }
 
 // Blockchain is blockchain struct.
 type Blockchain struct {
 	transactionPool   []*Transaction
+	poolIDs           map[[32]byte]bool
+	confirmedIDs      map[[32]byte]bool
+	prunedIDs         map[[32]byte]bool
 	chain             []*Block
 	blockchainAddress string
 	port              uint16
-	mux               sync.Mutex
+	mux               sync.RWMutex
+
+	chainID            string
+	genesisAllocations map[string]float32
+	genesisState       map[string]float32
+	prunedHeight       int
 
 	neighbors    []string
+	seedPeers    []string
 	muxNeighbors sync.Mutex
+
+	peerScores        map[string]*peerScore
+	trustedNodes      map[string]bool
+	seenAnnouncements map[[32]byte]time.Time
+	peerBanDuration   time.Duration
+	muxPeers          sync.Mutex
+
+	logger  utils.Logger
+	metrics Metrics
+	clock   Clock
+
+	orphans     map[[32]byte][]*Block
+	orphanOrder [][32]byte
+
+	genesisTimestamp  int64
+	pinnedGenesisHash *[32]byte
+
+	maxReorgDepth int
+	difficulty    int
+	maxBlockSize  int
+
+	identityKey *ecdsa.PrivateKey
+
+	peerClient  *http.Client
+	peersCtx    context.Context
+	cancelPeers context.CancelFunc
+
+	mempoolTTL          time.Duration
+	rebroadcastInterval time.Duration
+
+	miningNodeIndex int
+	miningNodeCount int
+
+	miningRestart   MiningRestartPolicy
+	miningWorkers   int
+	miningDutyCycle float64
+	cancelMining    context.CancelFunc
+	muxMining       sync.Mutex
+
+	maxNonce              uint64
+	coinbaseMaturity      int
+	requireKnownRecipient bool
+	dustThreshold         float32
+	maxPendingValue       float32
+	maxPendingCount       int
+	targetBlockTime       time.Duration
+	retargetWindow        int
+	maxRetargetSteps      int
+
+	muxSave           sync.Mutex
+	persistPath       string
+	persistGeneration int
+	persistHooked     bool
+	persistScheduled  bool
+	muxPersist        sync.Mutex
+
+	newBlockCallbacks []func(*Block)
+	reorgCallbacks    []func(rolledBack, added int)
+	mempoolCallbacks  []func(added, removed *Transaction)
+	mempoolChanges    []mempoolChange
+	muxEvents         sync.Mutex
+}
+
+// Option is option of NewBlockchain.
+type Option func(*Blockchain)
+
+// WithGenesisAllocations is option to credit initial balances in the genesis block.
+func WithGenesisAllocations(allocations map[string]float32) Option {
+	return func(bc *Blockchain) {
+		bc.genesisAllocations = allocations
+	}
+}
+
+// WithChainID is option to set network chain ID mixed into signed transactions.
+func WithChainID(chainID string) Option {
+	return func(bc *Blockchain) {
+		bc.chainID = chainID
+	}
+}
+
+// WithDifficulty is option to set number of leading hex zeros proof of work needs.
+func WithDifficulty(difficulty int) Option {
+	return func(bc *Blockchain) {
+		bc.difficulty = difficulty
+	}
+}
+
+// WithSimulatedMining is option for tests to mine blocks instantly with
+// nonce 0 instead of searching proof of work. It's WithDifficulty(0), so
+// mining rewards and pool handling are unchanged and the blocks are valid
+// only on chains in this mode.
+func WithSimulatedMining() Option {
+	return WithDifficulty(0)
+}
+
+// WithPeers is option to set peers ("host:port") always kept as neighbors
+// in addition to the ones found by scanning.
+func WithPeers(peers ...string) Option {
+	return func(bc *Blockchain) {
+		bc.seedPeers = peers
+	}
 }
 
 // NewBlockchain is to return new Blockchain struct.
-func NewBlockchain(blockchainAddress string, port uint16) *Blockchain {
-	b := &Block{}
+func NewBlockchain(blockchainAddress string, port uint16, opts ...Option) *Blockchain {
+	bc := newBlockchain(blockchainAddress, port)
+	for _, opt := range opts {
+		opt(bc)
+	}
+	bc.transactionPool = bc.genesisTransactions()
+	genesis := bc.createBlock(0, GenesisPreviousHash, 0)
+	if bc.genesisTimestamp != 0 {
+		genesis.timestamp = bc.genesisTimestamp
+	}
+	return bc
+}
+
+// genesisTransactions is to return genesis allocations as transactions from
+// MiningSender, ordered by address and with the genesis timestamp, 0 unless
+// set WithGenesisTimestamp, so every node builds the same genesis.
+func (bc *Blockchain) genesisTransactions() []*Transaction {
+	addresses := make([]string, 0, len(bc.genesisAllocations))
+	for address := range bc.genesisAllocations {
+		addresses = append(addresses, address)
+	}
+	sort.Strings(addresses)
+
+	transactions := make([]*Transaction, 0, len(addresses))
+	for _, address := range addresses {
+		t := NewTransaction(MiningSender, address, bc.genesisAllocations[address])
+		t.timestamp = bc.genesisTimestamp
+		transactions = append(transactions, t)
+	}
+	return transactions
+}
+
+// newBlockchain is to return Blockchain struct without any block.
+func newBlockchain(blockchainAddress string, port uint16) *Blockchain {
 	bc := new(Blockchain)
 	bc.blockchainAddress = blockchainAddress
-	bc.CreateBlock(0, b.Hash())
 	bc.port = port
+	bc.logger = utils.NewStdLogger()
+	bc.metrics = NopMetrics{}
+	bc.clock = realClock{}
+	bc.maxNonce = math.MaxUint64
+	bc.miningWorkers = 1
+	bc.miningDutyCycle = 1
+	bc.peerBanDuration = DefaultPeerBanDuration
+	bc.maxReorgDepth = DefaultMaxReorgDepth
+	bc.difficulty = MiningDifficulty
+	bc.maxRetargetSteps = DefaultMaxRetargetSteps
+	bc.initPeerClient()
+	bc.identityKey = newIdentityKey()
+	bc.rebroadcastInterval = DefaultRebroadcastInterval
 	return bc
 }
 
-// Chain is
+// ChainID is to return network chain ID. Empty is the default network.
+func (bc *Blockchain) ChainID() string {
+	return bc.chainID
+}
+
+// BlockchainAddress is to return miner's blockchain address.
+func (bc *Blockchain) BlockchainAddress() string {
+	return bc.blockchainAddress
+}
+
+// SetBlockchainAddress is set miner's blockchain address receiving rewards.
+func (bc *Blockchain) SetBlockchainAddress(blockchainAddress string) {
+	bc.blockchainAddress = blockchainAddress
+}
+
+// Port is to return Blockchain's port.
+func (bc *Blockchain) Port() uint16 {
+	return bc.port
+}
+
+// SetPort is set Blockchain's port used to find neighbors.
+func (bc *Blockchain) SetPort(port uint16) {
+	bc.port = port
+}
+
+// Chain is to return copy of the chain so callers can't mutate it.
 func (bc *Blockchain) Chain() []*Block {
-	return bc.chain
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+
+	chain := make([]*Block, len(bc.chain))
+	for i, b := range bc.chain {
+		chain[i] = b.copy()
+	}
+	return chain
+}
+
+// ChainLength is to return number of blocks in the chain.
+func (bc *Blockchain) ChainLength() int {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	return len(bc.chain)
+}
+
+// BlockAt is to return copy of block at height, or nil if it's pruned or
+// not mined yet.
+func (bc *Blockchain) BlockAt(height int) *Block {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	if height < bc.prunedHeight || height >= bc.Height() {
+		return nil
+	}
+	return bc.chain[height-bc.prunedHeight].copy()
+}
+
+// Logger is to return Blockchain's logger.
+func (bc *Blockchain) Logger() utils.Logger {
+	return bc.logger
+}
+
+// SetLogger is set Blockchain's logger. Use utils.NewNopLogger for quiet tests.
+func (bc *Blockchain) SetLogger(logger utils.Logger) {
+	bc.logger = logger
+}
+
+// SetMetrics is set Blockchain's metrics.
+func (bc *Blockchain) SetMetrics(metrics Metrics) {
+	bc.metrics = metrics
+	bc.metrics.SetChainHeight(len(bc.chain))
+	bc.metrics.SetTransactionPoolSize(len(bc.transactionPool))
 }
 
 // Run is
 func (bc *Blockchain) Run() {
-	bc.StartSyncNeighbors()
-	bc.ResolveConflicts()
+	if err := bc.Bootstrap(); err != nil {
+		bc.logger.Info("bootstrap", "action", "bootstrap", "status", "skipped", "reason", err)
+	}
+	_ = time.AfterFunc(time.Second*BlockchainNeighborSyncTimeSec, bc.StartSyncNeighbors)
+	if bc.MempoolTTL() > 0 {
+		bc.StartSweepExpired()
+	}
+	if d := bc.RebroadcastInterval(); d > 0 {
+		_ = time.AfterFunc(d, bc.StartRebroadcastPending)
+	}
 	bc.StartMining()
 }
 
+// Bootstrap is to discover neighbors and catch up to the longest valid chain
+// among them. It's safe to call again. It returns ErrNoNeighbors if none were
+// found, in which case the local chain is kept as is.
+func (bc *Blockchain) Bootstrap() error {
+	bc.SyncNeighbors()
+	if len(bc.Neighbors()) == 0 {
+		return ErrNoNeighbors
+	}
+	bc.ResolveConflicts()
+	return nil
+}
+
+// findNeighbors is to scan for neighbors. Tests replace it to skip the scan.
+var findNeighbors = utils.FindNeighbors
+
 // SetNeighbors is set Neighbors.
 func (bc *Blockchain) SetNeighbors() {
-	bc.neighbors = utils.FindNeighbors(
+	neighbors := findNeighbors(
 		utils.GetHost(), bc.port,
 		NeighborIPRangeStart, NeighborIPRangeEnd,
 		BlockchainPortRangeStart, BlockchainPortRangeEnd)
-	log.Printf("%v", bc.neighbors)
+	for _, p := range bc.seedPeers {
+		if !containsString(neighbors, p) {
+			neighbors = append(neighbors, p)
+		}
+	}
+	bc.neighbors = neighbors
+	bc.logger.Info("set neighbors", "action", "set_neighbors", "neighbors", bc.neighbors)
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// NodeAddress is to return this node's host:port as seen by neighbors.
+func (bc *Blockchain) NodeAddress() string {
+	return net.JoinHostPort(utils.GetHost(), strconv.Itoa(int(bc.port)))
 }
 
 // SyncNeighbors is
@@ -165,24 +615,41 @@ func (bc *Blockchain) SyncNeighbors() {
 	bc.SetNeighbors()
 }
 
+// Neighbors is to return copy of neighbor addresses.
+func (bc *Blockchain) Neighbors() []string {
+	bc.muxNeighbors.Lock()
+	defer bc.muxNeighbors.Unlock()
+	return append([]string(nil), bc.neighbors...)
+}
+
 // StartSyncNeighbors is
 func (bc *Blockchain) StartSyncNeighbors() {
 	bc.SyncNeighbors()
 	_ = time.AfterFunc(time.Second*BlockchainNeighborSyncTimeSec, bc.StartSyncNeighbors)
 }
 
-// TransactionPool is to return Blockchain's transaction pool.
+// TransactionPool is to return copy of Blockchain's transaction pool slice.
 func (bc *Blockchain) TransactionPool() []*Transaction {
-	return bc.transactionPool
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	return append([]*Transaction(nil), bc.transactionPool...)
 }
 
 // ClearTransactionPool is
 func (bc *Blockchain) ClearTransactionPool() {
-	bc.transactionPool = bc.transactionPool[:0]
+	bc.mux.Lock()
+	for _, t := range bc.transactionPool {
+		bc.queueMempoolChange(nil, t)
+	}
+	bc.setPool(nil)
+	bc.mux.Unlock()
+	bc.flushMempoolChanges()
 }
 
 // MarshalJSON is override Blockchain's marshaljson.
 func (bc *Blockchain) MarshalJSON() ([]byte, error) {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
 	return json.Marshal(struct {
 		Blocks []*Block `json:"chain"`
 	}{
@@ -200,29 +667,186 @@ func (bc *Blockchain) UnmarshalJSON(data []byte) error {
 	if err := json.Unmarshal(data, &v); err != nil {
 		return err
 	}
+	if len(bc.chain) == 0 {
+		return ErrEmptyChain
+	}
 	return nil
 }
 
-// CreateBlock is to return new Block struct.
-func (bc *Blockchain) CreateBlock(nonce int, previousHash [32]byte) *Block {
-	b := NewBlock(nonce, previousHash, bc.transactionPool)
+// CreateBlock is to return new Block struct mined at current difficulty.
+func (bc *Blockchain) CreateBlock(nonce uint64, previousHash [32]byte) *Block {
+	b := bc.createBlock(nonce, previousHash, bc.CurrentDifficulty())
+	bc.flushMempoolChanges()
+	bc.notifyNewBlock(b)
+	return b
+}
+
+func (bc *Blockchain) createBlock(nonce uint64, previousHash [32]byte, difficulty int) *Block {
+	return bc.createBlockWith(nonce, 0, previousHash, difficulty, bc.transactionPool)
+}
+
+// createBlockWith is to append block of transactions mined at difficulty
+// with extraNonce, taking them out of the pool.
+func (bc *Blockchain) createBlockWith(nonce, extraNonce uint64, previousHash [32]byte, difficulty int, transactions []*Transaction) *Block {
+	b := NewBlock(nonce, previousHash, transactions, difficulty)
+	b.timestamp = bc.clock.Now().UnixNano()
+	b.extraNonce = extraNonce
 	bc.chain = append(bc.chain, b)
-	bc.transactionPool = []*Transaction{}
-	for _, n := range bc.neighbors {
-		endpoint := fmt.Sprintf("http://%s/transactions", n)
-		client := &http.Client{}
-		req, _ := http.NewRequest("DELETE", endpoint, nil)
-		resp, _ := client.Do(req)
-		log.Printf("%v", resp)
+	bc.confirmBlock(b)
+	included := make(map[*Transaction]bool, len(transactions))
+	for _, t := range transactions {
+		included[t] = true
+		bc.queueMempoolChange(nil, t)
+	}
+	pool := make([]*Transaction, 0, len(bc.transactionPool))
+	for _, t := range bc.transactionPool {
+		if !included[t] {
+			pool = append(pool, t)
+		}
 	}
+	bc.setPool(pool)
+	bc.metrics.SetChainHeight(len(bc.chain))
 	return b
 }
 
-// LastBlock is find last block at chain.
+// AddBlock is to append a block mined by a peer to the chain.
+// A block which doesn't extend the tip is buffered as orphan and the chain is
+// reorganized if it completes a longer side chain, otherwise
+// ErrBlockNotExtendTip is returned.
+func (bc *Blockchain) AddBlock(b *Block) error {
+	bc.mux.Lock()
+	tipHash, err := bc.lastHash()
+	if err != nil {
+		bc.mux.Unlock()
+		return err
+	}
+	if !bc.VerifyBlock(b) {
+		bc.mux.Unlock()
+		return ErrInvalidBlock
+	}
+	if b.previousHash != tipHash {
+		bc.addOrphan(b)
+		rolledBack, added, ok := bc.reorganize(b)
+		if !ok {
+			bc.mux.Unlock()
+			return ErrBlockNotExtendTip
+		}
+		defer bc.notifyReorg(rolledBack, added)
+	} else {
+		bc.chain = append(bc.chain, b)
+		bc.confirmBlock(b)
+		bc.removeTransactions(b.transactions)
+		bc.connectOrphans()
+	}
+	tip := bc.LastBlock()
+	bc.metrics.SetChainHeight(len(bc.chain))
+	bc.mux.Unlock()
+
+	bc.flushMempoolChanges()
+	bc.notifyNewBlock(tip)
+	bc.logger.Info("add block", "action", "add_block", "status", "success")
+	return nil
+}
+
+// AddBlockFrom is AddBlock for a block pushed by peer, keyed as for
+// MarkPeerSeen, penalizing the peer if the block is invalid. Blocks from
+// banned peers are rejected.
+func (bc *Blockchain) AddBlockFrom(peer string, b *Block) error {
+	if bc.IsBanned(peer) {
+		return ErrPeerBanned
+	}
+	err := bc.AddBlock(b)
+	if errors.Is(err, ErrInvalidBlock) {
+		bc.PenalizePeer(peer, PeerInvalidPenalty)
+	} else {
+		bc.MarkPeerSeen(peer)
+	}
+	return err
+}
+
+// VerifyBlock is to verify block's mining reward, that no transaction is in
+// it twice, and its proof of work at the difficulty it records, which must
+// be at least the chain's difficulty. The retargeted difficulty isn't
+// enforced: it's a policy of this node's miner, see SetTargetBlockTime.
+func (bc *Blockchain) VerifyBlock(b *Block) bool {
+	return ValidCoinbase(b) && UniqueTransactions(b) && b.difficulty >= bc.difficulty && bc.ValidHeaderProof(b.Header())
+}
+
+// ValidCoinbase is to check block's only MiningSender transaction, the
+// coinbase, is its first and pays no more than MiningReward. Transactions
+// carry no fees, so the reward is the only value a miner may issue. Only
+// mined blocks are checked by it; the genesis block's allocations are also
+// paid by MiningSender.
+func ValidCoinbase(b *Block) bool {
+	for i, t := range b.transactions {
+		if t.senderBlockchainAddress == MiningSender && (i > 0 || t.value > MiningReward) {
+			return false
+		}
+	}
+	return true
+}
+
+// UniqueTransactions is to check no two of block's transactions have the
+// same Hash, so a crafted block can't count a spend twice.
+func UniqueTransactions(b *Block) bool {
+	seen := make(map[[32]byte]bool, len(b.transactions))
+	for _, t := range b.transactions {
+		id := t.Hash()
+		if seen[id] {
+			return false
+		}
+		seen[id] = true
+	}
+	return true
+}
+
+// removeTransactions is to remove transactions included in a block from the
+// pool. The block's copies, decoded without signatures, are replaced by the
+// pooled ones so they can be pooled again if the block is rolled back.
+func (bc *Blockchain) removeTransactions(transactions []*Transaction) {
+	included := make(map[[32]byte]int)
+	for i, t := range transactions {
+		included[t.Hash()] = i
+	}
+	pool := make([]*Transaction, 0, len(bc.transactionPool))
+	for _, t := range bc.transactionPool {
+		i, ok := included[t.Hash()]
+		if !ok {
+			pool = append(pool, t)
+			continue
+		}
+		transactions[i] = t
+		bc.queueMempoolChange(nil, t)
+	}
+	bc.setPool(pool)
+}
+
+// LastBlock is find last block at chain, or nil if the chain is empty.
 func (bc *Blockchain) LastBlock() *Block {
+	if len(bc.chain) == 0 {
+		return nil
+	}
 	return bc.chain[len(bc.chain)-1]
 }
 
+// lastHash is to return hash of the last block, or ErrEmptyChain.
+func (bc *Blockchain) lastHash() ([32]byte, error) {
+	last := bc.LastBlock()
+	if last == nil {
+		return [32]byte{}, ErrEmptyChain
+	}
+	return last.Hash(), nil
+}
+
+// Tip is to return height of the chain, the number of blocks including
+// pruned ones, and the last block's hash.
+func (bc *Blockchain) Tip() (int, [32]byte) {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	hash, _ := bc.lastHash()
+	return bc.Height(), hash
+}
+
 // Print is print blockchain data.
 func (bc *Blockchain) Print() {
 	for i, block := range bc.chain {
@@ -233,53 +857,138 @@ func (bc *Blockchain) Print() {
 }
 
 // CreateTransaction is create transaction.
-func (bc *Blockchain) CreateTransaction(sender string, recipient string, value float32, senderPublicKey *ecdsa.PublicKey, s *utils.Signature) bool {
-	isTransacted := bc.AddTransaction(sender, recipient, value, senderPublicKey, s)
-
-	if isTransacted {
-		for _, n := range bc.neighbors {
-			publicKeyStr := fmt.Sprintf("%064x%064x", senderPublicKey.X.Bytes(),
-				senderPublicKey.Y.Bytes())
-			signatureStr := s.String()
-			bt := &TransactionRequest{
-				&sender, &recipient, &publicKeyStr, &value, &signatureStr}
-			m, _ := json.Marshal(bt)
-			buf := bytes.NewBuffer(m)
-			endpoint := fmt.Sprintf("http://%s/transactions", n)
-			client := &http.Client{}
-			req, _ := http.NewRequest("PUT", endpoint, buf)
-			resp, _ := client.Do(req)
-			log.Printf("%v", resp)
+func (bc *Blockchain) CreateTransaction(sender string, recipient string, value float32, timestamp int64, senderPublicKey *ecdsa.PublicKey, s *utils.Signature) bool {
+	return bc.createTransaction(sender, recipient, value, timestamp, senderPublicKey, s) == nil
+}
+
+// createTransaction is to add transaction and broadcast it to neighbors.
+func (bc *Blockchain) createTransaction(sender string, recipient string, value float32, timestamp int64, senderPublicKey *ecdsa.PublicKey, s *utils.Signature) error {
+	bc.mux.Lock()
+	err := bc.addTransaction(sender, recipient, value, timestamp, senderPublicKey, s)
+	bc.mux.Unlock()
+	if err != nil {
+		return err
+	}
+	bc.flushMempoolChanges()
+	bc.broadcastTransaction(sender, recipient, value, timestamp, senderPublicKey, s)
+	return nil
+}
+
+// broadcastTransaction is to send transaction to neighbors, marked with
+// OriginNodeHeader so they add it without broadcasting it again.
+func (bc *Blockchain) broadcastTransaction(sender string, recipient string, value float32, timestamp int64, senderPublicKey *ecdsa.PublicKey, s *utils.Signature) {
+	for _, n := range bc.Neighbors() {
+		publicKeyStr := utils.PublicKeyString(senderPublicKey)
+		signatureStr := s.String()
+		bt := &TransactionRequest{
+			SenderBlockchainAddress:    &sender,
+			RecipientBlockchainAddress: &recipient,
+			SenderPublicKey:            &publicKeyStr,
+			Value:                      &value,
+			Timestamp:                  &timestamp,
+			Signature:                  &signatureStr,
+		}
+		m, _ := json.Marshal(bt)
+		endpoint := fmt.Sprintf("http://%s/transactions", n)
+		resp, err := bc.peerRequest(http.MethodPut, endpoint, bytes.NewBuffer(m))
+		if err != nil {
+			bc.logger.Error("broadcast transaction", "action", "broadcast_transaction", "status", "fail", "endpoint", endpoint, "error", err)
+			continue
 		}
+		resp.Body.Close()
+		bc.logger.Debug("broadcast transaction", "action", "broadcast_transaction", "status", resp.Status, "endpoint", endpoint)
+	}
+}
+
+// AddTransactions is to create signed transactions submitted in a batch.
+// Each transaction is handled independently and its error, or nil if
+// it was accepted, is returned at the same index.
+func (bc *Blockchain) AddTransactions(requests []*TransactionRequest) []error {
+	errs := make([]error, len(requests))
+	for i, tr := range requests {
+		errs[i] = bc.SubmitTransactionRequest(tr, true)
+	}
+	return errs
+}
+
+// SubmitTransactionRequest is to add transaction of request to the pool,
+// returning ErrMissingFields if it's malformed or the reason it was rejected.
+// Transactions submitted by users are broadcast to neighbors; ones received
+// from a peer must pass broadcast false so they don't loop between nodes.
+func (bc *Blockchain) SubmitTransactionRequest(tr *TransactionRequest, broadcast bool) error {
+	if tr == nil || !tr.Validate() {
+		return ErrMissingFields
+	}
+	publicKey, _ := utils.ParsePublicKey(*tr.SenderPublicKey)
+	signature := utils.SignatureFromString(*tr.Signature)
+	bc.mux.Lock()
+	err := bc.addTransaction(*tr.SenderBlockchainAddress,
+		*tr.RecipientBlockchainAddress, *tr.Value, *tr.Timestamp, publicKey, signature)
+	bc.mux.Unlock()
+	bc.flushMempoolChanges()
+	if err != nil {
+		return err
+	}
+	if broadcast {
+		bc.broadcastTransaction(*tr.SenderBlockchainAddress,
+			*tr.RecipientBlockchainAddress, *tr.Value, *tr.Timestamp, publicKey, signature)
+	} else {
+		bc.logger.Debug("broadcast transaction", "action", "broadcast_transaction", "status", "suppressed",
+			"sender", *tr.SenderBlockchainAddress)
 	}
-	return isTransacted
+	return nil
 }
 
 // AddTransaction is add transaction to transaction pool
-func (bc *Blockchain) AddTransaction(sender string, recipient string, value float32, senderPublicKey *ecdsa.PublicKey, s *utils.Signature) bool {
+func (bc *Blockchain) AddTransaction(sender string, recipient string, value float32, timestamp int64, senderPublicKey *ecdsa.PublicKey, s *utils.Signature) bool {
+	bc.mux.Lock()
+	err := bc.addTransaction(sender, recipient, value, timestamp, senderPublicKey, s)
+	bc.mux.Unlock()
+	bc.flushMempoolChanges()
+	return err == nil
+}
+
+// addTransaction is to add transaction to transaction pool, returning
+// ErrDustValue, ErrDuplicateTransaction, ErrSenderPoolLimit,
+// ErrSenderKeyMismatch, ErrInvalidSignature, ErrInsufficientBalance or
+// ErrUnknownRecipient if it's rejected. The caller must hold bc.mux.
+func (bc *Blockchain) addTransaction(sender string, recipient string, value float32, timestamp int64, senderPublicKey *ecdsa.PublicKey, s *utils.Signature) error {
 	t := NewTransaction(sender, recipient, value)
+	t.timestamp = timestamp
 
-	if sender == MiningSender {
-		bc.transactionPool = append(bc.transactionPool, t)
-		return true
-	}
+	bc.sweepExpired()
 
-	if bc.VerifyTransactionSignature(senderPublicKey, s, t) {
-		if bc.CalculateTotalAmount(sender) < value {
-			log.Println("ERROR: NOT enough balance in wallet.")
-			return false
-		}
-		bc.transactionPool = append(bc.transactionPool, t)
-		return true
+	if value < bc.dustThreshold {
+		return bc.rejectTransaction(ErrDustValue, RejectReasonDust, "sender", sender)
 	}
-	log.Println("ERROR: VERIFY TRANSACTION")
-	return false
+	if bc.isDuplicate(t) {
+		return bc.rejectTransaction(ErrDuplicateTransaction, RejectReasonDuplicate, "sender", sender)
+	}
+	if bc.exceedsPendingLimit(sender, value) {
+		return bc.rejectTransaction(ErrSenderPoolLimit, RejectReasonSenderPoolLimit, "sender", sender)
+	}
+	if senderPublicKey == nil || utils.AddressFromPublicKey(senderPublicKey) != sender {
+		return bc.rejectTransaction(ErrSenderKeyMismatch, RejectReasonSenderKeyMismatch, "sender", sender)
+	}
+	if !bc.VerifyTransactionSignature(senderPublicKey, s, t) {
+		return bc.rejectTransaction(ErrInvalidSignature, RejectReasonInvalidSignature, "sender", sender)
+	}
+	if bc.calculateTotalAmount(sender)-bc.pendingOutflow(sender) < value {
+		return bc.rejectTransaction(ErrInsufficientBalance, RejectReasonInsufficientBalance, "sender", sender)
+	}
+	if bc.requireKnownRecipient && !bc.knownAddress(recipient) {
+		return bc.rejectTransaction(ErrUnknownRecipient, RejectReasonUnknownRecipient, "recipient", recipient)
+	}
+	t.senderPublicKey = senderPublicKey
+	t.signatures = []*utils.Signature{s}
+	bc.addToPool(t)
+	return nil
 }
 
 // VerifyTransactionSignature is verify transaction by public key, signature, transaction.
 func (bc *Blockchain) VerifyTransactionSignature(senderPublicKey *ecdsa.PublicKey, s *utils.Signature, t *Transaction) bool {
-	m, _ := json.Marshal(t)
-	h := sha256.Sum256(m)
+	h := sha256.Sum256(utils.CanonicalSignedTransaction(bc.chainID,
+		t.senderBlockchainAddress, t.recipientBlockchainAddress, t.value, t.timestamp))
 	return ecdsa.Verify(senderPublicKey, h[:], s.R, s.S)
 }
 
@@ -287,54 +996,324 @@ func (bc *Blockchain) VerifyTransactionSignature(senderPublicKey *ecdsa.PublicKe
 func (bc *Blockchain) CopyTransactionPool() []*Transaction {
 	transactions := make([]*Transaction, 0)
 	for _, t := range bc.transactionPool {
-		transactions = append(transactions,
-			NewTransaction(t.senderBlockchainAddress,
-				t.recipientBlockchainAddress,
-				t.value))
+		tc := *t
+		transactions = append(transactions, &tc)
 	}
 	return transactions
 }
 
 // ValidProof is validate "000"
-func (bc *Blockchain) ValidProof(nonce int, previousHash [32]byte, transactions []*Transaction, difficulty int) bool {
-	zeros := strings.Repeat("0", difficulty)
-	guessBlock := Block{0, nonce, previousHash, transactions}
-	guessHashStr := fmt.Sprintf("%x", guessBlock.Hash())
-	return guessHashStr[:difficulty] == zeros
+func (bc *Blockchain) ValidProof(nonce, extraNonce uint64, previousHash [32]byte, transactions []*Transaction, difficulty int) bool {
+	guessHeader := &BlockHeader{
+		difficulty:   difficulty,
+		nonce:        nonce,
+		extraNonce:   extraNonce,
+		previousHash: previousHash,
+		merkleRoot:   MerkleRoot(transactions),
+	}
+	return bc.ValidHeaderProof(guessHeader)
+}
+
+// ValidHeaderProof is validate header hash starts with as many "0" as the
+// difficulty header records, by checking the hash is below proofTarget.
+// Proof of work is done with timestamp 0, so timestamps aren't covered by it
+// and any peer relaying a block can rewrite them.
+func (bc *Blockchain) ValidHeaderProof(h *BlockHeader) bool {
+	difficulty := h.difficulty
+	if difficulty < 0 || difficulty > 2*sha256.Size {
+		return false
+	}
+	guessHeader := *h
+	guessHeader.timestamp = 0
+	return utils.HashToBigInt(guessHeader.Hash()).Cmp(proofTarget(difficulty)) < 0
 }
 
 // ProofOfWork is proof of work.
-func (bc *Blockchain) ProofOfWork() int {
+// It returns ErrNonceExhausted if no nonce up to max nonce is valid.
+func (bc *Blockchain) ProofOfWork() (uint64, error) {
+	return bc.proofOfWork(bc.CurrentDifficulty())
+}
+
+func (bc *Blockchain) proofOfWork(difficulty int) (uint64, error) {
+	return bc.proofOfWorkOf(bc.CopyTransactionPool(), 0, difficulty)
+}
+
+func (bc *Blockchain) proofOfWorkOf(transactions []*Transaction, extraNonce uint64, difficulty int) (uint64, error) {
+	previousHash, err := bc.lastHash()
+	if err != nil {
+		return 0, err
+	}
+	header := &BlockHeader{
+		extraNonce:   extraNonce,
+		previousHash: previousHash,
+		merkleRoot:   MerkleRoot(transactions),
+	}
+	return bc.searchNonce(context.Background(), header, difficulty, nil)
+}
+
+// ProofOfWorkProgress is proof of work sending attempt count to progress every
+// ProofOfWorkProgressInterval attempts. Sends never block, updates are dropped
+// if progress isn't ready. progress is closed when a nonce is found or ctx is done.
+func (bc *Blockchain) ProofOfWorkProgress(ctx context.Context, progress chan<- int) (uint64, error) {
+	defer close(progress)
+	previousHash, err := bc.lastHash()
+	if err != nil {
+		return 0, err
+	}
 	transactions := bc.CopyTransactionPool()
-	previousHash := bc.LastBlock().Hash()
-	nonce := 0
-	for !bc.ValidProof(nonce, previousHash, transactions, MiningDifficulty) {
-		nonce++
+	header := &BlockHeader{
+		previousHash: previousHash,
+		merkleRoot:   MerkleRoot(transactions),
+	}
+	return bc.searchNonce(ctx, header, bc.CurrentDifficulty(), progress)
+}
+
+// CoinbaseMaturity is to return confirmations needed to spend mining rewards.
+func (bc *Blockchain) CoinbaseMaturity() int {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	return bc.coinbaseMaturity
+}
+
+// SetCoinbaseMaturity is set confirmations needed to spend mining rewards.
+// 0, the default, makes rewards spendable immediately.
+func (bc *Blockchain) SetCoinbaseMaturity(depth int) {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	bc.coinbaseMaturity = depth
+}
+
+// RequireKnownRecipient is to return whether transactions to addresses
+// never seen in the chain are rejected.
+func (bc *Blockchain) RequireKnownRecipient() bool {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	return bc.requireKnownRecipient
+}
+
+// SetRequireKnownRecipient is set whether transactions to addresses never
+// seen in the chain are rejected, to catch mistyped addresses.
+// It's off by default.
+func (bc *Blockchain) SetRequireKnownRecipient(require bool) {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	bc.requireKnownRecipient = require
+}
+
+// DustThreshold is to return value transactions must reach to be accepted.
+func (bc *Blockchain) DustThreshold() float32 {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	return bc.dustThreshold
+}
+
+// SetDustThreshold is set value below which transactions are rejected as
+// uneconomical spam. 0, the default, accepts any positive value.
+func (bc *Blockchain) SetDustThreshold(threshold float32) {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	bc.dustThreshold = threshold
+}
+
+// MaxPendingPerSender is to return total value and number of transactions
+// a sender may have pending in the pool.
+func (bc *Blockchain) MaxPendingPerSender() (float32, int) {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	return bc.maxPendingValue, bc.maxPendingCount
+}
+
+// SetMaxPendingPerSender is set total value and number of transactions a
+// sender may have pending in the pool, limiting how much a compromised
+// wallet can spam it. Transactions going over either are rejected.
+// 0, the default, disables the limit.
+func (bc *Blockchain) SetMaxPendingPerSender(value float32, count int) {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	bc.maxPendingValue = value
+	bc.maxPendingCount = count
+}
+
+// SetMiningSchedule is set round robin mining for test networks of
+// nodeCount nodes: this node, at nodeIndex, only mines blocks at heights
+// where height % nodeCount == nodeIndex. nodeCount 0, the default, mines
+// every block.
+func (bc *Blockchain) SetMiningSchedule(nodeIndex, nodeCount int) {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	bc.miningNodeIndex = nodeIndex
+	bc.miningNodeCount = nodeCount
+}
+
+// scheduledToMine is to check mining schedule allows this node to mine
+// block at height. mux must be held.
+func (bc *Blockchain) scheduledToMine(height int) bool {
+	return bc.miningNodeCount <= 0 || height%bc.miningNodeCount == bc.miningNodeIndex
+}
+
+// MiningRestartPolicy is to decide whether transaction t arriving in the
+// pool is worth abandoning the current proof of work to mine a new block
+// template including it.
+type MiningRestartPolicy func(t *Transaction) bool
+
+// MaxMiningRestarts is number of times a block is restarted at most, so a
+// steady stream of transactions can't keep the miner from finishing.
+const MaxMiningRestarts = 10
+
+// SetMiningRestartPolicy is set policy to cancel and rebuild the block being
+// mined when a transaction arrives. nil, the default, never restarts.
+func (bc *Blockchain) SetMiningRestartPolicy(policy MiningRestartPolicy) {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	bc.miningRestart = policy
+}
+
+// restartMining is to cancel the current proof of work if the restart
+// policy wants t in the block.
+func (bc *Blockchain) restartMining(t *Transaction) {
+	if bc.cancelMining != nil && bc.miningRestart != nil && bc.miningRestart(t) {
+		bc.cancelMining()
+		bc.cancelMining = nil
 	}
-	return nonce
+}
+
+// SetMaxNonce is set upper bound of proof of work nonce search.
+func (bc *Blockchain) SetMaxNonce(maxNonce uint64) {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	bc.maxNonce = maxNonce
 }
 
 // Mining is mining.
 func (bc *Blockchain) Mining() bool {
+	return bc.MineTo(bc.blockchainAddress, 0)
+}
+
+// MineTo is Mining paying the reward to address instead of miner's blockchain
+// address, for pools paying out per block. It fails if address isn't valid.
+// Pool miners working on the same block template pass distinct extraNonce
+// so their nonce searches don't overlap; it's committed in the block hash.
+func (bc *Blockchain) MineTo(address string, extraNonce uint64) bool {
+	if !utils.ValidBlockchainAddress(address) {
+		bc.logger.Error("mining", "action", "mining", "status", "fail", "reason", "invalid reward address", "address", address)
+		return false
+	}
+	return bc.mine(address, extraNonce, bc.CurrentDifficulty())
+}
+
+// MineWithDifficulty is Mining at difficulty instead of CurrentDifficulty,
+// for benchmarks and tests. Difficulty 0 mines instantly, on chains created
+// WithDifficulty(0). The block records difficulty and is checked against it
+// before it's appended. Difficulty below the chain's is refused, since
+// neither this node nor its peers would verify the block.
+func (bc *Blockchain) MineWithDifficulty(difficulty int) bool {
+	if difficulty < bc.difficulty {
+		bc.logger.Error("mining", "action", "mining", "status", "fail",
+			"error", fmt.Sprintf("difficulty %d is below chain difficulty %d", difficulty, bc.difficulty))
+		return false
+	}
+	return bc.mine(bc.blockchainAddress, 0, difficulty)
+}
+
+// mine is to mine a block at difficulty with extraNonce paying the reward to address.
+// The chain isn't locked during proof of work, so transactions can arrive
+// and, per the restart policy, cancel it to mine a new template. A nonce
+// found before the cancel is noticed is still used, so no block is lost.
+// The block is dropped if a peer's block extended the tip meanwhile.
+func (bc *Blockchain) mine(address string, extraNonce uint64, difficulty int) bool {
+	bc.muxMining.Lock()
+	defer bc.muxMining.Unlock()
+
 	bc.mux.Lock()
-	defer bc.mux.Unlock()
 
 	// if len(bc.transactionPool) == 0 {
 	// 	return false
 	// }
 
-	bc.AddTransaction(MiningSender, bc.blockchainAddress, MiningReward, nil, nil)
-	nonce := bc.ProofOfWork()
-	previousHash := bc.LastBlock().Hash()
-	bc.CreateBlock(nonce, previousHash)
-	log.Println("action=mining, status=success")
+	if height := bc.Height(); !bc.scheduledToMine(height) {
+		bc.mux.Unlock()
+		bc.logger.Debug("mining", "action", "mining", "status", "skipped", "reason", "not scheduled", "height", height)
+		return false
+	}
+
+	if _, err := bc.lastHash(); err != nil {
+		bc.mux.Unlock()
+		bc.logger.Error("mining", "action", "mining", "status", "fail", "error", err)
+		return false
+	}
+
+	var b *Block
+	for restarts := 0; ; restarts++ {
+		coinbase := NewTransaction(MiningSender, address, MiningReward)
+		coinbase.timestamp = bc.clock.Now().UnixNano()
+		transactions := bc.blockTransactions(coinbase)
+		previousHash := bc.LastBlock().Hash()
+		ctx, cancel := context.WithCancel(context.Background())
+		if restarts < MaxMiningRestarts {
+			bc.cancelMining = cancel
+		}
+		bc.mux.Unlock()
+
+		start := time.Now()
+		header := &BlockHeader{
+			extraNonce:   extraNonce,
+			previousHash: previousHash,
+			merkleRoot:   MerkleRoot(transactions),
+		}
+		nonce, err := bc.searchNonce(ctx, header, difficulty, nil)
+		bc.metrics.ObserveProofOfWork(time.Since(start))
+		cancel()
+
+		bc.mux.Lock()
+		bc.cancelMining = nil
+		if err == nil && !bc.ValidProof(nonce, extraNonce, previousHash, transactions, difficulty) {
+			err = ErrInvalidBlock
+		}
+		if err == nil && bc.LastBlock().Hash() != previousHash {
+			err = ErrBlockNotExtendTip
+		}
+		if err == nil {
+			b = bc.createBlockWith(nonce, extraNonce, previousHash, difficulty, transactions)
+			break
+		}
+		if errors.Is(err, context.Canceled) {
+			bc.logger.Info("mining", "action", "mining", "status", "restarted", "restarts", restarts+1)
+			continue
+		}
+		bc.mux.Unlock()
+		bc.logger.Error("mining", "action", "mining", "status", "fail", "error", err)
+		return false
+	}
+	bc.mux.Unlock()
+	bc.flushMempoolChanges()
+	// Neighbors' pools aren't cleared: AddBlock of the pushed block removes
+	// just the transactions it includes, and ones left out for block size
+	// stay pooled everywhere.
+
+	bc.metrics.IncMinedBlocks()
+
+	bc.notifyNewBlock(b)
+	bc.logger.Info("mining", "action", "mining", "status", "success")
+
+	m, _ := json.Marshal(b)
+	for _, n := range bc.Neighbors() {
+		resp, err := bc.peerRequest(http.MethodPost, fmt.Sprintf("http://%s/block", n), bytes.NewBuffer(m))
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusCreated {
+				bc.logger.Debug("push block", "action", "push_block", "status", resp.Status, "neighbor", n)
+				continue
+			}
+		}
 
-	for _, n := range bc.neighbors {
 		endpoint := fmt.Sprintf("http://%s/consensus", n)
-		client := &http.Client{}
-		req, _ := http.NewRequest("PUT", endpoint, nil)
-		resp, _ := client.Do(req)
-		log.Printf("%v", resp)
+		resp, err = bc.peerRequest(http.MethodPut, endpoint, nil)
+		if err != nil {
+			bc.logger.Error("consensus", "action", "consensus", "status", "fail", "endpoint", endpoint, "error", err)
+			continue
+		}
+		resp.Body.Close()
+		bc.logger.Debug("consensus", "action", "consensus", "status", resp.Status, "endpoint", endpoint)
 	}
 
 	return true
@@ -347,11 +1326,30 @@ func (bc *Blockchain) StartMining() {
 }
 
 // CalculateTotalAmount is to calculate total amount by args.
+// Mining rewards count only after coinbase maturity confirmations, while
+// genesis allocations are spendable at once.
 func (bc *Blockchain) CalculateTotalAmount(blockchainAddress string) float32 {
-	var totalAmount float32 = 0.0
-	for _, b := range bc.chain {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	return bc.calculateTotalAmount(blockchainAddress)
+}
+
+// calculateTotalAmount is CalculateTotalAmount for callers holding bc.mux.
+func (bc *Blockchain) calculateTotalAmount(blockchainAddress string) float32 {
+	return bc.amountOf(blockchainAddress, len(bc.chain))
+}
+
+// amountOf is calculateTotalAmount over the first n blocks of the chain,
+// with maturity counted as if they were the whole chain.
+func (bc *Blockchain) amountOf(blockchainAddress string, n int) float32 {
+	var totalAmount float32 = bc.genesisState[blockchainAddress]
+	for i, b := range bc.chain[:n] {
+		mature := b.IsGenesis() || n-i >= bc.coinbaseMaturity
 		for _, t := range b.transactions {
 			value := t.value
+			if t.senderBlockchainAddress == MiningSender && !mature {
+				continue
+			}
 			if blockchainAddress == t.recipientBlockchainAddress {
 				totalAmount += value
 			}
@@ -363,17 +1361,38 @@ func (bc *Blockchain) CalculateTotalAmount(blockchainAddress string) float32 {
 	return totalAmount
 }
 
-// ValidChain is valid chain.
+// TotalSupply is to return total amount issued by genesis allocations and mining rewards.
+func (bc *Blockchain) TotalSupply() float32 {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	totalSupply := -bc.genesisState[MiningSender]
+	for _, b := range bc.chain {
+		for _, t := range b.transactions {
+			if t.senderBlockchainAddress == MiningSender {
+				totalSupply += t.value
+			}
+		}
+	}
+	return totalSupply
+}
+
+// ValidChain is valid chain. The first block is the genesis block or, for
+// pruned chains, the first block kept; only its successors are verified,
+// and none of them may be a genesis block. ResolveConflicts checks the
+// first block itself is known with chainRoot.
 func (bc *Blockchain) ValidChain(chain []*Block) bool {
+	if len(chain) == 0 {
+		return false
+	}
 	preBlock := chain[0]
 	currentIndex := 1
 	for currentIndex < len(chain) {
 		b := chain[currentIndex]
-		if b.previousHash != preBlock.Hash() {
+		if b.IsGenesis() || b.previousHash != preBlock.Hash() {
 			return false
 		}
 
-		if !bc.ValidProof(b.Nonce(), b.PreviousHash(), b.Transactions(), MiningDifficulty) {
+		if !bc.VerifyBlock(b) {
 			return false
 		}
 
@@ -383,35 +1402,143 @@ func (bc *Blockchain) ValidChain(chain []*Block) bool {
 	return true
 }
 
-// ResolveConflicts is
-func (bc *Blockchain) ResolveConflicts() bool {
-	var longestChain []*Block = nil
-	maxLength := len(bc.chain)
+// peerChain is chain fetched from neighbor, whose first block is at height root.
+type peerChain struct {
+	neighbor string
+	chain    []*Block
+	root     int
+}
+
+// chainRoot is to return height of chain's first block if it's the pinned
+// genesis block or a block of the local chain, so a peer can't substitute a
+// genesis block of its own allocations. Without a pinned genesis hash, chains
+// from genesis are only accepted while the local genesis isn't pruned.
+// mux must be held.
+func (bc *Blockchain) chainRoot(chain []*Block) (int, bool) {
+	if len(chain) == 0 {
+		return 0, false
+	}
+	first := chain[0].Hash()
+	if bc.pinnedGenesisHash != nil && first == *bc.pinnedGenesisHash {
+		return 0, true
+	}
+	if i := bc.chainIndex(first); i >= 0 {
+		return bc.prunedHeight + i, true
+	}
+	return 0, false
+}
 
-	for _, n := range bc.neighbors {
+// ResolveConflicts is to replace the chain with the valid neighbor chain of
+// the most cumulative work. A neighbor chain must start from the local or
+// pinned genesis block, or, if it's pruned, from a block of the local chain.
+// Chains are fetched and verified without holding the chain lock, which is
+// only taken to switch to the best one.
+func (bc *Blockchain) ResolveConflicts() bool {
+	var candidates []*peerChain
+	for _, n := range bc.Neighbors() {
+		if bc.IsBanned(n) {
+			continue
+		}
 		endpoint := fmt.Sprintf("http://%s/chain", n)
-		resp, _ := http.Get(endpoint)
+		resp, err := bc.peerRequest(http.MethodGet, endpoint, nil)
+		if err != nil {
+			bc.logger.Error("resolve conflicts", "action", "resolve_conflicts", "status", "fail", "endpoint", endpoint, "error", err)
+			continue
+		}
 		if resp.StatusCode == 200 {
+			bc.MarkPeerSeen(n)
 			var bcResp Blockchain
 			decoder := json.NewDecoder(resp.Body)
-			_ = decoder.Decode(&bcResp)
-
-			chain := bcResp.Chain()
+			err := decoder.Decode(&bcResp)
+			resp.Body.Close()
+			if err != nil {
+				bc.logger.Error("resolve conflicts", "action", "resolve_conflicts", "status", "fail", "endpoint", endpoint, "error", err)
+				bc.PenalizePeer(n, PeerInvalidPenalty)
+				continue
+			}
 
-			if len(chain) > maxLength && bc.ValidChain(chain) {
-				maxLength = len(chain)
-				longestChain = chain
+			chain := bcResp.chain
+			bc.mux.RLock()
+			root, ok := bc.chainRoot(chain)
+			more := ok && bc.peerChainWork(chain, root).Cmp(bc.totalWork()) > 0
+			bc.mux.RUnlock()
+			if !ok {
+				bc.logger.Info("resolve conflicts", "action", "resolve_conflicts", "status", "unknown_root", "endpoint", endpoint)
+				continue
 			}
+			if more {
+				if !bc.ValidChain(chain) {
+					bc.logger.Error("resolve conflicts", "action", "resolve_conflicts", "status", "invalid_chain", "endpoint", endpoint)
+					bc.PenalizePeer(n, PeerInvalidPenalty)
+					continue
+				}
+				candidates = append(candidates, &peerChain{neighbor: n, chain: chain, root: root})
+			}
+		} else {
+			resp.Body.Close()
 		}
 	}
 
-	if longestChain != nil {
-		bc.chain = longestChain
-		log.Printf("Resovle conflicts replaced")
-		return true
+	bc.mux.Lock()
+	var longest *peerChain
+	var rolledBack int
+	maxWork := bc.totalWork()
+	for _, c := range candidates {
+		// The local chain may have changed since the chain was fetched.
+		root, ok := bc.chainRoot(c.chain)
+		if !ok {
+			continue
+		}
+		work := bc.peerChainWork(c.chain, root)
+		if work.Cmp(maxWork) <= 0 {
+			continue
+		}
+		depth := bc.reorgDepth(c.chain, root)
+		if bc.exceedsReorgDepth(depth) {
+			bc.logger.Error("resolve conflicts", "action", "resolve_conflicts", "status", "reorg_too_deep",
+				"neighbor", c.neighbor, "depth", depth, "max_depth", bc.maxReorgDepth)
+			continue
+		}
+		maxWork = work
+		c.root = root
+		longest = c
+		rolledBack = depth
 	}
-	log.Printf("Resovle conflicts not replaced")
-	return false
+	if longest == nil {
+		bc.mux.Unlock()
+		bc.logger.Info("resolve conflicts", "action", "resolve_conflicts", "status", "not_replaced")
+		return false
+	}
+
+	added := longest.root + len(longest.chain) - (bc.Height() - rolledBack)
+	oldChain := bc.chain
+	if longest.root > bc.prunedHeight {
+		oldChain = bc.chain[longest.root-bc.prunedHeight:]
+	}
+	if longest.root == 0 {
+		bc.genesisState = nil
+		bc.prunedIDs = nil
+	} else {
+		// The chain is pruned at a local block; fold the local blocks before it.
+		shared := bc.chain[:longest.root-bc.prunedHeight]
+		bc.genesisState = foldBalances(bc.genesisState, shared)
+		bc.recordPrunedIDs(shared)
+	}
+	bc.prunedHeight = longest.root
+	bc.chain = longest.chain
+	bc.indexConfirmed()
+	bc.reconcileMempool(oldChain, longest.chain)
+	bc.metrics.SetChainHeight(len(bc.chain))
+	tip := bc.LastBlock()
+	bc.mux.Unlock()
+
+	bc.flushMempoolChanges()
+	bc.notifyNewBlock(tip)
+	if rolledBack > 0 {
+		bc.notifyReorg(rolledBack, added)
+	}
+	bc.logger.Info("resolve conflicts", "action", "resolve_conflicts", "status", "replaced")
+	return true
 }
 
 // Transaction is transaction struct.
@@ -419,11 +1546,41 @@ type Transaction struct {
 	senderBlockchainAddress    string
 	recipientBlockchainAddress string
 	value                      float32
+	timestamp                  int64
+	publicKeys                 []string
+	threshold                  int
+	received                   time.Time
+
+	// Signing data of pooled transactions kept to rebroadcast them.
+	// Blocks don't carry it.
+	senderPublicKey *ecdsa.PublicKey
+	signatures      []*utils.Signature
 }
 
-// NewTransaction is to return new Transaction struct.
+// NewTransaction is to return new Transaction struct. Its timestamp is left
+// 0; Blockchain stamps the transactions it creates with its clock or the
+// signed timestamp.
 func NewTransaction(sender string, recipient string, value float32) *Transaction {
-	return &Transaction{sender, recipient, value}
+	return &Transaction{
+		senderBlockchainAddress:    sender,
+		recipientBlockchainAddress: recipient,
+		value:                      value,
+	}
+}
+
+// Timestamp is to return unix time in nanoseconds the transaction was signed with.
+func (t *Transaction) Timestamp() int64 {
+	return t.timestamp
+}
+
+// Hash is to return sha256.Sum256 hash of transaction's canonical bytes.
+func (t *Transaction) Hash() [32]byte {
+	return sha256.Sum256(t.CanonicalBytes())
+}
+
+// CanonicalBytes is to return transaction's canonical serialization for hashing.
+func (t *Transaction) CanonicalBytes() []byte {
+	return utils.CanonicalTransaction(t.senderBlockchainAddress, t.recipientBlockchainAddress, t.value, t.timestamp)
 }
 
 // Print is print transaction data.
@@ -432,31 +1589,45 @@ func (t *Transaction) Print() {
 	fmt.Printf("sender_blockchain_address      %s\n", t.senderBlockchainAddress)
 	fmt.Printf("recipient_blockchain_address   %s\n", t.recipientBlockchainAddress)
 	fmt.Printf("value                          %.2f\n", t.value)
+	fmt.Printf("timestamp                      %d\n", t.timestamp)
 }
 
 // MarshalJSON is override Transaction's marshaljson.
+// value is written as utils.FormatValue, the same decimal that is hashed.
 func (t *Transaction) MarshalJSON() ([]byte, error) {
 	return json.Marshal(struct {
-		Sender    string  `json:"sender_blockchain_address"`
-		Recipient string  `json:"recipient_blockchain_address"`
-		Value     float32 `json:"value"`
+		Sender     string      `json:"sender_blockchain_address"`
+		Recipient  string      `json:"recipient_blockchain_address"`
+		Value      json.Number `json:"value"`
+		Timestamp  int64       `json:"timestamp"`
+		PublicKeys []string    `json:"public_keys,omitempty"`
+		Threshold  int         `json:"threshold,omitempty"`
 	}{
-		Sender:    t.senderBlockchainAddress,
-		Recipient: t.recipientBlockchainAddress,
-		Value:     t.value,
+		Sender:     t.senderBlockchainAddress,
+		Recipient:  t.recipientBlockchainAddress,
+		Value:      json.Number(utils.FormatValue(t.value)),
+		Timestamp:  t.timestamp,
+		PublicKeys: t.publicKeys,
+		Threshold:  t.threshold,
 	})
 }
 
 // UnmarshalJSON is override Transaction's unmarshal json.
 func (t *Transaction) UnmarshalJSON(data []byte) error {
 	v := &struct {
-		Sender    *string  `json:"sender_blockchain_address"`
-		Recipient *string  `json:"recipient_blockchain_address"`
-		Value     *float32 `json:"value"`
+		Sender     *string   `json:"sender_blockchain_address"`
+		Recipient  *string   `json:"recipient_blockchain_address"`
+		Value      *float32  `json:"value"`
+		Timestamp  *int64    `json:"timestamp"`
+		PublicKeys *[]string `json:"public_keys"`
+		Threshold  *int      `json:"threshold"`
 	}{
-		Sender:    &t.senderBlockchainAddress,
-		Recipient: &t.recipientBlockchainAddress,
-		Value:     &t.value,
+		Sender:     &t.senderBlockchainAddress,
+		Recipient:  &t.recipientBlockchainAddress,
+		Value:      &t.value,
+		Timestamp:  &t.timestamp,
+		PublicKeys: &t.publicKeys,
+		Threshold:  &t.threshold,
 	}
 	if err := json.Unmarshal(data, &v); err != nil {
 		return err
@@ -470,6 +1641,7 @@ type TransactionRequest struct {
 	RecipientBlockchainAddress *string  `json:"recipient_blockchain_address"`
 	SenderPublicKey            *string  `json:"sender_public_key"`
 	Value                      *float32 `json:"value"`
+	Timestamp                  *int64   `json:"timestamp"`
 	Signature                  *string  `json:"signature"`
 }
 
@@ -479,9 +1651,16 @@ func (tr *TransactionRequest) Validate() bool {
 		tr.RecipientBlockchainAddress == nil ||
 		tr.SenderPublicKey == nil ||
 		tr.Value == nil ||
+		tr.Timestamp == nil ||
 		tr.Signature == nil {
 		return false
 	}
+	if _, err := utils.ParsePublicKey(*tr.SenderPublicKey); err != nil {
+		return false
+	}
+	if len(*tr.Signature) != 128 {
+		return false
+	}
 	return true
 }
 
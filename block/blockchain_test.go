@@ -0,0 +1,1039 @@
+package block
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"goblockchain/utils"
+	"goblockchain/wallet"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestBlockchain is to return Blockchain with opts whose logs are discarded.
+func newTestBlockchain(tb testing.TB, opts ...Option) *Blockchain {
+	tb.Helper()
+	bc := NewBlockchain(wallet.NewWallet().BlockchainAddress(), 0, opts...)
+	bc.SetLogger(utils.NewNopLogger())
+	return bc
+}
+
+// sendTransaction is to add transaction of value from w to recipient, signed
+// by w for bc's network, to bc's pool, reporting whether it was accepted.
+func sendTransaction(bc *Blockchain, w *wallet.Wallet, recipient string, value float32) bool {
+	t := wallet.NewTransaction(w.PrivateKey(), w.PublicKey(), w.BlockchainAddress(), recipient, value)
+	t.SetChainID(bc.ChainID())
+	return bc.AddTransaction(w.BlockchainAddress(), recipient, value, t.Timestamp(), w.PublicKey(), t.GenerateSignature())
+}
+
+// forkChain is to return Blockchain sharing bc's blocks, so blocks mined on
+// it extend bc's chain.
+func forkChain(tb testing.TB, bc *Blockchain) *Blockchain {
+	tb.Helper()
+	peer := newTestBlockchain(tb)
+	peer.chain = append([]*Block(nil), bc.chain...)
+	return peer
+}
+
+// servePeerChain is to serve peer's chain at /chain like a neighbor node and
+// make it bc's only neighbor.
+func servePeerChain(tb testing.TB, bc *Blockchain, peer *Blockchain) {
+	tb.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		m, _ := peer.MarshalJSON()
+		w.Write(m)
+	}))
+	tb.Cleanup(ts.Close)
+	bc.muxNeighbors.Lock()
+	bc.neighbors = []string{strings.TrimPrefix(ts.URL, "http://")}
+	bc.muxNeighbors.Unlock()
+}
+
+// logEntry is log recorded by recordLogger.
+type logEntry struct {
+	level  string
+	msg    string
+	fields map[string]string
+}
+
+// recordLogger is utils.Logger recording logs for tests to inspect.
+type recordLogger struct {
+	entries []logEntry
+	mux     sync.Mutex
+}
+
+func (l *recordLogger) Debug(msg string, args ...interface{}) { l.record("debug", msg, args) }
+func (l *recordLogger) Info(msg string, args ...interface{})  { l.record("info", msg, args) }
+func (l *recordLogger) Warn(msg string, args ...interface{})  { l.record("warn", msg, args) }
+func (l *recordLogger) Error(msg string, args ...interface{}) { l.record("error", msg, args) }
+
+func (l *recordLogger) record(level, msg string, args []interface{}) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	fields := make(map[string]string)
+	for i := 0; i+1 < len(args); i += 2 {
+		fields[fmt.Sprint(args[i])] = fmt.Sprint(args[i+1])
+	}
+	l.entries = append(l.entries, logEntry{level: level, msg: msg, fields: fields})
+}
+
+// find is to return the first entry of action and status, or nil.
+func (l *recordLogger) find(action, status string) *logEntry {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	for i, e := range l.entries {
+		if e.fields["action"] == action && e.fields["status"] == status {
+			return &l.entries[i]
+		}
+	}
+	return nil
+}
+
+func TestLogger(t *testing.T) {
+	bc := newTestBlockchain(t)
+	logger := &recordLogger{}
+	bc.SetLogger(logger)
+
+	w := wallet.NewWallet()
+	recipient := wallet.NewWallet().BlockchainAddress()
+	tx := wallet.NewTransaction(w.PrivateKey(), w.PublicKey(), w.BlockchainAddress(), recipient, 2)
+	if bc.AddTransaction(w.BlockchainAddress(), recipient, 1, tx.Timestamp(), w.PublicKey(), tx.GenerateSignature()) {
+		t.Fatal("transaction with signature of another value accepted")
+	}
+	e := logger.find("add_transaction", "fail")
+	if e == nil {
+		t.Fatal("rejected transaction not logged")
+	}
+	if e.level != "error" || e.fields["reason"] != "invalid signature" || e.fields["sender"] != w.BlockchainAddress() {
+		t.Errorf("logged %+v", *e)
+	}
+
+	bc.Mining()
+	if e := logger.find("mining", "success"); e == nil || e.level != "info" {
+		t.Errorf("mining logged %v", e)
+	}
+}
+
+func TestAddBlock(t *testing.T) {
+	bc := newTestBlockchain(t)
+	peer := forkChain(t, bc)
+	peer.Mining()
+	next := peer.LastBlock()
+	tampered := *next
+	tampered.nonce++
+	stranger := newTestBlockchain(t)
+	stranger.Mining()
+
+	tests := []struct {
+		name  string
+		block *Block
+		want  error
+	}{
+		{"wrong previous hash", stranger.LastBlock(), ErrBlockNotExtendTip},
+		{"invalid proof of work", &tampered, ErrInvalidBlock},
+		{"next block", next, nil},
+		{"same block again", next, ErrBlockNotExtendTip},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := len(bc.chain)
+			err := bc.AddBlock(tt.block)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("error = %v, want %v", err, tt.want)
+			}
+			if err == nil {
+				want++
+			}
+			if len(bc.chain) != want {
+				t.Errorf("chain length = %d, want %d", len(bc.chain), want)
+			}
+		})
+	}
+	if bc.LastBlock() != next {
+		t.Error("tip isn't the added block")
+	}
+}
+
+func TestAddBlockRemovesPooledTransactions(t *testing.T) {
+	bc := newTestBlockchain(t)
+	w := wallet.NewWallet()
+	bc.blockchainAddress = w.BlockchainAddress()
+	bc.Mining()
+	peer := forkChain(t, bc)
+	tx := wallet.NewTransaction(w.PrivateKey(), w.PublicKey(), w.BlockchainAddress(), "1Recipient", 0.5)
+	tx.SetChainID(bc.ChainID())
+	signature := tx.GenerateSignature()
+	for _, c := range []*Blockchain{bc, peer} {
+		if !c.AddTransaction(w.BlockchainAddress(), "1Recipient", 0.5, tx.Timestamp(), w.PublicKey(), signature) {
+			t.Fatal("transaction rejected")
+		}
+	}
+	peer.Mining()
+	if err := bc.AddBlock(peer.LastBlock()); err != nil {
+		t.Fatal(err)
+	}
+	if len(bc.TransactionPool()) != 0 {
+		t.Errorf("pool = %v, want the block's transaction removed", bc.TransactionPool())
+	}
+}
+
+func TestVerifyBlockMiningReward(t *testing.T) {
+	bc := newTestBlockchain(t)
+	peer := forkChain(t, bc)
+	// A second reward slipped in after the coinbase.
+	peer.transactionPool = append(peer.transactionPool, NewTransaction(MiningSender, "1Recipient", 0.5))
+	peer.Mining()
+	if ValidCoinbase(peer.LastBlock()) {
+		t.Fatal("block with two coinbases is valid")
+	}
+	if err := bc.AddBlock(peer.LastBlock()); !errors.Is(err, ErrInvalidBlock) {
+		t.Errorf("error = %v, want ErrInvalidBlock", err)
+	}
+
+	over := NewBlock(0, bc.LastBlock().Hash(), []*Transaction{NewTransaction(MiningSender, "1Miner", MiningReward+1)}, 0)
+	if ValidCoinbase(over) {
+		t.Error("coinbase paying more than the reward is valid")
+	}
+	late := NewBlock(0, bc.LastBlock().Hash(), []*Transaction{NewTransaction("1Alice", "1Bob", 1), NewTransaction(MiningSender, "1Miner", MiningReward)}, 0)
+	if ValidCoinbase(late) {
+		t.Error("coinbase after another transaction is valid")
+	}
+
+	// The genesis block's allocations aren't a coinbase.
+	funded := newTestBlockchain(t, WithGenesisAllocations(map[string]float32{"1Alice": 100}))
+	funded.Mining()
+	if err := funded.SelfCheck(); err != nil {
+		t.Errorf("chain with genesis allocations: %v", err)
+	}
+}
+
+func TestCoinbaseFirst(t *testing.T) {
+	for _, maxBlockSize := range []int{0, 1500} {
+		t.Run(fmt.Sprint(maxBlockSize), func(t *testing.T) {
+			alice := wallet.NewWallet()
+			bc := newTestBlockchain(t, WithDifficulty(0), WithGenesisAllocations(map[string]float32{alice.BlockchainAddress(): 100}))
+			bc.SetMaxBlockSize(maxBlockSize)
+			for i := 0; i < 10; i++ {
+				if !sendTransaction(bc, alice, "1Bob", 1) {
+					t.Fatal("transaction rejected")
+				}
+			}
+			for bc.PoolSize() > 0 {
+				if !bc.Mining() {
+					t.Fatal("mining failed")
+				}
+				b := bc.LastBlock()
+				coinbases := 0
+				for _, tx := range b.Transactions() {
+					if tx.senderBlockchainAddress == MiningSender {
+						coinbases++
+					}
+				}
+				if coinbases != 1 || !ValidCoinbase(b) {
+					t.Fatalf("block %d has %d coinbases, first at index 0: %v", bc.Height()-1, coinbases, ValidCoinbase(b))
+				}
+			}
+			if err := bc.SelfCheck(); err != nil {
+				t.Error(err)
+			}
+			if !bc.ValidChain(bc.Chain()) {
+				t.Error("mined chain isn't valid")
+			}
+		})
+	}
+}
+
+var update = flag.Bool("update", false, "update golden files")
+
+// goldenBlock is to return a block with fixed fields and transactions
+// covering escaped strings and float formatting.
+func goldenBlock() *Block {
+	var previousHash [32]byte
+	for i := range previousHash {
+		previousHash[i] = byte(i)
+	}
+	b := NewBlock(42, previousHash, []*Transaction{
+		NewTransaction(MiningSender, "1MinerAddress", MiningReward),
+		NewTransaction("1Alice", "1Bob<&>", 0.1),
+		NewTransaction("1Bob<&>", "1Alice", 1e-7),
+		NewTransaction("1Alice", "1Carol\"\\", 123456.7),
+	}, MiningDifficulty)
+	b.timestamp = 1700000000000000000
+	for i, t := range b.transactions {
+		t.timestamp = b.timestamp - int64(i)
+	}
+	return b
+}
+
+func TestBlockCanonicalBytesGolden(t *testing.T) {
+	b := goldenBlock()
+	var buf bytes.Buffer
+	buf.Write(b.Header().CanonicalBytes())
+	buf.WriteString("\n")
+	for _, tx := range b.transactions {
+		buf.Write(tx.CanonicalBytes())
+		buf.WriteString("\n")
+	}
+	fmt.Fprintf(&buf, "%x\n", b.Hash())
+
+	golden := filepath.Join("testdata", "block.golden")
+	if *update {
+		if err := os.WriteFile(golden, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("canonical bytes changed\ngot:\n%s\nwant:\n%s", buf.Bytes(), want)
+	}
+}
+
+func TestBlockHashIgnoresJSONLayout(t *testing.T) {
+	b := goldenBlock()
+	m, err := json.Marshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, m, "", "  "); err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range [][]byte{m, indented.Bytes()} {
+		var decoded Block
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if decoded.Hash() != b.Hash() {
+			t.Errorf("hash of decoded %s differs", data)
+		}
+	}
+}
+
+func TestTransactionJSONValue(t *testing.T) {
+	for _, value := range []float32{0.1, 1e-7, 123456.7} {
+		tx := NewTransaction("1Alice", "1Bob", value)
+		m, err := json.Marshal(tx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := `"value":` + utils.FormatValue(value) + `,`
+		if !strings.Contains(string(m), want) {
+			t.Errorf("%s doesn't contain %s", m, want)
+		}
+		var decoded Transaction
+		if err := json.Unmarshal(m, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if decoded.value != value || decoded.Hash() != tx.Hash() {
+			t.Errorf("decoded value %v, want %v with the same hash", decoded.value, value)
+		}
+	}
+}
+
+func TestBlockJSONDerivedFields(t *testing.T) {
+	b := goldenBlock()
+	m, err := json.Marshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Hash       string  `json:"hash"`
+		TxCount    int     `json:"tx_count"`
+		TotalValue float32 `json:"total_value"`
+	}
+	if err := json.Unmarshal(m, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Hash != fmt.Sprintf("%x", b.Hash()) || got.TxCount != len(b.transactions) || got.TotalValue != b.TotalValue() {
+		t.Errorf("derived fields = %+v", got)
+	}
+	var want float32
+	for _, tx := range b.transactions {
+		want += tx.value
+	}
+	if b.TotalValue() != want {
+		t.Errorf("total value = %v, want %v", b.TotalValue(), want)
+	}
+
+	// Derived fields are ignored when decoding.
+	forged := strings.Replace(string(m), got.Hash, strings.Repeat("0", 64), 1)
+	var decoded Block
+	if err := json.Unmarshal([]byte(forged), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Hash() != b.Hash() {
+		t.Error("decoded hash taken from the hash field")
+	}
+}
+
+func TestBlockUnmarshalJSONErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"null previous hash", `{"timestamp":1,"nonce":0,"previous_hash":null,"transaction":[]}`},
+		{"short previous hash", `{"timestamp":1,"nonce":0,"previous_hash":"00","transaction":[]}`},
+		{"not hex previous hash", `{"timestamp":1,"nonce":0,"previous_hash":"xyz","transaction":[]}`},
+		{"not an object", `[]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b Block
+			if err := json.Unmarshal([]byte(tt.data), &b); err == nil {
+				t.Errorf("decoded %s", tt.data)
+			}
+		})
+	}
+}
+
+func TestBlockHashCommitsToHeader(t *testing.T) {
+	base := goldenBlock()
+	tests := []struct {
+		name   string
+		modify func(b *Block)
+	}{
+		{"timestamp", func(b *Block) { b.timestamp++ }},
+		{"nonce", func(b *Block) { b.nonce++ }},
+		{"previous hash", func(b *Block) { b.previousHash[0]++ }},
+		{"transaction value", func(b *Block) { b.transactions[1] = NewTransaction("1Alice", "1Bob<&>", 0.2) }},
+		{"transaction order", func(b *Block) {
+			b.transactions[1], b.transactions[2] = b.transactions[2], b.transactions[1]
+		}},
+		{"dropped transaction", func(b *Block) { b.transactions = b.transactions[:3] }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := goldenBlock()
+			tt.modify(b)
+			if b.Hash() != b.Header().Hash() {
+				t.Error("block hash isn't its header hash")
+			}
+			if b.Hash() == base.Hash() {
+				t.Errorf("hash unchanged by %s", tt.name)
+			}
+		})
+	}
+}
+
+// benchmarkTransactions is to return n distinct transactions.
+func benchmarkTransactions(n int) []*Transaction {
+	transactions := make([]*Transaction, n)
+	for i := range transactions {
+		transactions[i] = NewTransaction("1Sender", fmt.Sprintf("1Recipient%d", i), float32(i))
+	}
+	return transactions
+}
+
+// BenchmarkProofOfWorkIteration compares the cost of one nonce attempt,
+// hashing the header, with marshaling and hashing the whole block as
+// attempts did before blocks had headers.
+func BenchmarkProofOfWorkIteration(b *testing.B) {
+	bc := newTestBlockchain(b)
+	for _, n := range []int{1, 100, 1000} {
+		transactions := benchmarkTransactions(n)
+		header := &BlockHeader{difficulty: 64, merkleRoot: MerkleRoot(transactions)}
+		b.Run(fmt.Sprintf("header/txs=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				header.nonce = uint64(i)
+				bc.ValidHeaderProof(header)
+			}
+		})
+		block := &Block{transactions: transactions}
+		b.Run(fmt.Sprintf("full_block/txs=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				block.nonce = uint64(i)
+				m, _ := json.Marshal(block)
+				sha256.Sum256(m)
+			}
+		})
+	}
+}
+
+func TestProofOfWorkExhaustion(t *testing.T) {
+	bc := newTestBlockchain(t)
+	bc.SetMaxNonce(1000)
+	header := &BlockHeader{}
+	if _, err := bc.searchNonce(context.Background(), header, 64, nil); !errors.Is(err, ErrNonceExhausted) {
+		t.Errorf("error = %v, want ErrNonceExhausted", err)
+	}
+	if header.nonce != 1000 {
+		t.Errorf("search stopped at nonce %d, want 1000", header.nonce)
+	}
+
+	bc.SetMaxNonce(0)
+	if _, err := bc.ProofOfWork(); err == nil {
+		t.Skip("nonce 0 is valid for this chain")
+	}
+	if bc.Mining() {
+		t.Error("mining succeeded")
+	}
+	if len(bc.chain) != 1 || len(bc.transactionPool) != 0 {
+		t.Errorf("chain length %d and pool %d after failed mining, want 1 and 0", len(bc.chain), len(bc.transactionPool))
+	}
+}
+
+func TestSearchNonceDoesNotWrap(t *testing.T) {
+	for _, start := range []uint64{math.MaxUint64, math.MaxUint64 - 10} {
+		bc := newTestBlockchain(t)
+		header := &BlockHeader{nonce: start}
+		if _, err := bc.searchNonce(context.Background(), header, 64, nil); !errors.Is(err, ErrNonceExhausted) {
+			t.Errorf("error = %v, want ErrNonceExhausted", err)
+		}
+		if header.nonce < start {
+			t.Errorf("nonce wrapped to %d", header.nonce)
+		}
+	}
+}
+
+func TestBlockNonceJSON(t *testing.T) {
+	for _, nonce := range []uint64{0, math.MaxInt32 + 1, math.MaxUint64} {
+		b := goldenBlock()
+		b.nonce = nonce
+		m, err := json.Marshal(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded Block
+		if err := json.Unmarshal(m, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if decoded.nonce != nonce {
+			t.Errorf("nonce %d decoded as %d", nonce, decoded.nonce)
+		}
+	}
+}
+
+func TestProofOfWorkProgress(t *testing.T) {
+	bc := newTestBlockchain(t)
+	progress := make(chan int, 10)
+	nonce, err := bc.ProofOfWorkProgress(context.Background(), progress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bc.ValidProof(nonce, 0, bc.LastBlock().Hash(), bc.CopyTransactionPool(), MiningDifficulty) {
+		t.Errorf("nonce %d isn't valid", nonce)
+	}
+	for range progress {
+	}
+
+	bc.SetMaxNonce(3 * ProofOfWorkProgressInterval)
+	progress = make(chan int, 10)
+	if _, err := bc.searchNonce(context.Background(), &BlockHeader{}, 64, progress); !errors.Is(err, ErrNonceExhausted) {
+		t.Fatalf("error = %v, want ErrNonceExhausted", err)
+	}
+	close(progress)
+	var got []int
+	for attempts := range progress {
+		got = append(got, attempts)
+	}
+	if fmt.Sprint(got) != fmt.Sprint([]int{1000, 2000, 3000}) {
+		t.Errorf("progress = %v", got)
+	}
+}
+
+func TestProofOfWorkProgressCancel(t *testing.T) {
+	bc := newTestBlockchain(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// An unbuffered channel nobody reads must not block the search.
+	progress := make(chan int)
+	if _, err := bc.searchNonce(ctx, &BlockHeader{}, 64, progress); !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want context.Canceled", err)
+	}
+}
+
+func TestBlockRecordsDifficulty(t *testing.T) {
+	bc := newTestBlockchain(t)
+	bc.Mining()
+	b := bc.LastBlock()
+	if b.Difficulty() != MiningDifficulty || b.Header().Difficulty() != MiningDifficulty {
+		t.Fatalf("mined block difficulty = %d, want %d", b.Difficulty(), MiningDifficulty)
+	}
+	if !bc.VerifyBlock(b) {
+		t.Fatal("mined block not verified")
+	}
+
+	m, _ := json.Marshal(b)
+	var decoded Block
+	if err := json.Unmarshal(m, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Difficulty() != b.Difficulty() || decoded.Hash() != b.Hash() {
+		t.Errorf("decoded difficulty %d hash %x, want %d %x", decoded.Difficulty(), decoded.Hash(), b.Difficulty(), b.Hash())
+	}
+
+	// The header commits to the difficulty, so changing it breaks the proof.
+	tampered := b.copy()
+	tampered.difficulty = MiningDifficulty + 1
+	if tampered.Hash() == b.Hash() || bc.VerifyBlock(tampered) {
+		t.Error("block with changed difficulty verified")
+	}
+}
+
+func TestVerifyBlockRejectsLowDifficulty(t *testing.T) {
+	bc := newTestBlockchain(t)
+	transactions := bc.CopyTransactionPool()
+	header := &BlockHeader{previousHash: bc.LastBlock().Hash(), merkleRoot: MerkleRoot(transactions)}
+	nonce, err := bc.searchNonce(context.Background(), header, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := NewBlock(nonce, bc.LastBlock().Hash(), transactions, 1)
+	if !bc.ValidHeaderProof(b.Header()) {
+		t.Fatal("proof at recorded difficulty not valid")
+	}
+	if bc.VerifyBlock(b) {
+		t.Error("block below chain difficulty verified")
+	}
+	if err := bc.AddBlock(b); !errors.Is(err, ErrInvalidBlock) {
+		t.Errorf("error = %v, want ErrInvalidBlock", err)
+	}
+
+	b.difficulty = 65
+	if bc.ValidHeaderProof(b.Header()) {
+		t.Error("difficulty longer than hash verified")
+	}
+}
+
+func TestWithDifficulty(t *testing.T) {
+	bc := newTestBlockchain(t, WithDifficulty(1))
+	if d := bc.CurrentDifficulty(); d != 1 {
+		t.Fatalf("current difficulty = %d, want 1", d)
+	}
+	bc.Mining()
+	if d := bc.LastBlock().Difficulty(); d != 1 {
+		t.Errorf("mined block difficulty = %d, want 1", d)
+	}
+
+	// Blocks above the chain's difficulty are accepted.
+	peer := forkChain(t, bc)
+	peer.difficulty = 2
+	peer.Mining()
+	if err := bc.AddBlock(peer.LastBlock()); err != nil {
+		t.Errorf("block at difficulty 2 rejected: %v", err)
+	}
+	if !bc.ValidChain(bc.Chain()) {
+		t.Error("chain of mixed difficulties not valid")
+	}
+}
+
+func TestWithSimulatedMining(t *testing.T) {
+	bc := newTestBlockchain(t, WithSimulatedMining())
+	miner := wallet.NewWallet().BlockchainAddress()
+	bc.blockchainAddress = miner
+	for i := 0; i < 10; i++ {
+		if !bc.Mining() {
+			t.Fatalf("mining block %d failed", i)
+		}
+		if b := bc.LastBlock(); b.Nonce() != 0 || b.Difficulty() != 0 {
+			t.Fatalf("block %d nonce %d and difficulty %d, want 0", i, b.Nonce(), b.Difficulty())
+		}
+	}
+	if got := bc.CalculateTotalAmount(miner); got != 10*MiningReward {
+		t.Errorf("miner balance = %v, want 10 rewards", got)
+	}
+	if len(bc.TransactionPool()) != 0 {
+		t.Errorf("pool = %v, want rewards mined", bc.TransactionPool())
+	}
+	if !bc.ValidChain(bc.Chain()) {
+		t.Error("simulated chain isn't valid at difficulty 0")
+	}
+
+	// Production chains don't accept the blocks.
+	if newTestBlockchain(t).ValidChain(bc.Chain()) {
+		t.Error("simulated chain valid at MiningDifficulty")
+	}
+}
+
+func TestSubmitTransactionRequestBroadcast(t *testing.T) {
+	tests := []struct {
+		name      string
+		broadcast bool
+		want      int
+	}{
+		{"from user", true, 1},
+		{"from peer", false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			alice := wallet.NewWallet()
+			bc := newTestBlockchain(t, WithGenesisAllocations(map[string]float32{alice.BlockchainAddress(): 10}))
+			var forwarded []*http.Request
+			var mux sync.Mutex
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				mux.Lock()
+				forwarded = append(forwarded, req)
+				mux.Unlock()
+			}))
+			defer ts.Close()
+			bc.neighbors = []string{strings.TrimPrefix(ts.URL, "http://")}
+
+			if err := bc.SubmitTransactionRequest(signedRequest(bc, alice, "1Bob", 1), tt.broadcast); err != nil {
+				t.Fatal(err)
+			}
+			mux.Lock()
+			defer mux.Unlock()
+			if len(forwarded) != tt.want {
+				t.Fatalf("forwarded %d times, want %d", len(forwarded), tt.want)
+			}
+			for _, req := range forwarded {
+				if req.Method != http.MethodPut || req.Header.Get(OriginNodeHeader) == "" {
+					t.Errorf("forwarded as %s with %s %q, want PUT from this node",
+						req.Method, OriginNodeHeader, req.Header.Get(OriginNodeHeader))
+				}
+			}
+			if bc.PoolSize() != 1 {
+				t.Errorf("pool size = %d, want 1", bc.PoolSize())
+			}
+		})
+	}
+}
+
+func TestMineWithDifficulty(t *testing.T) {
+	bc := newTestBlockchain(t, WithDifficulty(0))
+	for _, difficulty := range []int{0, 2} {
+		if !bc.MineWithDifficulty(difficulty) {
+			t.Fatalf("mining at difficulty %d failed", difficulty)
+		}
+		b := bc.LastBlock()
+		if b.Difficulty() != difficulty || !bc.VerifyBlock(b) {
+			t.Errorf("block recorded difficulty %d, verified %v, want %d and verified", b.Difficulty(), bc.VerifyBlock(b), difficulty)
+		}
+	}
+	if err := bc.SelfCheck(); err != nil {
+		t.Errorf("self check: %v", err)
+	}
+
+	// Blocks easier than the chain's difficulty wouldn't verify, so they aren't mined.
+	hard := newTestBlockchain(t, WithDifficulty(2))
+	if hard.MineWithDifficulty(1) {
+		t.Error("mined below chain difficulty")
+	}
+	if hard.Height() != 1 || hard.PoolSize() != 0 {
+		t.Errorf("height %d, pool size %d after refused mining, want 1 and 0", hard.Height(), hard.PoolSize())
+	}
+}
+
+func TestMineTo(t *testing.T) {
+	bc := newTestBlockchain(t)
+	pool := wallet.NewWallet().BlockchainAddress()
+	if !bc.MineTo(pool, 0) {
+		t.Fatal("mining failed")
+	}
+	if got := bc.CalculateTotalAmount(pool); got != MiningReward {
+		t.Errorf("reward address balance = %v, want %v", got, MiningReward)
+	}
+	if got := bc.CalculateTotalAmount(bc.blockchainAddress); got != 0 {
+		t.Errorf("miner balance = %v, want 0", got)
+	}
+
+	height := bc.Height()
+	if bc.MineTo("1Typo", 0) {
+		t.Error("mined to invalid address")
+	}
+	if bc.Height() != height || len(bc.TransactionPool()) != 0 {
+		t.Errorf("height %d and pool %v changed by failed mining", bc.Height(), bc.TransactionPool())
+	}
+}
+
+func TestMineToExtraNonce(t *testing.T) {
+	bc := newTestBlockchain(t)
+	address := bc.blockchainAddress
+	if !bc.MineTo(address, 0) {
+		t.Fatal("mining failed")
+	}
+	if h := string(bc.LastBlock().Header().CanonicalBytes()); strings.Contains(h, "extra_nonce") {
+		t.Errorf("header %s without extra nonce writes it", h)
+	}
+
+	// Miners of the same template with distinct extra nonces find distinct blocks.
+	hashes := make(map[[32]byte]bool)
+	for _, extraNonce := range []uint64{1, 2} {
+		fork := forkChain(t, bc)
+		if !fork.MineTo(address, extraNonce) {
+			t.Fatalf("mining with extra nonce %d failed", extraNonce)
+		}
+		b := fork.LastBlock()
+		if b.ExtraNonce() != extraNonce || !fork.VerifyBlock(b) {
+			t.Errorf("block of extra nonce %d: got %d, valid %v", extraNonce, b.ExtraNonce(), fork.VerifyBlock(b))
+		}
+		hashes[b.Hash()] = true
+
+		m, err := json.Marshal(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded Block
+		if err := json.Unmarshal(m, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if decoded.Hash() != b.Hash() || decoded.ExtraNonce() != extraNonce {
+			t.Errorf("extra nonce %d lost in JSON round trip", extraNonce)
+		}
+		if size := len(b.Header().CanonicalBytes()); size > maxHeaderSize {
+			t.Errorf("header size %d exceeds max header size %d", size, maxHeaderSize)
+		}
+	}
+	if len(hashes) != 2 {
+		t.Error("extra nonces gave the same block")
+	}
+}
+
+func TestMiningSchedule(t *testing.T) {
+	a, b := newTestBlockchain(t), newTestBlockchain(t)
+	b.chain = append([]*Block(nil), a.chain...)
+	a.SetMiningSchedule(0, 2)
+	b.SetMiningSchedule(1, 2)
+
+	// The chain holds genesis only, so the next block is at height 1.
+	if a.Mining() {
+		t.Fatal("node 0 mined block 1")
+	}
+	if !b.Mining() {
+		t.Fatal("node 1 didn't mine block 1")
+	}
+	if err := a.AddBlock(b.LastBlock()); err != nil {
+		t.Fatal(err)
+	}
+	if b.Mining() || !a.Mining() {
+		t.Error("block 2 not mined by node 0 only")
+	}
+
+	a.SetMiningSchedule(0, 0)
+	if !a.Mining() {
+		t.Error("node without a schedule didn't mine")
+	}
+}
+
+func TestMiningRestart(t *testing.T) {
+	// mineWhileSending is to mine at difficulty 4 and send a transaction once
+	// proof of work has started, reporting whether mining restarted and
+	// whether the mined block holds the transaction. A block mined before
+	// the transaction is sent reports neither.
+	mineWhileSending := func(policy MiningRestartPolicy) (restarted, included bool) {
+		alice := wallet.NewWallet()
+		bc := newTestBlockchain(t, WithGenesisAllocations(map[string]float32{alice.BlockchainAddress(): 10}))
+		logger := &recordLogger{}
+		bc.SetLogger(logger)
+		bc.SetMiningRestartPolicy(policy)
+
+		done := make(chan bool, 1)
+		go func() { done <- bc.MineWithDifficulty(4) }()
+		for started := false; !started; {
+			select {
+			case <-done:
+				return false, false
+			case <-time.After(time.Millisecond):
+			}
+			bc.mux.Lock()
+			started = bc.cancelMining != nil
+			bc.mux.Unlock()
+		}
+		if !sendTransaction(bc, alice, "1Bob", 1) {
+			t.Fatal("transaction rejected")
+		}
+		if !<-done {
+			t.Fatal("mining failed")
+		}
+		for _, tx := range bc.LastBlock().Transactions() {
+			included = included || tx.recipientBlockchainAddress == "1Bob"
+		}
+		return logger.find("mining", "restarted") != nil, included
+	}
+
+	// The nonce can be found before the transaction arrives, so retry.
+	restarted, included := false, false
+	for i := 0; i < 20 && !restarted; i++ {
+		restarted, included = mineWhileSending(func(*Transaction) bool { return true })
+	}
+	if !restarted || !included {
+		t.Errorf("restarted %v, included %v, want both", restarted, included)
+	}
+
+	if restarted, included := mineWhileSending(nil); restarted || included {
+		t.Errorf("without policy restarted %v, included %v, want neither", restarted, included)
+	}
+}
+
+func TestEmptyChain(t *testing.T) {
+	for _, data := range []string{`{"chains":[]}`, `{"chain":[]}`, `{"chain":null}`, `{}`} {
+		t.Run(data, func(t *testing.T) {
+			bc := newBlockchain(wallet.NewWallet().BlockchainAddress(), 0)
+			bc.SetLogger(utils.NewNopLogger())
+			if err := json.Unmarshal([]byte(data), bc); !errors.Is(err, ErrEmptyChain) {
+				t.Fatalf("unmarshal error = %v, want ErrEmptyChain", err)
+			}
+
+			// The node errors cleanly on the chain left empty.
+			if bc.LastBlock() != nil {
+				t.Error("LastBlock of empty chain isn't nil")
+			}
+			if _, err := bc.ProofOfWork(); !errors.Is(err, ErrEmptyChain) {
+				t.Errorf("ProofOfWork error = %v", err)
+			}
+			if bc.Mining() || len(bc.TransactionPool()) != 0 {
+				t.Error("mined on empty chain")
+			}
+			if err := bc.AddBlock(goldenBlock()); !errors.Is(err, ErrEmptyChain) {
+				t.Errorf("AddBlock error = %v", err)
+			}
+			if _, err := bc.Snapshot(); !errors.Is(err, ErrEmptyChain) {
+				t.Errorf("Snapshot error = %v", err)
+			}
+			if bc.ValidChain(bc.chain) {
+				t.Error("empty chain is valid")
+			}
+		})
+	}
+}
+
+// newTestChain is to return Blockchain of blocks mined blocks after genesis,
+// each holding perBlock signed transactions.
+func newTestChain(tb testing.TB, blocks int, perBlock int) *Blockchain {
+	tb.Helper()
+	w := wallet.NewWallet()
+	bc := newTestBlockchain(tb, WithGenesisAllocations(map[string]float32{w.BlockchainAddress(): 1e6}))
+	bc.blockchainAddress = w.BlockchainAddress()
+	recipient := wallet.NewWallet().BlockchainAddress()
+	for i := 0; i < blocks; i++ {
+		for j := 0; j < perBlock; j++ {
+			if !sendTransaction(bc, w, recipient, float32(i*perBlock+j+1)) {
+				tb.Fatalf("transaction %d of block %d rejected", j, i)
+			}
+		}
+		if !bc.Mining() {
+			tb.Fatalf("mining block %d failed", i)
+		}
+	}
+	return bc
+}
+
+func TestValidChain(t *testing.T) {
+	bc := newTestChain(t, 3, 2)
+	copyChain := func() []*Block {
+		chain := make([]*Block, len(bc.chain))
+		for i, b := range bc.chain {
+			c := *b
+			c.transactions = append([]*Transaction(nil), b.transactions...)
+			chain[i] = &c
+		}
+		return chain
+	}
+	tests := []struct {
+		name   string
+		modify func(chain []*Block) []*Block
+		want   bool
+	}{
+		{"valid", func(chain []*Block) []*Block { return chain }, true},
+		{"pruned", func(chain []*Block) []*Block { return chain[2:] }, true},
+		{"empty", func(chain []*Block) []*Block { return nil }, false},
+		{"broken link", func(chain []*Block) []*Block {
+			chain[2].previousHash[0]++
+			return chain
+		}, false},
+		{"excess coinbase", func(chain []*Block) []*Block {
+			chain[3].transactions = append(chain[3].transactions, NewTransaction(MiningSender, "1Miner", MiningReward))
+			return chain
+		}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bc.ValidChain(tt.modify(copyChain())); got != tt.want {
+				t.Errorf("ValidChain = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// BenchmarkValidChain is cost of validating a whole chain again, as
+// ResolveConflicts does for each neighbor chain. Signatures are verified
+// when transactions are pooled, not in ValidChain, so no signature cache
+// is needed for it.
+func BenchmarkValidChain(b *testing.B) {
+	for _, blocks := range []int{10, 100} {
+		bc := newTestChain(b, blocks, 10)
+		b.Run(fmt.Sprintf("blocks=%d", blocks), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if !bc.ValidChain(bc.chain) {
+					b.Fatal("chain invalid")
+				}
+			}
+		})
+	}
+}
+
+func TestDustThreshold(t *testing.T) {
+	alice := wallet.NewWallet()
+	keys := []*ecdsa.PublicKey{alice.PublicKey()}
+	multisig := MultisigAddress(1, keys)
+	bc := newTestBlockchain(t, WithGenesisAllocations(map[string]float32{alice.BlockchainAddress(): 10, multisig: 10}))
+	m := &countMetrics{rejected: make(map[string]int)}
+	bc.SetMetrics(m)
+
+	if err := submit(bc, signedRequest(bc, alice, "1Bob", 0.009)); err != nil {
+		t.Fatalf("value below threshold rejected by default: %v", err)
+	}
+	bc.SetDustThreshold(0.01)
+	tests := []struct {
+		value float32
+		want  error
+	}{
+		{0.009, ErrDustValue},
+		{0.01, nil},
+		{1, nil},
+	}
+	for _, tt := range tests {
+		if err := submit(bc, signedRequest(bc, alice, "1Bob", tt.value)); !errors.Is(err, tt.want) {
+			t.Errorf("value %v: error = %v, want %v", tt.value, err, tt.want)
+		}
+	}
+	if m.rejected[RejectReasonDust] != 1 {
+		t.Errorf("dust rejections = %d, want 1", m.rejected[RejectReasonDust])
+	}
+
+	tx := wallet.NewTransaction(alice.PrivateKey(), alice.PublicKey(), multisig, "1Bob", 0.001)
+	tx.SetChainID(bc.ChainID())
+	err := bc.AddMultisigTransaction(multisig, "1Bob", 0.001, tx.Timestamp(), keys, 1, []*utils.Signature{tx.GenerateSignature()})
+	if !errors.Is(err, ErrDustValue) {
+		t.Errorf("multisig error = %v, want ErrDustValue", err)
+	}
+}
+
+func TestSenderKeyMismatch(t *testing.T) {
+	alice, mallory := wallet.NewWallet(), wallet.NewWallet()
+	bc := newTestBlockchain(t, WithGenesisAllocations(map[string]float32{alice.BlockchainAddress(): 10}))
+	m := &countMetrics{rejected: make(map[string]int)}
+	bc.SetMetrics(m)
+
+	// mallory signs with her own key, naming alice as sender.
+	tx := wallet.NewTransaction(mallory.PrivateKey(), mallory.PublicKey(), alice.BlockchainAddress(), "1Bob", 1)
+	tx.SetChainID(bc.ChainID())
+	bc.mux.Lock()
+	err := bc.addTransaction(alice.BlockchainAddress(), "1Bob", 1, tx.Timestamp(), mallory.PublicKey(), tx.GenerateSignature())
+	missing := bc.addTransaction(alice.BlockchainAddress(), "1Bob", 1, tx.Timestamp()+1, nil, tx.GenerateSignature())
+	bc.mux.Unlock()
+	if !errors.Is(err, ErrSenderKeyMismatch) || !errors.Is(missing, ErrSenderKeyMismatch) {
+		t.Errorf("errors = %v, %v; want ErrSenderKeyMismatch", err, missing)
+	}
+	if m.rejected[RejectReasonSenderKeyMismatch] != 2 || bc.PoolSize() != 0 {
+		t.Errorf("%d rejections, pool size %d", m.rejected[RejectReasonSenderKeyMismatch], bc.PoolSize())
+	}
+	if !sendTransaction(bc, alice, "1Bob", 1) {
+		t.Error("owner's transaction rejected")
+	}
+}
@@ -0,0 +1,59 @@
+package block
+
+import (
+	"crypto/sha256"
+	"math"
+)
+
+// maxHeaderSize is canonical size of the largest block header, of max width
+// timestamp, difficulty, nonce and extra nonce. Packing reserves it since
+// the header isn't known until the block is mined.
+var maxHeaderSize = len((&Block{
+	timestamp:  math.MaxInt64,
+	difficulty: 2 * sha256.Size,
+	nonce:      math.MaxUint64,
+	extraNonce: math.MaxUint64,
+}).Header().CanonicalBytes())
+
+// Size is to return byte length of block's canonical serialization,
+// its header and the canonical bytes of each transaction.
+func (b *Block) Size() int {
+	size := len(b.Header().CanonicalBytes())
+	for _, t := range b.transactions {
+		size += len(t.CanonicalBytes())
+	}
+	return size
+}
+
+// MaxBlockSize is to return max block Size mined blocks are packed to.
+func (bc *Blockchain) MaxBlockSize() int {
+	return bc.maxBlockSize
+}
+
+// SetMaxBlockSize is set max block Size mined blocks are packed to.
+// Transactions which don't fit stay in the pool. 0 disables the limit.
+func (bc *Blockchain) SetMaxBlockSize(size int) {
+	bc.maxBlockSize = size
+}
+
+// blockTransactions is to return transactions of a block paying coinbase:
+// coinbase first, then pool transactions in arrival order until the next
+// one would exceed max block size.
+func (bc *Blockchain) blockTransactions(coinbase *Transaction) []*Transaction {
+	selected := make([]*Transaction, 0, len(bc.transactionPool)+1)
+	selected = append(selected, coinbase)
+	if bc.maxBlockSize <= 0 {
+		return append(selected, bc.transactionPool...)
+	}
+
+	budget := bc.maxBlockSize - maxHeaderSize - len(coinbase.CanonicalBytes())
+	for _, t := range bc.transactionPool {
+		size := len(t.CanonicalBytes())
+		if size > budget {
+			break
+		}
+		budget -= size
+		selected = append(selected, t)
+	}
+	return selected
+}
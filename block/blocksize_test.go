@@ -0,0 +1,103 @@
+package block
+
+import (
+	"goblockchain/wallet"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestBlockSize(t *testing.T) {
+	bc := newTestBlockchain(t, WithDifficulty(0), WithGenesisAllocations(map[string]float32{"1Alice": 1, "1Bob": 2}))
+	b := bc.LastBlock()
+	want := len(b.Header().CanonicalBytes())
+	for _, t := range b.Transactions() {
+		want += len(t.CanonicalBytes())
+	}
+	if got := b.Size(); got != want || got <= len(b.Header().CanonicalBytes()) {
+		t.Errorf("size = %d, want %d", got, want)
+	}
+}
+
+func TestMaxBlockSize(t *testing.T) {
+	alice := wallet.NewWallet()
+	bc := newTestBlockchain(t, WithDifficulty(0), WithGenesisAllocations(map[string]float32{alice.BlockchainAddress(): 100}))
+	for i := 0; i < 5; i++ {
+		if !sendTransaction(bc, alice, "1Bob", 1) {
+			t.Fatal("transaction rejected")
+		}
+	}
+	txSize := len(bc.transactionPool[0].CanonicalBytes())
+	coinbase := NewTransaction(MiningSender, bc.blockchainAddress, MiningReward)
+	coinbase.timestamp = bc.clock.Now().UnixNano()
+	// Room for the coinbase and two transactions, but not three.
+	bc.SetMaxBlockSize(maxHeaderSize + len(coinbase.CanonicalBytes()) + 2*txSize + txSize/2)
+
+	for _, wantPooled := range []int{3, 1, 0} {
+		if !bc.Mining() {
+			t.Fatal("mining failed")
+		}
+		b := bc.LastBlock()
+		if b.Size() > bc.MaxBlockSize() {
+			t.Errorf("block size %d over max %d", b.Size(), bc.MaxBlockSize())
+		}
+		if got := bc.PoolSize(); got != wantPooled {
+			t.Errorf("pool size = %d after mining, want %d", got, wantPooled)
+		}
+		if !ValidCoinbase(b) {
+			t.Error("mining reward isn't the block's first transaction")
+		}
+	}
+	if got := bc.CalculateTotalAmount("1Bob"); got != 5 {
+		t.Errorf("recipient balance = %v, want 5", got)
+	}
+
+	// With the limit disabled the whole pool is mined.
+	for i := 0; i < 4; i++ {
+		sendTransaction(bc, alice, "1Bob", 1)
+	}
+	bc.SetMaxBlockSize(0)
+	bc.Mining()
+	if bc.PoolSize() != 0 || len(bc.LastBlock().Transactions()) != 5 {
+		t.Errorf("pool size %d, block of %d transactions, want 0 and 5", bc.PoolSize(), len(bc.LastBlock().Transactions()))
+	}
+}
+
+func TestMiningKeepsNeighborPools(t *testing.T) {
+	alice := wallet.NewWallet()
+	bc := newTestBlockchain(t, WithDifficulty(0), WithGenesisAllocations(map[string]float32{alice.BlockchainAddress(): 100}))
+	var methods []string
+	var mux sync.Mutex
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mux.Lock()
+		defer mux.Unlock()
+		methods = append(methods, req.Method+" "+req.URL.Path)
+	}))
+	t.Cleanup(ts.Close)
+	bc.muxNeighbors.Lock()
+	bc.neighbors = []string{strings.TrimPrefix(ts.URL, "http://")}
+	bc.muxNeighbors.Unlock()
+
+	for i := 0; i < 3; i++ {
+		sendTransaction(bc, alice, "1Bob", 1)
+	}
+	txSize := len(bc.transactionPool[0].CanonicalBytes())
+	coinbase := NewTransaction(MiningSender, bc.blockchainAddress, MiningReward)
+	coinbase.timestamp = bc.clock.Now().UnixNano()
+	bc.SetMaxBlockSize(maxHeaderSize + len(coinbase.CanonicalBytes()) + txSize)
+	if !bc.Mining() {
+		t.Fatal("mining failed")
+	}
+	if bc.PoolSize() != 2 {
+		t.Fatalf("pool size = %d, want the overflow pooled", bc.PoolSize())
+	}
+	mux.Lock()
+	defer mux.Unlock()
+	for _, m := range methods {
+		if strings.HasPrefix(m, http.MethodDelete) {
+			t.Errorf("neighbor got %s, which would drop its overflow transactions", m)
+		}
+	}
+}
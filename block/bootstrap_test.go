@@ -0,0 +1,61 @@
+package block
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// stubNeighbors is to make neighbor discovery find neighbors until the test ends.
+func stubNeighbors(tb testing.TB, neighbors ...string) {
+	tb.Helper()
+	orig := findNeighbors
+	findNeighbors = func(string, uint16, uint8, uint8, uint16, uint16) []string {
+		return append([]string(nil), neighbors...)
+	}
+	tb.Cleanup(func() { findNeighbors = orig })
+}
+
+func TestBootstrapNoNeighbors(t *testing.T) {
+	stubNeighbors(t)
+	bc := newTestBlockchain(t)
+	height := bc.Height()
+
+	if err := bc.Bootstrap(); !errors.Is(err, ErrNoNeighbors) {
+		t.Fatalf("error = %v, want ErrNoNeighbors", err)
+	}
+	if bc.Height() != height {
+		t.Errorf("height = %d, want %d", bc.Height(), height)
+	}
+}
+
+func TestBootstrap(t *testing.T) {
+	bc := newTestBlockchain(t)
+	peer := forkChain(t, bc)
+	peer.Mining()
+	peer.Mining()
+	servePeerChain(t, bc, peer)
+	stubNeighbors(t, bc.Neighbors()...)
+
+	if err := bc.Bootstrap(); err != nil {
+		t.Fatal(err)
+	}
+	if got := bc.Neighbors(); len(got) != 1 || !strings.HasPrefix(got[0], "127.0.0.1:") {
+		t.Errorf("neighbors = %v", got)
+	}
+	if bc.LastBlock().Hash() != peer.LastBlock().Hash() {
+		t.Error("peer chain not adopted")
+	}
+}
+
+func TestWithPeers(t *testing.T) {
+	stubNeighbors(t, "127.0.0.1:5001", "127.0.0.1:5002")
+	bc := newTestBlockchain(t, WithPeers("127.0.0.1:5002", "10.0.0.1:5000"))
+
+	bc.SetNeighbors()
+	want := []string{"127.0.0.1:5001", "127.0.0.1:5002", "10.0.0.1:5000"}
+	if got := bc.Neighbors(); !reflect.DeepEqual(got, want) {
+		t.Errorf("neighbors = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,26 @@
+package block
+
+import (
+	"goblockchain/wallet"
+	"testing"
+)
+
+func TestChainIDReplay(t *testing.T) {
+	alice := wallet.NewWallet()
+	allocations := map[string]float32{alice.BlockchainAddress(): 10}
+	mainnet := newTestBlockchain(t, WithGenesisAllocations(allocations))
+	testnet := newTestBlockchain(t, WithGenesisAllocations(allocations), WithChainID("testnet"))
+
+	tx := wallet.NewTransaction(alice.PrivateKey(), alice.PublicKey(), alice.BlockchainAddress(), "1Bob", 1)
+	tx.SetChainID("testnet")
+	s := tx.GenerateSignature()
+	if mainnet.AddTransaction(alice.BlockchainAddress(), "1Bob", 1, tx.Timestamp(), alice.PublicKey(), s) {
+		t.Error("testnet transaction replayed on the default network")
+	}
+	if !testnet.AddTransaction(alice.BlockchainAddress(), "1Bob", 1, tx.Timestamp(), alice.PublicKey(), s) {
+		t.Error("testnet transaction rejected on testnet")
+	}
+	if !sendTransaction(mainnet, alice, "1Bob", 1) {
+		t.Error("default network transaction rejected")
+	}
+}
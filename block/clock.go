@@ -0,0 +1,26 @@
+package block
+
+import "time"
+
+// Clock is source of the time blocks, pool entries and peer bans are
+// stamped with, replaceable so time dependent behavior can be tested.
+type Clock interface {
+	Now() time.Time
+}
+
+// realClock is Clock of the system time.
+type realClock struct{}
+
+// Now is to return the system time.
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// WithClock is option to stamp blocks, coinbases, pool entries, peer bans
+// and announcements with clock's time instead of the system time, including
+// the genesis block unless WithGenesisTimestamp is given.
+func WithClock(clock Clock) Option {
+	return func(bc *Blockchain) {
+		bc.clock = clock
+	}
+}
@@ -0,0 +1,64 @@
+package block
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// FakeClock is Clock for tests which only moves when it's set or advanced.
+type FakeClock struct {
+	now time.Time
+	mux sync.Mutex
+}
+
+// NewFakeClock is to return new FakeClock struct stopped at now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now is to return FakeClock's time.
+func (c *FakeClock) Now() time.Time {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.now
+}
+
+// Set is set FakeClock's time.
+func (c *FakeClock) Set(now time.Time) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.now = now
+}
+
+// Advance is to move FakeClock's time forward by d.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestFakeClock(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	clock := NewFakeClock(start)
+	bc := newTestBlockchain(t, WithDifficulty(0), WithClock(clock))
+	if got := bc.LastBlock().Timestamp(); got != start.UnixNano() {
+		t.Errorf("genesis timestamp = %d, want %d", got, start.UnixNano())
+	}
+
+	for i := 0; i < 3; i++ {
+		clock.Advance(10 * time.Second)
+		bc.Mining()
+	}
+	if got := bc.AverageBlockTime(3); got != 10*time.Second {
+		t.Errorf("average block time = %v, want 10s", got)
+	}
+	if coinbase := bc.LastBlock().Transactions()[0]; coinbase.Timestamp() != clock.Now().UnixNano() {
+		t.Errorf("coinbase timestamp = %d, want the clock's", coinbase.Timestamp())
+	}
+
+	clock.Set(start)
+	if got := clock.Now(); !got.Equal(start) {
+		t.Errorf("clock after Set = %v, want %v", got, start)
+	}
+}
@@ -0,0 +1,93 @@
+package block
+
+import (
+	"goblockchain/wallet"
+	"sync"
+	"testing"
+)
+
+func TestChainReturnsCopy(t *testing.T) {
+	bc := newTestBlockchain(t)
+	bc.Mining()
+	hash := bc.LastBlock().Hash()
+
+	chain := bc.Chain()
+	chain[1].nonce++
+	chain[1].transactions[0].value = 1000
+	chain[0] = nil
+	if bc.LastBlock().Hash() != hash || bc.chain[0] == nil {
+		t.Error("chain changed through Chain's result")
+	}
+	b := bc.BlockAt(1)
+	b.transactions[0].value = 1000
+	if bc.LastBlock().Hash() != hash {
+		t.Error("chain changed through BlockAt's result")
+	}
+	if bc.BlockAt(2) != nil || bc.BlockAt(-1) != nil {
+		t.Error("BlockAt out of range isn't nil")
+	}
+}
+
+// TestReadsDuringMining is for go test -race: readers must not race with
+// mining appending blocks and clearing the pool.
+func TestReadsDuringMining(t *testing.T) {
+	bc := newTestBlockchain(t)
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			bc.Chain()
+			bc.MarshalJSON()
+			bc.CalculateTotalAmount("1Alice")
+			bc.TotalSupply()
+			bc.TransactionPool()
+			bc.ChainPage(0, 10, "")
+			bc.Confirmations(1)
+			bc.AverageBlockTime(2)
+		}
+	}()
+	for i := 0; i < 5; i++ {
+		bc.Mining()
+	}
+	close(done)
+	wg.Wait()
+}
+
+// TestPoolWritesDuringReplace is for go test -race: adding transactions must
+// not race with the pool being cleared or the chain being replaced.
+func TestPoolWritesDuringReplace(t *testing.T) {
+	alice := wallet.NewWallet()
+	bc := newTestBlockchain(t, WithGenesisAllocations(map[string]float32{alice.BlockchainAddress(): 1000}))
+	peer := forkChain(t, bc)
+	peer.Mining()
+	peer.Mining()
+	servePeerChain(t, bc, peer)
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			sendTransaction(bc, alice, "1Bob", 1)
+		}
+	}()
+	for i := 0; i < 5; i++ {
+		bc.ClearTransactionPool()
+		bc.ResolveConflicts()
+	}
+	close(done)
+	wg.Wait()
+}
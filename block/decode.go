@@ -0,0 +1,66 @@
+package block
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goblockchain/utils"
+)
+
+// ErrMalformedBlock is returned by DecodeBlock for input which isn't a block.
+var ErrMalformedBlock = errors.New("malformed block")
+
+// DecodeBlock is to decode block json as served by /chain and pushed to
+// /block, failing with ErrMalformedBlock and the reason if it isn't one.
+func DecodeBlock(data []byte) (*Block, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || data[0] != '{' {
+		return nil, fmt.Errorf("%w: expected a json object", ErrMalformedBlock)
+	}
+	var b Block
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedBlock, err)
+	}
+	for i, t := range b.transactions {
+		if t == nil {
+			return nil, fmt.Errorf("%w: transaction %d is null", ErrMalformedBlock, i)
+		}
+	}
+	return &b, nil
+}
+
+// BlockDescription is block annotated with what the node computes of it,
+// for debugging received blocks.
+type BlockDescription struct {
+	Block         *Block `json:"block"`
+	Hash          string `json:"hash"`
+	MerkleRoot    string `json:"merkle_root"`
+	Size          int    `json:"size"`
+	Genesis       bool   `json:"genesis"`
+	Difficulty    int    `json:"difficulty"`
+	ValidProof    bool   `json:"valid_proof"`
+	ValidCoinbase bool   `json:"valid_coinbase"`
+	ExtendsTip    bool   `json:"extends_tip"`
+}
+
+// DescribeBlock is to return description of b checked against the chain at
+// CurrentDifficulty. The proof is valid if b records at least that
+// difficulty and its hash meets the difficulty it records.
+func (bc *Blockchain) DescribeBlock(b *Block) *BlockDescription {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	tipHash, _ := bc.lastHash()
+	difficulty := bc.currentDifficulty()
+	return &BlockDescription{
+		Block:         b,
+		Hash:          utils.HashToHex(b.Hash()),
+		MerkleRoot:    utils.HashToHex(b.MerkleRoot()),
+		Size:          b.Size(),
+		Genesis:       b.IsGenesis(),
+		Difficulty:    difficulty,
+		ValidProof:    b.difficulty >= difficulty && bc.ValidHeaderProof(b.Header()),
+		ValidCoinbase: ValidCoinbase(b),
+		ExtendsTip:    b.previousHash == tipHash,
+	}
+}
@@ -0,0 +1,70 @@
+package block
+
+import (
+	"encoding/json"
+	"errors"
+	"goblockchain/utils"
+	"strings"
+	"testing"
+)
+
+func TestDecodeBlock(t *testing.T) {
+	bc := newTestBlockchain(t)
+	bc.Mining()
+	mined, _ := json.Marshal(bc.LastBlock())
+
+	b, err := DecodeBlock(mined)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Hash() != bc.LastBlock().Hash() {
+		t.Error("decoded block hash differs from the mined block's")
+	}
+
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"empty", ``, "json object"},
+		{"array", `[]`, "json object"},
+		{"null", `null`, "json object"},
+		{"bad json", `{"nonce":`, "unexpected end"},
+		{"bad previous_hash", `{"previous_hash":"xyz","transaction":[]}`, "previous_hash"},
+		{"null previous_hash", `{"previous_hash":null,"transaction":[]}`, "previous_hash"},
+		{"null transaction", strings.Replace(string(mined), `"transaction":[`, `"transaction":[null,`, 1), "transaction 0 is null"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeBlock([]byte(tt.data))
+			if !errors.Is(err, ErrMalformedBlock) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want ErrMalformedBlock naming %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDescribeBlock(t *testing.T) {
+	bc := newTestBlockchain(t)
+	peer := forkChain(t, bc)
+	peer.Mining()
+	b := peer.LastBlock()
+
+	d := bc.DescribeBlock(b)
+	if d.Hash != utils.HashToHex(b.Hash()) || d.MerkleRoot != utils.HashToHex(b.MerkleRoot()) || d.Size != b.Size() {
+		t.Errorf("description = %+v", d)
+	}
+	if !d.ValidProof || !d.ValidCoinbase || !d.ExtendsTip || d.Genesis || d.Difficulty != MiningDifficulty {
+		t.Errorf("description of mined block = %+v", d)
+	}
+
+	tampered := *b
+	tampered.nonce++
+	if bc.DescribeBlock(&tampered).ValidProof {
+		t.Error("tampered nonce has valid proof")
+	}
+	bc.Mining()
+	if bc.DescribeBlock(b).ExtendsTip {
+		t.Error("block behind the tip extends it")
+	}
+}
@@ -0,0 +1,144 @@
+package block
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"goblockchain/utils"
+	"goblockchain/wallet"
+	"testing"
+)
+
+// signedRequest is to return request of transaction of value from w to
+// recipient signed by w for bc's network.
+func signedRequest(bc *Blockchain, w *wallet.Wallet, recipient string, value float32) *TransactionRequest {
+	t := wallet.NewTransaction(w.PrivateKey(), w.PublicKey(), w.BlockchainAddress(), recipient, value)
+	t.SetChainID(bc.ChainID())
+	sender, publicKey, signature := w.BlockchainAddress(), w.PublicKeyStr(), t.GenerateSignature().String()
+	timestamp := t.Timestamp()
+	return &TransactionRequest{
+		SenderBlockchainAddress:    &sender,
+		RecipientBlockchainAddress: &recipient,
+		SenderPublicKey:            &publicKey,
+		Value:                      &value,
+		Timestamp:                  &timestamp,
+		Signature:                  &signature,
+	}
+}
+
+// submit is to add request to bc's pool, returning why it was rejected.
+func submit(bc *Blockchain, tr *TransactionRequest) error {
+	return bc.AddTransactions([]*TransactionRequest{tr})[0]
+}
+
+func TestDuplicateTransaction(t *testing.T) {
+	alice := wallet.NewWallet()
+	bc := newTestBlockchain(t, WithGenesisAllocations(map[string]float32{alice.BlockchainAddress(): 100}))
+	m := &countMetrics{rejected: make(map[string]int)}
+	bc.SetMetrics(m)
+
+	tr := signedRequest(bc, alice, "1Bob", 1)
+	if err := submit(bc, tr); err != nil {
+		t.Fatal(err)
+	}
+	if err := submit(bc, tr); !errors.Is(err, ErrDuplicateTransaction) {
+		t.Errorf("pooled replay: error = %v, want ErrDuplicateTransaction", err)
+	}
+	// Paying the same value again is signed with a new timestamp.
+	again := signedRequest(bc, alice, "1Bob", 1)
+	if err := submit(bc, again); err != nil {
+		t.Errorf("repeated payment: %v", err)
+	}
+
+	bc.Mining()
+	if err := submit(bc, tr); !errors.Is(err, ErrDuplicateTransaction) {
+		t.Errorf("confirmed replay: error = %v, want ErrDuplicateTransaction", err)
+	}
+	if m.rejected[RejectReasonDuplicate] != 2 {
+		t.Errorf("rejected = %v", m.rejected)
+	}
+
+	missing := signedRequest(bc, alice, "1Bob", 1)
+	missing.Timestamp = nil
+	if err := submit(bc, missing); !errors.Is(err, ErrMissingFields) {
+		t.Errorf("no timestamp: error = %v, want ErrMissingFields", err)
+	}
+
+	// A changed timestamp doesn't match the signature.
+	*tr.Timestamp++
+	if err := submit(bc, tr); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("changed timestamp: error = %v, want ErrInvalidSignature", err)
+	}
+}
+
+func TestDuplicateTransactionAfterPrune(t *testing.T) {
+	alice := wallet.NewWallet()
+	bc := newTestBlockchain(t, WithGenesisAllocations(map[string]float32{alice.BlockchainAddress(): 100}))
+	tr := signedRequest(bc, alice, "1Bob", 1)
+	if err := submit(bc, tr); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i <= PruneSafetyDepth; i++ {
+		bc.Mining()
+	}
+	if err := bc.Prune(PruneSafetyDepth); err != nil {
+		t.Fatal(err)
+	}
+	if bc.PrunedHeight() < 2 {
+		t.Fatalf("pruned height %d, want the transaction's block pruned", bc.PrunedHeight())
+	}
+	if err := submit(bc, tr); !errors.Is(err, ErrDuplicateTransaction) {
+		t.Errorf("replay of pruned transaction: error = %v, want ErrDuplicateTransaction", err)
+	}
+}
+
+func TestDuplicateMultisigTransaction(t *testing.T) {
+	owner := wallet.NewWallet()
+	keys := []*ecdsa.PublicKey{owner.PublicKey()}
+	sender := MultisigAddress(1, keys)
+	bc := newTestBlockchain(t, WithGenesisAllocations(map[string]float32{sender: 10}))
+	tx := wallet.NewTransaction(owner.PrivateKey(), owner.PublicKey(), sender, "1Bob", 1)
+	signatures := []*utils.Signature{tx.GenerateSignature()}
+
+	if err := bc.AddMultisigTransaction(sender, "1Bob", 1, tx.Timestamp(), keys, 1, signatures); err != nil {
+		t.Fatal(err)
+	}
+	bc.Mining()
+	err := bc.AddMultisigTransaction(sender, "1Bob", 1, tx.Timestamp(), keys, 1, signatures)
+	if !errors.Is(err, ErrDuplicateTransaction) {
+		t.Errorf("error = %v, want ErrDuplicateTransaction", err)
+	}
+}
+
+func TestDuplicateTransactionInBlock(t *testing.T) {
+	alice := wallet.NewWallet()
+	bc := newTestBlockchain(t, WithGenesisAllocations(map[string]float32{alice.BlockchainAddress(): 100}))
+	peer := forkChain(t, bc)
+	if err := submit(peer, signedRequest(peer, alice, "1Bob", 1)); err != nil {
+		t.Fatal(err)
+	}
+	// The same spend slipped into the block a second time.
+	peer.transactionPool = append(peer.transactionPool, peer.transactionPool[0])
+	peer.Mining()
+	b := peer.LastBlock()
+	if len(b.transactions) != 3 {
+		t.Fatalf("block has %d transactions, want the coinbase and the spend twice", len(b.transactions))
+	}
+	if UniqueTransactions(b) || bc.VerifyBlock(b) {
+		t.Fatal("block with a duplicated transaction is valid")
+	}
+	if err := bc.AddBlock(b); !errors.Is(err, ErrInvalidBlock) {
+		t.Errorf("error = %v, want ErrInvalidBlock", err)
+	}
+	if err := peer.SelfCheck(); err == nil {
+		t.Error("self check passed a chain with a duplicated transaction")
+	}
+
+	single := forkChain(t, bc)
+	if err := submit(single, signedRequest(single, alice, "1Bob", 1)); err != nil {
+		t.Fatal(err)
+	}
+	single.Mining()
+	if !UniqueTransactions(single.LastBlock()) || !bc.VerifyBlock(single.LastBlock()) {
+		t.Error("block without a duplicate isn't valid")
+	}
+}
@@ -0,0 +1,70 @@
+package block
+
+import "errors"
+
+// Machine readable codes of errors returned by Blockchain, for API responses.
+const (
+	ErrorCodeMissingFields        = "missing_fields"
+	ErrorCodeInvalidSignature     = "invalid_signature"
+	ErrorCodeInsufficientBalance  = "insufficient_balance"
+	ErrorCodeDuplicateTransaction = "duplicate_transaction"
+	ErrorCodeUnknownRecipient     = "unknown_recipient"
+	ErrorCodeDustValue            = "dust_value"
+	ErrorCodeSenderKeyMismatch    = "sender_key_mismatch"
+	ErrorCodeSenderPoolLimit      = "sender_pool_limit"
+	ErrorCodeInvalidMultisig      = "invalid_multisig"
+	ErrorCodeInvalidBlock         = "invalid_block"
+	ErrorCodeMalformedBlock       = "malformed_block"
+	ErrorCodeBlockNotExtendTip    = "block_not_extend_tip"
+	ErrorCodePeerBanned           = "peer_banned"
+	ErrorCodeTransactionNotFound  = "transaction_not_found"
+	ErrorCodeInvalidPeer          = "invalid_peer"
+	ErrorCodePeerSelf             = "peer_self"
+	ErrorCodePeerDuplicate        = "peer_duplicate"
+	ErrorCodePeerUnreachable      = "peer_unreachable"
+	ErrorCodeInvalidAnnouncement  = "invalid_announcement"
+	ErrorCodeStaleAnnouncement    = "stale_announcement"
+	ErrorCodeUnknownNode          = "unknown_node"
+	ErrorCodeReplayedAnnouncement = "replayed_announcement"
+	ErrorCodeHeightOutOfRange     = "height_out_of_range"
+	ErrorCodeUnknown              = "unknown"
+)
+
+var errorCodes = []struct {
+	err  error
+	code string
+}{
+	{ErrMissingFields, ErrorCodeMissingFields},
+	{ErrInvalidSignature, ErrorCodeInvalidSignature},
+	{ErrInsufficientBalance, ErrorCodeInsufficientBalance},
+	{ErrDuplicateTransaction, ErrorCodeDuplicateTransaction},
+	{ErrUnknownRecipient, ErrorCodeUnknownRecipient},
+	{ErrDustValue, ErrorCodeDustValue},
+	{ErrSenderKeyMismatch, ErrorCodeSenderKeyMismatch},
+	{ErrSenderPoolLimit, ErrorCodeSenderPoolLimit},
+	{ErrInvalidMultisig, ErrorCodeInvalidMultisig},
+	{ErrInvalidBlock, ErrorCodeInvalidBlock},
+	{ErrMalformedBlock, ErrorCodeMalformedBlock},
+	{ErrBlockNotExtendTip, ErrorCodeBlockNotExtendTip},
+	{ErrPeerBanned, ErrorCodePeerBanned},
+	{ErrTransactionNotFound, ErrorCodeTransactionNotFound},
+	{ErrInvalidPeer, ErrorCodeInvalidPeer},
+	{ErrPeerSelf, ErrorCodePeerSelf},
+	{ErrPeerDuplicate, ErrorCodePeerDuplicate},
+	{ErrPeerUnreachable, ErrorCodePeerUnreachable},
+	{ErrInvalidAnnouncement, ErrorCodeInvalidAnnouncement},
+	{ErrStaleAnnouncement, ErrorCodeStaleAnnouncement},
+	{ErrUnknownNode, ErrorCodeUnknownNode},
+	{ErrReplayedAnnouncement, ErrorCodeReplayedAnnouncement},
+	{ErrHeightOutOfRange, ErrorCodeHeightOutOfRange},
+}
+
+// ErrorCode is to return machine readable code of err.
+func ErrorCode(err error) string {
+	for _, c := range errorCodes {
+		if errors.Is(err, c.err) {
+			return c.code
+		}
+	}
+	return ErrorCodeUnknown
+}
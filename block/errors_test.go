@@ -0,0 +1,29 @@
+package block
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestErrorCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{ErrInvalidSignature, ErrorCodeInvalidSignature},
+		{fmt.Errorf("add transaction: %w", ErrInsufficientBalance), ErrorCodeInsufficientBalance},
+		{ErrBlockNotExtendTip, ErrorCodeBlockNotExtendTip},
+		{ErrDustValue, ErrorCodeDustValue},
+		{ErrSenderKeyMismatch, ErrorCodeSenderKeyMismatch},
+		{ErrSenderPoolLimit, ErrorCodeSenderPoolLimit},
+		{fmt.Errorf("%w: bad json", ErrMalformedBlock), ErrorCodeMalformedBlock},
+		{ErrStaleAnnouncement, ErrorCodeStaleAnnouncement},
+		{errors.New("disk full"), ErrorCodeUnknown},
+	}
+	for _, tt := range tests {
+		if got := ErrorCode(tt.err); got != tt.want {
+			t.Errorf("ErrorCode(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package block
+
+// OnNewBlock is to register callback fired when a new block is created
+// by CreateBlock or accepted by ResolveConflicts.
+// Callbacks are invoked without holding the chain lock.
+func (bc *Blockchain) OnNewBlock(fn func(*Block)) {
+	bc.muxEvents.Lock()
+	defer bc.muxEvents.Unlock()
+	bc.newBlockCallbacks = append(bc.newBlockCallbacks, fn)
+}
+
+func (bc *Blockchain) notifyNewBlock(b *Block) {
+	bc.muxEvents.Lock()
+	callbacks := make([]func(*Block), len(bc.newBlockCallbacks))
+	copy(callbacks, bc.newBlockCallbacks)
+	bc.muxEvents.Unlock()
+
+	for _, fn := range callbacks {
+		fn(b)
+	}
+}
+
+// OnReorg is to register callback fired when the chain is reorganized, with
+// number of blocks rolled back and added.
+// Callbacks are invoked without holding the chain lock.
+func (bc *Blockchain) OnReorg(fn func(rolledBack, added int)) {
+	bc.muxEvents.Lock()
+	defer bc.muxEvents.Unlock()
+	bc.reorgCallbacks = append(bc.reorgCallbacks, fn)
+}
+
+func (bc *Blockchain) notifyReorg(rolledBack, added int) {
+	bc.muxEvents.Lock()
+	callbacks := make([]func(int, int), len(bc.reorgCallbacks))
+	copy(callbacks, bc.reorgCallbacks)
+	bc.muxEvents.Unlock()
+
+	for _, fn := range callbacks {
+		fn(rolledBack, added)
+	}
+}
+
+// OnMempoolChange is to register callback fired when a transaction enters
+// (added) or leaves (removed) the transaction pool. The other argument is nil.
+// Mining rewards aren't reported. Callbacks are invoked without holding the chain lock.
+func (bc *Blockchain) OnMempoolChange(fn func(added, removed *Transaction)) {
+	bc.muxEvents.Lock()
+	defer bc.muxEvents.Unlock()
+	bc.mempoolCallbacks = append(bc.mempoolCallbacks, fn)
+}
+
+type mempoolChange struct {
+	added   *Transaction
+	removed *Transaction
+}
+
+// queueMempoolChange is to queue pool change until flushMempoolChanges is
+// called once the chain lock is released.
+func (bc *Blockchain) queueMempoolChange(added, removed *Transaction) {
+	if (added != nil && added.senderBlockchainAddress == MiningSender) ||
+		(removed != nil && removed.senderBlockchainAddress == MiningSender) {
+		return
+	}
+	bc.muxEvents.Lock()
+	defer bc.muxEvents.Unlock()
+	if len(bc.mempoolCallbacks) > 0 {
+		bc.mempoolChanges = append(bc.mempoolChanges, mempoolChange{added, removed})
+	}
+}
+
+func (bc *Blockchain) flushMempoolChanges() {
+	bc.muxEvents.Lock()
+	changes := bc.mempoolChanges
+	bc.mempoolChanges = nil
+	callbacks := make([]func(*Transaction, *Transaction), len(bc.mempoolCallbacks))
+	copy(callbacks, bc.mempoolCallbacks)
+	bc.muxEvents.Unlock()
+
+	for _, c := range changes {
+		for _, fn := range callbacks {
+			fn(c.added, c.removed)
+		}
+	}
+}
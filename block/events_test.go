@@ -0,0 +1,137 @@
+package block
+
+import (
+	"fmt"
+	"goblockchain/wallet"
+	"testing"
+	"time"
+)
+
+func TestOnNewBlock(t *testing.T) {
+	tests := []struct {
+		name  string
+		apply func(t *testing.T, bc *Blockchain)
+	}{
+		{"mining", func(t *testing.T, bc *Blockchain) {
+			if !bc.Mining() {
+				t.Error("mining failed")
+			}
+		}},
+		{"create block", func(t *testing.T, bc *Blockchain) {
+			bc.CreateBlock(0, bc.LastBlock().Hash())
+		}},
+		{"peer block", func(t *testing.T, bc *Blockchain) {
+			peer := forkChain(t, bc)
+			peer.Mining()
+			if err := bc.AddBlock(peer.LastBlock()); err != nil {
+				t.Error(err)
+			}
+		}},
+		{"resolve conflicts", func(t *testing.T, bc *Blockchain) {
+			peer := forkChain(t, bc)
+			peer.Mining()
+			peer.Mining()
+			servePeerChain(t, bc, peer)
+			if !bc.ResolveConflicts() {
+				t.Error("peer chain not adopted")
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bc := newTestBlockchain(t)
+			var got []*Block
+			bc.OnNewBlock(func(b *Block) {
+				// The chain lock isn't held, so the callback may take it.
+				bc.mux.Lock()
+				tip := bc.LastBlock()
+				bc.mux.Unlock()
+				if tip != b {
+					t.Errorf("callback block isn't the tip")
+				}
+				got = append(got, b)
+			})
+
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				tt.apply(t, bc)
+			}()
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("callback deadlocked")
+			}
+
+			if len(got) != 1 {
+				t.Fatalf("callback fired %d times, want 1", len(got))
+			}
+			if got[0].Hash() != bc.LastBlock().Hash() {
+				t.Errorf("callback saw %x, want new block %x", got[0].Hash(), bc.LastBlock().Hash())
+			}
+		})
+	}
+}
+
+func TestOnMempoolChange(t *testing.T) {
+	alice := wallet.NewWallet()
+	bc := newTestBlockchain(t, WithGenesisAllocations(map[string]float32{alice.BlockchainAddress(): 10}))
+	var added, removed []*Transaction
+	var sizes []int
+	bc.OnMempoolChange(func(a, r *Transaction) {
+		if (a == nil) == (r == nil) {
+			t.Errorf("added %v and removed %v, want exactly one", a, r)
+		}
+		if a != nil {
+			added = append(added, a)
+		}
+		if r != nil {
+			removed = append(removed, r)
+		}
+		// Callbacks run without the chain lock, so they can read the chain.
+		sizes = append(sizes, bc.PoolSize())
+	})
+
+	if !sendTransaction(bc, alice, "1Bob", 1) || !sendTransaction(bc, alice, "1Carol", 2) {
+		t.Fatal("transaction rejected")
+	}
+	if len(added) != 2 || added[0].recipientBlockchainAddress != "1Bob" || len(removed) != 0 {
+		t.Fatalf("added %d removed %d after adding, want 2 and 0", len(added), len(removed))
+	}
+
+	bc.Mining()
+	if len(added) != 2 || len(removed) != 2 {
+		t.Errorf("added %d removed %d after mining, want mining reward unreported", len(added), len(removed))
+	}
+
+	sendTransaction(bc, alice, "1Bob", 1)
+	bc.ClearTransactionPool()
+	if len(added) != 3 || len(removed) != 3 || removed[2] != added[2] {
+		t.Errorf("added %d removed %d after clearing pool, want 3 and 3", len(added), len(removed))
+	}
+	if want := []int{1, 2, 0, 0, 1, 0}; fmt.Sprint(sizes) != fmt.Sprint(want) {
+		t.Errorf("pool sizes seen by callback = %v, want %v", sizes, want)
+	}
+}
+
+func TestOnMempoolChangePeerBlock(t *testing.T) {
+	alice := wallet.NewWallet()
+	bc := newTestBlockchain(t, WithGenesisAllocations(map[string]float32{alice.BlockchainAddress(): 10}))
+	sendTransaction(bc, alice, "1Bob", 1)
+	peer := forkChain(t, bc)
+	peer.transactionPool = bc.CopyTransactionPool()
+	peer.Mining()
+
+	var removed int
+	bc.OnMempoolChange(func(_, r *Transaction) {
+		if r != nil {
+			removed++
+		}
+	})
+	if err := bc.AddBlock(peer.LastBlock()); err != nil {
+		t.Fatal(err)
+	}
+	if removed != 1 || bc.PoolSize() != 0 {
+		t.Errorf("removed %d, pool size %d, want transaction mined by peer removed", removed, bc.PoolSize())
+	}
+}
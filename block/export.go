@@ -0,0 +1,36 @@
+package block
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+// ExportCSV is to write one csv row per transaction with a header row.
+func (bc *Blockchain) ExportCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"block_index", "timestamp", "sender_blockchain_address",
+		"recipient_blockchain_address", "value"}); err != nil {
+		return err
+	}
+	err := bc.Iterate(func(index int, b *Block) error {
+		for _, t := range b.transactions {
+			record := []string{
+				strconv.Itoa(index),
+				strconv.FormatInt(b.timestamp, 10),
+				t.senderBlockchainAddress,
+				t.recipientBlockchainAddress,
+				strconv.FormatFloat(float64(t.value), 'f', -1, 32),
+			}
+			if err := cw.Write(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,33 @@
+package block
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"goblockchain/wallet"
+	"testing"
+)
+
+func TestExportCSV(t *testing.T) {
+	bc := newTestBlockchain(t, WithGenesisAllocations(map[string]float32{"1Alice": 0.1}))
+	miner := wallet.NewWallet().BlockchainAddress()
+	bc.blockchainAddress = miner
+	bc.Mining()
+
+	var buf bytes.Buffer
+	if err := bc.ExportCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"block_index", "timestamp", "sender_blockchain_address", "recipient_blockchain_address", "value"},
+		{"0", fmt.Sprint(bc.chain[0].timestamp), MiningSender, "1Alice", "0.1"},
+		{"1", fmt.Sprint(bc.chain[1].timestamp), MiningSender, miner, "1"},
+	}
+	if fmt.Sprint(records) != fmt.Sprint(want) {
+		t.Errorf("records = %v, want %v", records, want)
+	}
+}
@@ -0,0 +1,57 @@
+package block
+
+import (
+	"errors"
+	"fmt"
+)
+
+// GenesisPreviousHash is previous hash of the genesis block, the hash of an empty Block.
+var GenesisPreviousHash = (&Block{}).Hash()
+
+// IsGenesis is to check block is a genesis block: its previous hash is
+// GenesisPreviousHash and its nonce is 0, as it isn't mined. Mined blocks
+// link to the block before them, so they never report true.
+func (b *Block) IsGenesis() bool {
+	return b.previousHash == GenesisPreviousHash && b.nonce == 0
+}
+
+// ErrGenesisMismatch is returned by CheckGenesis when genesis hash isn't the pinned one.
+var ErrGenesisMismatch = errors.New("genesis hash mismatch")
+
+// WithGenesisTimestamp is option to set genesis block's timestamp (unix nanoseconds)
+// instead of the time it's created, so every node builds the same genesis.
+func WithGenesisTimestamp(timestamp int64) Option {
+	return func(bc *Blockchain) {
+		bc.genesisTimestamp = timestamp
+	}
+}
+
+// WithGenesisHash is option to pin the expected genesis hash checked by CheckGenesis.
+func WithGenesisHash(hash [32]byte) Option {
+	return func(bc *Blockchain) {
+		bc.pinnedGenesisHash = &hash
+	}
+}
+
+// GenesisHash is to return hash of the genesis block, or false if it's pruned.
+func (bc *Blockchain) GenesisHash() ([32]byte, bool) {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	if len(bc.chain) == 0 || !bc.chain[0].IsGenesis() {
+		return [32]byte{}, false
+	}
+	return bc.chain[0].Hash(), true
+}
+
+// CheckGenesis is to return ErrGenesisMismatch if genesis hash isn't the one
+// pinned by WithGenesisHash. Without a pin, or once genesis is pruned, it passes.
+func (bc *Blockchain) CheckGenesis() error {
+	if bc.pinnedGenesisHash == nil {
+		return nil
+	}
+	hash, ok := bc.GenesisHash()
+	if !ok || hash == *bc.pinnedGenesisHash {
+		return nil
+	}
+	return fmt.Errorf("%w: got %x, want %x", ErrGenesisMismatch, hash, *bc.pinnedGenesisHash)
+}
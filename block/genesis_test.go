@@ -0,0 +1,112 @@
+package block
+
+import (
+	"errors"
+	"goblockchain/wallet"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenesisAllocations(t *testing.T) {
+	alice, bob := wallet.NewWallet(), wallet.NewWallet()
+	allocations := map[string]float32{alice.BlockchainAddress(): 100, bob.BlockchainAddress(): 50}
+	bc := newTestBlockchain(t, WithGenesisAllocations(allocations))
+	other := newTestBlockchain(t, WithGenesisAllocations(allocations))
+
+	if bc.chain[0].MerkleRoot() != other.chain[0].MerkleRoot() {
+		t.Error("genesis transactions differ between nodes")
+	}
+	for address, want := range allocations {
+		if got := bc.CalculateTotalAmount(address); got != want {
+			t.Errorf("balance of %s = %v, want %v", address, got, want)
+		}
+	}
+	if got := bc.TotalSupply(); got != 150 {
+		t.Errorf("total supply = %v, want 150", got)
+	}
+	bc.Mining()
+	if got := bc.TotalSupply(); got != 150+MiningReward {
+		t.Errorf("total supply after mining = %v, want %v", got, 150+MiningReward)
+	}
+}
+
+func TestGenesisAllocationsSkipCoinbaseMaturity(t *testing.T) {
+	alice := wallet.NewWallet()
+	bc := newTestBlockchain(t, WithGenesisAllocations(map[string]float32{alice.BlockchainAddress(): 10}))
+	bc.SetCoinbaseMaturity(100)
+
+	if got := bc.CalculateTotalAmount(alice.BlockchainAddress()); got != 10 {
+		t.Errorf("balance = %v, want the allocation spendable at once", got)
+	}
+	if !sendTransaction(bc, alice, "1Bob", 4) {
+		t.Fatal("spending allocation rejected")
+	}
+	bc.blockchainAddress = alice.BlockchainAddress()
+	bc.Mining()
+	if got := bc.CalculateTotalAmount(alice.BlockchainAddress()); got != 6 {
+		t.Errorf("balance = %v, want 6 with the immature reward excluded", got)
+	}
+}
+
+func TestGenesisTimestamp(t *testing.T) {
+	opts := []Option{WithGenesisTimestamp(1700000000000000000), WithGenesisAllocations(map[string]float32{"1Alice": 10})}
+	bc := newTestBlockchain(t, opts...)
+	other := newTestBlockchain(t, opts...)
+
+	hash, ok := bc.GenesisHash()
+	if !ok {
+		t.Fatal("genesis hash unavailable")
+	}
+	if otherHash, _ := other.GenesisHash(); otherHash != hash {
+		t.Errorf("genesis hashes %x and %x differ", hash, otherHash)
+	}
+	if got := bc.chain[0].transactions[0].timestamp; got != 1700000000000000000 {
+		t.Errorf("allocation timestamp = %d, want genesis timestamp", got)
+	}
+}
+
+func TestCheckGenesis(t *testing.T) {
+	bc := newTestBlockchain(t, WithGenesisTimestamp(1))
+	hash, _ := bc.GenesisHash()
+	if err := bc.CheckGenesis(); err != nil {
+		t.Errorf("unpinned genesis: %v", err)
+	}
+	if err := newTestBlockchain(t, WithGenesisTimestamp(1), WithGenesisHash(hash)).CheckGenesis(); err != nil {
+		t.Errorf("pinned genesis: %v", err)
+	}
+	if err := newTestBlockchain(t, WithGenesisTimestamp(2), WithGenesisHash(hash)).CheckGenesis(); !errors.Is(err, ErrGenesisMismatch) {
+		t.Errorf("error = %v, want ErrGenesisMismatch", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "chain.json")
+	if err := bc.SaveToFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadFromFile(path, WithGenesisHash([32]byte{1})); !errors.Is(err, ErrGenesisMismatch) {
+		t.Errorf("load error = %v, want ErrGenesisMismatch", err)
+	}
+}
+
+func TestIsGenesis(t *testing.T) {
+	bc := newTestBlockchain(t)
+	bc.Mining()
+	if !bc.chain[0].IsGenesis() || bc.chain[1].IsGenesis() {
+		t.Fatal("only the first block should be genesis")
+	}
+
+	// A genesis block can't follow another block.
+	other := newTestBlockchain(t, WithGenesisAllocations(map[string]float32{"1Mallory": 1000}))
+	if bc.ValidChain([]*Block{bc.chain[0], other.chain[0]}) {
+		t.Error("chain with a second genesis block is valid")
+	}
+
+	for i := 0; i < PruneSafetyDepth+1; i++ {
+		bc.Mining()
+	}
+	if err := bc.Prune(PruneSafetyDepth); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := bc.GenesisHash(); ok {
+		t.Error("genesis hash of a pruned chain")
+	}
+}
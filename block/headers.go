@@ -0,0 +1,99 @@
+package block
+
+import (
+	"encoding/json"
+	"fmt"
+	"goblockchain/utils"
+)
+
+// MaxHeaders is number of headers Headers returns at most, so light clients
+// page through long chains.
+const MaxHeaders = 2000
+
+// Headers is to return headers of up to MaxHeaders blocks from height from,
+// or from the oldest kept block if from is pruned, for light clients to
+// validate proof of work without transactions.
+func (bc *Blockchain) Headers(from int) []BlockHeader {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	if from < bc.prunedHeight {
+		from = bc.prunedHeight
+	}
+	headers := make([]BlockHeader, 0)
+	for i := from - bc.prunedHeight; i < len(bc.chain) && len(headers) < MaxHeaders; i++ {
+		headers = append(headers, *bc.chain[i].Header())
+	}
+	return headers
+}
+
+// ValidHeaders is to check each header links to the one before it and,
+// unless it's the genesis header, records at least the chain's difficulty
+// and satisfies proof of work at it, as VerifyBlock checks blocks. The
+// first header's previous hash isn't checked.
+func (bc *Blockchain) ValidHeaders(headers []BlockHeader) bool {
+	for i := range headers {
+		h := &headers[i]
+		if i > 0 && h.previousHash != headers[i-1].Hash() {
+			return false
+		}
+		genesis := h.previousHash == GenesisPreviousHash && h.nonce == 0
+		if !genesis && (h.difficulty < bc.difficulty || !bc.ValidHeaderProof(h)) {
+			return false
+		}
+	}
+	return true
+}
+
+// MarshalJSON is override BlockHeader's marshaljson. hash is derived and
+// ignored by UnmarshalJSON.
+func (h BlockHeader) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Timestamp    int64  `json:"timestamp"`
+		Difficulty   int    `json:"difficulty"`
+		Nonce        uint64 `json:"nonce"`
+		ExtraNonce   uint64 `json:"extra_nonce,omitempty"`
+		PreviousHash string `json:"previous_hash"`
+		MerkleRoot   string `json:"merkle_root"`
+		Hash         string `json:"hash"`
+	}{
+		Timestamp:    h.timestamp,
+		Difficulty:   h.difficulty,
+		Nonce:        h.nonce,
+		ExtraNonce:   h.extraNonce,
+		PreviousHash: utils.HashToHex(h.previousHash),
+		MerkleRoot:   utils.HashToHex(h.merkleRoot),
+		Hash:         utils.HashToHex(h.Hash()),
+	})
+}
+
+// UnmarshalJSON is override BlockHeader's unmarshal json.
+func (h *BlockHeader) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Timestamp    int64  `json:"timestamp"`
+		Difficulty   int    `json:"difficulty"`
+		Nonce        uint64 `json:"nonce"`
+		ExtraNonce   uint64 `json:"extra_nonce"`
+		PreviousHash string `json:"previous_hash"`
+		MerkleRoot   string `json:"merkle_root"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	previousHash, err := utils.HexToHash(v.PreviousHash)
+	if err != nil {
+		return fmt.Errorf("invalid previous_hash: %w", err)
+	}
+	merkleRoot, err := utils.HexToHash(v.MerkleRoot)
+	if err != nil {
+		return fmt.Errorf("invalid merkle_root: %w", err)
+	}
+	*h = BlockHeader{
+		timestamp:    v.Timestamp,
+		difficulty:   v.Difficulty,
+		nonce:        v.Nonce,
+		extraNonce:   v.ExtraNonce,
+		previousHash: previousHash,
+		merkleRoot:   merkleRoot,
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package block
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+func TestHeaders(t *testing.T) {
+	bc := newTestBlockchain(t)
+	for i := 0; i < 4; i++ {
+		bc.Mining()
+	}
+
+	headers := bc.Headers(0)
+	if len(headers) != 5 {
+		t.Fatalf("headers = %d, want 5", len(headers))
+	}
+	for i := range headers {
+		if headers[i].Hash() != bc.chain[i].Hash() {
+			t.Errorf("header %d hash differs from the block's", i)
+		}
+		if i > 0 && headers[i].previousHash != headers[i-1].Hash() {
+			t.Errorf("header %d doesn't link to the one before it", i)
+		}
+		if i > 0 && !bc.ValidHeaderProof(&headers[i]) {
+			t.Errorf("header %d fails proof of work", i)
+		}
+	}
+	if !bc.ValidHeaders(headers) {
+		t.Error("served headers aren't valid")
+	}
+	if from := bc.Headers(3); len(from) != 2 || from[0].Hash() != bc.chain[3].Hash() || !bc.ValidHeaders(from) {
+		t.Errorf("headers from 3 = %d headers", len(from))
+	}
+	if past := bc.Headers(bc.Height()); len(past) != 0 {
+		t.Errorf("headers past the tip = %d, want 0", len(past))
+	}
+}
+
+func TestValidHeaders(t *testing.T) {
+	bc := newTestBlockchain(t)
+	for i := 0; i < 3; i++ {
+		bc.Mining()
+	}
+	tests := []struct {
+		name   string
+		tamper func(headers []BlockHeader)
+	}{
+		{"swapped", func(headers []BlockHeader) { headers[1], headers[2] = headers[2], headers[1] }},
+		{"nonce", func(headers []BlockHeader) { headers[3].nonce++ }},
+		{"below chain difficulty", func(headers []BlockHeader) { headers[3].difficulty = 0 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := bc.Headers(0)
+			tt.tamper(headers)
+			if bc.ValidHeaders(headers) {
+				t.Error("tampered headers are valid")
+			}
+		})
+	}
+}
+
+func TestBlockHeaderJSON(t *testing.T) {
+	bc := newTestBlockchain(t)
+	bc.MineTo(bc.blockchainAddress, 7)
+	h := bc.LastBlock().Header()
+	m, err := json.Marshal(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got BlockHeader
+	if err := json.Unmarshal(m, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != *h || got.Hash() != h.Hash() {
+		t.Errorf("round trip = %+v, want %+v", got, *h)
+	}
+	for _, bad := range []string{`{"previous_hash":"xyz"}`, `{"previous_hash":"` + fmt.Sprintf("%064x", 0) + `","merkle_root":"xyz"}`} {
+		if err := json.Unmarshal([]byte(bad), &got); err == nil {
+			t.Errorf("%s decoded", bad)
+		}
+	}
+}
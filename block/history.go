@@ -0,0 +1,80 @@
+package block
+
+import "goblockchain/utils"
+
+// Directions of a HistoryEntry relative to the queried address.
+const (
+	HistoryDirectionIn   = "in"
+	HistoryDirectionOut  = "out"
+	HistoryDirectionSelf = "self"
+)
+
+// HistoryEntry is a transaction involving an address, as a line of its
+// statement. BlockHeight is -1 and Confirmed false while it's pending, and
+// Timestamp is then when the pool received it instead of the block time.
+type HistoryEntry struct {
+	TransactionHash string  `json:"transaction_hash"`
+	Direction       string  `json:"direction"`
+	Counterparty    string  `json:"counterparty"`
+	Value           float32 `json:"value"`
+	BlockHeight     int     `json:"block_height"`
+	Timestamp       int64   `json:"timestamp"`
+	Confirmed       bool    `json:"confirmed"`
+}
+
+// History is to return up to limit transactions involving address, newest
+// first, skipping the first from. Pending transactions come first, then
+// the chain from the tip down.
+func (bc *Blockchain) History(address string, from, limit int) []HistoryEntry {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+
+	entries := make([]HistoryEntry, 0)
+	for i := len(bc.transactionPool) - 1; i >= 0; i-- {
+		t := bc.transactionPool[i]
+		if t.senderBlockchainAddress == MiningSender {
+			continue
+		}
+		if e, ok := historyEntry(t, address); ok {
+			e.BlockHeight = -1
+			e.Timestamp = t.received.UnixNano()
+			entries = append(entries, e)
+		}
+	}
+	for i := len(bc.chain) - 1; i >= 0; i-- {
+		b := bc.chain[i]
+		for j := len(b.transactions) - 1; j >= 0; j-- {
+			if e, ok := historyEntry(b.transactions[j], address); ok {
+				e.BlockHeight = bc.prunedHeight + i
+				e.Timestamp = b.timestamp
+				e.Confirmed = true
+				entries = append(entries, e)
+			}
+		}
+	}
+
+	if from < 0 || from >= len(entries) || limit <= 0 {
+		return []HistoryEntry{}
+	}
+	entries = entries[from:]
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries
+}
+
+// historyEntry is to return entry of t seen from address, false if t doesn't involve it.
+func historyEntry(t *Transaction, address string) (HistoryEntry, bool) {
+	e := HistoryEntry{TransactionHash: utils.HashToHex(t.Hash()), Value: t.value}
+	switch {
+	case t.senderBlockchainAddress == address && t.recipientBlockchainAddress == address:
+		e.Direction, e.Counterparty = HistoryDirectionSelf, address
+	case t.senderBlockchainAddress == address:
+		e.Direction, e.Counterparty = HistoryDirectionOut, t.recipientBlockchainAddress
+	case t.recipientBlockchainAddress == address:
+		e.Direction, e.Counterparty = HistoryDirectionIn, t.senderBlockchainAddress
+	default:
+		return e, false
+	}
+	return e, true
+}
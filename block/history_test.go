@@ -0,0 +1,78 @@
+package block
+
+import (
+	"goblockchain/wallet"
+	"testing"
+)
+
+func TestAddressHistory(t *testing.T) {
+	alice, bob := wallet.NewWallet(), wallet.NewWallet()
+	bc := newTestBlockchain(t, WithGenesisAllocations(map[string]float32{alice.BlockchainAddress(): 10}))
+	if !sendTransaction(bc, alice, bob.BlockchainAddress(), 3) {
+		t.Fatal("transaction to bob rejected")
+	}
+	bc.Mining()
+	if !sendTransaction(bc, bob, alice.BlockchainAddress(), 1) {
+		t.Fatal("transaction to alice rejected")
+	}
+
+	got := bc.History(alice.BlockchainAddress(), 0, 10)
+	want := []struct {
+		direction    string
+		counterparty string
+		value        float32
+		height       int
+		confirmed    bool
+	}{
+		{HistoryDirectionIn, bob.BlockchainAddress(), 1, -1, false},
+		{HistoryDirectionOut, bob.BlockchainAddress(), 3, 1, true},
+		{HistoryDirectionIn, MiningSender, 10, 0, true},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("history has %d entries, want %d: %+v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		e := got[i]
+		if e.Direction != w.direction || e.Counterparty != w.counterparty || e.Value != w.value ||
+			e.BlockHeight != w.height || e.Confirmed != w.confirmed {
+			t.Errorf("entry %d = %+v, want %+v", i, e, w)
+		}
+	}
+	if got[0].Timestamp == 0 {
+		t.Error("pending entry has no received time")
+	}
+	if got[1].Timestamp != bc.chain[1].timestamp {
+		t.Errorf("confirmed entry timestamp = %d, want block time %d", got[1].Timestamp, bc.chain[1].timestamp)
+	}
+
+	tests := []struct {
+		name        string
+		from, limit int
+		want        int
+	}{
+		{"page", 1, 1, 1},
+		{"past the end", 3, 10, 0},
+		{"negative from", -1, 10, 0},
+		{"zero limit", 0, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if page := bc.History(alice.BlockchainAddress(), tt.from, tt.limit); len(page) != tt.want {
+				t.Errorf("History(%d, %d) has %d entries, want %d", tt.from, tt.limit, len(page), tt.want)
+			}
+		})
+	}
+	if page := bc.History(alice.BlockchainAddress(), 1, 1); page[0].Value != 3 {
+		t.Errorf("page from 1 starts at %+v, want the transfer to bob", page[0])
+	}
+}
+
+func TestAddressHistorySelf(t *testing.T) {
+	e, ok := historyEntry(NewTransaction("1Alice", "1Alice", 1), "1Alice")
+	if !ok || e.Direction != HistoryDirectionSelf || e.Counterparty != "1Alice" {
+		t.Errorf("self transfer = %+v, %v", e, ok)
+	}
+	if _, ok := historyEntry(NewTransaction("1Bob", "1Carol", 1), "1Alice"); ok {
+		t.Error("unrelated transaction in history")
+	}
+}
@@ -0,0 +1,190 @@
+package block
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"goblockchain/utils"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Headers of announcements to peers, naming the node identity, the time the
+// request was signed at in unix nanoseconds and the node's signature of the
+// request method, path, timestamp and body.
+const (
+	NodeIDHeader        = "X-Node-ID"
+	NodeTimestampHeader = "X-Node-Timestamp"
+	NodeSignatureHeader = "X-Node-Signature"
+)
+
+// MaxAnnouncementAge is how far an announcement's timestamp may be from the
+// receiving node's clock, so a captured request can't be replayed later.
+// Within it, replays are caught by the announcements seen.
+const MaxAnnouncementAge = 5 * time.Minute
+
+// MaxSeenAnnouncements is max number of announcements remembered to reject
+// replays until they're stale.
+const MaxSeenAnnouncements = 10000
+
+// Errors returned by VerifyAnnouncement.
+var (
+	ErrInvalidAnnouncement  = errors.New("invalid announcement signature")
+	ErrStaleAnnouncement    = errors.New("stale announcement")
+	ErrUnknownNode          = errors.New("unknown node")
+	ErrReplayedAnnouncement = errors.New("replayed announcement")
+)
+
+// WithIdentityKey is option to set node identity key signing announcements
+// to peers, instead of a key generated at start.
+func WithIdentityKey(key *ecdsa.PrivateKey) Option {
+	return func(bc *Blockchain) {
+		bc.identityKey = key
+	}
+}
+
+// newIdentityKey is to return new node identity key.
+func newIdentityKey() *ecdsa.PrivateKey {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	return key
+}
+
+// NodeID is to return hex of node identity public key. It's separate from
+// wallet keys and only authenticates the node's announcements.
+func (bc *Blockchain) NodeID() string {
+	return utils.PublicKeyString(&bc.identityKey.PublicKey)
+}
+
+// SetTrustedNodes is set node IDs announcements are accepted from. Trust is
+// opt-in: empty, the default, only verifies signatures, so an unknown node
+// signing with a key it generated is accepted; signatures then only bind a
+// request to the identity that sent it.
+func (bc *Blockchain) SetTrustedNodes(nodeIDs []string) {
+	trusted := make(map[string]bool, len(nodeIDs))
+	for _, id := range nodeIDs {
+		trusted[id] = true
+	}
+	bc.muxPeers.Lock()
+	defer bc.muxPeers.Unlock()
+	bc.trustedNodes = trusted
+}
+
+// TrustsNodes is to check trusted nodes are set, so the node ID of a
+// verified announcement names a node this node knows rather than any key
+// the sender generated.
+func (bc *Blockchain) TrustsNodes() bool {
+	bc.muxPeers.Lock()
+	defer bc.muxPeers.Unlock()
+	return len(bc.trustedNodes) > 0
+}
+
+// announcementHash is to return hash of the announcement fields signed by
+// the node identity key, each but the body followed by a newline.
+func announcementHash(method string, path string, timestamp string, body []byte) [32]byte {
+	h := sha256.New()
+	for _, f := range []string{method, path, timestamp} {
+		h.Write([]byte(f))
+		h.Write([]byte{'\n'})
+	}
+	h.Write(body)
+	var sum [32]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+// signAnnouncement is to return signature of the request method, path,
+// timestamp and body with node identity key.
+func (bc *Blockchain) signAnnouncement(method string, path string, timestamp string, body []byte) string {
+	h := announcementHash(method, path, timestamp, body)
+	r, s, err := ecdsa.Sign(rand.Reader, bc.identityKey, h[:])
+	if err != nil {
+		return ""
+	}
+	return (&utils.Signature{R: r, S: s}).String()
+}
+
+// SignRequest is to set headers of req naming the node identity and signing
+// req's method, path, the current time and body, as peers' announcements
+// are signed.
+func (bc *Blockchain) SignRequest(req *http.Request, body []byte) {
+	timestamp := strconv.FormatInt(bc.clock.Now().UnixNano(), 10)
+	req.Header.Set(NodeIDHeader, bc.NodeID())
+	req.Header.Set(NodeTimestampHeader, timestamp)
+	req.Header.Set(NodeSignatureHeader, bc.signAnnouncement(req.Method, req.URL.RequestURI(), timestamp, body))
+}
+
+// VerifyAnnouncement is to check signature is nodeID's signature of the
+// request method, path, timestamp and body, returning ErrInvalidAnnouncement
+// if it isn't, ErrStaleAnnouncement if timestamp is more than
+// MaxAnnouncementAge from now, ErrUnknownNode if trusted nodes are set and
+// nodeID isn't one of them, or ErrReplayedAnnouncement if it was accepted
+// before. Without trusted nodes any nodeID is accepted.
+func (bc *Blockchain) VerifyAnnouncement(nodeID string, signature string, timestamp string,
+	method string, path string, body []byte) error {
+	publicKey, err := utils.ParsePublicKey(nodeID)
+	if err != nil {
+		return ErrInvalidAnnouncement
+	}
+	if _, err := hex.DecodeString(signature); err != nil || len(signature) != 128 {
+		return ErrInvalidAnnouncement
+	}
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidAnnouncement
+	}
+	s := utils.SignatureFromString(signature)
+	h := announcementHash(method, path, timestamp, body)
+	if !ecdsa.Verify(publicKey, h[:], s.R, s.S) {
+		return ErrInvalidAnnouncement
+	}
+	age := bc.clock.Now().Sub(time.Unix(0, ts))
+	if age > MaxAnnouncementAge || age < -MaxAnnouncementAge {
+		return ErrStaleAnnouncement
+	}
+
+	bc.muxPeers.Lock()
+	defer bc.muxPeers.Unlock()
+	if len(bc.trustedNodes) > 0 && !bc.trustedNodes[utils.PublicKeyString(publicKey)] {
+		return ErrUnknownNode
+	}
+	// Seen announcements are keyed by the signed hash rather than the
+	// signature, which can be altered and still verify.
+	seen := sha256.Sum256(append([]byte(utils.PublicKeyString(publicKey)+"\n"), h[:]...))
+	if _, ok := bc.seenAnnouncements[seen]; ok {
+		return ErrReplayedAnnouncement
+	}
+	bc.rememberAnnouncement(seen, time.Unix(0, ts).Add(MaxAnnouncementAge))
+	return nil
+}
+
+// rememberAnnouncement is to remember announcement seen until staleAt, when
+// it's rejected as stale anyway. At MaxSeenAnnouncements, stale ones are
+// dropped, then the one closest to stale. muxPeers must be held.
+func (bc *Blockchain) rememberAnnouncement(seen [32]byte, staleAt time.Time) {
+	if bc.seenAnnouncements == nil {
+		bc.seenAnnouncements = make(map[[32]byte]time.Time)
+	}
+	if len(bc.seenAnnouncements) >= MaxSeenAnnouncements {
+		now := bc.clock.Now()
+		for k, t := range bc.seenAnnouncements {
+			if now.After(t) {
+				delete(bc.seenAnnouncements, k)
+			}
+		}
+	}
+	if len(bc.seenAnnouncements) >= MaxSeenAnnouncements {
+		var oldest [32]byte
+		var oldestAt time.Time
+		for k, t := range bc.seenAnnouncements {
+			if oldestAt.IsZero() || t.Before(oldestAt) {
+				oldest, oldestAt = k, t
+			}
+		}
+		delete(bc.seenAnnouncements, oldest)
+	}
+	bc.seenAnnouncements[seen] = staleAt
+}
@@ -0,0 +1,89 @@
+package block
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestVerifyAnnouncement(t *testing.T) {
+	bc, peer := newTestBlockchain(t), newTestBlockchain(t)
+	body := []byte(`{"nonce":1}`)
+	now := strconv.FormatInt(time.Now().UnixNano(), 10)
+	stale := strconv.FormatInt(time.Now().Add(-2*MaxAnnouncementAge).UnixNano(), 10)
+	signature := peer.signAnnouncement(http.MethodPost, "/block", now, body)
+
+	tests := []struct {
+		name      string
+		nodeID    string
+		signature string
+		timestamp string
+		method    string
+		path      string
+		body      []byte
+		want      error
+	}{
+		// Without trusted nodes only signatures are verified, so a node
+		// this one doesn't know is accepted, once.
+		{"unknown node", peer.NodeID(), signature, now, http.MethodPost, "/block", body, nil},
+		{"replayed", peer.NodeID(), signature, now, http.MethodPost, "/block", body, ErrReplayedAnnouncement},
+		{"forged body", peer.NodeID(), signature, now, http.MethodPost, "/block", []byte(`{"nonce":2}`), ErrInvalidAnnouncement},
+		{"other endpoint", peer.NodeID(), signature, now, http.MethodPut, "/consensus", body, ErrInvalidAnnouncement},
+		{"other identity", bc.NodeID(), signature, now, http.MethodPost, "/block", body, ErrInvalidAnnouncement},
+		{"unsigned", "", "", "", http.MethodPost, "/block", body, ErrInvalidAnnouncement},
+		{"stale", peer.NodeID(), peer.signAnnouncement(http.MethodPost, "/block", stale, body), stale, http.MethodPost, "/block", body, ErrStaleAnnouncement},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := bc.VerifyAnnouncement(tt.nodeID, tt.signature, tt.timestamp, tt.method, tt.path, tt.body)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	body = []byte(`{"nonce":3}`)
+	signature = peer.signAnnouncement(http.MethodPost, "/block", now, body)
+	bc.SetTrustedNodes([]string{bc.NodeID()})
+	if err := bc.VerifyAnnouncement(peer.NodeID(), signature, now, http.MethodPost, "/block", body); !errors.Is(err, ErrUnknownNode) {
+		t.Errorf("untrusted node: error = %v, want ErrUnknownNode", err)
+	}
+	bc.SetTrustedNodes([]string{bc.NodeID(), peer.NodeID()})
+	if err := bc.VerifyAnnouncement(peer.NodeID(), signature, now, http.MethodPost, "/block", body); err != nil {
+		t.Errorf("trusted node: error = %v", err)
+	}
+}
+
+func TestSignRequest(t *testing.T) {
+	bc, peer := newTestBlockchain(t), newTestBlockchain(t)
+	body := []byte(`{}`)
+	req, _ := http.NewRequest(http.MethodPut, "http://127.0.0.1:5000/consensus?all=1", nil)
+	peer.SignRequest(req, body)
+	err := bc.VerifyAnnouncement(req.Header.Get(NodeIDHeader), req.Header.Get(NodeSignatureHeader),
+		req.Header.Get(NodeTimestampHeader), req.Method, "/consensus?all=1", body)
+	if err != nil {
+		t.Errorf("signed request: %v", err)
+	}
+}
+
+func TestAnnouncementAgeByClock(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1700000000, 0))
+	bc, peer := newTestBlockchain(t, WithClock(clock)), newTestBlockchain(t, WithClock(clock))
+	req, _ := http.NewRequest(http.MethodPut, "http://127.0.0.1:5000/consensus", nil)
+	peer.SignRequest(req, nil)
+	verify := func() error {
+		return bc.VerifyAnnouncement(req.Header.Get(NodeIDHeader), req.Header.Get(NodeSignatureHeader),
+			req.Header.Get(NodeTimestampHeader), req.Method, "/consensus", nil)
+	}
+
+	clock.Advance(MaxAnnouncementAge)
+	if err := verify(); err != nil {
+		t.Errorf("announcement at max age: %v", err)
+	}
+	clock.Advance(time.Second)
+	if err := verify(); !errors.Is(err, ErrStaleAnnouncement) {
+		t.Errorf("error = %v, want ErrStaleAnnouncement", err)
+	}
+}
@@ -0,0 +1,34 @@
+package block
+
+import (
+	"goblockchain/wallet"
+	"testing"
+)
+
+func TestCoinbaseMaturity(t *testing.T) {
+	bc := newTestBlockchain(t)
+	miner := wallet.NewWallet()
+	bc.blockchainAddress = miner.BlockchainAddress()
+	bc.SetCoinbaseMaturity(3)
+
+	bc.Mining()
+	bc.Mining()
+	if got := bc.CalculateTotalAmount(miner.BlockchainAddress()); got != 0 {
+		t.Errorf("balance with immature rewards = %v, want 0", got)
+	}
+	if sendTransaction(bc, miner, "1Alice", MiningReward) {
+		t.Error("immature reward spent")
+	}
+	bc.Mining()
+	if got := bc.CalculateTotalAmount(miner.BlockchainAddress()); got != MiningReward {
+		t.Errorf("balance with one mature reward = %v, want %v", got, MiningReward)
+	}
+	if !sendTransaction(bc, miner, "1Alice", MiningReward) {
+		t.Error("mature reward rejected")
+	}
+
+	bc.SetCoinbaseMaturity(0)
+	if got := bc.CalculateTotalAmount(miner.BlockchainAddress()); got != 3*MiningReward {
+		t.Errorf("balance without maturity = %v, want %v", got, 3*MiningReward)
+	}
+}
@@ -0,0 +1,322 @@
+package block
+
+import "time"
+
+// MempoolSweepInterval is interval StartSweepExpired evicts expired transactions at.
+const MempoolSweepInterval = time.Minute
+
+// MempoolSummary is summary of the transaction pool.
+type MempoolSummary struct {
+	Count      int           `json:"count"`
+	TotalValue float32       `json:"total_value"`
+	OldestAge  time.Duration `json:"-"`
+}
+
+// PoolSize is to return number of transactions in the pool.
+func (bc *Blockchain) PoolSize() int {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	return len(bc.transactionPool)
+}
+
+// MempoolSummary is to return count, total value and age of the oldest
+// transaction of the pool.
+func (bc *Blockchain) MempoolSummary() MempoolSummary {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+
+	s := MempoolSummary{Count: len(bc.transactionPool)}
+	var oldest time.Time
+	for _, t := range bc.transactionPool {
+		s.TotalValue += t.value
+		if oldest.IsZero() || t.received.Before(oldest) {
+			oldest = t.received
+		}
+	}
+	if !oldest.IsZero() {
+		s.OldestAge = bc.clock.Now().Sub(oldest)
+	}
+	return s
+}
+
+// addToPool is to append transaction to the pool, stamping when it was received.
+func (bc *Blockchain) addToPool(t *Transaction) {
+	t.received = bc.clock.Now()
+	bc.transactionPool = append(bc.transactionPool, t)
+	if t.senderBlockchainAddress != MiningSender {
+		if bc.poolIDs == nil {
+			bc.poolIDs = make(map[[32]byte]bool)
+		}
+		bc.poolIDs[t.Hash()] = true
+	}
+	bc.metrics.SetTransactionPoolSize(len(bc.transactionPool))
+	bc.queueMempoolChange(t, nil)
+	if t.senderBlockchainAddress != MiningSender {
+		bc.restartMining(t)
+	}
+}
+
+// pendingOutflow is to return total value sender pays in pooled transactions.
+func (bc *Blockchain) pendingOutflow(sender string) float32 {
+	var total float32
+	for _, t := range bc.transactionPool {
+		if t.senderBlockchainAddress == sender {
+			total += t.value
+		}
+	}
+	return total
+}
+
+// setPool is to replace the transaction pool, rebuilding the set of pooled transaction IDs.
+func (bc *Blockchain) setPool(pool []*Transaction) {
+	bc.transactionPool = pool
+	bc.poolIDs = make(map[[32]byte]bool, len(pool))
+	for _, t := range pool {
+		if t.senderBlockchainAddress != MiningSender {
+			bc.poolIDs[t.Hash()] = true
+		}
+	}
+	bc.metrics.SetTransactionPoolSize(len(bc.transactionPool))
+}
+
+// rejectTransaction is to log and count a transaction rejected for err,
+// naming the address at key, and return err.
+func (bc *Blockchain) rejectTransaction(err error, reason string, key string, address string) error {
+	bc.logger.Error("add transaction", "action", "add_transaction", "status", "fail", "reason", err, key, address)
+	bc.metrics.IncRejectedTransactions(reason)
+	return err
+}
+
+// exceedsPendingLimit is to report whether pooling a transaction of value
+// from sender would put it over the per sender pending limits.
+func (bc *Blockchain) exceedsPendingLimit(sender string, value float32) bool {
+	if bc.maxPendingValue <= 0 && bc.maxPendingCount <= 0 {
+		return false
+	}
+	count, total := 1, value
+	for _, t := range bc.transactionPool {
+		if t.senderBlockchainAddress == sender {
+			count++
+			total += t.value
+		}
+	}
+	return (bc.maxPendingValue > 0 && total > bc.maxPendingValue) ||
+		(bc.maxPendingCount > 0 && count > bc.maxPendingCount)
+}
+
+// isDuplicate is to report whether transaction with the same ID is already
+// pooled or confirmed, so a signed transaction can't be replayed. The ID
+// commits to the transaction's timestamp, so repeating a payment is a new
+// transaction.
+func (bc *Blockchain) isDuplicate(t *Transaction) bool {
+	id := t.Hash()
+	return bc.poolIDs[id] || bc.confirmedIDs[id]
+}
+
+// confirmBlock is to add IDs of block's transactions to the confirmed IDs
+// isDuplicate checks. Mining rewards can't be submitted, so they're left out.
+func (bc *Blockchain) confirmBlock(b *Block) {
+	if bc.confirmedIDs == nil {
+		bc.confirmedIDs = make(map[[32]byte]bool)
+	}
+	for _, t := range b.transactions {
+		if t.senderBlockchainAddress != MiningSender {
+			bc.confirmedIDs[t.Hash()] = true
+		}
+	}
+}
+
+// indexConfirmed is to rebuild the confirmed IDs from IDs of pruned blocks
+// and the chain after the chain was replaced.
+func (bc *Blockchain) indexConfirmed() {
+	bc.confirmedIDs = make(map[[32]byte]bool, len(bc.prunedIDs))
+	for id := range bc.prunedIDs {
+		bc.confirmedIDs[id] = true
+	}
+	for _, b := range bc.chain {
+		bc.confirmBlock(b)
+	}
+}
+
+// recordPrunedIDs is to keep IDs of transactions of blocks being pruned, so
+// they stay confirmed, and can't be replayed, once the blocks are dropped.
+func (bc *Blockchain) recordPrunedIDs(blocks []*Block) {
+	if bc.prunedIDs == nil {
+		bc.prunedIDs = make(map[[32]byte]bool)
+	}
+	for _, b := range blocks {
+		for _, t := range b.transactions {
+			if t.senderBlockchainAddress != MiningSender {
+				bc.prunedIDs[t.Hash()] = true
+			}
+		}
+	}
+}
+
+// MempoolTTL is to return how long a transaction may wait in the pool.
+func (bc *Blockchain) MempoolTTL() time.Duration {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	return bc.mempoolTTL
+}
+
+// SetMempoolTTL is set how long a transaction may wait in the pool before
+// it's evicted. 0, the default, keeps transactions until they're mined.
+func (bc *Blockchain) SetMempoolTTL(ttl time.Duration) {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	bc.mempoolTTL = ttl
+}
+
+// SweepExpired is to evict transactions waiting in the pool longer than
+// MempoolTTL, returning the number evicted.
+func (bc *Blockchain) SweepExpired() int {
+	bc.mux.Lock()
+	n := bc.sweepExpired()
+	bc.mux.Unlock()
+	bc.flushMempoolChanges()
+	if n > 0 {
+		bc.logger.Info("sweep mempool", "action", "sweep_mempool", "status", "success", "evicted", n)
+	}
+	return n
+}
+
+// StartSweepExpired is to SweepExpired every MempoolSweepInterval.
+func (bc *Blockchain) StartSweepExpired() {
+	bc.SweepExpired()
+	_ = time.AfterFunc(MempoolSweepInterval, bc.StartSweepExpired)
+}
+
+func (bc *Blockchain) sweepExpired() int {
+	if bc.mempoolTTL <= 0 {
+		return 0
+	}
+	deadline := bc.clock.Now().Add(-bc.mempoolTTL)
+	pool := make([]*Transaction, 0, len(bc.transactionPool))
+	for _, t := range bc.transactionPool {
+		if t.senderBlockchainAddress != MiningSender && t.received.Before(deadline) {
+			bc.queueMempoolChange(nil, t)
+			continue
+		}
+		pool = append(pool, t)
+	}
+	n := len(bc.transactionPool) - len(pool)
+	if n > 0 {
+		bc.setPool(pool)
+	}
+	return n
+}
+
+// reconcileMempool is to update the pool after the chain switched from
+// oldChain to newChain. Transactions newChain confirms are removed, and ones
+// only oldChain confirmed are pooled again if their sender can still afford
+// them with their other pending transactions, so payments in rolled back
+// blocks aren't lost. Only transactions still carrying their signatures, as
+// ones pooled by this node do, can be pooled again; others are dropped, as
+// they couldn't be rebroadcast. Mining rewards of rolled back blocks are
+// dropped. Both may hold just the blocks after the fork. The caller holds
+// bc.mux.
+func (bc *Blockchain) reconcileMempool(oldChain, newChain []*Block) {
+	confirmed := make(map[[32]byte]bool)
+	for _, b := range newChain {
+		for _, t := range b.transactions {
+			confirmed[t.Hash()] = true
+		}
+	}
+	pool := make([]*Transaction, 0, len(bc.transactionPool))
+	for _, t := range bc.transactionPool {
+		if confirmed[t.Hash()] {
+			bc.queueMempoolChange(nil, t)
+		} else {
+			pool = append(pool, t)
+		}
+	}
+	bc.setPool(pool)
+
+	restored := 0
+	for _, b := range oldChain {
+		for _, t := range b.transactions {
+			id := t.Hash()
+			if t.senderBlockchainAddress == MiningSender || confirmed[id] || bc.poolIDs[id] {
+				continue
+			}
+			if len(t.signatures) == 0 {
+				bc.logger.Info("reconcile mempool", "action", "reconcile_mempool", "status", "dropped",
+					"reason", "unsigned", "sender", t.senderBlockchainAddress)
+				continue
+			}
+			if bc.calculateTotalAmount(t.senderBlockchainAddress)-bc.pendingOutflow(t.senderBlockchainAddress) < t.value {
+				bc.logger.Info("reconcile mempool", "action", "reconcile_mempool", "status", "dropped",
+					"reason", ErrInsufficientBalance, "sender", t.senderBlockchainAddress)
+				continue
+			}
+			tc := *t
+			bc.addToPool(&tc)
+			restored++
+		}
+	}
+	if restored > 0 {
+		bc.logger.Info("reconcile mempool", "action", "reconcile_mempool", "status", "success", "restored", restored)
+	}
+}
+
+// DefaultRebroadcastInterval is default interval pending transactions are
+// broadcast again at.
+const DefaultRebroadcastInterval = 5 * time.Minute
+
+// RebroadcastInterval is to return interval pending transactions are
+// broadcast again at.
+func (bc *Blockchain) RebroadcastInterval() time.Duration {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	return bc.rebroadcastInterval
+}
+
+// SetRebroadcastInterval is set interval Run broadcasts pending transactions
+// again at, so ones neighbors missed still propagate. 0 disables it.
+func (bc *Blockchain) SetRebroadcastInterval(d time.Duration) {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	bc.rebroadcastInterval = d
+}
+
+// RebroadcastPending is to broadcast pool transactions not yet in the chain
+// to neighbors again, returning the number broadcast.
+func (bc *Blockchain) RebroadcastPending() int {
+	bc.mux.RLock()
+	confirmed := make(map[[32]byte]bool)
+	for _, b := range bc.chain {
+		for _, t := range b.transactions {
+			confirmed[t.Hash()] = true
+		}
+	}
+	pending := make([]*Transaction, 0, len(bc.transactionPool))
+	for _, t := range bc.transactionPool {
+		if t.senderBlockchainAddress != MiningSender && len(t.signatures) > 0 && !confirmed[t.Hash()] {
+			pending = append(pending, t)
+		}
+	}
+	bc.mux.RUnlock()
+
+	for _, t := range pending {
+		if len(t.publicKeys) > 0 {
+			bc.broadcastMultisigTransaction(t.senderBlockchainAddress, t.recipientBlockchainAddress, t.value, t.timestamp,
+				t.publicKeys, t.threshold, t.signatures)
+		} else {
+			bc.broadcastTransaction(t.senderBlockchainAddress, t.recipientBlockchainAddress, t.value, t.timestamp,
+				t.senderPublicKey, t.signatures[0])
+		}
+	}
+	if len(pending) > 0 {
+		bc.logger.Info("rebroadcast", "action", "rebroadcast", "status", "success", "transactions", len(pending))
+	}
+	return len(pending)
+}
+
+// StartRebroadcastPending is to RebroadcastPending every rebroadcast interval.
+func (bc *Blockchain) StartRebroadcastPending() {
+	bc.RebroadcastPending()
+	if d := bc.RebroadcastInterval(); d > 0 {
+		_ = time.AfterFunc(d, bc.StartRebroadcastPending)
+	}
+}
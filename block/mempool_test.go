@@ -0,0 +1,220 @@
+package block
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"goblockchain/utils"
+	"goblockchain/wallet"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMempoolSummary(t *testing.T) {
+	alice := wallet.NewWallet()
+	bc := newTestBlockchain(t, WithGenesisAllocations(map[string]float32{alice.BlockchainAddress(): 10}))
+	if s := bc.MempoolSummary(); s.Count != 0 || s.TotalValue != 0 || s.OldestAge != 0 {
+		t.Errorf("summary of empty pool = %+v", s)
+	}
+	if !sendTransaction(bc, alice, "1Carol", 1.5) || !sendTransaction(bc, alice, "1Bob", 2) {
+		t.Fatal("transaction rejected")
+	}
+	bc.transactionPool[0].received = time.Now().Add(-time.Minute)
+
+	s := bc.MempoolSummary()
+	if s.Count != 2 || bc.PoolSize() != 2 || s.TotalValue != 3.5 {
+		t.Errorf("summary = %+v, pool size %d", s, bc.PoolSize())
+	}
+	if s.OldestAge < time.Minute || s.OldestAge > 2*time.Minute {
+		t.Errorf("oldest age = %v, want about a minute", s.OldestAge)
+	}
+}
+
+func TestMaxPendingPerSender(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  float32
+		count  int
+		values []float32
+		want   []error
+	}{
+		{"disabled", 0, 0, []float32{3, 3, 3}, []error{nil, nil, nil}},
+		{"value", 5, 0, []float32{2, 3, 0.5}, []error{nil, nil, ErrSenderPoolLimit}},
+		{"count", 0, 2, []float32{1, 1, 1}, []error{nil, nil, ErrSenderPoolLimit}},
+		{"both", 5, 2, []float32{4, 2, 1}, []error{nil, ErrSenderPoolLimit, nil}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			alice, bob := wallet.NewWallet(), wallet.NewWallet()
+			bc := newTestBlockchain(t, WithGenesisAllocations(map[string]float32{alice.BlockchainAddress(): 100, bob.BlockchainAddress(): 100}))
+			m := &countMetrics{rejected: make(map[string]int)}
+			bc.SetMetrics(m)
+			bc.SetMaxPendingPerSender(tt.value, tt.count)
+
+			rejected := 0
+			for i, value := range tt.values {
+				err := submit(bc, signedRequest(bc, alice, "1Carol", value))
+				if !errors.Is(err, tt.want[i]) {
+					t.Errorf("transaction %d of %v: error = %v, want %v", i, value, err, tt.want[i])
+				}
+				if err != nil {
+					rejected++
+				}
+			}
+			if m.rejected[RejectReasonSenderPoolLimit] != rejected {
+				t.Errorf("pool limit rejections = %d, want %d", m.rejected[RejectReasonSenderPoolLimit], rejected)
+			}
+			// Other senders have limits of their own.
+			if err := submit(bc, signedRequest(bc, bob, "1Carol", 1)); err != nil {
+				t.Errorf("other sender: %v", err)
+			}
+			// Mining frees the sender's room in the pool.
+			bc.Mining()
+			if err := submit(bc, signedRequest(bc, alice, "1Carol", 1)); err != nil {
+				t.Errorf("after mining: %v", err)
+			}
+		})
+	}
+}
+
+func TestMaxPendingPerSenderMultisig(t *testing.T) {
+	owner := wallet.NewWallet()
+	keys := []*ecdsa.PublicKey{owner.PublicKey()}
+	multisig := MultisigAddress(1, keys)
+	bc := newTestBlockchain(t, WithGenesisAllocations(map[string]float32{multisig: 100}))
+	bc.SetMaxPendingPerSender(0, 1)
+
+	for i, want := range []error{nil, ErrSenderPoolLimit} {
+		tx := wallet.NewTransaction(owner.PrivateKey(), owner.PublicKey(), multisig, "1Bob", 1)
+		tx.SetChainID(bc.ChainID())
+		err := bc.AddMultisigTransaction(multisig, "1Bob", 1, tx.Timestamp(), keys, 1, []*utils.Signature{tx.GenerateSignature()})
+		if !errors.Is(err, want) {
+			t.Errorf("transaction %d: error = %v, want %v", i, err, want)
+		}
+	}
+}
+
+func TestPendingBalance(t *testing.T) {
+	alice := wallet.NewWallet()
+	owner := wallet.NewWallet()
+	keys := []*ecdsa.PublicKey{owner.PublicKey()}
+	multisig := MultisigAddress(1, keys)
+	bc := newTestBlockchain(t, WithGenesisAllocations(map[string]float32{alice.BlockchainAddress(): 10, multisig: 10}))
+
+	// Pending transactions spend the balance, so it can't be spent twice.
+	if err := submit(bc, signedRequest(bc, alice, "1Bob", 6)); err != nil {
+		t.Fatal(err)
+	}
+	if err := submit(bc, signedRequest(bc, alice, "1Carol", 6)); !errors.Is(err, ErrInsufficientBalance) {
+		t.Errorf("second spend: error = %v, want ErrInsufficientBalance", err)
+	}
+	if err := submit(bc, signedRequest(bc, alice, "1Carol", 4)); err != nil {
+		t.Errorf("spend of the rest: %v", err)
+	}
+
+	for i, tt := range []struct {
+		value float32
+		want  error
+	}{{6, nil}, {6, ErrInsufficientBalance}} {
+		tx := wallet.NewTransaction(owner.PrivateKey(), owner.PublicKey(), multisig, "1Bob", tt.value)
+		tx.SetChainID(bc.ChainID())
+		err := bc.AddMultisigTransaction(multisig, "1Bob", tt.value, tx.Timestamp(), keys, 1, []*utils.Signature{tx.GenerateSignature()})
+		if !errors.Is(err, tt.want) {
+			t.Errorf("multisig transaction %d: error = %v, want %v", i, err, tt.want)
+		}
+	}
+}
+
+func TestSweepExpired(t *testing.T) {
+	alice := wallet.NewWallet()
+	bc := newTestBlockchain(t, WithGenesisAllocations(map[string]float32{alice.BlockchainAddress(): 10}))
+	if !sendTransaction(bc, alice, "1Bob", 1) {
+		t.Fatal("transaction rejected")
+	}
+	bc.transactionPool[0].received = time.Now().Add(-time.Hour)
+	if n := bc.SweepExpired(); n != 0 || bc.PoolSize() != 1 {
+		t.Fatalf("swept %d without TTL, pool size %d", n, bc.PoolSize())
+	}
+
+	bc.SetMempoolTTL(time.Minute)
+	if n := bc.SweepExpired(); n != 1 {
+		t.Errorf("swept %d, want the expired transaction", n)
+	}
+	if n := bc.PoolSize(); n != 0 {
+		t.Errorf("pool size = %d, want 0", n)
+	}
+
+	// Adding a transaction sweeps expired ones too.
+	if !sendTransaction(bc, alice, "1Carol", 1) {
+		t.Fatal("transaction rejected")
+	}
+	bc.transactionPool[len(bc.transactionPool)-1].received = time.Now().Add(-time.Hour)
+	if !sendTransaction(bc, alice, "1Dave", 1) {
+		t.Fatal("transaction rejected")
+	}
+	if n := bc.PoolSize(); n != 1 {
+		t.Errorf("pool size = %d, want only the new transaction", n)
+	}
+}
+
+func TestRebroadcastPending(t *testing.T) {
+	alice := wallet.NewWallet()
+	owner := wallet.NewWallet()
+	keys := []*ecdsa.PublicKey{owner.PublicKey()}
+	multisig := MultisigAddress(1, keys)
+	allocations := WithGenesisAllocations(map[string]float32{alice.BlockchainAddress(): 10, multisig: 10})
+	bc := newTestBlockchain(t, allocations)
+	other := newTestBlockchain(t, allocations)
+
+	// The neighbor adds what it's sent to other's pool.
+	var mu sync.Mutex
+	var multisigRequests []*MultisigTransactionRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch req.URL.Path {
+		case "/transactions":
+			var tr TransactionRequest
+			json.NewDecoder(req.Body).Decode(&tr)
+			if err := submit(other, &tr); err != nil {
+				t.Errorf("rebroadcast transaction rejected: %v", err)
+			}
+		case "/transactions/multisig":
+			var tr MultisigTransactionRequest
+			json.NewDecoder(req.Body).Decode(&tr)
+			multisigRequests = append(multisigRequests, &tr)
+		}
+	}))
+	defer ts.Close()
+
+	if !sendTransaction(bc, alice, "1Bob", 1) {
+		t.Fatal("transaction rejected")
+	}
+	tx := wallet.NewTransaction(owner.PrivateKey(), owner.PublicKey(), multisig, "1Bob", 2)
+	if err := bc.AddMultisigTransaction(multisig, "1Bob", 2, tx.Timestamp(), keys, 1,
+		[]*utils.Signature{tx.GenerateSignature()}); err != nil {
+		t.Fatal(err)
+	}
+	// Unsigned transactions can't be sent again.
+	bc.transactionPool = append(bc.transactionPool, NewTransaction(MiningSender, "1Carol", 1))
+
+	bc.muxNeighbors.Lock()
+	bc.neighbors = []string{strings.TrimPrefix(ts.URL, "http://")}
+	bc.muxNeighbors.Unlock()
+	if n := bc.RebroadcastPending(); n != 2 {
+		t.Errorf("rebroadcast %d transactions, want 2", n)
+	}
+	if other.PoolSize() != 1 {
+		t.Errorf("neighbor pooled %d transactions, want 1", other.PoolSize())
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(multisigRequests) != 1 || *multisigRequests[0].Timestamp != tx.Timestamp() ||
+		len(multisigRequests[0].Signatures) != 1 {
+		t.Errorf("multisig requests = %+v", multisigRequests)
+	}
+}
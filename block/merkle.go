@@ -0,0 +1,145 @@
+package block
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"goblockchain/utils"
+)
+
+// MerkleRoot is to return merkle root of transaction hashes.
+// An odd node is paired with itself and no transactions give the zero hash.
+func MerkleRoot(transactions []*Transaction) [32]byte {
+	if len(transactions) == 0 {
+		return [32]byte{}
+	}
+	level := make([][32]byte, len(transactions))
+	for i, t := range transactions {
+		level[i] = t.Hash()
+	}
+	for len(level) > 1 {
+		level = nextMerkleLevel(level)
+	}
+	return level[0]
+}
+
+// nextMerkleLevel is to return hashes of level's pairs.
+func nextMerkleLevel(level [][32]byte) [][32]byte {
+	next := make([][32]byte, 0, (len(level)+1)/2)
+	for i := 0; i < len(level); i += 2 {
+		right := level[i]
+		if i+1 < len(level) {
+			right = level[i+1]
+		}
+		next = append(next, hashPair(level[i], right))
+	}
+	return next
+}
+
+func hashPair(left [32]byte, right [32]byte) [32]byte {
+	var m [64]byte
+	copy(m[:32], left[:])
+	copy(m[32:], right[:])
+	return sha256.Sum256(m[:])
+}
+
+// MerkleProofStep is sibling hash on the path from a transaction to the
+// merkle root. Left is set when the sibling is hashed on the left.
+type MerkleProofStep struct {
+	Hash [32]byte
+	Left bool
+}
+
+// MarshalJSON is override MerkleProofStep's marshaljson.
+func (s MerkleProofStep) MarshalJSON() ([]byte, error) {
+	position := "right"
+	if s.Left {
+		position = "left"
+	}
+	return json.Marshal(struct {
+		Hash     string `json:"hash"`
+		Position string `json:"position"`
+	}{utils.HashToHex(s.Hash), position})
+}
+
+// UnmarshalJSON is override MerkleProofStep's unmarshaljson.
+func (s *MerkleProofStep) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Hash     string `json:"hash"`
+		Position string `json:"position"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	hash, err := utils.HexToHash(v.Hash)
+	if err != nil {
+		return fmt.Errorf("invalid hash: %w", err)
+	}
+	switch v.Position {
+	case "left":
+		s.Left = true
+	case "right":
+		s.Left = false
+	default:
+		return fmt.Errorf("invalid position %q", v.Position)
+	}
+	s.Hash = hash
+	return nil
+}
+
+// MerkleProof is to return sibling hashes from transaction at index up to
+// MerkleRoot of transactions, or nil if index is out of range.
+func MerkleProof(transactions []*Transaction, index int) []MerkleProofStep {
+	if index < 0 || index >= len(transactions) {
+		return nil
+	}
+	level := make([][32]byte, len(transactions))
+	for i, t := range transactions {
+		level[i] = t.Hash()
+	}
+	proof := make([]MerkleProofStep, 0)
+	for len(level) > 1 {
+		if index%2 == 1 {
+			proof = append(proof, MerkleProofStep{Hash: level[index-1], Left: true})
+		} else if index+1 < len(level) {
+			proof = append(proof, MerkleProofStep{Hash: level[index+1]})
+		} else {
+			proof = append(proof, MerkleProofStep{Hash: level[index]})
+		}
+		level = nextMerkleLevel(level)
+		index /= 2
+	}
+	return proof
+}
+
+// VerifyMerkleProof is to report whether proof leads from transaction hash
+// txHash to root.
+func VerifyMerkleProof(txHash [32]byte, proof []MerkleProofStep, root [32]byte) bool {
+	h := txHash
+	for _, s := range proof {
+		if s.Left {
+			h = hashPair(s.Hash, h)
+		} else {
+			h = hashPair(h, s.Hash)
+		}
+	}
+	return h == root
+}
+
+// MerkleProof is to return proof of transaction txHash in block at height
+// and the block's merkle root. It fails with ErrHeightOutOfRange for blocks
+// not in the chain and ErrTransactionNotFound if the block doesn't hold it.
+func (bc *Blockchain) MerkleProof(height int, txHash [32]byte) ([]MerkleProofStep, [32]byte, error) {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	if height < bc.prunedHeight || height >= bc.Height() {
+		return nil, [32]byte{}, fmt.Errorf("%w: %d not in [%d, %d]", ErrHeightOutOfRange, height, bc.prunedHeight, bc.Height()-1)
+	}
+	transactions := bc.chain[height-bc.prunedHeight].transactions
+	for i, t := range transactions {
+		if t.Hash() == txHash {
+			return MerkleProof(transactions, i), MerkleRoot(transactions), nil
+		}
+	}
+	return nil, [32]byte{}, fmt.Errorf("%w in block %d", ErrTransactionNotFound, height)
+}
@@ -0,0 +1,78 @@
+package block
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goblockchain/wallet"
+	"testing"
+)
+
+func TestMerkleProof(t *testing.T) {
+	for n := 1; n <= 7; n++ {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			transactions := make([]*Transaction, n)
+			for i := range transactions {
+				transactions[i] = NewTransaction(MiningSender, fmt.Sprintf("1Miner%d", i), 1)
+			}
+			root := MerkleRoot(transactions)
+			for i, tx := range transactions {
+				proof := MerkleProof(transactions, i)
+				if !VerifyMerkleProof(tx.Hash(), proof, root) {
+					t.Errorf("proof of transaction %d doesn't verify", i)
+				}
+				if VerifyMerkleProof([32]byte{1}, proof, root) {
+					t.Errorf("proof of transaction %d verifies another hash", i)
+				}
+			}
+			if MerkleProof(transactions, n) != nil || MerkleProof(transactions, -1) != nil {
+				t.Error("proof of index out of range")
+			}
+		})
+	}
+}
+
+func TestMerkleProofStepJSON(t *testing.T) {
+	steps := []MerkleProofStep{{Hash: [32]byte{1}, Left: true}, {Hash: [32]byte{2}}}
+	data, err := json.Marshal(steps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []MerkleProofStep
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != steps[0] || got[1] != steps[1] {
+		t.Errorf("round trip = %+v, want %+v", got, steps)
+	}
+	for _, bad := range []string{`{"hash":"00","position":"left"}`, `{"hash":"` + fmt.Sprintf("%064x", 1) + `","position":"up"}`} {
+		var s MerkleProofStep
+		if err := json.Unmarshal([]byte(bad), &s); err == nil {
+			t.Errorf("%s decoded", bad)
+		}
+	}
+}
+
+func TestBlockchainMerkleProof(t *testing.T) {
+	alice := wallet.NewWallet()
+	bc := newTestBlockchain(t, WithGenesisAllocations(map[string]float32{alice.BlockchainAddress(): 10}))
+	if !sendTransaction(bc, alice, "1Carol", 1) || !sendTransaction(bc, alice, "1Bob", 1) {
+		t.Fatal("transaction rejected")
+	}
+	hash := bc.TransactionPool()[1].Hash()
+	bc.Mining()
+
+	proof, root, err := bc.MerkleProof(1, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root != bc.LastBlock().MerkleRoot() || !VerifyMerkleProof(hash, proof, root) {
+		t.Error("proof doesn't verify against the block's root")
+	}
+	if _, _, err := bc.MerkleProof(0, hash); !errors.Is(err, ErrTransactionNotFound) {
+		t.Errorf("other block: error = %v, want ErrTransactionNotFound", err)
+	}
+	if _, _, err := bc.MerkleProof(2, hash); !errors.Is(err, ErrHeightOutOfRange) {
+		t.Errorf("unmined block: error = %v, want ErrHeightOutOfRange", err)
+	}
+}
@@ -0,0 +1,42 @@
+package block
+
+import "time"
+
+// Metrics is instrumentation hook for Blockchain.
+// The block package has no dependency on a metrics backend.
+type Metrics interface {
+	SetChainHeight(height int)
+	SetTransactionPoolSize(size int)
+	IncMinedBlocks()
+	ObserveProofOfWork(d time.Duration)
+	IncRejectedTransactions(reason string)
+}
+
+// NopMetrics is Metrics discarding all values.
+type NopMetrics struct{}
+
+// SetChainHeight is to discard value.
+func (NopMetrics) SetChainHeight(height int) {}
+
+// SetTransactionPoolSize is to discard value.
+func (NopMetrics) SetTransactionPoolSize(size int) {}
+
+// IncMinedBlocks is to discard value.
+func (NopMetrics) IncMinedBlocks() {}
+
+// ObserveProofOfWork is to discard value.
+func (NopMetrics) ObserveProofOfWork(d time.Duration) {}
+
+// IncRejectedTransactions is to discard value.
+func (NopMetrics) IncRejectedTransactions(reason string) {}
+
+// Rejected transaction reasons reported to Metrics.
+const (
+	RejectReasonInsufficientBalance = "insufficient_balance"
+	RejectReasonInvalidSignature    = "invalid_signature"
+	RejectReasonDuplicate           = "duplicate"
+	RejectReasonUnknownRecipient    = "unknown_recipient"
+	RejectReasonDust                = "dust"
+	RejectReasonSenderKeyMismatch   = "sender_key_mismatch"
+	RejectReasonSenderPoolLimit     = "sender_pool_limit"
+)
@@ -0,0 +1,76 @@
+package block
+
+import (
+	"goblockchain/wallet"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countMetrics is Metrics recording values for tests to assert on.
+type countMetrics struct {
+	chainHeight  int
+	poolSize     int
+	minedBlocks  int
+	proofOfWorks int
+	rejected     map[string]int
+	mux          sync.Mutex
+}
+
+func (m *countMetrics) SetChainHeight(height int) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.chainHeight = height
+}
+
+func (m *countMetrics) SetTransactionPoolSize(size int) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.poolSize = size
+}
+
+func (m *countMetrics) IncMinedBlocks() {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.minedBlocks++
+}
+
+func (m *countMetrics) ObserveProofOfWork(d time.Duration) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.proofOfWorks++
+}
+
+func (m *countMetrics) IncRejectedTransactions(reason string) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	if m.rejected == nil {
+		m.rejected = make(map[string]int)
+	}
+	m.rejected[reason]++
+}
+
+func TestMetrics(t *testing.T) {
+	bc := newTestBlockchain(t)
+	m := &countMetrics{}
+	bc.SetMetrics(m)
+	if m.chainHeight != 1 || m.poolSize != 0 {
+		t.Fatalf("initial height %d and pool size %d, want 1 and 0", m.chainHeight, m.poolSize)
+	}
+
+	bc.Mining()
+	bc.Mining()
+	if m.chainHeight != 3 || m.minedBlocks != 2 || m.proofOfWorks != 2 || m.poolSize != 0 {
+		t.Errorf("after mining: %+v", m)
+	}
+
+	w := wallet.NewWallet()
+	recipient := wallet.NewWallet().BlockchainAddress()
+	tx := wallet.NewTransaction(w.PrivateKey(), w.PublicKey(), w.BlockchainAddress(), recipient, 2)
+	bc.AddTransaction(w.BlockchainAddress(), recipient, 1, tx.Timestamp(), w.PublicKey(), tx.GenerateSignature())
+	tx = wallet.NewTransaction(w.PrivateKey(), w.PublicKey(), w.BlockchainAddress(), recipient, 1)
+	bc.AddTransaction(w.BlockchainAddress(), recipient, 1, tx.Timestamp(), w.PublicKey(), tx.GenerateSignature())
+	if m.rejected[RejectReasonInvalidSignature] != 1 || m.rejected[RejectReasonInsufficientBalance] != 1 {
+		t.Errorf("rejected = %v", m.rejected)
+	}
+}
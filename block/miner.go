@@ -0,0 +1,127 @@
+package block
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// MiningWorkers is to return number of goroutines proof of work runs on.
+func (bc *Blockchain) MiningWorkers() int {
+	return bc.miningWorkers
+}
+
+// SetMiningWorkers is set number of goroutines proof of work runs on. Each
+// searches every n-th nonce. 1, the default, searches nonces in order.
+func (bc *Blockchain) SetMiningWorkers(n int) {
+	if n < 1 {
+		n = 1
+	}
+	bc.miningWorkers = n
+}
+
+// MiningDutyCycle is to return fraction of time proof of work runs.
+func (bc *Blockchain) MiningDutyCycle() float64 {
+	return bc.miningDutyCycle
+}
+
+// SetMiningDutyCycle is set fraction of time, in (0, 1], proof of work
+// workers run; they sleep the rest to leave CPU to other processes.
+// Values out of range run at full speed, the default.
+func (bc *Blockchain) SetMiningDutyCycle(fraction float64) {
+	if fraction <= 0 || fraction > 1 {
+		fraction = 1
+	}
+	bc.miningDutyCycle = fraction
+}
+
+// searchNonce is to search nonce from header's nonce on for a header hash
+// valid at difficulty, recorded in header, split over the mining workers. All workers have
+// stopped when it returns, so progress may be closed afterwards. The max
+// nonce is read once, so setting it doesn't race with the search.
+func (bc *Blockchain) searchNonce(ctx context.Context, header *BlockHeader, difficulty int, progress chan<- int) (uint64, error) {
+	header.difficulty = difficulty
+	bc.mux.RLock()
+	maxNonce := bc.maxNonce
+	bc.mux.RUnlock()
+	workers := bc.miningWorkers
+	var attempts int64
+	if workers <= 1 {
+		return bc.searchNonceStep(ctx, header, 1, maxNonce, &attempts, progress)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type result struct {
+		nonce uint64
+		err   error
+	}
+	results := make(chan result, workers)
+	for i := 0; i < workers; i++ {
+		h := *header
+		h.nonce += uint64(i)
+		go func() {
+			nonce, err := bc.searchNonceStep(ctx, &h, uint64(workers), maxNonce, &attempts, progress)
+			results <- result{nonce, err}
+		}()
+	}
+
+	var found *uint64
+	err := ErrNonceExhausted
+	for i := 0; i < workers; i++ {
+		r := <-results
+		switch {
+		case r.err == nil && found == nil:
+			found = &r.nonce
+			cancel()
+		case r.err != nil && !errors.Is(r.err, ErrNonceExhausted) && found == nil:
+			err = r.err
+		}
+	}
+	if found != nil {
+		return *found, nil
+	}
+	return 0, err
+}
+
+// searchNonceStep is to try nonces of header every step up to maxNonce,
+// counting tries in attempts shared by workers.
+func (bc *Blockchain) searchNonceStep(ctx context.Context, header *BlockHeader, step uint64, maxNonce uint64,
+	attempts *int64, progress chan<- int) (uint64, error) {
+	if header.nonce > maxNonce {
+		return 0, ErrNonceExhausted
+	}
+	busy := bc.clock.Now()
+	for n := 1; !bc.ValidHeaderProof(header); n++ {
+		if maxNonce-header.nonce < step {
+			return 0, ErrNonceExhausted
+		}
+		if n%ProofOfWorkProgressInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return 0, err
+			}
+			total := atomic.AddInt64(attempts, ProofOfWorkProgressInterval)
+			if progress != nil {
+				select {
+				case progress <- int(total):
+				default:
+				}
+			}
+			busy = bc.throttle(busy)
+		}
+		header.nonce += step
+	}
+	return header.nonce, nil
+}
+
+// throttle is to sleep so the time worked since busy, by bc.clock, is the
+// duty cycle fraction of the total, returning when work resumes.
+func (bc *Blockchain) throttle(busy time.Time) time.Time {
+	d := bc.miningDutyCycle
+	if d >= 1 {
+		return busy
+	}
+	time.Sleep(time.Duration(float64(bc.clock.Now().Sub(busy)) * (1 - d) / d))
+	return bc.clock.Now()
+}
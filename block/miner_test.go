@@ -0,0 +1,63 @@
+package block
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMiningWorkers(t *testing.T) {
+	bc := newTestBlockchain(t)
+	for _, workers := range []int{1, 2, 4} {
+		bc.SetMiningWorkers(workers)
+		header := &BlockHeader{}
+		nonce, err := bc.searchNonce(context.Background(), header, MiningDifficulty, nil)
+		if err != nil {
+			t.Fatalf("%d workers: %v", workers, err)
+		}
+		header.nonce = nonce
+		if !bc.ValidHeaderProof(header) {
+			t.Errorf("%d workers found invalid nonce %d", workers, nonce)
+		}
+	}
+
+	bc.SetMaxNonce(3 * ProofOfWorkProgressInterval)
+	if _, err := bc.searchNonce(context.Background(), &BlockHeader{}, 64, nil); !errors.Is(err, ErrNonceExhausted) {
+		t.Errorf("exhausted: error = %v, want ErrNonceExhausted", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	bc.SetMaxNonce(^uint64(0))
+	if _, err := bc.searchNonce(ctx, &BlockHeader{}, 64, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("canceled: error = %v, want context.Canceled", err)
+	}
+
+	bc.SetMiningWorkers(0)
+	if bc.MiningWorkers() != 1 {
+		t.Errorf("workers = %d after setting 0, want 1", bc.MiningWorkers())
+	}
+}
+
+func TestMiningDutyCycle(t *testing.T) {
+	bc := newTestBlockchain(t)
+	for _, fraction := range []float64{0, -1, 2} {
+		bc.SetMiningDutyCycle(fraction)
+		if bc.MiningDutyCycle() != 1 {
+			t.Errorf("duty cycle = %v after setting %v, want 1", bc.MiningDutyCycle(), fraction)
+		}
+	}
+
+	bc.SetMiningDutyCycle(0.5)
+	busy := time.Now().Add(-20 * time.Millisecond)
+	start := time.Now()
+	bc.throttle(busy)
+	// Working 20ms at half duty sleeps another 20ms.
+	if slept := time.Since(start); slept < 15*time.Millisecond {
+		t.Errorf("slept %v, want about 20ms", slept)
+	}
+	header := &BlockHeader{}
+	if header.nonce, _ = bc.searchNonce(context.Background(), header, 2, nil); !bc.ValidHeaderProof(header) {
+		t.Error("throttled search found invalid nonce")
+	}
+}
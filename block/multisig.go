@@ -0,0 +1,237 @@
+package block
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goblockchain/utils"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/btcsuite/btcutil/base58"
+	"golang.org/x/crypto/ripemd160"
+)
+
+// MultisigAddressVersion is version byte of multisig blockchain addresses.
+const MultisigAddressVersion = 0x05
+
+// ErrInvalidMultisig is returned when multisig threshold or public keys don't
+// match the sender address.
+var ErrInvalidMultisig = errors.New("invalid multisig")
+
+// MultisigAddress is to return blockchain address of M-of-N multisig account.
+// It's derived like a wallet address from sha256 of the threshold and the
+// sorted public keys.
+func MultisigAddress(threshold int, publicKeys []*ecdsa.PublicKey) string {
+	keys := multisigKeyStrings(publicKeys)
+	sort.Strings(keys)
+
+	h2 := sha256.New()
+	h2.Write([]byte(strconv.Itoa(threshold)))
+	for _, k := range keys {
+		h2.Write([]byte(k))
+	}
+	h3 := ripemd160.New()
+	h3.Write(h2.Sum(nil))
+
+	vd4 := make([]byte, 21)
+	vd4[0] = MultisigAddressVersion
+	copy(vd4[1:], h3.Sum(nil))
+	digest5 := sha256.Sum256(vd4)
+	digest6 := sha256.Sum256(digest5[:])
+
+	dc8 := make([]byte, 25)
+	copy(dc8[:21], vd4)
+	copy(dc8[21:], digest6[:4])
+	return base58.Encode(dc8)
+}
+
+func multisigKeyStrings(publicKeys []*ecdsa.PublicKey) []string {
+	keys := make([]string, len(publicKeys))
+	for i, pk := range publicKeys {
+		keys[i] = utils.PublicKeyString(pk)
+	}
+	return keys
+}
+
+// PublicKeys is to return multisig public keys of transaction, empty if it's single signed.
+func (t *Transaction) PublicKeys() []string {
+	return t.publicKeys
+}
+
+// Threshold is to return number of signatures multisig transaction requires.
+func (t *Transaction) Threshold() int {
+	return t.threshold
+}
+
+// CreateMultisigTransaction is AddMultisigTransaction and broadcast it to neighbors.
+func (bc *Blockchain) CreateMultisigTransaction(sender string, recipient string, value float32, timestamp int64,
+	publicKeys []*ecdsa.PublicKey, threshold int, signatures []*utils.Signature) error {
+	if err := bc.AddMultisigTransaction(sender, recipient, value, timestamp, publicKeys, threshold, signatures); err != nil {
+		return err
+	}
+	bc.broadcastMultisigTransaction(sender, recipient, value, timestamp, multisigKeyStrings(publicKeys), threshold, signatures)
+	return nil
+}
+
+// broadcastMultisigTransaction is to send multisig transaction to neighbors,
+// marked with OriginNodeHeader so they add it without broadcasting it again.
+func (bc *Blockchain) broadcastMultisigTransaction(sender string, recipient string, value float32, timestamp int64,
+	keys []string, threshold int, signatures []*utils.Signature) {
+	sigs := make([]string, len(signatures))
+	for i, s := range signatures {
+		sigs[i] = s.String()
+	}
+	for _, n := range bc.Neighbors() {
+		bt := &MultisigTransactionRequest{
+			SenderBlockchainAddress:    &sender,
+			RecipientBlockchainAddress: &recipient,
+			SenderPublicKeys:           keys,
+			Threshold:                  &threshold,
+			Value:                      &value,
+			Timestamp:                  &timestamp,
+			Signatures:                 sigs,
+		}
+		m, _ := json.Marshal(bt)
+		endpoint := fmt.Sprintf("http://%s/transactions/multisig", n)
+		resp, err := bc.peerRequest(http.MethodPut, endpoint, bytes.NewBuffer(m))
+		if err != nil {
+			bc.logger.Error("broadcast transaction", "action", "broadcast_transaction", "status", "fail", "endpoint", endpoint, "error", err)
+			continue
+		}
+		resp.Body.Close()
+		bc.logger.Debug("broadcast transaction", "action", "broadcast_transaction", "status", resp.Status, "endpoint", endpoint)
+	}
+}
+
+// AddMultisigTransaction is to add transaction from M-of-N multisig sender to
+// transaction pool. It needs threshold valid signatures from distinct
+// publicKeys, and sender must be MultisigAddress of threshold and publicKeys.
+func (bc *Blockchain) AddMultisigTransaction(sender string, recipient string, value float32, timestamp int64,
+	publicKeys []*ecdsa.PublicKey, threshold int, signatures []*utils.Signature) error {
+	bc.mux.Lock()
+	err := bc.addMultisigTransaction(sender, recipient, value, timestamp, publicKeys, threshold, signatures)
+	bc.mux.Unlock()
+	bc.flushMempoolChanges()
+	return err
+}
+
+// addMultisigTransaction is AddMultisigTransaction for callers holding bc.mux.
+func (bc *Blockchain) addMultisigTransaction(sender string, recipient string, value float32, timestamp int64,
+	publicKeys []*ecdsa.PublicKey, threshold int, signatures []*utils.Signature) error {
+	keys := multisigKeyStrings(publicKeys)
+	if threshold < 1 || threshold > len(publicKeys) || hasDuplicate(keys) ||
+		MultisigAddress(threshold, publicKeys) != sender {
+		return bc.rejectTransaction(ErrInvalidMultisig, RejectReasonInvalidSignature, "sender", sender)
+	}
+
+	t := NewTransaction(sender, recipient, value)
+	t.timestamp = timestamp
+	t.publicKeys = keys
+	t.threshold = threshold
+	t.signatures = signatures
+	bc.sweepExpired()
+	if value < bc.dustThreshold {
+		return bc.rejectTransaction(ErrDustValue, RejectReasonDust, "sender", sender)
+	}
+	if bc.isDuplicate(t) {
+		return bc.rejectTransaction(ErrDuplicateTransaction, RejectReasonDuplicate, "sender", sender)
+	}
+	if bc.exceedsPendingLimit(sender, value) {
+		return bc.rejectTransaction(ErrSenderPoolLimit, RejectReasonSenderPoolLimit, "sender", sender)
+	}
+	if bc.CountMultisigSignatures(t, publicKeys, signatures) < threshold {
+		return bc.rejectTransaction(ErrInvalidSignature, RejectReasonInvalidSignature, "sender", sender)
+	}
+
+	if bc.calculateTotalAmount(sender)-bc.pendingOutflow(sender) < value {
+		return bc.rejectTransaction(ErrInsufficientBalance, RejectReasonInsufficientBalance, "sender", sender)
+	}
+	if bc.requireKnownRecipient && !bc.knownAddress(recipient) {
+		return bc.rejectTransaction(ErrUnknownRecipient, RejectReasonUnknownRecipient, "recipient", recipient)
+	}
+	bc.addToPool(t)
+	return nil
+}
+
+// CountMultisigSignatures is to return number of publicKeys with a valid
+// signature of transaction. Extra signatures by the same key count once.
+func (bc *Blockchain) CountMultisigSignatures(t *Transaction, publicKeys []*ecdsa.PublicKey, signatures []*utils.Signature) int {
+	h := sha256.Sum256(utils.CanonicalSignedTransaction(bc.chainID,
+		t.senderBlockchainAddress, t.recipientBlockchainAddress, t.value, t.timestamp))
+	signed := make([]bool, len(publicKeys))
+	count := 0
+	for _, s := range signatures {
+		for i, pk := range publicKeys {
+			if !signed[i] && ecdsa.Verify(pk, h[:], s.R, s.S) {
+				signed[i] = true
+				count++
+				break
+			}
+		}
+	}
+	return count
+}
+
+func hasDuplicate(keys []string) bool {
+	seen := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		if seen[k] {
+			return true
+		}
+		seen[k] = true
+	}
+	return false
+}
+
+// MultisigTransactionRequest is request struct of M-of-N multisig transaction.
+type MultisigTransactionRequest struct {
+	SenderBlockchainAddress    *string  `json:"sender_blockchain_address"`
+	RecipientBlockchainAddress *string  `json:"recipient_blockchain_address"`
+	SenderPublicKeys           []string `json:"sender_public_keys"`
+	Threshold                  *int     `json:"threshold"`
+	Value                      *float32 `json:"value"`
+	Timestamp                  *int64   `json:"timestamp"`
+	Signatures                 []string `json:"signatures"`
+}
+
+// Validate is to Validate MultisigTransactionRequest.
+func (tr *MultisigTransactionRequest) Validate() bool {
+	if tr.SenderBlockchainAddress == nil ||
+		tr.RecipientBlockchainAddress == nil ||
+		len(tr.SenderPublicKeys) == 0 ||
+		tr.Threshold == nil ||
+		tr.Value == nil ||
+		tr.Timestamp == nil ||
+		len(tr.Signatures) == 0 {
+		return false
+	}
+	for _, k := range tr.SenderPublicKeys {
+		if _, err := utils.ParsePublicKey(k); err != nil {
+			return false
+		}
+	}
+	for _, s := range tr.Signatures {
+		if len(s) != 128 {
+			return false
+		}
+	}
+	return true
+}
+
+// Keys is to return parsed public keys and signatures of validated request.
+func (tr *MultisigTransactionRequest) Keys() ([]*ecdsa.PublicKey, []*utils.Signature) {
+	publicKeys := make([]*ecdsa.PublicKey, len(tr.SenderPublicKeys))
+	for i, k := range tr.SenderPublicKeys {
+		publicKeys[i], _ = utils.ParsePublicKey(k)
+	}
+	signatures := make([]*utils.Signature, len(tr.Signatures))
+	for i, s := range tr.Signatures {
+		signatures[i] = utils.SignatureFromString(s)
+	}
+	return publicKeys, signatures
+}
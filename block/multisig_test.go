@@ -0,0 +1,84 @@
+package block
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"errors"
+	"goblockchain/utils"
+	"goblockchain/wallet"
+	"testing"
+
+	"github.com/btcsuite/btcutil/base58"
+)
+
+func TestAddMultisigTransaction(t *testing.T) {
+	owners := []*wallet.Wallet{wallet.NewWallet(), wallet.NewWallet(), wallet.NewWallet()}
+	outsider := wallet.NewWallet()
+	keys := []*ecdsa.PublicKey{owners[0].PublicKey(), owners[1].PublicKey(), owners[2].PublicKey()}
+	sender := MultisigAddress(2, keys)
+	recipient := wallet.NewWallet().BlockchainAddress()
+	const value = 10
+	const timestamp = 1700000000000000000
+
+	// Every owner signs the same payload, timestamp included.
+	sign := func(w *wallet.Wallet) *utils.Signature {
+		tx := wallet.NewTransaction(w.PrivateKey(), w.PublicKey(), sender, recipient, value)
+		tx.SetTimestamp(timestamp)
+		return tx.GenerateSignature()
+	}
+	sig0, sig1, sig2 := sign(owners[0]), sign(owners[1]), sign(owners[2])
+
+	tests := []struct {
+		name       string
+		sender     string
+		keys       []*ecdsa.PublicKey
+		threshold  int
+		signatures []*utils.Signature
+		want       error
+	}{
+		{"no signature", sender, keys, 2, nil, ErrInvalidSignature},
+		{"one of two signatures", sender, keys, 2, []*utils.Signature{sig0}, ErrInvalidSignature},
+		{"two signatures", sender, keys, 2, []*utils.Signature{sig0, sig2}, nil},
+		{"three signatures", sender, keys, 2, []*utils.Signature{sig2, sig1, sig0}, nil},
+		{"same signature twice", sender, keys, 2, []*utils.Signature{sig1, sig1}, ErrInvalidSignature},
+		{"same key twice", sender, keys, 2, []*utils.Signature{sig1, sign(owners[1])}, ErrInvalidSignature},
+		{"outsider signature", sender, keys, 2, []*utils.Signature{sig0, sign(outsider)}, ErrInvalidSignature},
+		{"threshold not matching address", sender, keys, 1, []*utils.Signature{sig0}, ErrInvalidMultisig},
+		{"keys not matching address", sender, []*ecdsa.PublicKey{keys[0], keys[1], outsider.PublicKey()}, 2,
+			[]*utils.Signature{sig0, sig1}, ErrInvalidMultisig},
+		{"duplicate keys", MultisigAddress(2, []*ecdsa.PublicKey{keys[0], keys[0]}), []*ecdsa.PublicKey{keys[0], keys[0]}, 2,
+			[]*utils.Signature{sig0, sig0}, ErrInvalidMultisig},
+		{"zero threshold", MultisigAddress(0, keys), keys, 0, nil, ErrInvalidMultisig},
+		{"threshold over keys", MultisigAddress(4, keys), keys, 4, []*utils.Signature{sig0, sig1, sig2}, ErrInvalidMultisig},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bc := newTestBlockchain(t, WithGenesisAllocations(map[string]float32{tt.sender: 100}))
+			err := bc.AddMultisigTransaction(tt.sender, recipient, value, timestamp, tt.keys, tt.threshold, tt.signatures)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("error = %v, want %v", err, tt.want)
+			}
+			if pooled := len(bc.TransactionPool()) == 1; pooled != (tt.want == nil) {
+				t.Errorf("pooled = %v", pooled)
+			}
+		})
+	}
+}
+
+func TestMultisigAddressIgnoresKeyOrder(t *testing.T) {
+	a, b, c := wallet.NewWallet().PublicKey(), wallet.NewWallet().PublicKey(), wallet.NewWallet().PublicKey()
+	address := MultisigAddress(2, []*ecdsa.PublicKey{a, b, c})
+	if MultisigAddress(2, []*ecdsa.PublicKey{c, a, b}) != address {
+		t.Error("address depends on key order")
+	}
+	if MultisigAddress(3, []*ecdsa.PublicKey{a, b, c}) == address {
+		t.Error("address doesn't depend on threshold")
+	}
+	decoded := base58.Decode(address)
+	checksum := sha256.Sum256(decoded[:21])
+	checksum = sha256.Sum256(checksum[:])
+	if len(decoded) != 25 || decoded[0] != MultisigAddressVersion || !bytes.Equal(decoded[21:], checksum[:4]) {
+		t.Errorf("invalid address %s", address)
+	}
+}
@@ -0,0 +1,150 @@
+package block
+
+// MaxOrphanBlocks is max number of blocks buffered in the orphan pool.
+// The oldest orphan is evicted when it's full.
+const MaxOrphanBlocks = 64
+
+// OrphanCount is to return number of blocks in the orphan pool.
+func (bc *Blockchain) OrphanCount() int {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	return len(bc.orphanOrder)
+}
+
+// addOrphan is to buffer block which doesn't extend the tip, keyed by previousHash.
+func (bc *Blockchain) addOrphan(b *Block) {
+	hash := b.Hash()
+	if bc.orphanByHash(hash) != nil || bc.chainIndex(hash) >= 0 {
+		return
+	}
+	if bc.orphans == nil {
+		bc.orphans = make(map[[32]byte][]*Block)
+	}
+	if len(bc.orphanOrder) >= MaxOrphanBlocks {
+		bc.removeOrphan(bc.orphanByHash(bc.orphanOrder[0]))
+	}
+	bc.orphans[b.previousHash] = append(bc.orphans[b.previousHash], b)
+	bc.orphanOrder = append(bc.orphanOrder, hash)
+}
+
+// removeOrphan is to remove block from the orphan pool.
+func (bc *Blockchain) removeOrphan(b *Block) {
+	if b == nil {
+		return
+	}
+	hash := b.Hash()
+	siblings := bc.orphans[b.previousHash]
+	for i, o := range siblings {
+		if o == b {
+			siblings = append(siblings[:i:i], siblings[i+1:]...)
+			break
+		}
+	}
+	if len(siblings) == 0 {
+		delete(bc.orphans, b.previousHash)
+	} else {
+		bc.orphans[b.previousHash] = siblings
+	}
+	for i, h := range bc.orphanOrder {
+		if h == hash {
+			bc.orphanOrder = append(bc.orphanOrder[:i:i], bc.orphanOrder[i+1:]...)
+			break
+		}
+	}
+}
+
+// orphanByHash is to find orphan block by its hash.
+func (bc *Blockchain) orphanByHash(hash [32]byte) *Block {
+	for _, siblings := range bc.orphans {
+		for _, o := range siblings {
+			if o.Hash() == hash {
+				return o
+			}
+		}
+	}
+	return nil
+}
+
+// chainIndex is to return index of block with hash in the chain, or -1.
+func (bc *Blockchain) chainIndex(hash [32]byte) int {
+	for i := len(bc.chain) - 1; i >= 0; i-- {
+		if bc.chain[i].Hash() == hash {
+			return i
+		}
+	}
+	return -1
+}
+
+// longestOrphanPath is to return the longest chain of orphans descending from hash.
+func (bc *Blockchain) longestOrphanPath(hash [32]byte) []*Block {
+	var longest []*Block
+	for _, child := range bc.orphans[hash] {
+		path := append([]*Block{child}, bc.longestOrphanPath(child.Hash())...)
+		if len(path) > len(longest) {
+			longest = path
+		}
+	}
+	return longest
+}
+
+// connectOrphans is to append orphans extending the tip to the chain.
+func (bc *Blockchain) connectOrphans() {
+	for _, o := range bc.longestOrphanPath(bc.LastBlock().Hash()) {
+		if !bc.VerifyBlock(o) {
+			return
+		}
+		bc.removeOrphan(o)
+		bc.chain = append(bc.chain, o)
+		bc.confirmBlock(o)
+		bc.removeTransactions(o.transactions)
+	}
+}
+
+// reorganize is to switch to the side chain containing orphan b if it connects
+// to the chain, has more cumulative work and is within the reorg depth limit,
+// choosing forks by the same rule as ResolveConflicts. Blocks rolled back are
+// kept as orphans and their transactions go back to the pool.
+func (bc *Blockchain) reorganize(b *Block) (rolledBack, added int, ok bool) {
+	branch := []*Block{b}
+	parentHash := b.previousHash
+	for {
+		p := bc.orphanByHash(parentHash)
+		if p == nil {
+			break
+		}
+		branch = append([]*Block{p}, branch...)
+		parentHash = p.previousHash
+	}
+	forkIndex := bc.chainIndex(parentHash)
+	if forkIndex < 0 {
+		return 0, 0, false
+	}
+	branch = append(branch, bc.longestOrphanPath(b.Hash())...)
+
+	candidate := make([]*Block, 0, forkIndex+1+len(branch))
+	candidate = append(candidate, bc.chain[:forkIndex+1]...)
+	candidate = append(candidate, branch...)
+	// Work from the fork block on is compared; before it the chains agree.
+	if chainWork(candidate[forkIndex:]).Cmp(chainWork(bc.chain[forkIndex:])) <= 0 || !bc.ValidChain(candidate) {
+		return 0, 0, false
+	}
+	rolledBackBlocks := bc.chain[forkIndex+1:]
+	if bc.exceedsReorgDepth(len(rolledBackBlocks)) {
+		bc.logger.Error("reorganize", "action", "reorganize", "status", "reorg_too_deep",
+			"depth", len(rolledBackBlocks), "max_depth", bc.maxReorgDepth)
+		return 0, 0, false
+	}
+
+	bc.chain = candidate
+	bc.indexConfirmed()
+	for _, o := range branch {
+		bc.removeOrphan(o)
+	}
+	bc.reconcileMempool(rolledBackBlocks, branch)
+	for _, r := range rolledBackBlocks {
+		bc.addOrphan(r)
+	}
+	bc.logger.Info("reorganize", "action", "reorganize", "status", "success",
+		"rolled_back", len(rolledBackBlocks), "added", len(branch))
+	return len(rolledBackBlocks), len(branch), true
+}
@@ -0,0 +1,109 @@
+package block
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestOrphanConnects(t *testing.T) {
+	bc := newTestBlockchain(t)
+	peer := forkChain(t, bc)
+	peer.Mining()
+	peer.Mining()
+
+	if err := bc.AddBlock(peer.chain[2]); !errors.Is(err, ErrBlockNotExtendTip) {
+		t.Fatalf("error = %v, want ErrBlockNotExtendTip", err)
+	}
+	if bc.OrphanCount() != 1 {
+		t.Fatalf("orphans = %d, want 1", bc.OrphanCount())
+	}
+	if err := bc.AddBlock(peer.chain[1]); err != nil {
+		t.Fatal(err)
+	}
+	if len(bc.chain) != 3 || bc.LastBlock() != peer.chain[2] || bc.OrphanCount() != 0 {
+		t.Errorf("chain length %d, orphans %d; want the orphan connected", len(bc.chain), bc.OrphanCount())
+	}
+}
+
+func TestReorganize(t *testing.T) {
+	bc := newTestBlockchain(t)
+	peer := forkChain(t, bc)
+	bc.Mining()
+	own := bc.LastBlock()
+	peer.Mining()
+	peer.Mining()
+
+	var notified []*Block
+	bc.OnNewBlock(func(b *Block) { notified = append(notified, b) })
+	if err := bc.AddBlock(peer.chain[1]); !errors.Is(err, ErrBlockNotExtendTip) {
+		t.Fatalf("fork of equal length: error = %v, want ErrBlockNotExtendTip", err)
+	}
+	if bc.LastBlock() != own {
+		t.Fatal("switched to fork of equal length")
+	}
+	if err := bc.AddBlock(peer.chain[2]); err != nil {
+		t.Fatalf("longer fork: %v", err)
+	}
+	if len(bc.chain) != 3 || bc.LastBlock() != peer.chain[2] || bc.chain[1] != peer.chain[1] {
+		t.Error("chain isn't the longer fork")
+	}
+	if bc.OrphanCount() != 1 || bc.orphanByHash(own.Hash()) == nil {
+		t.Error("rolled back block isn't kept as orphan")
+	}
+	if len(notified) != 1 || notified[0] != peer.chain[2] {
+		t.Errorf("notified %d blocks, want the new tip", len(notified))
+	}
+}
+
+func TestReorganizeByWork(t *testing.T) {
+	bc := newTestBlockchain(t)
+	peer := forkChain(t, bc)
+	bc.Mining()
+	own := bc.LastBlock()
+	if !peer.MineWithDifficulty(MiningDifficulty + 1) {
+		t.Fatal("peer mining failed")
+	}
+
+	// Of equal length forks, the one mined at higher difficulty wins, as
+	// it does in ResolveConflicts.
+	if err := bc.AddBlock(peer.chain[1]); err != nil {
+		t.Fatalf("fork of more work: %v", err)
+	}
+	if bc.LastBlock() != peer.chain[1] || bc.orphanByHash(own.Hash()) == nil {
+		t.Fatal("chain isn't the fork of more work")
+	}
+
+	// A longer fork of less work isn't switched to.
+	easy := forkChain(t, bc)
+	easy.chain = easy.chain[:1]
+	easy.Mining()
+	easy.Mining()
+	bc.AddBlock(easy.chain[1])
+	if err := bc.AddBlock(easy.chain[2]); !errors.Is(err, ErrBlockNotExtendTip) {
+		t.Errorf("longer fork of less work: error = %v, want ErrBlockNotExtendTip", err)
+	}
+	if bc.LastBlock() != peer.chain[1] {
+		t.Error("switched to longer fork of less work")
+	}
+}
+
+func TestOrphanPoolBound(t *testing.T) {
+	bc := newTestBlockchain(t)
+	peer := forkChain(t, bc)
+	for i := 0; i < MaxOrphanBlocks+2; i++ {
+		peer.Mining()
+	}
+	for _, b := range peer.chain[2:] {
+		bc.AddBlock(b)
+	}
+	if bc.OrphanCount() != MaxOrphanBlocks {
+		t.Fatalf("orphans = %d, want %d", bc.OrphanCount(), MaxOrphanBlocks)
+	}
+	// The oldest orphan, the child of peer.chain[1], was evicted.
+	if err := bc.AddBlock(peer.chain[1]); err != nil {
+		t.Fatal(err)
+	}
+	if len(bc.chain) != 2 {
+		t.Errorf("chain length = %d, want 2 with the evicted orphan missing", len(bc.chain))
+	}
+}
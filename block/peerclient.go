@@ -0,0 +1,63 @@
+package block
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Timeouts of requests to peers.
+const (
+	PeerDialTimeout    = 5 * time.Second
+	PeerRequestTimeout = 30 * time.Second
+)
+
+// newPeerClient is to return http client for requests to peers, bounded by
+// PeerDialTimeout to connect and PeerRequestTimeout in total.
+func newPeerClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: PeerDialTimeout}).DialContext
+	return &http.Client{Transport: transport, Timeout: PeerRequestTimeout}
+}
+
+// SetPeerTimeout is set total timeout of a request to a peer.
+func (bc *Blockchain) SetPeerTimeout(d time.Duration) {
+	bc.peerClient.Timeout = d
+}
+
+// Close is to cancel in-flight requests to peers. Requests made after it fail at once.
+func (bc *Blockchain) Close() {
+	bc.cancelPeers()
+}
+
+// peerRequest is to send request to a peer with the shared client,
+// canceled by Close. The method, path, current time and body are signed
+// with the node identity key.
+func (bc *Blockchain) peerRequest(method string, endpoint string, body io.Reader) (*http.Response, error) {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = io.ReadAll(body); err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequestWithContext(bc.peersCtx, method, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set(OriginNodeHeader, bc.NodeAddress())
+	bc.SignRequest(req, data)
+	return bc.peerClient.Do(req)
+}
+
+// initPeerClient is to set up the shared peer client and its context.
+func (bc *Blockchain) initPeerClient() {
+	bc.peerClient = newPeerClient()
+	bc.peersCtx, bc.cancelPeers = context.WithCancel(context.Background())
+}
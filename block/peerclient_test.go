@@ -0,0 +1,80 @@
+package block
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// hangingPeer is to return address of a peer answering nothing until the
+// test ends or the request is canceled.
+func hangingPeer(t *testing.T) string {
+	t.Helper()
+	done := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		select {
+		case <-req.Context().Done():
+		case <-done:
+		}
+	}))
+	t.Cleanup(func() {
+		close(done)
+		ts.Close()
+	})
+	return strings.TrimPrefix(ts.URL, "http://")
+}
+
+func TestPeerRequest(t *testing.T) {
+	bc := newTestBlockchain(t)
+	var got http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		got = req.Header
+	}))
+	t.Cleanup(ts.Close)
+
+	resp, err := bc.peerRequest(http.MethodPut, ts.URL, strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got.Get(OriginNodeHeader) != bc.NodeAddress() || got.Get("Content-Type") != "application/json" {
+		t.Errorf("headers = %v", got)
+	}
+}
+
+func TestSetPeerTimeout(t *testing.T) {
+	bc := newTestBlockchain(t)
+	bc.SetPeerTimeout(50 * time.Millisecond)
+	start := time.Now()
+	if err := bc.AddPeer(hangingPeer(t)); !errors.Is(err, ErrPeerUnreachable) {
+		t.Errorf("error = %v, want ErrPeerUnreachable", err)
+	}
+	if elapsed := time.Since(start); elapsed > PeerRequestTimeout/2 {
+		t.Errorf("request took %v, want it timed out", elapsed)
+	}
+}
+
+func TestClose(t *testing.T) {
+	bc := newTestBlockchain(t)
+	address := hangingPeer(t)
+	errs := make(chan error, 1)
+	go func() { errs <- bc.AddPeer(address) }()
+	time.Sleep(50 * time.Millisecond)
+	bc.Close()
+	select {
+	case err := <-errs:
+		if !errors.Is(err, ErrPeerUnreachable) {
+			t.Errorf("error = %v, want ErrPeerUnreachable", err)
+		}
+	case <-time.After(PeerRequestTimeout / 2):
+		t.Fatal("in-flight request not canceled")
+	}
+
+	// Requests after Close fail at once.
+	if _, err := bc.peerRequest(http.MethodGet, "http://"+address, nil); err == nil {
+		t.Error("request after Close succeeded")
+	}
+}
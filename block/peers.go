@@ -0,0 +1,187 @@
+package block
+
+import (
+	"errors"
+	"fmt"
+	"goblockchain/utils"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Peer scoring parameters.
+const (
+	PeerInvalidPenalty     = 10
+	PeerBanThreshold       = -30
+	DefaultPeerBanDuration = time.Hour
+)
+
+// Errors returned by AddPeer.
+var (
+	ErrInvalidPeer     = errors.New("invalid peer address")
+	ErrPeerSelf        = errors.New("peer is this node")
+	ErrPeerDuplicate   = errors.New("peer already known")
+	ErrPeerUnreachable = errors.New("peer unreachable")
+)
+
+type peerScore struct {
+	score       int
+	bannedUntil time.Time
+	lastSeen    time.Time
+}
+
+// PeerInfo is status of a known peer.
+type PeerInfo struct {
+	Address  string    `json:"address"`
+	LastSeen time.Time `json:"last_seen"`
+	Banned   bool      `json:"banned"`
+	Score    int       `json:"score"`
+}
+
+// peer is to return peer's score, adding it if it's unknown. muxPeers must be held.
+func (bc *Blockchain) peer(address string) *peerScore {
+	if bc.peerScores == nil {
+		bc.peerScores = make(map[string]*peerScore)
+	}
+	p, ok := bc.peerScores[address]
+	if !ok {
+		p = &peerScore{}
+		bc.peerScores[address] = p
+	}
+	return p
+}
+
+// MarkPeerSeen is to record peer answered or sent us something just now.
+//
+// Peers are scored by a key the peer can't change at will: a neighbor by
+// its host:port, and a node pushing blocks by the node ID of its verified
+// announcement if trusted nodes are set, else by its IP, since any sender
+// can generate a new node ID to shed a ban.
+func (bc *Blockchain) MarkPeerSeen(peer string) {
+	bc.muxPeers.Lock()
+	defer bc.muxPeers.Unlock()
+	bc.peer(peer).lastSeen = bc.clock.Now()
+}
+
+// Peers is to return status of neighbors and other scored peers, sorted by
+// address: host:port of neighbors and node ID or IP of others.
+func (bc *Blockchain) Peers() []PeerInfo {
+	neighbors := bc.Neighbors()
+
+	bc.muxPeers.Lock()
+	defer bc.muxPeers.Unlock()
+
+	now := bc.clock.Now()
+	addresses := make(map[string]bool, len(neighbors)+len(bc.peerScores))
+	for address := range bc.peerScores {
+		addresses[address] = true
+	}
+	for _, n := range neighbors {
+		addresses[n] = true
+	}
+	peers := make([]PeerInfo, 0, len(addresses))
+	for address := range addresses {
+		info := PeerInfo{Address: address, Banned: bc.isBanned(address, now)}
+		if p, ok := bc.peerScores[address]; ok {
+			info.LastSeen = p.lastSeen
+			info.Score = p.score
+		}
+		peers = append(peers, info)
+	}
+	sort.Slice(peers, func(i, j int) bool { return peers[i].Address < peers[j].Address })
+	return peers
+}
+
+// PenalizePeer is to decrease peer's score, banning it below PeerBanThreshold.
+// peer is a neighbor's host:port, a node ID or an IP, as for MarkPeerSeen.
+func (bc *Blockchain) PenalizePeer(peer string, penalty int) {
+	bc.muxPeers.Lock()
+	defer bc.muxPeers.Unlock()
+
+	p := bc.peer(peer)
+	p.score -= penalty
+	if p.score < PeerBanThreshold && p.bannedUntil.IsZero() {
+		p.bannedUntil = bc.clock.Now().Add(bc.peerBanDuration)
+		bc.logger.Info("ban peer", "action", "ban_peer", "peer", peer, "score", p.score)
+	}
+}
+
+// IsBanned is to check peer, a neighbor's host:port, a node ID or an IP, is
+// banned. An expired ban is lifted with its score reset.
+func (bc *Blockchain) IsBanned(peer string) bool {
+	bc.muxPeers.Lock()
+	defer bc.muxPeers.Unlock()
+	return bc.isBanned(peer, bc.clock.Now())
+}
+
+func (bc *Blockchain) isBanned(peer string, now time.Time) bool {
+	p, ok := bc.peerScores[peer]
+	if !ok || p.bannedUntil.IsZero() {
+		return false
+	}
+	if now.After(p.bannedUntil) {
+		delete(bc.peerScores, peer)
+		return false
+	}
+	return true
+}
+
+// BannedPeers is to return currently banned peers.
+func (bc *Blockchain) BannedPeers() []string {
+	bc.muxPeers.Lock()
+	defer bc.muxPeers.Unlock()
+
+	now := bc.clock.Now()
+	banned := make([]string, 0)
+	for peer := range bc.peerScores {
+		if bc.isBanned(peer, now) {
+			banned = append(banned, peer)
+		}
+	}
+	sort.Strings(banned)
+	return banned
+}
+
+// SetPeerBanDuration is set how long a peer stays banned.
+func (bc *Blockchain) SetPeerBanDuration(d time.Duration) {
+	bc.peerBanDuration = d
+}
+
+// AddPeer is to add peer ("host:port") as neighbor after checking it answers
+// on /health. Added peers are kept when neighbors are rescanned.
+func (bc *Blockchain) AddPeer(address string) error {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidPeer, err)
+	}
+	if port == strconv.Itoa(int(bc.port)) {
+		if ip := net.ParseIP(host); host == "localhost" || host == utils.GetHost() || (ip != nil && ip.IsLoopback()) {
+			return ErrPeerSelf
+		}
+	}
+	if containsString(bc.Neighbors(), address) {
+		return ErrPeerDuplicate
+	}
+
+	resp, err := bc.peerRequest(http.MethodGet, fmt.Sprintf("http://%s/health", address), nil)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrPeerUnreachable, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %s", ErrPeerUnreachable, resp.Status)
+	}
+
+	bc.muxNeighbors.Lock()
+	defer bc.muxNeighbors.Unlock()
+	if containsString(bc.neighbors, address) {
+		return ErrPeerDuplicate
+	}
+	bc.seedPeers = append(bc.seedPeers, address)
+	bc.neighbors = append(bc.neighbors, address)
+	bc.MarkPeerSeen(address)
+	bc.logger.Info("add peer", "action", "add_peer", "status", "success", "peer", address)
+	return nil
+}
@@ -0,0 +1,170 @@
+package block
+
+import (
+	"errors"
+	"fmt"
+	"goblockchain/utils"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// banPeer is to penalize peer until it's banned.
+func banPeer(bc *Blockchain, peer string) {
+	for i := 0; i <= -PeerBanThreshold/PeerInvalidPenalty; i++ {
+		bc.PenalizePeer(peer, PeerInvalidPenalty)
+	}
+}
+
+func TestPeerBan(t *testing.T) {
+	bc := newTestBlockchain(t)
+	bc.PenalizePeer("10.0.0.1:5000", PeerInvalidPenalty)
+	if bc.IsBanned("10.0.0.1:5000") {
+		t.Fatal("banned after one penalty")
+	}
+	banPeer(bc, "10.0.0.1:5000")
+
+	if !bc.IsBanned("10.0.0.1:5000") {
+		t.Error("10.0.0.1:5000 isn't banned")
+	}
+	// Nodes sharing a host aren't banned for each other.
+	for _, peer := range []string{"10.0.0.1", "10.0.0.1:6000", "10.0.0.2:5000"} {
+		if bc.IsBanned(peer) {
+			t.Errorf("%s banned", peer)
+		}
+	}
+	if got := fmt.Sprint(bc.BannedPeers()); got != "[10.0.0.1:5000]" {
+		t.Errorf("banned peers = %s", got)
+	}
+
+	bc.SetPeerBanDuration(time.Nanosecond)
+	banPeer(bc, "10.0.0.3")
+	time.Sleep(time.Millisecond)
+	if bc.IsBanned("10.0.0.3") {
+		t.Error("ban didn't expire")
+	}
+	bc.PenalizePeer("10.0.0.3", PeerInvalidPenalty)
+	if bc.IsBanned("10.0.0.3") {
+		t.Error("score not reset after ban expired")
+	}
+}
+
+func TestAddBlockFrom(t *testing.T) {
+	bc := newTestBlockchain(t)
+	peer := forkChain(t, bc)
+	peer.Mining()
+	tampered := *peer.LastBlock()
+	tampered.nonce++
+
+	for i := 0; i <= -PeerBanThreshold/PeerInvalidPenalty; i++ {
+		if err := bc.AddBlockFrom(peer.NodeID(), &tampered); !errors.Is(err, ErrInvalidBlock) {
+			t.Fatalf("error = %v, want ErrInvalidBlock", err)
+		}
+	}
+	if err := bc.AddBlockFrom(peer.NodeID(), peer.LastBlock()); !errors.Is(err, ErrPeerBanned) {
+		t.Errorf("error = %v, want ErrPeerBanned", err)
+	}
+	if err := bc.AddBlockFrom(newTestBlockchain(t).NodeID(), peer.LastBlock()); err != nil {
+		t.Errorf("block from other peer: %v", err)
+	}
+}
+
+func TestResolveConflictsSkipsBannedPeer(t *testing.T) {
+	bc := newTestBlockchain(t)
+	peer := forkChain(t, bc)
+	peer.Mining()
+	servePeerChain(t, bc, peer)
+
+	// Another node on the neighbor's host doesn't get it skipped.
+	banPeer(bc, "127.0.0.1")
+	if bc.IsBanned(bc.Neighbors()[0]) {
+		t.Fatal("neighbor banned for its host")
+	}
+	banPeer(bc, bc.Neighbors()[0])
+	if bc.ResolveConflicts() {
+		t.Fatal("chain of banned peer adopted")
+	}
+	bc.peerScores = nil
+	if !bc.ResolveConflicts() {
+		t.Error("longer chain not adopted after ban lifted")
+	}
+}
+
+func TestPeers(t *testing.T) {
+	stubNeighbors(t, "10.0.0.1:5000", "10.0.0.2:5000")
+	bc := newTestBlockchain(t)
+	bc.SetNeighbors()
+	banPeer(bc, "10.0.0.1:5000")
+	bc.MarkPeerSeen("10.0.0.2:5000")
+	bc.PenalizePeer("10.0.0.3:5000", PeerInvalidPenalty)
+
+	peers := bc.Peers()
+	// A scored neighbor is listed once.
+	want := []string{"10.0.0.1:5000", "10.0.0.2:5000", "10.0.0.3:5000"}
+	if len(peers) != len(want) {
+		t.Fatalf("peers = %+v, want %v", peers, want)
+	}
+	for i, p := range peers {
+		if p.Address != want[i] {
+			t.Errorf("peers[%d] = %s, want %s", i, p.Address, want[i])
+		}
+	}
+	if !peers[0].Banned || peers[0].Score >= PeerBanThreshold {
+		t.Errorf("banned neighbor = %+v", peers[0])
+	}
+	if peers[1].Banned || peers[1].LastSeen.IsZero() {
+		t.Errorf("seen neighbor = %+v", peers[1])
+	}
+	if peers[2].Banned || peers[2].Score != -PeerInvalidPenalty {
+		t.Errorf("penalized peer = %+v", peers[2])
+	}
+
+	// Peers returns a copy.
+	peers[2].Score = 0
+	peers[2].Banned = true
+	if p := bc.Peers()[2]; p.Score != -PeerInvalidPenalty || p.Banned {
+		t.Errorf("peer changed through returned slice: %+v", p)
+	}
+}
+
+func TestAddPeer(t *testing.T) {
+	bc := NewBlockchain("", 5000)
+	bc.SetLogger(utils.NewNopLogger())
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/health" {
+			http.NotFound(w, req)
+		}
+	}))
+	t.Cleanup(healthy.Close)
+	down := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(down.Close)
+	address := strings.TrimPrefix(healthy.URL, "http://")
+
+	if err := bc.AddPeer(address); err != nil {
+		t.Fatalf("add peer: %v", err)
+	}
+	if !containsString(bc.Neighbors(), address) {
+		t.Errorf("neighbors = %v, want %s added", bc.Neighbors(), address)
+	}
+	if p := bc.Peers(); len(p) != 1 || p[0].LastSeen.IsZero() {
+		t.Errorf("peers = %+v, want added peer seen", p)
+	}
+
+	tests := []struct {
+		address string
+		want    error
+	}{
+		{"no-port", ErrInvalidPeer},
+		{"127.0.0.1:5000", ErrPeerSelf},
+		{"localhost:5000", ErrPeerSelf},
+		{address, ErrPeerDuplicate},
+		{strings.TrimPrefix(down.URL, "http://"), ErrPeerUnreachable},
+	}
+	for _, tt := range tests {
+		if err := bc.AddPeer(tt.address); !errors.Is(err, tt.want) {
+			t.Errorf("AddPeer(%q) = %v, want %v", tt.address, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,240 @@
+package block
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"goblockchain/utils"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// persistedChain is json struct of chain saved by SaveToFile.
+type persistedChain struct {
+	ChainID      string             `json:"chain_id"`
+	PrunedHeight int                `json:"pruned_height"`
+	GenesisState map[string]float32 `json:"genesis_state,omitempty"`
+	Chain        []*Block           `json:"chain"`
+	// PrunedIDs is IDs of transactions of pruned blocks, kept so they
+	// can't be replayed.
+	PrunedIDs []string             `json:"pruned_transaction_ids,omitempty"`
+	Pool      []*pooledTransaction `json:"pool,omitempty"`
+}
+
+// prunedIDStrings is to return hex of pruned transaction IDs, sorted so
+// saving the same chain twice writes the same file.
+func (bc *Blockchain) prunedIDStrings() []string {
+	ids := make([]string, 0, len(bc.prunedIDs))
+	for id := range bc.prunedIDs {
+		ids = append(ids, hex.EncodeToString(id[:]))
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// pooledTransaction is json struct of pending transaction with its signing data,
+// so it can be verified again when it's loaded.
+type pooledTransaction struct {
+	Sender     string   `json:"sender_blockchain_address"`
+	Recipient  string   `json:"recipient_blockchain_address"`
+	Value      float32  `json:"value"`
+	Timestamp  int64    `json:"timestamp"`
+	PublicKey  string   `json:"sender_public_key,omitempty"`
+	PublicKeys []string `json:"public_keys,omitempty"`
+	Threshold  int      `json:"threshold,omitempty"`
+	Signatures []string `json:"signatures"`
+}
+
+// pooledTransactions is to return pending transactions which can be signed
+// again, which leaves out mining rewards.
+func (bc *Blockchain) pooledTransactions() []*pooledTransaction {
+	pool := make([]*pooledTransaction, 0, len(bc.transactionPool))
+	for _, t := range bc.transactionPool {
+		if t.senderBlockchainAddress == MiningSender || len(t.signatures) == 0 {
+			continue
+		}
+		pt := &pooledTransaction{
+			Sender:     t.senderBlockchainAddress,
+			Recipient:  t.recipientBlockchainAddress,
+			Value:      t.value,
+			Timestamp:  t.timestamp,
+			PublicKeys: t.publicKeys,
+			Threshold:  t.threshold,
+			Signatures: make([]string, len(t.signatures)),
+		}
+		if t.senderPublicKey != nil {
+			pt.PublicKey = utils.PublicKeyString(t.senderPublicKey)
+		}
+		for i, s := range t.signatures {
+			pt.Signatures[i] = s.String()
+		}
+		pool = append(pool, pt)
+	}
+	return pool
+}
+
+// restorePool is to add loaded pending transactions to the pool, verifying
+// each against the chain. Ones no longer valid are dropped.
+func (bc *Blockchain) restorePool(pool []*pooledTransaction) {
+	bc.mux.Lock()
+	restored := 0
+	for _, pt := range pool {
+		signatures := make([]*utils.Signature, len(pt.Signatures))
+		for i, s := range pt.Signatures {
+			signatures[i] = utils.SignatureFromString(s)
+		}
+		var err error
+		if len(pt.PublicKeys) > 0 {
+			publicKeys := make([]*ecdsa.PublicKey, len(pt.PublicKeys))
+			for i, k := range pt.PublicKeys {
+				if publicKeys[i], err = utils.ParsePublicKey(k); err != nil {
+					break
+				}
+			}
+			if err == nil {
+				err = bc.addMultisigTransaction(pt.Sender, pt.Recipient, pt.Value, pt.Timestamp, publicKeys, pt.Threshold, signatures)
+			}
+		} else {
+			var publicKey *ecdsa.PublicKey
+			publicKey, err = utils.ParsePublicKey(pt.PublicKey)
+			if err == nil && len(signatures) != 1 {
+				err = ErrInvalidSignature
+			}
+			if err == nil {
+				err = bc.addTransaction(pt.Sender, pt.Recipient, pt.Value, pt.Timestamp, publicKey, signatures[0])
+			}
+		}
+		if err != nil {
+			bc.logger.Info("restore pool", "action", "restore_pool", "status", "dropped", "sender", pt.Sender, "error", err)
+			continue
+		}
+		restored++
+	}
+	bc.mux.Unlock()
+	bc.flushMempoolChanges()
+	bc.logger.Info("restore pool", "action", "restore_pool", "status", "success", "restored", restored, "dropped", len(pool)-restored)
+}
+
+// SaveToFile is to write the chain and pending transactions to path as json.
+// The file is replaced atomically so a crash never leaves it half written.
+// Concurrent saves are serialized, so an older snapshot never replaces a
+// newer one.
+func (bc *Blockchain) SaveToFile(path string) error {
+	bc.muxSave.Lock()
+	defer bc.muxSave.Unlock()
+	return bc.saveToFile(path)
+}
+
+// saveToFile is SaveToFile for callers holding bc.muxSave.
+func (bc *Blockchain) saveToFile(path string) error {
+	bc.mux.RLock()
+	m, err := json.Marshal(&persistedChain{
+		ChainID:      bc.chainID,
+		PrunedHeight: bc.prunedHeight,
+		GenesisState: bc.genesisState,
+		Chain:        bc.chain,
+		PrunedIDs:    bc.prunedIDStrings(),
+		Pool:         bc.pooledTransactions(),
+	})
+	bc.mux.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(m); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadFromFile is to return new Blockchain restored from SaveToFile json,
+// failing if it doesn't pass SelfCheck or CheckGenesis. Pending transactions
+// are verified again and ones no longer valid are dropped. Miner's
+// blockchain address and port are unset.
+func LoadFromFile(path string, opts ...Option) (*Blockchain, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var p persistedChain
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+	if len(p.Chain) == 0 {
+		return nil, fmt.Errorf("load %s: %w", path, ErrEmptyChain)
+	}
+
+	bc := newBlockchain("", 0)
+	bc.chainID = p.ChainID
+	for _, opt := range opts {
+		opt(bc)
+	}
+	bc.chain = p.Chain
+	bc.prunedHeight = p.PrunedHeight
+	bc.genesisState = p.GenesisState
+	if err := bc.SelfCheck(); err != nil {
+		return nil, fmt.Errorf("load %s: %w", path, err)
+	}
+	for _, s := range p.PrunedIDs {
+		b, err := hex.DecodeString(s)
+		if err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("load %s: invalid pruned transaction id %q", path, s)
+		}
+		var id [32]byte
+		copy(id[:], b)
+		if bc.prunedIDs == nil {
+			bc.prunedIDs = make(map[[32]byte]bool, len(p.PrunedIDs))
+		}
+		bc.prunedIDs[id] = true
+	}
+	bc.indexConfirmed()
+	if err := bc.CheckGenesis(); err != nil {
+		return nil, fmt.Errorf("load %s: %w", path, err)
+	}
+	bc.restorePool(p.Pool)
+	return bc, nil
+}
+
+// SelfCheck is to verify links, mining rewards and proof of work of the
+// chain, returning an error naming the height of the first bad block. The
+// genesis block isn't mined so only its successors are checked for proof of
+// work and reward.
+func (bc *Blockchain) SelfCheck() error {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	for i, b := range bc.chain {
+		height := bc.prunedHeight + i
+		if i > 0 && b.previousHash != bc.chain[i-1].Hash() {
+			return fmt.Errorf("block %d: previous hash doesn't match block %d", height, height-1)
+		}
+		if b.IsGenesis() != (height == 0) {
+			return fmt.Errorf("block %d: genesis block must be at height 0 and only there", height)
+		}
+		if b.IsGenesis() {
+			continue
+		}
+		if !ValidCoinbase(b) {
+			return fmt.Errorf("block %d: coinbase isn't first or exceeds %v", height, MiningReward)
+		}
+		if !UniqueTransactions(b) {
+			return fmt.Errorf("block %d: duplicate transaction", height)
+		}
+		if !bc.VerifyBlock(b) {
+			return fmt.Errorf("block %d: invalid proof of work", height)
+		}
+	}
+	return nil
+}
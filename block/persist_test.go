@@ -0,0 +1,205 @@
+package block
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
+	"goblockchain/utils"
+	"goblockchain/wallet"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveToFileRoundTrip(t *testing.T) {
+	bc := newTestBlockchain(t, WithChainID("testnet"))
+	miner, alice := wallet.NewWallet(), wallet.NewWallet()
+	bc.blockchainAddress = miner.BlockchainAddress()
+	bc.Mining()
+	tx := wallet.NewTransaction(miner.PrivateKey(), miner.PublicKey(), miner.BlockchainAddress(), alice.BlockchainAddress(), 0.5)
+	tx.SetChainID(bc.ChainID())
+	signature := tx.GenerateSignature()
+	if !bc.AddTransaction(miner.BlockchainAddress(), alice.BlockchainAddress(), 0.5, tx.Timestamp(), miner.PublicKey(), signature) {
+		t.Fatal("transaction rejected")
+	}
+	for i := 0; i <= PruneSafetyDepth; i++ {
+		bc.Mining()
+	}
+	if err := bc.Prune(PruneSafetyDepth); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "chain.json")
+	if err := bc.SaveToFile(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded.SetLogger(utils.NewNopLogger())
+
+	if loaded.ChainID() != bc.ChainID() {
+		t.Errorf("loaded chain ID %q, want %q", loaded.ChainID(), bc.ChainID())
+	}
+	if loaded.Height() != bc.Height() || loaded.PrunedHeight() != bc.PrunedHeight() ||
+		loaded.LastBlock().Hash() != bc.LastBlock().Hash() {
+		t.Errorf("loaded height %d, pruned height %d and tip %x, want %d, %d and %x",
+			loaded.Height(), loaded.PrunedHeight(), loaded.LastBlock().Hash(),
+			bc.Height(), bc.PrunedHeight(), bc.LastBlock().Hash())
+	}
+	for _, address := range []string{miner.BlockchainAddress(), alice.BlockchainAddress()} {
+		if got, want := loaded.CalculateTotalAmount(address), bc.CalculateTotalAmount(address); got != want {
+			t.Errorf("balance of %s = %v, want %v", address, got, want)
+		}
+	}
+	// The transaction was pruned before saving, and still can't be replayed.
+	if loaded.AddTransaction(miner.BlockchainAddress(), alice.BlockchainAddress(), 0.5, tx.Timestamp(), miner.PublicKey(), signature) {
+		t.Error("pruned transaction replayed after loading")
+	}
+
+	// An explicit WithChainID overrides the saved chain ID.
+	overridden, err := LoadFromFile(path, WithChainID("other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if overridden.ChainID() != "other" {
+		t.Errorf("chain ID = %q, want option's", overridden.ChainID())
+	}
+}
+
+func TestSaveToFilePool(t *testing.T) {
+	alice, owner := wallet.NewWallet(), wallet.NewWallet()
+	keys := []*ecdsa.PublicKey{owner.PublicKey()}
+	multisig := MultisigAddress(1, keys)
+	bc := newTestBlockchain(t, WithGenesisAllocations(map[string]float32{alice.BlockchainAddress(): 10, multisig: 10}))
+	if !sendTransaction(bc, alice, "1Bob", 1) || !sendTransaction(bc, alice, "1Carol", 2) {
+		t.Fatal("transaction rejected")
+	}
+	tx := wallet.NewTransaction(owner.PrivateKey(), owner.PublicKey(), multisig, "1Bob", 3)
+	if err := bc.AddMultisigTransaction(multisig, "1Bob", 3, tx.Timestamp(), keys, 1,
+		[]*utils.Signature{tx.GenerateSignature()}); err != nil {
+		t.Fatal(err)
+	}
+	// Unsigned transactions aren't saved.
+	bc.transactionPool = append(bc.transactionPool, NewTransaction(MiningSender, "1Dave", 1))
+
+	path := filepath.Join(t.TempDir(), "chain.json")
+	if err := bc.SaveToFile(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := loaded.TransactionPool()
+	if len(pool) != 3 {
+		t.Fatalf("loaded pool of %d transactions, want 3", len(pool))
+	}
+	for i, t0 := range pool {
+		if t0.Hash() != bc.transactionPool[i].Hash() {
+			t.Errorf("pool[%d] = %v, want %v", i, t0, bc.transactionPool[i])
+		}
+	}
+
+	// A transaction whose signature no longer verifies is dropped.
+	data, _ := os.ReadFile(path)
+	os.WriteFile(path, []byte(strings.Replace(string(data), `"recipient_blockchain_address":"1Carol","value":2`,
+		`"recipient_blockchain_address":"1Carol","value":5`, 1)), 0644)
+	loaded, err = LoadFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := loaded.PoolSize(); n != 2 {
+		t.Errorf("loaded pool of %d transactions, want the tampered one dropped", n)
+	}
+}
+
+func TestLoadFromFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := LoadFromFile(filepath.Join(dir, "missing.json")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("missing file error = %v, want os.ErrNotExist", err)
+	}
+	empty := filepath.Join(dir, "empty.json")
+	if err := os.WriteFile(empty, []byte(`{"chain":[]}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadFromFile(empty); !errors.Is(err, ErrEmptyChain) {
+		t.Errorf("empty chain error = %v, want ErrEmptyChain", err)
+	}
+	if _, err := LoadSnapshot([]byte(`{"chain":[]}`)); err == nil {
+		t.Error("loaded empty snapshot")
+	}
+
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"not json", `chain`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "chain.json")
+			if err := os.WriteFile(path, []byte(tt.data), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := LoadFromFile(path); err == nil {
+				t.Error("loaded invalid file")
+			}
+		})
+	}
+}
+
+// breakProof is to change b's nonce so its proof of work isn't valid.
+func breakProof(b *Block) {
+	bc := &Blockchain{}
+	for b.nonce++; bc.ValidHeaderProof(b.Header()); b.nonce++ {
+	}
+}
+
+func TestLoadFromFileCorrupted(t *testing.T) {
+	bc := newTestBlockchain(t)
+	for i := 0; i < 3; i++ {
+		bc.Mining()
+	}
+	path := filepath.Join(t.TempDir(), "chain.json")
+	if err := bc.SaveToFile(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		corrupt func(p *persistedChain)
+		want    string
+	}{
+		{"proof of work", func(p *persistedChain) { breakProof(p.Chain[2]) }, "block 2"},
+		{"mining reward", func(p *persistedChain) {
+			p.Chain[2].transactions = append(p.Chain[2].transactions, NewTransaction(MiningSender, "1Miner", MiningReward))
+		}, "block 2: coinbase"},
+		{"link", func(p *persistedChain) { p.Chain[3].previousHash = [32]byte{1} }, "block 3"},
+		{"dropped block", func(p *persistedChain) { p.Chain = append(p.Chain[:1], p.Chain[2:]...) }, "block 1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var p persistedChain
+			if err := json.Unmarshal(data, &p); err != nil {
+				t.Fatal(err)
+			}
+			tt.corrupt(&p)
+			m, _ := json.Marshal(&p)
+			corrupted := filepath.Join(t.TempDir(), "chain.json")
+			if err := os.WriteFile(corrupted, m, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := LoadFromFile(corrupted)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %v, want it to name %s", err, tt.want)
+			}
+		})
+	}
+}
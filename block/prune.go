@@ -0,0 +1,62 @@
+package block
+
+import "fmt"
+
+// PruneSafetyDepth is minimum number of blocks Prune must keep.
+const PruneSafetyDepth = 6
+
+// Prune is to drop all but the last keep blocks, folding the balances of
+// the dropped blocks into the genesis state snapshot. IDs of the dropped
+// transactions are kept, so they can't be replayed. keep must be at least
+// the coinbase maturity, so only matured rewards are folded.
+func (bc *Blockchain) Prune(keep int) error {
+	if keep < PruneSafetyDepth {
+		return fmt.Errorf("prune keep %d is below safety depth %d", keep, PruneSafetyDepth)
+	}
+
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+
+	if keep < bc.coinbaseMaturity {
+		return fmt.Errorf("prune keep %d is below coinbase maturity %d", keep, bc.coinbaseMaturity)
+	}
+
+	if len(bc.chain) <= keep {
+		return nil
+	}
+	discard := bc.chain[:len(bc.chain)-keep]
+
+	bc.genesisState = foldBalances(bc.genesisState, discard)
+	bc.recordPrunedIDs(discard)
+	bc.prunedHeight += len(discard)
+	bc.chain = append([]*Block(nil), bc.chain[len(bc.chain)-keep:]...)
+	bc.logger.Info("prune", "action", "prune", "status", "success", "pruned_height", bc.prunedHeight)
+	return nil
+}
+
+// foldBalances is to return copy of state with balances moved by blocks.
+func foldBalances(state map[string]float32, blocks []*Block) map[string]float32 {
+	balances := make(map[string]float32, len(state))
+	for address, amount := range state {
+		balances[address] = amount
+	}
+	for _, b := range blocks {
+		for _, t := range b.transactions {
+			balances[t.recipientBlockchainAddress] += t.value
+			balances[t.senderBlockchainAddress] -= t.value
+		}
+	}
+	return balances
+}
+
+// PrunedHeight is to return number of blocks dropped by Prune.
+func (bc *Blockchain) PrunedHeight() int {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	return bc.prunedHeight
+}
+
+// Height is to return chain height including pruned blocks.
+func (bc *Blockchain) Height() int {
+	return bc.prunedHeight + len(bc.chain)
+}
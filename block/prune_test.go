@@ -0,0 +1,93 @@
+package block
+
+import (
+	"goblockchain/wallet"
+	"testing"
+)
+
+func TestPrune(t *testing.T) {
+	bc := newTestBlockchain(t)
+	miner, alice, bob := wallet.NewWallet(), wallet.NewWallet(), wallet.NewWallet()
+	bc.blockchainAddress = miner.BlockchainAddress()
+	for i := 0; i < 3; i++ {
+		bc.Mining()
+	}
+	if !sendTransaction(bc, miner, alice.BlockchainAddress(), 2) {
+		t.Fatal("transaction to alice rejected")
+	}
+	bc.Mining()
+	if !sendTransaction(bc, alice, bob.BlockchainAddress(), 0.5) {
+		t.Fatal("transaction to bob rejected")
+	}
+	for i := 0; i < PruneSafetyDepth; i++ {
+		bc.Mining()
+	}
+
+	addresses := []string{miner.BlockchainAddress(), alice.BlockchainAddress(), bob.BlockchainAddress(), MiningSender}
+	before := make(map[string]float32)
+	for _, address := range addresses {
+		before[address] = bc.CalculateTotalAmount(address)
+	}
+	height := bc.Height()
+
+	if err := bc.Prune(PruneSafetyDepth - 1); err == nil {
+		t.Error("pruned below safety depth")
+	}
+	if err := bc.Prune(PruneSafetyDepth); err != nil {
+		t.Fatal(err)
+	}
+	if len(bc.chain) != PruneSafetyDepth || bc.PrunedHeight() != height-PruneSafetyDepth || bc.Height() != height {
+		t.Fatalf("kept %d blocks, pruned height %d, height %d", len(bc.chain), bc.PrunedHeight(), bc.Height())
+	}
+	for _, address := range addresses {
+		if got := bc.CalculateTotalAmount(address); got != before[address] {
+			t.Errorf("balance of %s = %v after pruning, want %v", address, got, before[address])
+		}
+	}
+
+	// Balances keep adding up on top of the snapshot.
+	bc.Mining()
+	if got, want := bc.CalculateTotalAmount(miner.BlockchainAddress()), before[miner.BlockchainAddress()]+MiningReward; got != want {
+		t.Errorf("miner balance = %v after mining, want %v", got, want)
+	}
+	if err := bc.Prune(len(bc.chain)); err != nil {
+		t.Errorf("prune keeping every block: %v", err)
+	}
+}
+
+func TestTipAfterPrune(t *testing.T) {
+	bc := newTestBlockchain(t)
+	for i := 0; i < PruneSafetyDepth+2; i++ {
+		bc.Mining()
+	}
+	if err := bc.Prune(PruneSafetyDepth); err != nil {
+		t.Fatal(err)
+	}
+	if length, hash := bc.Tip(); length != PruneSafetyDepth+3 || hash != bc.LastBlock().Hash() {
+		t.Errorf("tip = %d, %x; want %d, %x", length, hash, PruneSafetyDepth+3, bc.LastBlock().Hash())
+	}
+}
+
+func TestPruneBelowCoinbaseMaturity(t *testing.T) {
+	bc := newTestBlockchain(t)
+	miner := bc.blockchainAddress
+	bc.SetCoinbaseMaturity(PruneSafetyDepth + 4)
+	for i := 0; i < PruneSafetyDepth+6; i++ {
+		bc.Mining()
+	}
+	spendable := bc.CalculateTotalAmount(miner)
+
+	if err := bc.Prune(PruneSafetyDepth); err == nil {
+		t.Fatal("pruned fewer blocks than coinbase maturity")
+	}
+	if bc.PrunedHeight() != 0 || bc.CalculateTotalAmount(miner) != spendable {
+		t.Errorf("rejected prune changed the chain: pruned height %d, balance %v, want 0, %v", bc.PrunedHeight(), bc.CalculateTotalAmount(miner), spendable)
+	}
+
+	if err := bc.Prune(bc.CoinbaseMaturity()); err != nil {
+		t.Fatal(err)
+	}
+	if got := bc.CalculateTotalAmount(miner); got != spendable {
+		t.Errorf("miner balance = %v after pruning at maturity, want %v", got, spendable)
+	}
+}
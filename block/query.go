@@ -0,0 +1,187 @@
+package block
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrHeightOutOfRange is returned for a height that's pruned or not mined yet.
+var ErrHeightOutOfRange = errors.New("height out of range")
+
+// Iterate is to call fn with the height and block of each block from the
+// oldest kept to the tip, stopping at and returning the first error fn
+// returns. Blocks aren't copied and the chain is read locked meanwhile, so
+// fn must not mutate blocks nor call methods changing the chain.
+func (bc *Blockchain) Iterate(fn func(index int, b *Block) error) error {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	for i, b := range bc.chain {
+		if err := fn(bc.prunedHeight+i, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ChainPage is to return up to limit blocks from height from, filtered to
+// blocks with transactions involving address when it isn't empty, and the
+// height to continue from or -1 when there are no more blocks. Pruned
+// blocks are skipped.
+func (bc *Blockchain) ChainPage(from int, limit int, address string) ([]*Block, int) {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	if from < bc.prunedHeight {
+		from = bc.prunedHeight
+	}
+	blocks := make([]*Block, 0, limit)
+	for height := from; height < bc.Height(); height++ {
+		if len(blocks) == limit {
+			return blocks, height
+		}
+		b := bc.chain[height-bc.prunedHeight]
+		if address == "" || b.HasAddress(address) {
+			blocks = append(blocks, b)
+		}
+	}
+	return blocks, -1
+}
+
+// HasAddress is to check block has transactions involving address.
+func (b *Block) HasAddress(address string) bool {
+	for _, t := range b.transactions {
+		if t.senderBlockchainAddress == address || t.recipientBlockchainAddress == address {
+			return true
+		}
+	}
+	return false
+}
+
+// FindTransaction is to find transaction by hash in the chain and the
+// transaction pool. It returns the containing block height, or -1 when the
+// transaction is pending, and false when it's unknown.
+func (bc *Blockchain) FindTransaction(hash [32]byte) (*Transaction, int, bool) {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	for i := len(bc.chain) - 1; i >= 0; i-- {
+		for _, t := range bc.chain[i].transactions {
+			if t.Hash() == hash {
+				return t, bc.prunedHeight + i, true
+			}
+		}
+	}
+	for _, t := range bc.transactionPool {
+		if t.Hash() == hash {
+			return t, -1, true
+		}
+	}
+	return nil, -1, false
+}
+
+// Confirmations is to return number of confirmations of the block at height,
+// counting the block itself, or 0 if it's pruned or not mined yet.
+func (bc *Blockchain) Confirmations(height int) int {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	return bc.confirmations(height)
+}
+
+// confirmations is Confirmations for callers holding bc.mux.
+func (bc *Blockchain) confirmations(height int) int {
+	if height < bc.prunedHeight || height >= bc.Height() {
+		return 0
+	}
+	return bc.Height() - height
+}
+
+// KnownAddress is to check address has appeared in the chain as a sender or
+// recipient, or holds a balance carried over from pruned blocks.
+func (bc *Blockchain) KnownAddress(address string) bool {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	return bc.knownAddress(address)
+}
+
+func (bc *Blockchain) knownAddress(address string) bool {
+	if _, ok := bc.genesisState[address]; ok {
+		return true
+	}
+	for _, b := range bc.chain {
+		if b.HasAddress(address) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddressBalance is balance of an address.
+type AddressBalance struct {
+	Address string  `json:"address"`
+	Balance float32 `json:"balance"`
+}
+
+// balances is to return ledger balance of every address, pruned blocks and
+// immature mining rewards included.
+func (bc *Blockchain) balances() map[string]float32 {
+	return foldBalances(bc.genesisState, bc.chain)
+}
+
+// RichList is to return up to n addresses with the highest positive balance,
+// highest first and ties ordered by address. MiningSender isn't listed.
+func (bc *Blockchain) RichList(n int) []AddressBalance {
+	bc.mux.RLock()
+	balances := bc.balances()
+	bc.mux.RUnlock()
+
+	list := make([]AddressBalance, 0, len(balances))
+	for address, balance := range balances {
+		if address != MiningSender && balance > 0 {
+			list = append(list, AddressBalance{Address: address, Balance: balance})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Balance != list[j].Balance {
+			return list[i].Balance > list[j].Balance
+		}
+		return list[i].Address < list[j].Address
+	})
+	if n >= 0 && len(list) > n {
+		list = list[:n]
+	}
+	return list
+}
+
+// Preview is to return balances of every address after applying txs in
+// order on top of the chain, without changing anything. It fails with
+// ErrInsufficientBalance naming the sender if a transaction would overdraw.
+// MiningSender isn't checked nor included. Starting balances are those of
+// RichList: pooled transactions aren't applied, and mining rewards count
+// before they mature.
+func (bc *Blockchain) Preview(txs []*Transaction) (map[string]float32, error) {
+	bc.mux.RLock()
+	balances := bc.balances()
+	bc.mux.RUnlock()
+
+	for i, t := range txs {
+		if t.senderBlockchainAddress != MiningSender && balances[t.senderBlockchainAddress] < t.value {
+			return nil, fmt.Errorf("transaction %d: %w: %s", i, ErrInsufficientBalance, t.senderBlockchainAddress)
+		}
+		balances[t.senderBlockchainAddress] -= t.value
+		balances[t.recipientBlockchainAddress] += t.value
+	}
+	delete(balances, MiningSender)
+	return balances, nil
+}
+
+// BalanceAtHeight is to return address's balance as of the block at height,
+// summing transactions of that block and the ones before it. Mining rewards
+// count once they were mature at that height. Heights of pruned blocks
+// can't be queried.
+func (bc *Blockchain) BalanceAtHeight(address string, height int) (float32, error) {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	if height < bc.prunedHeight || height >= bc.Height() {
+		return 0, fmt.Errorf("%w: %d not in [%d, %d]", ErrHeightOutOfRange, height, bc.prunedHeight, bc.Height()-1)
+	}
+	return bc.amountOf(address, height-bc.prunedHeight+1), nil
+}
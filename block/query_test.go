@@ -0,0 +1,259 @@
+package block
+
+import (
+	"crypto/ecdsa"
+	"errors"
+	"fmt"
+	"goblockchain/utils"
+	"goblockchain/wallet"
+	"strings"
+	"testing"
+)
+
+func TestIterate(t *testing.T) {
+	bc := newTestBlockchain(t)
+	for i := 0; i < 5; i++ {
+		bc.Mining()
+	}
+	stop := errors.New("stop")
+	tests := []struct {
+		name     string
+		stopAt   int
+		want     error
+		wantSeen []int
+	}{
+		{"whole chain", -1, nil, []int{0, 1, 2, 3, 4, 5}},
+		{"stop at 3", 3, stop, []int{0, 1, 2, 3}},
+		{"stop at genesis", 0, stop, []int{0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen []int
+			err := bc.Iterate(func(index int, b *Block) error {
+				seen = append(seen, index)
+				if b != bc.chain[index] {
+					t.Errorf("block at %d isn't the chain's", index)
+				}
+				if index == tt.stopAt {
+					return stop
+				}
+				return nil
+			})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+			if fmt.Sprint(seen) != fmt.Sprint(tt.wantSeen) {
+				t.Errorf("visited %v, want %v", seen, tt.wantSeen)
+			}
+		})
+	}
+
+	// Heights count pruned blocks.
+	for i := 0; i < PruneSafetyDepth; i++ {
+		bc.Mining()
+	}
+	if err := bc.Prune(PruneSafetyDepth); err != nil {
+		t.Fatal(err)
+	}
+	first := -1
+	bc.Iterate(func(index int, b *Block) error {
+		first = index
+		return stop
+	})
+	if first != bc.PrunedHeight() {
+		t.Errorf("first height = %d, want %d", first, bc.PrunedHeight())
+	}
+}
+
+func TestChainPageAfterPrune(t *testing.T) {
+	bc := newTestBlockchain(t)
+	for i := 0; i < PruneSafetyDepth+2; i++ {
+		bc.Mining()
+	}
+	if err := bc.Prune(PruneSafetyDepth); err != nil {
+		t.Fatal(err)
+	}
+	pruned := bc.PrunedHeight()
+
+	blocks, next := bc.ChainPage(0, 2, "")
+	if len(blocks) != 2 || blocks[0] != bc.chain[0] || next != pruned+2 {
+		t.Errorf("page from 0 = %d blocks, next %d; want pruned blocks skipped and next %d", len(blocks), next, pruned+2)
+	}
+	blocks, next = bc.ChainPage(next, PruneSafetyDepth, "")
+	if len(blocks) != PruneSafetyDepth-2 || blocks[0] != bc.chain[2] || next != -1 {
+		t.Errorf("page from %d = %d blocks, next %d", pruned+2, len(blocks), next)
+	}
+}
+
+func TestFindTransaction(t *testing.T) {
+	alice := wallet.NewWallet()
+	bc := newTestBlockchain(t, WithGenesisAllocations(map[string]float32{alice.BlockchainAddress(): 10}))
+	if !sendTransaction(bc, alice, "1Bob", 1) {
+		t.Fatal("transaction rejected")
+	}
+	hash := bc.TransactionPool()[0].Hash()
+
+	if tx, height, ok := bc.FindTransaction(hash); !ok || height != -1 || tx.Hash() != hash {
+		t.Fatalf("pending transaction found at %d, %v", height, ok)
+	}
+	bc.Mining()
+	bc.Mining()
+	if _, height, ok := bc.FindTransaction(hash); !ok || height != 1 {
+		t.Fatalf("mined transaction found at %d, %v; want height 1", height, ok)
+	}
+	if got := bc.Confirmations(1); got != 2 {
+		t.Errorf("confirmations = %d, want 2", got)
+	}
+	if got := bc.Confirmations(bc.Height()); got != 0 {
+		t.Errorf("confirmations of unmined height = %d, want 0", got)
+	}
+	if _, _, ok := bc.FindTransaction([32]byte{1}); ok {
+		t.Error("unknown transaction found")
+	}
+}
+
+func TestRequireKnownRecipient(t *testing.T) {
+	alice, bob := wallet.NewWallet(), wallet.NewWallet()
+	bc := newTestBlockchain(t, WithGenesisAllocations(map[string]float32{alice.BlockchainAddress(): 10}))
+	if !sendTransaction(bc, alice, "1Typo", 1) {
+		t.Fatal("transaction to unknown recipient rejected by default")
+	}
+
+	bc.SetRequireKnownRecipient(true)
+	if !bc.KnownAddress(alice.BlockchainAddress()) || bc.KnownAddress(bob.BlockchainAddress()) {
+		t.Error("known addresses wrong")
+	}
+	if sendTransaction(bc, alice, bob.BlockchainAddress(), 1) {
+		t.Error("transaction to unknown recipient accepted")
+	}
+	bc.Mining()
+	// "1Typo" is known once a block pays it.
+	if !sendTransaction(bc, alice, "1Typo", 1) {
+		t.Error("transaction to known recipient rejected")
+	}
+
+	// Multisig transactions are checked too.
+	keys := []*ecdsa.PublicKey{alice.PublicKey()}
+	sender := MultisigAddress(1, keys)
+	multisig := newTestBlockchain(t, WithGenesisAllocations(map[string]float32{sender: 10}))
+	multisig.SetRequireKnownRecipient(true)
+	tx := wallet.NewTransaction(alice.PrivateKey(), alice.PublicKey(), sender, bob.BlockchainAddress(), 1)
+	tx.SetTimestamp(1)
+	err := multisig.AddMultisigTransaction(sender, bob.BlockchainAddress(), 1, 1, keys, 1, []*utils.Signature{tx.GenerateSignature()})
+	if !errors.Is(err, ErrUnknownRecipient) {
+		t.Errorf("multisig error = %v, want ErrUnknownRecipient", err)
+	}
+}
+
+func TestRichList(t *testing.T) {
+	alice, bob := wallet.NewWallet(), wallet.NewWallet()
+	bc := newTestBlockchain(t, WithGenesisAllocations(map[string]float32{
+		alice.BlockchainAddress(): 10, bob.BlockchainAddress(): 5, "1Carol": 5,
+	}))
+	if !sendTransaction(bc, bob, alice.BlockchainAddress(), 5) {
+		t.Fatal("transaction rejected")
+	}
+	bc.Mining()
+
+	want := []AddressBalance{
+		{alice.BlockchainAddress(), 15},
+		{"1Carol", 5},
+		{bc.blockchainAddress, MiningReward},
+	}
+	got := bc.RichList(-1)
+	if len(got) != len(want) {
+		t.Fatalf("rich list = %v, want %v without emptied and mining sender addresses", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("rich list[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+	if got := bc.RichList(1); len(got) != 1 || got[0] != want[0] {
+		t.Errorf("rich list of 1 = %v", got)
+	}
+}
+
+func TestPreview(t *testing.T) {
+	bc := newTestBlockchain(t, WithGenesisAllocations(map[string]float32{"1Alice": 10}))
+	height := bc.Height()
+
+	got, err := bc.Preview([]*Transaction{
+		NewTransaction("1Alice", "1Bob", 4),
+		NewTransaction("1Bob", "1Carol", 3),
+		NewTransaction(MiningSender, "1Carol", 1),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]float32{"1Alice": 6, "1Bob": 1, "1Carol": 4}
+	if len(got) != len(want) {
+		t.Errorf("preview = %v, want %v", got, want)
+	}
+	for address, balance := range want {
+		if got[address] != balance {
+			t.Errorf("%s balance = %v, want %v", address, got[address], balance)
+		}
+	}
+
+	_, err = bc.Preview([]*Transaction{
+		NewTransaction("1Alice", "1Bob", 4),
+		NewTransaction("1Bob", "1Carol", 5),
+	})
+	if !errors.Is(err, ErrInsufficientBalance) || !strings.Contains(err.Error(), "transaction 1") {
+		t.Errorf("error = %v, want ErrInsufficientBalance of transaction 1", err)
+	}
+	if bc.Height() != height || bc.CalculateTotalAmount("1Alice") != 10 || bc.CalculateTotalAmount("1Bob") != 0 {
+		t.Error("preview changed the chain")
+	}
+}
+
+func TestBalanceAtHeight(t *testing.T) {
+	alice := wallet.NewWallet()
+	bc := newTestBlockchain(t, WithGenesisAllocations(map[string]float32{alice.BlockchainAddress(): 10}))
+	bc.SetCoinbaseMaturity(2)
+	miner := bc.blockchainAddress
+	bc.Mining()
+	if !sendTransaction(bc, alice, "1Bob", 4) {
+		t.Fatal("transaction rejected")
+	}
+	bc.Mining()
+	bc.Mining()
+
+	tests := []struct {
+		address string
+		height  int
+		want    float32
+	}{
+		{alice.BlockchainAddress(), 0, 10},
+		{alice.BlockchainAddress(), 1, 10},
+		{alice.BlockchainAddress(), 2, 6},
+		{"1Bob", 1, 0},
+		{"1Bob", 3, 4},
+		// The reward of block 1 matures with block 2.
+		{miner, 1, 0},
+		{miner, 2, MiningReward},
+		{miner, 3, 2 * MiningReward},
+	}
+	for _, tt := range tests {
+		got, err := bc.BalanceAtHeight(tt.address, tt.height)
+		if err != nil || got != tt.want {
+			t.Errorf("balance of %s at %d = %v, %v, want %v", tt.address, tt.height, got, err, tt.want)
+		}
+	}
+	if got, _ := bc.BalanceAtHeight(miner, bc.Height()-1); got != bc.CalculateTotalAmount(miner) {
+		t.Errorf("balance at the tip = %v, want %v", got, bc.CalculateTotalAmount(miner))
+	}
+
+	for i := 0; i < PruneSafetyDepth; i++ {
+		bc.Mining()
+	}
+	if err := bc.Prune(PruneSafetyDepth); err != nil {
+		t.Fatal(err)
+	}
+	for _, height := range []int{-1, bc.PrunedHeight() - 1, bc.Height()} {
+		if _, err := bc.BalanceAtHeight(miner, height); !errors.Is(err, ErrHeightOutOfRange) {
+			t.Errorf("height %d: error = %v, want ErrHeightOutOfRange", height, err)
+		}
+	}
+}
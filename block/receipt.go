@@ -0,0 +1,80 @@
+package block
+
+import (
+	"encoding/json"
+	"errors"
+	"goblockchain/utils"
+)
+
+// Receipt statuses.
+const (
+	ReceiptStatusPending   = "pending"
+	ReceiptStatusConfirmed = "confirmed"
+)
+
+// ErrTransactionNotFound is returned by Receipt for a transaction neither
+// in the chain nor in the pool.
+var ErrTransactionNotFound = errors.New("transaction not found")
+
+// Receipt is where a transaction was mined. BlockIndex is the block's
+// height, counting pruned blocks. Block fields are unset while it's
+// pending. Transactions carry no fee, so none is reported.
+type Receipt struct {
+	TransactionHash  [32]byte
+	Status           string
+	BlockIndex       int
+	BlockHash        [32]byte
+	TransactionIndex int
+	Confirmations    int
+}
+
+// Receipt is to return receipt of transaction with hash txHash,
+// or ErrTransactionNotFound.
+func (bc *Blockchain) Receipt(txHash [32]byte) (*Receipt, error) {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+
+	for i := len(bc.chain) - 1; i >= 0; i-- {
+		b := bc.chain[i]
+		height := bc.prunedHeight + i
+		for j, t := range b.transactions {
+			if t.Hash() == txHash {
+				return &Receipt{
+					TransactionHash:  txHash,
+					Status:           ReceiptStatusConfirmed,
+					BlockIndex:       height,
+					BlockHash:        b.Hash(),
+					TransactionIndex: j,
+					Confirmations:    bc.confirmations(height),
+				}, nil
+			}
+		}
+	}
+	if bc.poolIDs[txHash] {
+		return &Receipt{TransactionHash: txHash, Status: ReceiptStatusPending, BlockIndex: -1, TransactionIndex: -1}, nil
+	}
+	return nil, ErrTransactionNotFound
+}
+
+// MarshalJSON is override Receipt's marshaljson.
+func (r *Receipt) MarshalJSON() ([]byte, error) {
+	v := struct {
+		TransactionHash  string  `json:"transaction_hash"`
+		Status           string  `json:"status"`
+		BlockIndex       *int    `json:"block_index"`
+		BlockHash        *string `json:"block_hash"`
+		TransactionIndex *int    `json:"transaction_index"`
+		Confirmations    int     `json:"confirmations"`
+	}{
+		TransactionHash: utils.HashToHex(r.TransactionHash),
+		Status:          r.Status,
+		Confirmations:   r.Confirmations,
+	}
+	if r.Status == ReceiptStatusConfirmed {
+		blockHash := utils.HashToHex(r.BlockHash)
+		v.BlockIndex = &r.BlockIndex
+		v.BlockHash = &blockHash
+		v.TransactionIndex = &r.TransactionIndex
+	}
+	return json.Marshal(v)
+}
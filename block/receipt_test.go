@@ -0,0 +1,117 @@
+package block
+
+import (
+	"encoding/json"
+	"errors"
+	"goblockchain/wallet"
+	"testing"
+)
+
+func TestReceipt(t *testing.T) {
+	w := wallet.NewWallet()
+	bc := newTestBlockchain(t, WithGenesisAllocations(map[string]float32{w.BlockchainAddress(): 100}))
+	if !sendTransaction(bc, w, "1Bob", 1) {
+		t.Fatal("transaction rejected")
+	}
+	confirmed := bc.TransactionPool()[0].Hash()
+	bc.Mining()
+	mined := bc.LastBlock()
+	coinbase := mined.transactions[0]
+	bc.Mining()
+	if !sendTransaction(bc, w, "1Bob", 2) {
+		t.Fatal("transaction rejected")
+	}
+	pending := bc.TransactionPool()[0].Hash()
+
+	tests := []struct {
+		name          string
+		hash          [32]byte
+		err           error
+		status        string
+		blockIndex    int
+		blockHash     [32]byte
+		txIndex       int
+		confirmations int
+	}{
+		{"confirmed", confirmed, nil, ReceiptStatusConfirmed, 1, mined.Hash(), 1, 2},
+		{"coinbase", coinbase.Hash(), nil, ReceiptStatusConfirmed, 1, mined.Hash(), 0, 2},
+		{"pending", pending, nil, ReceiptStatusPending, -1, [32]byte{}, -1, 0},
+		{"unknown", [32]byte{1}, ErrTransactionNotFound, "", 0, [32]byte{}, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := bc.Receipt(tt.hash)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if r.TransactionHash != tt.hash || r.Status != tt.status || r.BlockIndex != tt.blockIndex ||
+				r.BlockHash != tt.blockHash || r.TransactionIndex != tt.txIndex || r.Confirmations != tt.confirmations {
+				t.Errorf("receipt = %+v", r)
+			}
+		})
+	}
+}
+
+func TestReceiptAfterPrune(t *testing.T) {
+	w := wallet.NewWallet()
+	bc := newTestBlockchain(t, WithGenesisAllocations(map[string]float32{w.BlockchainAddress(): 100}))
+	for i := 0; i < 4; i++ {
+		bc.Mining()
+	}
+	if !sendTransaction(bc, w, "1Bob", 1) {
+		t.Fatal("transaction rejected")
+	}
+	tx := bc.TransactionPool()[0].Hash()
+	bc.Mining()
+	for i := 0; i < PruneSafetyDepth; i++ {
+		bc.Mining()
+	}
+	if err := bc.Prune(PruneSafetyDepth + 1); err != nil {
+		t.Fatal(err)
+	}
+	if bc.PrunedHeight() == 0 {
+		t.Fatal("nothing pruned")
+	}
+
+	r, err := bc.Receipt(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Confirmations count the block itself.
+	if r.BlockIndex != 5 || r.Confirmations != PruneSafetyDepth+1 {
+		t.Errorf("receipt at height %d with %d confirmations, want 5 and %d", r.BlockIndex, r.Confirmations, PruneSafetyDepth+1)
+	}
+	if b := bc.BlockAt(r.BlockIndex); b == nil || b.Hash() != r.BlockHash {
+		t.Error("receipt height isn't the block's height")
+	}
+}
+
+func TestReceiptJSON(t *testing.T) {
+	zero := "0000000000000000000000000000000000000000000000000000000000000000"
+	tests := []struct {
+		name    string
+		receipt *Receipt
+		want    string
+	}{
+		{"pending", &Receipt{Status: ReceiptStatusPending, BlockIndex: -1, TransactionIndex: -1},
+			`{"transaction_hash":"` + zero + `","status":"pending",` +
+				`"block_index":null,"block_hash":null,"transaction_index":null,"confirmations":0}`},
+		{"confirmed", &Receipt{Status: ReceiptStatusConfirmed, BlockIndex: 3, TransactionIndex: 2, Confirmations: 4},
+			`{"transaction_hash":"` + zero + `","status":"confirmed",` +
+				`"block_index":3,"block_hash":"` + zero + `","transaction_index":2,"confirmations":4}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := json.Marshal(tt.receipt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(m) != tt.want {
+				t.Errorf("got %s, want %s", m, tt.want)
+			}
+		})
+	}
+}
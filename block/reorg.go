@@ -0,0 +1,36 @@
+package block
+
+// DefaultMaxReorgDepth is default max number of blocks a reorganization may roll back.
+const DefaultMaxReorgDepth = 100
+
+// MaxReorgDepth is to return max number of blocks a reorganization may roll back.
+func (bc *Blockchain) MaxReorgDepth() int {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	return bc.maxReorgDepth
+}
+
+// SetMaxReorgDepth is set max number of blocks a reorganization may roll back.
+// 0 disables the limit.
+func (bc *Blockchain) SetMaxReorgDepth(depth int) {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	bc.maxReorgDepth = depth
+}
+
+// reorgDepth is to return number of local blocks replaced by switching to chain,
+// whose first block is at height root while the local chain may be pruned.
+func (bc *Blockchain) reorgDepth(chain []*Block, root int) int {
+	for i := len(bc.chain) - 1; i >= 0; i-- {
+		index := bc.prunedHeight + i - root
+		if index >= 0 && index < len(chain) && chain[index].Hash() == bc.chain[i].Hash() {
+			return len(bc.chain) - 1 - i
+		}
+	}
+	return len(bc.chain)
+}
+
+// exceedsReorgDepth is to report whether rolling back depth blocks is over the limit.
+func (bc *Blockchain) exceedsReorgDepth(depth int) bool {
+	return bc.maxReorgDepth > 0 && depth > bc.maxReorgDepth
+}
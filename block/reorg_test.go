@@ -0,0 +1,202 @@
+package block
+
+import (
+	"errors"
+	"goblockchain/wallet"
+	"testing"
+)
+
+// forkedChains is to return bc having mined own blocks and a peer sharing
+// bc's genesis which mined peerBlocks blocks of its own.
+func forkedChains(t *testing.T, own, peerBlocks int) (*Blockchain, *Blockchain) {
+	t.Helper()
+	bc := newTestBlockchain(t)
+	peer := forkChain(t, bc)
+	for i := 0; i < own; i++ {
+		bc.Mining()
+	}
+	for i := 0; i < peerBlocks; i++ {
+		peer.Mining()
+	}
+	return bc, peer
+}
+
+func TestReorgCallback(t *testing.T) {
+	bc, peer := forkedChains(t, 2, 3)
+	var rolledBack, added int
+	bc.OnReorg(func(r, a int) { rolledBack, added = r, a })
+	for _, b := range peer.chain[1:] {
+		bc.AddBlock(b)
+	}
+	if bc.LastBlock() != peer.LastBlock() {
+		t.Fatal("not reorganized onto the longer fork")
+	}
+	if rolledBack != 2 || added != 3 {
+		t.Errorf("reorg rolled back %d and added %d, want 2 and 3", rolledBack, added)
+	}
+}
+
+func TestMaxReorgDepth(t *testing.T) {
+	bc, peer := forkedChains(t, 2, 3)
+	bc.SetMaxReorgDepth(1)
+	reorgs := 0
+	bc.OnReorg(func(int, int) { reorgs++ })
+	for _, b := range peer.chain[1:] {
+		if err := bc.AddBlock(b); !errors.Is(err, ErrBlockNotExtendTip) {
+			t.Fatalf("error = %v, want ErrBlockNotExtendTip", err)
+		}
+	}
+	if len(bc.chain) != 3 || reorgs != 0 {
+		t.Errorf("chain length %d after %d reorgs, want the local chain kept", len(bc.chain), reorgs)
+	}
+
+	servePeerChain(t, bc, peer)
+	if bc.ResolveConflicts() {
+		t.Error("resolve conflicts rolled back more than the max depth")
+	}
+	bc.SetMaxReorgDepth(0)
+	if !bc.ResolveConflicts() || bc.LastBlock().Hash() != peer.LastBlock().Hash() {
+		t.Error("resolve conflicts without limit didn't adopt the longer chain")
+	}
+	if reorgs != 1 {
+		t.Errorf("reorgs = %d, want 1", reorgs)
+	}
+}
+
+func TestResolveConflictsByWork(t *testing.T) {
+	bc, peer := forkedChains(t, 2, 1)
+	servePeerChain(t, bc, peer)
+	if !peer.MineWithDifficulty(MiningDifficulty + 1) {
+		t.Fatal("peer mining failed")
+	}
+	if peer.Height() != bc.Height() {
+		t.Fatalf("peer height %d, want equal to %d", peer.Height(), bc.Height())
+	}
+	// Of equal length chains, the one mined at higher difficulty wins.
+	if !bc.ResolveConflicts() || bc.LastBlock().Hash() != peer.LastBlock().Hash() {
+		t.Fatal("chain of more work not adopted")
+	}
+
+	// A longer chain of less work isn't.
+	other := forkChain(t, bc)
+	other.chain = other.chain[:1]
+	for i := 0; i < bc.Height(); i++ {
+		other.Mining()
+	}
+	servePeerChain(t, bc, other)
+	if bc.ResolveConflicts() {
+		t.Error("longer chain of less work adopted")
+	}
+}
+
+func TestResolveConflictsUnknownRoot(t *testing.T) {
+	bc := newTestBlockchain(t)
+	// A chain of a genesis of its own, however long, isn't adopted, nor is
+	// its peer penalized.
+	peer := newTestBlockchain(t, WithGenesisAllocations(map[string]float32{"1Mallory": 1000}))
+	peer.Mining()
+	peer.Mining()
+	servePeerChain(t, bc, peer)
+	if bc.ResolveConflicts() {
+		t.Fatal("chain of unknown genesis adopted")
+	}
+	if len(bc.Peers()) != 1 || bc.Peers()[0].Score != 0 {
+		t.Errorf("peers = %+v, want peer unpenalized", bc.Peers())
+	}
+
+	// Unless its genesis is the pinned one.
+	genesis, _ := peer.GenesisHash()
+	pinned := newTestBlockchain(t, WithGenesisHash(genesis))
+	servePeerChain(t, pinned, peer)
+	if !pinned.ResolveConflicts() || pinned.CalculateTotalAmount("1Mallory") != 1000 {
+		t.Error("chain of pinned genesis not adopted")
+	}
+}
+
+func TestResolveConflictsPrunedPeer(t *testing.T) {
+	w := wallet.NewWallet()
+	bc := newTestBlockchain(t)
+	bc.blockchainAddress = w.BlockchainAddress()
+	for i := 0; i < PruneSafetyDepth+2; i++ {
+		bc.Mining()
+	}
+	peer := forkChain(t, bc)
+	peer.Mining()
+	if err := peer.Prune(PruneSafetyDepth); err != nil {
+		t.Fatal(err)
+	}
+	servePeerChain(t, bc, peer)
+
+	if !bc.ResolveConflicts() {
+		t.Fatal("pruned chain of more work not adopted")
+	}
+	if bc.PrunedHeight() != peer.PrunedHeight() || bc.LastBlock().Hash() != peer.LastBlock().Hash() {
+		t.Errorf("pruned height %d and tip %x, want %d and %x",
+			bc.PrunedHeight(), bc.LastBlock().Hash(), peer.PrunedHeight(), peer.LastBlock().Hash())
+	}
+	// Rewards of the blocks folded away are kept.
+	if got, want := bc.CalculateTotalAmount(w.BlockchainAddress()), float32(PruneSafetyDepth+2)*MiningReward; got != want {
+		t.Errorf("balance = %v, want %v", got, want)
+	}
+}
+
+func TestResolveConflictsReconcilesMempool(t *testing.T) {
+	alice := wallet.NewWallet()
+	bc := newTestBlockchain(t, WithGenesisAllocations(map[string]float32{alice.BlockchainAddress(): 10}))
+	peer := forkChain(t, bc)
+	if !sendTransaction(bc, alice, "1Bob", 1) || !sendTransaction(bc, alice, "1Erin", 6) {
+		t.Fatal("transaction rejected")
+	}
+	bc.Mining()
+
+	// A payment both nodes pooled but only the peer confirmed.
+	tx := wallet.NewTransaction(alice.PrivateKey(), alice.PublicKey(), alice.BlockchainAddress(), "1Dave", 2)
+	tx.SetChainID(bc.ChainID())
+	for _, c := range []*Blockchain{bc, peer} {
+		if !c.AddTransaction(alice.BlockchainAddress(), "1Dave", 2, tx.Timestamp(), alice.PublicKey(), tx.GenerateSignature()) {
+			t.Fatal("transaction rejected")
+		}
+	}
+	if !sendTransaction(peer, alice, "1Carol", 2) {
+		t.Fatal("transaction rejected")
+	}
+	peer.Mining()
+	peer.Mining()
+	servePeerChain(t, bc, peer)
+	if !bc.ResolveConflicts() {
+		t.Fatal("longer chain not adopted")
+	}
+
+	// The payment to Dave leaves the pool. The one to Bob is pooled again,
+	// and the one to Erin is dropped, as Alice can't afford it along with it.
+	pool := bc.TransactionPool()
+	if len(pool) != 1 || pool[0].recipientBlockchainAddress != "1Bob" {
+		t.Errorf("pool = %v, want only the payment to 1Bob", pool)
+	}
+}
+
+func TestResolveConflictsDropsUnsignedTransactions(t *testing.T) {
+	alice := wallet.NewWallet()
+	bc := newTestBlockchain(t, WithGenesisAllocations(map[string]float32{alice.BlockchainAddress(): 10}))
+	peer, other := forkChain(t, bc), forkChain(t, bc)
+	if !sendTransaction(peer, alice, "1Bob", 1) {
+		t.Fatal("transaction rejected")
+	}
+	peer.Mining()
+	servePeerChain(t, bc, peer)
+	if !bc.ResolveConflicts() {
+		t.Fatal("peer chain not adopted")
+	}
+
+	// The payment came in a peer block without its signature, so it isn't
+	// pooled again when that block is rolled back.
+	other.Mining()
+	other.Mining()
+	servePeerChain(t, bc, other)
+	if !bc.ResolveConflicts() {
+		t.Fatal("other chain not adopted")
+	}
+	if n := bc.PoolSize(); n != 0 {
+		t.Errorf("pool size = %d, want the unsigned payment dropped", n)
+	}
+}
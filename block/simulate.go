@@ -0,0 +1,56 @@
+package block
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// SimParams is parameters of a chain SimulateChain mines.
+type SimParams struct {
+	// Blocks is number of blocks mined after genesis.
+	Blocks int
+	// Difficulty is the chain's difficulty, retargeting doesn't go below it.
+	Difficulty int
+	// TargetBlockTime, RetargetWindow and MaxRetargetSteps are as set by
+	// SetTargetBlockTime and SetMaxRetargetSteps.
+	TargetBlockTime  time.Duration
+	RetargetWindow   int
+	MaxRetargetSteps int
+	// Hashrate is to return hashes per second the network mines the block
+	// at height with.
+	Hashrate func(height int) float64
+	// Seed is seed of the random tries, so the same params give the same chain.
+	Seed int64
+}
+
+// SimulateChain is to mine a chain of params.Blocks empty blocks at the
+// difficulty retargeting picks, without doing proof of work. Each block's
+// nonce is the number of tries drawn for its difficulty, about 16^difficulty,
+// and its timestamp is the time the hashrate at its height takes for them,
+// so the blocks' proofs aren't valid.
+func SimulateChain(params SimParams) []*Block {
+	clock := &simClock{now: time.Unix(0, 0)}
+	bc := NewBlockchain("", 0, WithDifficulty(params.Difficulty), WithClock(clock))
+	bc.SetTargetBlockTime(params.TargetBlockTime, params.RetargetWindow)
+	bc.SetMaxRetargetSteps(params.MaxRetargetSteps)
+
+	rnd := rand.New(rand.NewSource(params.Seed))
+	for height := 1; height <= params.Blocks; height++ {
+		difficulty := bc.CurrentDifficulty()
+		tries := rnd.ExpFloat64() * math.Pow(16, float64(difficulty))
+		clock.now = clock.now.Add(time.Duration(tries / params.Hashrate(height) * float64(time.Second)))
+		bc.createBlock(uint64(tries), bc.LastBlock().Hash(), difficulty)
+	}
+	return bc.chain
+}
+
+// simClock is Clock SimulateChain moves as blocks are mined.
+type simClock struct {
+	now time.Time
+}
+
+// Now is to return simClock's time.
+func (c *simClock) Now() time.Time {
+	return c.now
+}
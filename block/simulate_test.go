@@ -0,0 +1,53 @@
+package block
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSimulateChain(t *testing.T) {
+	// 16^3 tries every 10 seconds, doubling midway. Each difficulty step is
+	// 16 times, so block time can only be held within 4 times of the target.
+	params := SimParams{
+		Blocks:           400,
+		Difficulty:       1,
+		TargetBlockTime:  10 * time.Second,
+		RetargetWindow:   10,
+		MaxRetargetSteps: 1,
+		Hashrate: func(height int) float64 {
+			if height > 200 {
+				return 2 * 4096.0 / 10
+			}
+			return 4096.0 / 10
+		},
+		Seed: 1,
+	}
+	chain := SimulateChain(params)
+	if len(chain) != params.Blocks+1 {
+		t.Fatalf("chain length = %d, want %d", len(chain), params.Blocks+1)
+	}
+	for _, half := range [][]*Block{chain[100:201], chain[300:]} {
+		avg := time.Duration((half[len(half)-1].timestamp - half[0].timestamp) / int64(len(half)-1))
+		if avg < params.TargetBlockTime/4 || avg > 4*params.TargetBlockTime {
+			t.Errorf("average block time = %v, want within 4 times of %v", avg, params.TargetBlockTime)
+		}
+	}
+
+	if again := SimulateChain(params); !reflect.DeepEqual(blockTimes(again), blockTimes(chain)) {
+		t.Error("same seed simulated another chain")
+	}
+	params.Seed = 2
+	if other := SimulateChain(params); reflect.DeepEqual(blockTimes(other), blockTimes(chain)) {
+		t.Error("another seed simulated the same chain")
+	}
+}
+
+// blockTimes is to return timestamps of chain's blocks.
+func blockTimes(chain []*Block) []int64 {
+	times := make([]int64, len(chain))
+	for i, b := range chain {
+		times[i] = b.timestamp
+	}
+	return times
+}
@@ -0,0 +1,79 @@
+package block
+
+import (
+	"encoding/json"
+	"errors"
+	"goblockchain/utils"
+)
+
+// ErrSnapshotTipMismatch is returned when snapshot's tip block doesn't match its tip hash.
+var ErrSnapshotTipMismatch = errors.New("snapshot tip hash mismatch")
+
+// snapshot is state snapshot json struct.
+type snapshot struct {
+	ChainID  string             `json:"chain_id"`
+	Height   int                `json:"height"`
+	TipHash  string             `json:"tip_hash"`
+	Balances map[string]float32 `json:"balances"`
+	Tip      *Block             `json:"tip"`
+}
+
+// Snapshot is to return json of per-address balances at the current height,
+// with the tip block and its hash so a new node can check and extend it.
+func (bc *Blockchain) Snapshot() ([]byte, error) {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+
+	balances := bc.balances()
+	tip := bc.LastBlock()
+	if tip == nil {
+		return nil, ErrEmptyChain
+	}
+	return json.Marshal(&snapshot{
+		ChainID:  bc.chainID,
+		Height:   bc.Height(),
+		TipHash:  utils.HashToHex(tip.Hash()),
+		Balances: balances,
+		Tip:      tip,
+	})
+}
+
+// LoadSnapshot is to return new Blockchain restored from Snapshot json on
+// the snapshot's network, with opts applied. The snapshot has no transactions
+// before the tip, so the restored chain can't tell them from replays. Miner's
+// blockchain address and port are unset.
+func LoadSnapshot(data []byte, opts ...Option) (*Blockchain, error) {
+	var s snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+	if s.Tip == nil || s.Height < 1 {
+		return nil, errors.New("snapshot has no tip block")
+	}
+	if utils.HashToHex(s.Tip.Hash()) != s.TipHash {
+		return nil, ErrSnapshotTipMismatch
+	}
+
+	genesisState := make(map[string]float32, len(s.Balances))
+	for address, amount := range s.Balances {
+		genesisState[address] = amount
+	}
+	for _, t := range s.Tip.transactions {
+		genesisState[t.recipientBlockchainAddress] -= t.value
+		genesisState[t.senderBlockchainAddress] += t.value
+	}
+
+	bc := newBlockchain("", 0)
+	bc.chainID = s.ChainID
+	for _, opt := range opts {
+		opt(bc)
+	}
+	bc.chain = []*Block{s.Tip}
+	bc.genesisState = genesisState
+	bc.prunedHeight = s.Height - 1
+	if err := bc.SelfCheck(); err != nil {
+		return nil, err
+	}
+	bc.indexConfirmed()
+	return bc, nil
+}
@@ -0,0 +1,93 @@
+package block
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goblockchain/utils"
+	"goblockchain/wallet"
+	"testing"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	bc := newTestBlockchain(t, WithChainID("testnet"))
+	miner, alice := wallet.NewWallet(), wallet.NewWallet()
+	bc.blockchainAddress = miner.BlockchainAddress()
+	bc.Mining()
+	bc.Mining()
+	if !sendTransaction(bc, miner, alice.BlockchainAddress(), 1.5) {
+		t.Fatal("transaction rejected")
+	}
+	bc.Mining()
+
+	data, err := bc.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := LoadSnapshot(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored.SetLogger(utils.NewNopLogger())
+
+	if restored.ChainID() != bc.ChainID() {
+		t.Errorf("restored chain ID %q, want %q", restored.ChainID(), bc.ChainID())
+	}
+	if restored.Height() != bc.Height() || restored.LastBlock().Hash() != bc.LastBlock().Hash() {
+		t.Errorf("restored height %d and tip %x, want %d and %x",
+			restored.Height(), restored.LastBlock().Hash(), bc.Height(), bc.LastBlock().Hash())
+	}
+	for _, address := range []string{miner.BlockchainAddress(), alice.BlockchainAddress(), MiningSender, "1Unknown"} {
+		if got, want := restored.CalculateTotalAmount(address), bc.CalculateTotalAmount(address); got != want {
+			t.Errorf("balance of %s = %v, want %v", address, got, want)
+		}
+	}
+
+	// The restored chain is extended by blocks mined on the source.
+	peer := forkChain(t, bc)
+	peer.Mining()
+	if err := restored.AddBlock(peer.LastBlock()); err != nil {
+		t.Errorf("next block rejected: %v", err)
+	}
+	if !sendTransaction(restored, alice, miner.BlockchainAddress(), 1) {
+		t.Error("transaction signed for the network rejected")
+	}
+}
+
+func TestLoadSnapshotErrors(t *testing.T) {
+	bc := newTestBlockchain(t)
+	bc.Mining()
+	data, _ := bc.Snapshot()
+	var s snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		t.Fatal(err)
+	}
+	tipHash := s.TipHash
+	s.TipHash = fmt.Sprintf("%x", [32]byte{1})
+	tampered, _ := json.Marshal(&s)
+	breakProof(s.Tip)
+	s.TipHash = fmt.Sprintf("%x", s.Tip.Hash())
+	unmined, _ := json.Marshal(&s)
+	if s.TipHash == tipHash {
+		t.Fatal("tip unchanged")
+	}
+
+	tests := []struct {
+		name string
+		data string
+		want error
+	}{
+		{"tampered tip hash", string(tampered), ErrSnapshotTipMismatch},
+		{"tip without proof of work", string(unmined), nil},
+		{"no tip", `{"height":1,"balances":{}}`, nil},
+		{"not json", `snapshot`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadSnapshot([]byte(tt.data))
+			if err == nil || (tt.want != nil && !errors.Is(err, tt.want)) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package block
+
+import (
+	"crypto/sha256"
+	"math"
+	"math/big"
+	"time"
+)
+
+// CurrentDifficulty is to return difficulty used for the next block.
+func (bc *Blockchain) CurrentDifficulty() int {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	return bc.currentDifficulty()
+}
+
+// currentDifficulty is CurrentDifficulty for callers holding bc.mux. With
+// a target block time set, it's the tip's difficulty moved by the steps of
+// 16 times closest to the ratio of the target to the average time of the
+// last retarget window blocks, at most the max retarget steps and not below
+// the chain's difficulty. So it holds while the average is within 4 times
+// of the target, where a step would land further off. It only moves once
+// the window blocks were all mined at the tip's difficulty, so blocks from
+// before a retarget don't push it further.
+func (bc *Blockchain) currentDifficulty() int {
+	if bc.targetBlockTime <= 0 || len(bc.chain) < bc.retargetWindow+1 {
+		return bc.difficulty
+	}
+	tip := bc.chain[len(bc.chain)-1].difficulty
+	difficulty := tip
+	if difficulty < bc.difficulty {
+		difficulty = bc.difficulty
+	}
+	for _, b := range bc.chain[len(bc.chain)-bc.retargetWindow:] {
+		if b.difficulty != tip {
+			return difficulty
+		}
+	}
+
+	difficulty += bc.retargetSteps(bc.averageBlockTime(bc.retargetWindow))
+	if difficulty > 2*sha256.Size {
+		difficulty = 2 * sha256.Size
+	}
+	if difficulty < bc.difficulty {
+		difficulty = bc.difficulty
+	}
+	return difficulty
+}
+
+// retargetSteps is to return difficulty steps closest to the target over
+// avg, negative if avg is over the target, clamped to the max retarget
+// steps. An avg of 0, a burst of blocks with the same timestamp, takes the
+// max.
+func (bc *Blockchain) retargetSteps(avg time.Duration) int {
+	steps := bc.maxRetargetSteps
+	if avg > 0 {
+		steps = int(math.Round(math.Log(float64(bc.targetBlockTime)/float64(avg)) / math.Log(16)))
+	}
+	if steps > bc.maxRetargetSteps {
+		steps = bc.maxRetargetSteps
+	}
+	if steps < -bc.maxRetargetSteps {
+		steps = -bc.maxRetargetSteps
+	}
+	return steps
+}
+
+// DefaultMaxRetargetSteps is default max difficulty steps one retarget moves.
+const DefaultMaxRetargetSteps = 1
+
+// MaxRetargetSteps is to return max difficulty steps one retarget moves.
+func (bc *Blockchain) MaxRetargetSteps() int {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	return bc.maxRetargetSteps
+}
+
+// SetMaxRetargetSteps is set max difficulty steps the next block's
+// difficulty may move from the tip's, so a burst of fast or slow blocks, or
+// rewritten timestamps, swing it at most 16^steps times. Difficulty counts
+// hex zeros, so a finer factor such as 4 can't be expressed. Values below
+// 1 are taken as 1, the default.
+func (bc *Blockchain) SetMaxRetargetSteps(steps int) {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	if steps < 1 {
+		steps = 1
+	}
+	bc.maxRetargetSteps = steps
+}
+
+// SetTargetBlockTime is set block time difficulty is retargeted toward,
+// judged by the average over the last window blocks. Difficulty counts hex
+// zeros, so each step makes proof of work 16 times harder or easier.
+// 0, the default, disables retargeting.
+//
+// Retargeting is local only. Peers, this node included, check blocks only
+// against the chain's difficulty, not the retargeted one. And the average
+// comes from block timestamps, which proof of work doesn't cover, so a peer
+// relaying blocks can rewrite them to push this node's difficulty up or
+// down; it must not be relied on against untrusted peers.
+func (bc *Blockchain) SetTargetBlockTime(target time.Duration, window int) {
+	bc.mux.Lock()
+	defer bc.mux.Unlock()
+	if window < 1 {
+		window = 1
+	}
+	bc.targetBlockTime = target
+	bc.retargetWindow = window
+}
+
+// AverageBlockTime is to return average time between the last window blocks.
+// It returns 0 if the chain is too short.
+func (bc *Blockchain) AverageBlockTime(window int) time.Duration {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	return bc.averageBlockTime(window)
+}
+
+// averageBlockTime is AverageBlockTime for callers holding bc.mux.
+func (bc *Blockchain) averageBlockTime(window int) time.Duration {
+	if window < 1 || len(bc.chain) < window+1 {
+		return 0
+	}
+	last := bc.chain[len(bc.chain)-1].timestamp
+	first := bc.chain[len(bc.chain)-1-window].timestamp
+	return time.Duration((last - first) / int64(window))
+}
+
+// HashrateEstimate is to estimate hashes per second from the difficulty and
+// the average time between the last window blocks.
+// It returns 0 if the chain is too short.
+func (bc *Blockchain) HashrateEstimate(window int) float64 {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	avg := bc.averageBlockTime(window)
+	if avg <= 0 {
+		return 0
+	}
+	// Each leading hex zero is 4 bits, so a valid hash takes 16^difficulty tries.
+	expectedHashes := math.Pow(16, float64(bc.currentDifficulty()))
+	return expectedHashes / avg.Seconds()
+}
+
+// BlockTimes is to return nanoseconds between each block and the block before it,
+// so it has one entry less than the chain.
+func (bc *Blockchain) BlockTimes() []int64 {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	if len(bc.chain) < 2 {
+		return []int64{}
+	}
+	times := make([]int64, len(bc.chain)-1)
+	for i := 1; i < len(bc.chain); i++ {
+		times[i-1] = bc.chain[i].timestamp - bc.chain[i-1].timestamp
+	}
+	return times
+}
+
+// DifficultyHistory is to return difficulty each block records it was mined
+// at. The genesis block isn't mined, so its difficulty is 0.
+func (bc *Blockchain) DifficultyHistory() []int {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	history := make([]int, len(bc.chain))
+	for i, b := range bc.chain {
+		history[i] = b.difficulty
+	}
+	return history
+}
+
+// BlockWork is to return expected hashes to mine a block at difficulty.
+// It's 2^256 over proofTarget, 2^(4*difficulty).
+func BlockWork(difficulty int) *big.Int {
+	space := new(big.Int).Lsh(big.NewInt(1), 256)
+	return space.Div(space, proofTarget(difficulty))
+}
+
+// proofTarget is to return bound header hash must be below at difficulty.
+// Each leading hex zero is 4 bits, so it's 2^(256-4*difficulty).
+func proofTarget(difficulty int) *big.Int {
+	return new(big.Int).Lsh(big.NewInt(1), uint(256-4*difficulty))
+}
+
+// TotalWork is to return cumulative work of the chain, including pruned blocks.
+func (bc *Blockchain) TotalWork() *big.Int {
+	bc.mux.RLock()
+	defer bc.mux.RUnlock()
+	return bc.totalWork()
+}
+
+// totalWork is to return cumulative work of the chain. mux must be held.
+func (bc *Blockchain) totalWork() *big.Int {
+	return bc.workAt(bc.Height() - 1)
+}
+
+// workAt is to return cumulative work of the local chain up to the block at
+// height. Pruned blocks' own difficulties are dropped, so they count at the
+// chain's minimum difficulty. mux must be held.
+func (bc *Blockchain) workAt(height int) *big.Int {
+	work := new(big.Int)
+	if height <= 0 {
+		return work
+	}
+	if bc.prunedHeight > 0 {
+		work.Mul(big.NewInt(int64(bc.prunedHeight-1)), BlockWork(bc.difficulty))
+	}
+	for i := 0; i <= height-bc.prunedHeight && i < len(bc.chain); i++ {
+		if !bc.chain[i].IsGenesis() {
+			work.Add(work, BlockWork(bc.chain[i].difficulty))
+		}
+	}
+	return work
+}
+
+// peerChainWork is to return cumulative work of chain whose first block is
+// the local block at height root. mux must be held.
+func (bc *Blockchain) peerChainWork(chain []*Block, root int) *big.Int {
+	return new(big.Int).Add(bc.workAt(root), chainWork(chain))
+}
+
+// chainWork is to return cumulative work of chain's blocks after its first,
+// by the difficulty each block records. The first is genesis, which isn't
+// mined, or a block counted already.
+func chainWork(chain []*Block) *big.Int {
+	work := new(big.Int)
+	for i := 1; i < len(chain); i++ {
+		work.Add(work, BlockWork(chain[i].difficulty))
+	}
+	return work
+}
@@ -0,0 +1,181 @@
+package block
+
+import (
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	bc := newTestBlockchain(t)
+	if got := bc.AverageBlockTime(2); got != 0 {
+		t.Errorf("average block time of genesis only = %v, want 0", got)
+	}
+	bc.Mining()
+	bc.Mining()
+	for i, b := range bc.chain {
+		b.timestamp = int64(i) * int64(4*time.Second)
+	}
+
+	if got := bc.AverageBlockTime(2); got != 4*time.Second {
+		t.Errorf("average block time = %v, want 4s", got)
+	}
+	if got := bc.AverageBlockTime(3); got != 0 {
+		t.Errorf("average block time of window longer than chain = %v, want 0", got)
+	}
+	// 16^3 hashes every 4 seconds.
+	if got := bc.HashrateEstimate(2); got != 1024 {
+		t.Errorf("hashrate = %v, want 1024", got)
+	}
+}
+
+func TestRetarget(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1700000000, 0))
+	bc := newTestBlockchain(t, WithDifficulty(0), WithClock(clock))
+	mine := func(blockTime time.Duration) {
+		t.Helper()
+		clock.Advance(blockTime)
+		if !bc.Mining() {
+			t.Fatal("mining failed")
+		}
+	}
+	mine(time.Second)
+	mine(time.Second)
+	if got := bc.CurrentDifficulty(); got != 0 {
+		t.Fatalf("difficulty without target = %d, want 0", got)
+	}
+
+	bc.SetTargetBlockTime(10*time.Second, 2)
+	tests := []struct {
+		blockTime time.Duration
+		want      int
+	}{
+		// A retarget waits for the window to be mined at its difficulty.
+		{time.Second, 1},
+		// Blocks over 4 times under the target raise difficulty.
+		{time.Second, 2},
+		// On target it holds.
+		{10 * time.Second, 2},
+		{10 * time.Second, 2},
+		// Within 4 times of the target it holds, over 4 times lowers it,
+		// down to the chain's difficulty.
+		{time.Minute, 2},
+		{time.Minute, 1},
+		{time.Minute, 1},
+		{time.Minute, 0},
+		{time.Minute, 0},
+		{time.Minute, 0},
+	}
+	if got := bc.CurrentDifficulty(); got != 1 {
+		t.Fatalf("difficulty after fast blocks = %d, want 1", got)
+	}
+	for i, tt := range tests {
+		want := bc.CurrentDifficulty()
+		mine(tt.blockTime)
+		if got := bc.LastBlock().Difficulty(); got != want {
+			t.Errorf("block %d mined at difficulty %d, want %d", i, got, want)
+		}
+		if got := bc.CurrentDifficulty(); got != tt.want {
+			t.Errorf("after block %d of %v: difficulty = %d, want %d", i, tt.blockTime, got, tt.want)
+		}
+	}
+	if err := bc.SelfCheck(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRetargetClamp(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1700000000, 0))
+	bc := newTestBlockchain(t, WithDifficulty(0), WithClock(clock))
+	bc.SetTargetBlockTime(10*time.Second, 1)
+	if got := bc.MaxRetargetSteps(); got != DefaultMaxRetargetSteps {
+		t.Errorf("max retarget steps = %d, want %d", got, DefaultMaxRetargetSteps)
+	}
+
+	// Instant blocks would raise difficulty without bound, the clamp holds
+	// each retarget to the max steps.
+	if !bc.Mining() {
+		t.Fatal("mining failed")
+	}
+	if got := bc.CurrentDifficulty(); got != 1 {
+		t.Errorf("difficulty after instant block = %d, want 1", got)
+	}
+	bc.SetMaxRetargetSteps(2)
+	for _, want := range []int{4, 6} {
+		if !bc.Mining() {
+			t.Fatal("mining failed")
+		}
+		if got := bc.CurrentDifficulty(); got != want {
+			t.Errorf("difficulty after instant block = %d, want %d", got, want)
+		}
+	}
+
+	// A block 16^2 times over the target lowers it by both steps.
+	bc.chain[len(bc.chain)-1].timestamp += int64(2560 * time.Second)
+	if got := bc.CurrentDifficulty(); got != 2 {
+		t.Errorf("difficulty after slow block = %d, want 2", got)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	bc := newTestBlockchain(t)
+	if got := bc.BlockTimes(); len(got) != 0 {
+		t.Errorf("block times of genesis only = %v", got)
+	}
+	bc.Mining()
+	bc.Mining()
+	for i, b := range bc.chain {
+		b.timestamp = int64(i*i) * int64(time.Second)
+	}
+
+	times := bc.BlockTimes()
+	if len(times) != 2 || times[0] != int64(time.Second) || times[1] != int64(3*time.Second) {
+		t.Errorf("block times = %v, want [1s 3s]", times)
+	}
+	history := bc.DifficultyHistory()
+	if len(history) != 3 || history[0] != 0 || history[1] != MiningDifficulty || history[2] != MiningDifficulty {
+		t.Errorf("difficulty history = %v", history)
+	}
+	bc.chain[2].difficulty = MiningDifficulty + 1
+	if got := bc.DifficultyHistory()[2]; got != MiningDifficulty+1 {
+		t.Errorf("difficulty history = %d, want recorded %d", got, MiningDifficulty+1)
+	}
+}
+
+func TestTotalWork(t *testing.T) {
+	bc := newTestBlockchain(t)
+	if got := bc.TotalWork(); got.Sign() != 0 {
+		t.Errorf("work of genesis only = %v, want 0", got)
+	}
+	bc.Mining()
+	bc.MineWithDifficulty(MiningDifficulty + 1)
+	want := new(big.Int).Add(BlockWork(MiningDifficulty), BlockWork(MiningDifficulty+1))
+	if got := bc.TotalWork(); got.Cmp(want) != 0 {
+		t.Errorf("total work = %v, want %v", got, want)
+	}
+	if got := BlockWork(2); got.Int64() != 256 {
+		t.Errorf("work at difficulty 2 = %v, want 256", got)
+	}
+}
+
+func TestValidHeaderProofBounds(t *testing.T) {
+	bc := newTestBlockchain(t)
+	for _, tt := range []struct {
+		difficulty int
+		want       bool
+	}{
+		{-1, false},
+		{0, true},
+		{65, false},
+	} {
+		h := &BlockHeader{difficulty: tt.difficulty}
+		if got := bc.ValidHeaderProof(h); got != tt.want {
+			t.Errorf("proof at difficulty %d = %v, want %v", tt.difficulty, got, tt.want)
+		}
+	}
+	// A mined header's hash is below the target of its difficulty.
+	bc.MineWithDifficulty(2)
+	if !bc.ValidHeaderProof(bc.LastBlock().Header()) {
+		t.Error("mined header rejected")
+	}
+}
@@ -1,26 +1,139 @@
-package main
+package blockchain_server
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"goblockchain/block"
 	"goblockchain/utils"
 	"goblockchain/wallet"
 	"io"
 	"log"
+	"math"
+	"net"
 	"net/http"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 var cache map[string]*block.Blockchain = make(map[string]*block.Blockchain)
 
+// DefaultHost is default host to bind.
+const DefaultHost = "0.0.0.0"
+
+// Page limits of /chain.
+const (
+	DefaultChainPageLimit = 20
+	MaxChainPageLimit     = 100
+)
+
+// Limits of /richlist.
+const (
+	DefaultRichListLimit = 10
+	MaxRichListLimit     = 100
+)
+
+// ErrorCodeMiningFailed is error code of /mine when no block was mined.
+const ErrorCodeMiningFailed = "mining_failed"
+
+// StatsWindow is number of recent blocks used by /stats.
+const StatsWindow = 10
+
+// MaxTipWait is longest /tip waits for a new block.
+const MaxTipWait = time.Minute
+
 // BlockchainServer is BlockchainServer struct.
 type BlockchainServer struct {
-	port uint16
+	host    string
+	port    uint16
+	logger  utils.Logger
+	hub     *BlockHub
+	metrics *MetricsRegistry
+	limiter *utils.RateLimiter
+	chainID string
+
+	tlsCertFile   string
+	tlsKeyFile    string
+	tlsSelfSigned bool
+
+	server    *http.Server
+	muxServer sync.Mutex
+
+	maxBodyBytes int64
 }
 
 // NewBlockchainServer is to return new NewBlockchainServer struct.
 func NewBlockchainServer(port uint16) *BlockchainServer {
-	return &BlockchainServer{port}
+	return &BlockchainServer{host: DefaultHost, port: port, logger: utils.NewStdLogger(), hub: NewBlockHub(), metrics: NewMetricsRegistry(),
+		maxBodyBytes: utils.DefaultMaxBodyBytes}
+}
+
+// Metrics is to return BlockchainServer's metrics registry.
+func (bcs *BlockchainServer) Metrics() *MetricsRegistry {
+	return bcs.metrics
+}
+
+// SetMetrics is to set BlockchainServer's metrics registry, also used by its blockchain.
+func (bcs *BlockchainServer) SetMetrics(metrics *MetricsRegistry) {
+	bcs.metrics = metrics
+}
+
+// ChainID is to return BlockchainServer's network chain ID.
+func (bcs *BlockchainServer) ChainID() string {
+	return bcs.chainID
+}
+
+// SetChainID is to set BlockchainServer's network chain ID.
+func (bcs *BlockchainServer) SetChainID(chainID string) {
+	bcs.chainID = chainID
+}
+
+// SetTLS is to serve over TLS with certificate and key files.
+func (bcs *BlockchainServer) SetTLS(certFile, keyFile string) {
+	bcs.tlsCertFile = certFile
+	bcs.tlsKeyFile = keyFile
+}
+
+// SetSelfSignedTLS is to serve over TLS with a self signed certificate, for local development.
+func (bcs *BlockchainServer) SetSelfSignedTLS(selfSigned bool) {
+	bcs.tlsSelfSigned = selfSigned
+}
+
+// SetMaxBodyBytes is to limit request body size, replying 413 to larger ones.
+// 0 disables the limit.
+func (bcs *BlockchainServer) SetMaxBodyBytes(limit int64) {
+	bcs.maxBodyBytes = limit
+}
+
+// SetRateLimit is to limit requests per client IP to rate per second with burst.
+func (bcs *BlockchainServer) SetRateLimit(rate float64, burst int) {
+	bcs.limiter = utils.NewRateLimiter(rate, burst)
+}
+
+// Logger is to return BlockchainServer's logger.
+func (bcs *BlockchainServer) Logger() utils.Logger {
+	return bcs.logger
+}
+
+// SetLogger is to set BlockchainServer's logger, also used by its blockchain.
+func (bcs *BlockchainServer) SetLogger(logger utils.Logger) {
+	bcs.logger = logger
+}
+
+// Host is to return BlockchainServer's host.
+func (bcs *BlockchainServer) Host() string {
+	return bcs.host
+}
+
+// SetHost is to set BlockchainServer's host to bind.
+func (bcs *BlockchainServer) SetHost(host string) {
+	bcs.host = host
 }
 
 // Port is to return BlockchainServer's port.
@@ -33,25 +146,151 @@ func (bcs *BlockchainServer) GetBlockchain() *block.Blockchain {
 	bc, ok := cache["blockchain"]
 	if !ok {
 		minersWallet := wallet.NewWallet()
-		bc = block.NewBlockchain(minersWallet.BlockchainAddress(), bcs.Port())
-		cache["blockchain"] = bc
-		log.Printf("private_key %v", minersWallet.PrivateKeyStr())
-		log.Printf("publick_key %v", minersWallet.PublicKeyStr())
-		log.Printf("blockchain_address %v", minersWallet.BlockchainAddress())
+		bc = block.NewBlockchain(minersWallet.BlockchainAddress(), bcs.Port(),
+			block.WithChainID(bcs.ChainID()))
+		bcs.SetBlockchain(bc)
+		bcs.logger.Info("miner wallet",
+			"private_key", minersWallet.PrivateKeyStr(),
+			"public_key", minersWallet.PublicKeyStr(),
+			"blockchain_address", minersWallet.BlockchainAddress())
 	}
 	return bc
 }
 
+// SetBlockchain is to serve existing blockchain, e.g. one loaded from disk,
+// wiring it to BlockchainServer's logger, metrics and websocket hub.
+func (bcs *BlockchainServer) SetBlockchain(bc *block.Blockchain) {
+	bc.SetLogger(bcs.logger)
+	bc.OnNewBlock(bcs.hub.Publish)
+	bc.SetMetrics(bcs.metrics)
+	cache["blockchain"] = bc
+}
+
 // GetChain is api to get blockchain's json.
 func (bcs *BlockchainServer) GetChain(w http.ResponseWriter, req *http.Request) {
 	switch req.Method {
 	case http.MethodGet:
-		w.Header().Add("Content-Type", "application/json")
 		bc := bcs.GetBlockchain()
-		m, _ := bc.MarshalJSON()
+		q := req.URL.Query()
+		paged := q.Has("from") || q.Has("limit") || q.Has("address")
+		from, limit := 0, 0
+		if paged {
+			var err error
+			from, limit, err = pageParams(q.Get("from"), q.Get("limit"), bc.Height())
+			if err != nil {
+				bcs.logger.Error("page params", "error", err)
+				utils.WriteError(w, http.StatusBadRequest, utils.ErrorCodeInvalidRequest, err.Error())
+				return
+			}
+		}
+
+		// The ETag is of the tip, the pruned height and the page, read
+		// before the body so the body is never older than its ETag, and a
+		// match is answered without marshalling the chain.
+		height, tipHash := bc.Tip()
+		etag := chainETag(height, tipHash, bc.PrunedHeight(), paged, from, limit, q.Get("address"))
+		w.Header().Set("ETag", etag)
+		if etagMatch(req.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		var m []byte
+		if !paged {
+			m, _ = bc.MarshalJSON()
+		} else {
+			blocks, next := bc.ChainPage(from, limit, q.Get("address"))
+			var nextPtr *int
+			if next >= 0 {
+				nextPtr = &next
+			}
+			m, _ = json.Marshal(struct {
+				Blocks []*block.Block `json:"chain"`
+				Next   *int           `json:"next"`
+			}{
+				Blocks: blocks,
+				Next:   nextPtr,
+			})
+		}
+		utils.SetContentType(w, utils.ContentTypeJSON)
 		io.WriteString(w, string(m[:]))
 	default:
-		log.Printf("ERROR: Invalid HTTP Method")
+		bcs.logger.Error("invalid http method", "method", req.Method, "path", req.URL.Path)
+		utils.MethodNotAllowed(w, http.MethodGet)
+	}
+}
+
+// chainETag is to return ETag of /chain at the tip of height and hash with
+// blocks below pruned dropped, and of the page from, limit and address
+// if paged.
+func chainETag(height int, tipHash [32]byte, pruned int, paged bool, from, limit int, address string) string {
+	if !paged {
+		return fmt.Sprintf(`"%d-%x-%d"`, height, tipHash, pruned)
+	}
+	page := sha256.Sum256([]byte(fmt.Sprintf("%d/%d/%s", from, limit, address)))
+	return fmt.Sprintf(`"%d-%x-%d-%x"`, height, tipHash, pruned, page[:8])
+}
+
+// etagMatch is to check If-None-Match header value matches etag.
+func etagMatch(ifNoneMatch string, etag string) bool {
+	for _, t := range strings.Split(ifNoneMatch, ",") {
+		t = strings.TrimPrefix(strings.TrimSpace(t), "W/")
+		if t == "*" || t == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// pageParams is to parse from and limit query values, defaulting and capping limit.
+func pageParams(fromStr string, limitStr string, length int) (int, int, error) {
+	from, limit := 0, DefaultChainPageLimit
+	var err error
+	if fromStr != "" {
+		from, err = strconv.Atoi(fromStr)
+		if err != nil {
+			return 0, 0, err
+		}
+		if from < 0 || from > length {
+			return 0, 0, fmt.Errorf("from %d out of range [0, %d]", from, length)
+		}
+	}
+	if limitStr != "" {
+		limit, err = strconv.Atoi(limitStr)
+		if err != nil {
+			return 0, 0, err
+		}
+		if limit <= 0 {
+			return 0, 0, fmt.Errorf("limit %d must be positive", limit)
+		}
+	}
+	if limit > MaxChainPageLimit {
+		limit = MaxChainPageLimit
+	}
+	return from, limit, nil
+}
+
+// Headers is api to return headers of blocks from height from, up to
+// block.MaxHeaders, for light clients to sync without transactions.
+func (bcs *BlockchainServer) Headers(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		from := 0
+		if s := req.URL.Query().Get("from"); s != "" {
+			var err error
+			from, err = strconv.Atoi(s)
+			if err != nil || from < 0 {
+				bcs.logger.Error("headers from", "from", s)
+				utils.WriteError(w, http.StatusBadRequest, utils.ErrorCodeInvalidRequest, "from must be a non-negative integer")
+				return
+			}
+		}
+		m, _ := json.Marshal(bcs.GetBlockchain().Headers(from))
+		utils.SetContentType(w, utils.ContentTypeJSON)
+		io.WriteString(w, string(m))
+	default:
+		bcs.logger.Error("invalid http method", "method", req.Method, "path", req.URL.Path)
+		utils.MethodNotAllowed(w, http.MethodGet)
 	}
 }
 
@@ -59,7 +298,7 @@ func (bcs *BlockchainServer) GetChain(w http.ResponseWriter, req *http.Request)
 func (bcs *BlockchainServer) Transactions(w http.ResponseWriter, req *http.Request) {
 	switch req.Method {
 	case http.MethodGet:
-		w.Header().Add("Content-Type", "application/json")
+		utils.SetContentType(w, utils.ContentTypeJSON)
 		bc := bcs.GetBlockchain()
 		transactions := bc.TransactionPool()
 		m, _ := json.Marshal(struct {
@@ -71,95 +310,261 @@ func (bcs *BlockchainServer) Transactions(w http.ResponseWriter, req *http.Reque
 		})
 		io.WriteString(w, string(m[:]))
 
-	case http.MethodPost:
+	case http.MethodPost, http.MethodPut:
+		// Transactions from peers are added without broadcasting them again.
+		fromPeer := req.Method == http.MethodPut || req.Header.Get(block.OriginNodeHeader) != ""
+		if fromPeer && !bcs.verifyAnnouncement(w, req) {
+			return
+		}
 		decoder := json.NewDecoder(req.Body)
 		var t block.TransactionRequest
 		err := decoder.Decode(&t)
 		if err != nil {
-			log.Printf("ERROR: %v", err)
-			io.WriteString(w, string(utils.JSONStatus("fail")))
+			bcs.logger.Error("decode transaction request", "error", err)
+			utils.WriteRequestError(w, err)
 			return
 		}
-		if !t.Validate() {
-			log.Println("ERROR: missing field(s)")
-			io.WriteString(w, string(utils.JSONStatus("fail")))
+		err = bcs.GetBlockchain().SubmitTransactionRequest(&t, !fromPeer)
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, block.ErrorCode(err), err.Error())
 			return
 		}
-		publicKey := utils.PublicKeyFromString(*t.SenderPublicKey)
-		signature := utils.SignatureFromString(*t.Signature)
-		bc := bcs.GetBlockchain()
-		isCreated := bc.CreateTransaction(*t.SenderBlockchainAddress,
-			*t.RecipientBlockchainAddress, *t.Value, publicKey, signature)
 
-		w.Header().Add("Content-Type", "application/json")
-		var m []byte
-		if !isCreated {
-			w.WriteHeader(http.StatusBadRequest)
-			m = utils.JSONStatus("fail")
-		} else {
+		utils.SetContentType(w, utils.ContentTypeJSON)
+		if req.Method == http.MethodPost {
 			w.WriteHeader(http.StatusCreated)
-			m = utils.JSONStatus("success")
 		}
+		io.WriteString(w, string(utils.JSONStatus("success")))
+	default:
+		bcs.logger.Error("invalid http method", "method", req.Method, "path", req.URL.Path)
+		utils.MethodNotAllowed(w, http.MethodGet, http.MethodPost, http.MethodPut)
+	}
+}
+
+// TransactionsBatch is api to submit an array of signed transactions,
+// returning a multi-status with the result of each transaction.
+func (bcs *BlockchainServer) TransactionsBatch(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		utils.SetContentType(w, utils.ContentTypeJSON)
+		decoder := json.NewDecoder(req.Body)
+		var requests []*block.TransactionRequest
+		if err := decoder.Decode(&requests); err != nil {
+			bcs.logger.Error("decode transaction batch", "error", err)
+			utils.WriteRequestError(w, err)
+			return
+		}
+
+		type result struct {
+			Index  int    `json:"index"`
+			Status string `json:"status"`
+			Code   string `json:"code,omitempty"`
+			Reason string `json:"reason,omitempty"`
+		}
+		errs := bcs.GetBlockchain().AddTransactions(requests)
+		results := make([]result, len(errs))
+		for i, err := range errs {
+			results[i] = result{Index: i, Status: "accepted"}
+			if err != nil {
+				results[i] = result{Index: i, Status: "rejected", Code: block.ErrorCode(err), Reason: err.Error()}
+			}
+		}
+		m, _ := json.Marshal(struct {
+			Results []result `json:"results"`
+		}{
+			Results: results,
+		})
+		w.WriteHeader(http.StatusMultiStatus)
 		io.WriteString(w, string(m))
+	default:
+		bcs.logger.Error("invalid http method", "method", req.Method, "path", req.URL.Path)
+		utils.MethodNotAllowed(w, http.MethodPost)
+	}
+}
 
-	case http.MethodPut:
+// TransactionsMultisig is api to create (POST) or add broadcast (PUT)
+// M-of-N multisig transactions.
+func (bcs *BlockchainServer) TransactionsMultisig(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost, http.MethodPut:
+		utils.SetContentType(w, utils.ContentTypeJSON)
+		fromPeer := req.Method == http.MethodPut || req.Header.Get(block.OriginNodeHeader) != ""
+		if fromPeer && !bcs.verifyAnnouncement(w, req) {
+			return
+		}
 		decoder := json.NewDecoder(req.Body)
-		var t block.TransactionRequest
-		err := decoder.Decode(&t)
-		if err != nil {
-			log.Printf("ERROR: %v", err)
-			io.WriteString(w, string(utils.JSONStatus("fail")))
+		var t block.MultisigTransactionRequest
+		if err := decoder.Decode(&t); err != nil {
+			bcs.logger.Error("decode transaction request", "error", err)
+			utils.WriteRequestError(w, err)
 			return
 		}
 		if !t.Validate() {
-			log.Println("ERROR: missing field(s)")
-			io.WriteString(w, string(utils.JSONStatus("fail")))
+			bcs.logger.Error("validate request", "reason", "missing field(s)")
+			utils.WriteError(w, http.StatusBadRequest, block.ErrorCodeMissingFields, block.ErrMissingFields.Error())
 			return
 		}
-		publicKey := utils.PublicKeyFromString(*t.SenderPublicKey)
-		signature := utils.SignatureFromString(*t.Signature)
+		publicKeys, signatures := t.Keys()
 		bc := bcs.GetBlockchain()
-		isUpdated := bc.AddTransaction(*t.SenderBlockchainAddress,
-			*t.RecipientBlockchainAddress, *t.Value, publicKey, signature)
+		add := bc.CreateMultisigTransaction
+		if fromPeer {
+			add = bc.AddMultisigTransaction
+		}
+		if err := add(*t.SenderBlockchainAddress, *t.RecipientBlockchainAddress,
+			*t.Value, *t.Timestamp, publicKeys, *t.Threshold, signatures); err != nil {
+			utils.WriteError(w, http.StatusBadRequest, block.ErrorCode(err), err.Error())
+			return
+		}
+		if req.Method == http.MethodPost {
+			w.WriteHeader(http.StatusCreated)
+		}
+		io.WriteString(w, string(utils.JSONStatus("success")))
+	default:
+		bcs.logger.Error("invalid http method", "method", req.Method, "path", req.URL.Path)
+		utils.MethodNotAllowed(w, http.MethodPost, http.MethodPut)
+	}
+}
 
-		w.Header().Add("Content-Type", "application/json")
-		var m []byte
-		if !isUpdated {
-			w.WriteHeader(http.StatusBadRequest)
-			m = utils.JSONStatus("fail")
-		} else {
-			m = utils.JSONStatus("success")
+// Transaction is api to look up a transaction by id.
+func (bcs *BlockchainServer) Transaction(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		utils.SetContentType(w, utils.ContentTypeJSON)
+		hash, err := utils.HexToHash(req.URL.Query().Get("id"))
+		if err != nil {
+			bcs.logger.Error("decode transaction id", "id", req.URL.Query().Get("id"))
+			utils.WriteError(w, http.StatusBadRequest, utils.ErrorCodeInvalidRequest, "id must be 64 hex characters")
+			return
 		}
-		io.WriteString(w, string(m))
-	case http.MethodDelete:
+
 		bc := bcs.GetBlockchain()
-		bc.ClearTransactionPool()
-		io.WriteString(w, string(utils.JSONStatus("success")))
+		t, blockIndex, ok := bc.FindTransaction(hash)
+		if !ok {
+			utils.WriteError(w, http.StatusNotFound, utils.ErrorCodeNotFound, "transaction not found")
+			return
+		}
+
+		resp := struct {
+			Transaction    *block.Transaction `json:"transaction"`
+			Status         string             `json:"status"`
+			BlockIndex     *int               `json:"block_index"`
+			BlockTimestamp *int64             `json:"block_timestamp"`
+			Confirmations  int                `json:"confirmations"`
+		}{
+			Transaction: t,
+			Status:      "pending",
+		}
+		if b := bc.BlockAt(blockIndex); blockIndex >= 0 && b != nil {
+			timestamp := b.Timestamp()
+			resp.Status = "confirmed"
+			resp.BlockIndex = &blockIndex
+			resp.BlockTimestamp = &timestamp
+			resp.Confirmations = bc.Confirmations(blockIndex)
+		}
+		m, _ := json.Marshal(resp)
+		io.WriteString(w, string(m[:]))
+	default:
+		bcs.logger.Error("invalid http method", "method", req.Method, "path", req.URL.Path)
+		utils.MethodNotAllowed(w, http.MethodGet)
+	}
+}
+
+// Receipt is api to return receipt of transaction whose hash is tx query parameter.
+func (bcs *BlockchainServer) Receipt(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		hash, err := utils.HexToHash(req.URL.Query().Get("tx"))
+		if err != nil {
+			bcs.logger.Error("decode transaction hash", "tx", req.URL.Query().Get("tx"))
+			utils.WriteError(w, http.StatusBadRequest, utils.ErrorCodeInvalidRequest, "tx must be 64 hex characters")
+			return
+		}
+		r, err := bcs.GetBlockchain().Receipt(hash)
+		if err != nil {
+			utils.WriteError(w, http.StatusNotFound, block.ErrorCode(err), err.Error())
+			return
+		}
+		m, _ := json.Marshal(r)
+		utils.SetContentType(w, utils.ContentTypeJSON)
+		io.WriteString(w, string(m))
 	default:
-		log.Println("ERROR: Invalid HTTP Method")
-		w.WriteHeader(http.StatusBadRequest)
+		bcs.logger.Error("invalid http method", "method", req.Method, "path", req.URL.Path)
+		utils.MethodNotAllowed(w, http.MethodGet)
 	}
 }
 
-// Mine is api to do mining.
+// MerkleProof is api to return proof that transaction tx is in block at
+// height block, with the block's merkle root, for light clients to run
+// block.VerifyMerkleProof.
+func (bcs *BlockchainServer) MerkleProof(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		height, err := strconv.Atoi(req.URL.Query().Get("block"))
+		if err != nil {
+			bcs.logger.Error("merkle proof block", "block", req.URL.Query().Get("block"))
+			utils.WriteError(w, http.StatusBadRequest, utils.ErrorCodeInvalidRequest, "block must be an integer")
+			return
+		}
+		hash, err := utils.HexToHash(req.URL.Query().Get("tx"))
+		if err != nil {
+			bcs.logger.Error("decode transaction hash", "tx", req.URL.Query().Get("tx"))
+			utils.WriteError(w, http.StatusBadRequest, utils.ErrorCodeInvalidRequest, "tx must be 64 hex characters")
+			return
+		}
+		proof, root, err := bcs.GetBlockchain().MerkleProof(height, hash)
+		if err != nil {
+			utils.WriteError(w, http.StatusNotFound, block.ErrorCode(err), err.Error())
+			return
+		}
+		m, _ := json.Marshal(struct {
+			Block           int                     `json:"block"`
+			TransactionHash string                  `json:"transaction_hash"`
+			MerkleRoot      string                  `json:"merkle_root"`
+			Proof           []block.MerkleProofStep `json:"proof"`
+		}{height, utils.HashToHex(hash), utils.HashToHex(root), proof})
+		utils.SetContentType(w, utils.ContentTypeJSON)
+		io.WriteString(w, string(m))
+	default:
+		bcs.logger.Error("invalid http method", "method", req.Method, "path", req.URL.Path)
+		utils.MethodNotAllowed(w, http.MethodGet)
+	}
+}
+
+// Mine is api to do mining. The reward goes to the address query parameter
+// when it's given, otherwise to miner's blockchain address. The extra_nonce
+// query parameter partitions the nonce search between pool miners.
 func (bcs *BlockchainServer) Mine(w http.ResponseWriter, req *http.Request) {
 	switch req.Method {
 	case http.MethodGet:
 		bc := bcs.GetBlockchain()
-		isMined := bc.Mining()
+		address := bc.BlockchainAddress()
+		if a := req.URL.Query().Get("address"); a != "" {
+			if !utils.ValidBlockchainAddress(a) {
+				utils.WriteError(w, http.StatusBadRequest, utils.ErrorCodeInvalidRequest, "invalid address")
+				return
+			}
+			address = a
+		}
+		var extraNonce uint64
+		if s := req.URL.Query().Get("extra_nonce"); s != "" {
+			n, err := strconv.ParseUint(s, 10, 64)
+			if err != nil {
+				utils.WriteError(w, http.StatusBadRequest, utils.ErrorCodeInvalidRequest, "extra_nonce must be a non-negative integer")
+				return
+			}
+			extraNonce = n
+		}
+		isMined := bc.MineTo(address, extraNonce)
 
-		var m []byte
 		if !isMined {
-			w.WriteHeader(http.StatusBadRequest)
-			m = utils.JSONStatus("fail")
-		} else {
-			m = utils.JSONStatus("success")
+			utils.WriteError(w, http.StatusBadRequest, ErrorCodeMiningFailed, "mining failed")
+			return
 		}
-		w.Header().Add("Content-Type", "application/json")
-		io.WriteString(w, string(m))
+		utils.SetContentType(w, utils.ContentTypeJSON)
+		io.WriteString(w, string(utils.JSONStatus("success")))
 	default:
-		log.Println("ERROR: Invalid HTTP Method")
-		w.WriteHeader(http.StatusBadRequest)
+		bcs.logger.Error("invalid http method", "method", req.Method, "path", req.URL.Path)
+		utils.MethodNotAllowed(w, http.MethodGet)
 	}
 }
 
@@ -171,11 +576,11 @@ func (bcs *BlockchainServer) StartMine(w http.ResponseWriter, req *http.Request)
 		bc.StartMining()
 
 		m := utils.JSONStatus("success")
-		w.Header().Add("Content-Type", "application/json")
+		utils.SetContentType(w, utils.ContentTypeJSON)
 		io.WriteString(w, string(m))
 	default:
-		log.Println("ERROR: Invalid HTTP Method")
-		w.WriteHeader(http.StatusBadRequest)
+		bcs.logger.Error("invalid http method", "method", req.Method, "path", req.URL.Path)
+		utils.MethodNotAllowed(w, http.MethodGet)
 	}
 }
 
@@ -189,11 +594,454 @@ func (bcs *BlockchainServer) Amount(w http.ResponseWriter, req *http.Request) {
 		ar := &block.AmountResponse{Amount: amount}
 		m, _ := ar.MarshalJSON()
 
-		w.Header().Add("Content-Type", "application/json")
+		utils.SetContentType(w, utils.ContentTypeJSON)
+		io.WriteString(w, string(m[:]))
+	default:
+		bcs.logger.Error("invalid http method", "method", req.Method, "path", req.URL.Path)
+		utils.MethodNotAllowed(w, http.MethodGet)
+	}
+}
+
+// Balance is api to return balance of address as of block height, the
+// latest block when height isn't given.
+func (bcs *BlockchainServer) Balance(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		bc := bcs.GetBlockchain()
+		address := req.URL.Query().Get("address")
+		if address == "" {
+			utils.WriteError(w, http.StatusBadRequest, utils.ErrorCodeInvalidRequest, "address is required")
+			return
+		}
+		height := bc.Height() - 1
+		if s := req.URL.Query().Get("height"); s != "" {
+			var err error
+			height, err = strconv.Atoi(s)
+			if err != nil {
+				bcs.logger.Error("balance height", "height", s)
+				utils.WriteError(w, http.StatusBadRequest, utils.ErrorCodeInvalidRequest, "height must be an integer")
+				return
+			}
+		}
+		balance, err := bc.BalanceAtHeight(address, height)
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, block.ErrorCode(err), err.Error())
+			return
+		}
+		m, _ := json.Marshal(struct {
+			Address string  `json:"address"`
+			Height  int     `json:"height"`
+			Balance float32 `json:"balance"`
+		}{address, height, balance})
+		utils.SetContentType(w, utils.ContentTypeJSON)
+		io.WriteString(w, string(m))
+	default:
+		bcs.logger.Error("invalid http method", "method", req.Method, "path", req.URL.Path)
+		utils.MethodNotAllowed(w, http.MethodGet)
+	}
+}
+
+// Block is api to accept a block mined by a peer.
+func (bcs *BlockchainServer) Block(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		utils.SetContentType(w, utils.ContentTypeJSON)
+		if !bcs.verifyAnnouncement(w, req) {
+			return
+		}
+		decoder := json.NewDecoder(req.Body)
+		var b block.Block
+		err := decoder.Decode(&b)
+		if err != nil {
+			bcs.logger.Error("decode block", "error", err)
+			utils.WriteRequestError(w, err)
+			return
+		}
+
+		// Scores are kept by the verified node ID only if trusted nodes are
+		// set, as otherwise a banned sender could just sign with a new key,
+		// and else by the sending IP. Never by OriginNodeHeader, which any
+		// client could set to get an honest neighbor banned.
+		bc := bcs.GetBlockchain()
+		peer := utils.ClientIP(req)
+		if bc.TrustsNodes() {
+			peer = req.Header.Get(block.NodeIDHeader)
+		}
+		err = bc.AddBlockFrom(peer, &b)
+		switch {
+		case errors.Is(err, block.ErrBlockNotExtendTip):
+			utils.WriteError(w, http.StatusConflict, block.ErrorCode(err), err.Error())
+		case errors.Is(err, block.ErrPeerBanned):
+			utils.WriteError(w, http.StatusForbidden, block.ErrorCode(err), err.Error())
+		case err != nil:
+			bcs.logger.Error("add block", "peer", peer, "error", err)
+			utils.WriteError(w, http.StatusBadRequest, block.ErrorCode(err), err.Error())
+		default:
+			w.WriteHeader(http.StatusCreated)
+			io.WriteString(w, string(utils.JSONStatus("success")))
+		}
+	default:
+		bcs.logger.Error("invalid http method", "method", req.Method, "path", req.URL.Path)
+		utils.MethodNotAllowed(w, http.MethodPost)
+	}
+}
+
+// Decode is api to decode a raw block posted as json and return it
+// normalized, with its computed hash and whether its proof of work is
+// valid at the current difficulty, for debugging received blocks.
+func (bcs *BlockchainServer) Decode(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		data, err := io.ReadAll(req.Body)
+		if err != nil {
+			bcs.logger.Error("read block", "error", err)
+			utils.WriteRequestError(w, err)
+			return
+		}
+		b, err := block.DecodeBlock(data)
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, block.ErrorCode(err), err.Error())
+			return
+		}
+		m, _ := json.MarshalIndent(bcs.GetBlockchain().DescribeBlock(b), "", "  ")
+		utils.SetContentType(w, utils.ContentTypeJSON)
+		io.WriteString(w, string(m))
+	default:
+		bcs.logger.Error("invalid http method", "method", req.Method, "path", req.URL.Path)
+		utils.MethodNotAllowed(w, http.MethodPost)
+	}
+}
+
+// verifyAnnouncement is to check req from a peer is signed by its node
+// identity, replying 401 if it isn't. The sender isn't penalized, as an
+// unauthenticated request can't be told apart from a client's mistake and
+// shares its IP with any nodes on the same host. req.Body can be read
+// again afterwards.
+func (bcs *BlockchainServer) verifyAnnouncement(w http.ResponseWriter, req *http.Request) bool {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		bcs.logger.Error("read announcement", "error", err)
+		utils.WriteRequestError(w, err)
+		return false
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	bc := bcs.GetBlockchain()
+	err = bc.VerifyAnnouncement(req.Header.Get(block.NodeIDHeader), req.Header.Get(block.NodeSignatureHeader),
+		req.Header.Get(block.NodeTimestampHeader), req.Method, req.URL.RequestURI(), body)
+	if err != nil {
+		bcs.logger.Error("verify announcement", "peer", utils.ClientIP(req), "error", err)
+		utils.WriteError(w, http.StatusUnauthorized, block.ErrorCode(err), err.Error())
+		return false
+	}
+	return true
+}
+
+// Tip is api to return chain length, counting pruned blocks, and tip hash.
+// If the known query parameter is the current tip hash, it long-polls up to
+// wait, capped at MaxTipWait, for a new block before returning the tip.
+func (bcs *BlockchainServer) Tip(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		var wait time.Duration
+		if s := req.URL.Query().Get("wait"); s != "" {
+			var err error
+			wait, err = time.ParseDuration(s)
+			if err != nil || wait < 0 {
+				bcs.logger.Error("tip wait", "wait", s)
+				utils.WriteError(w, http.StatusBadRequest, utils.ErrorCodeInvalidRequest, "wait must be a duration such as 30s")
+				return
+			}
+		}
+		if wait > MaxTipWait {
+			wait = MaxTipWait
+		}
+
+		bc := bcs.GetBlockchain()
+		// Subscribe before reading the tip so a block arriving in between isn't missed.
+		ch := bcs.hub.Subscribe()
+		defer bcs.hub.Unsubscribe(ch)
+		length, hash := bc.Tip()
+		if known := req.URL.Query().Get("known"); known == utils.HashToHex(hash) && wait > 0 {
+			timer := time.NewTimer(wait)
+			defer timer.Stop()
+			select {
+			case <-ch:
+			case <-timer.C:
+			case <-req.Context().Done():
+				return
+			}
+			length, hash = bc.Tip()
+		}
+
+		m, _ := json.Marshal(struct {
+			Length int    `json:"length"`
+			Hash   string `json:"hash"`
+		}{
+			Length: length,
+			Hash:   utils.HashToHex(hash),
+		})
+		utils.SetContentType(w, utils.ContentTypeJSON)
+		io.WriteString(w, string(m))
+	default:
+		bcs.logger.Error("invalid http method", "method", req.Method, "path", req.URL.Path)
+		utils.MethodNotAllowed(w, http.MethodGet)
+	}
+}
+
+// Stats is api to return difficulty, average block time and estimated hashrate.
+func (bcs *BlockchainServer) Stats(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		bc := bcs.GetBlockchain()
+		m, _ := json.Marshal(struct {
+			Difficulty        int     `json:"difficulty"`
+			AverageBlockTime  float64 `json:"average_block_time"`
+			EstimatedHashrate float64 `json:"estimated_hashrate"`
+		}{
+			Difficulty:        bc.CurrentDifficulty(),
+			AverageBlockTime:  bc.AverageBlockTime(StatsWindow).Seconds(),
+			EstimatedHashrate: bc.HashrateEstimate(StatsWindow),
+		})
+		utils.SetContentType(w, utils.ContentTypeJSON)
 		io.WriteString(w, string(m[:]))
 	default:
-		log.Println("ERROR: Invalid HTTP Method")
-		w.WriteHeader(http.StatusBadRequest)
+		bcs.logger.Error("invalid http method", "method", req.Method, "path", req.URL.Path)
+		utils.MethodNotAllowed(w, http.MethodGet)
+	}
+}
+
+// History is api to return block times in seconds and difficulty per block,
+// or with the address query parameter, a page of transactions involving
+// address, newest first and pending ones included.
+func (bcs *BlockchainServer) History(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		bc := bcs.GetBlockchain()
+		q := req.URL.Query()
+		if address := q.Get("address"); address != "" {
+			from, limit, err := pageParams(q.Get("from"), q.Get("limit"), math.MaxInt32)
+			if err != nil {
+				bcs.logger.Error("page params", "error", err)
+				utils.WriteError(w, http.StatusBadRequest, utils.ErrorCodeInvalidRequest, err.Error())
+				return
+			}
+			entries := bc.History(address, from, limit)
+			var nextPtr *int
+			if len(entries) == limit {
+				next := from + limit
+				nextPtr = &next
+			}
+			m, _ := json.Marshal(struct {
+				Address      string               `json:"address"`
+				Transactions []block.HistoryEntry `json:"transactions"`
+				Next         *int                 `json:"next"`
+			}{
+				Address:      address,
+				Transactions: entries,
+				Next:         nextPtr,
+			})
+			utils.SetContentType(w, utils.ContentTypeJSON)
+			io.WriteString(w, string(m[:]))
+			return
+		}
+
+		blockTimes := bc.BlockTimes()
+		seconds := make([]float64, len(blockTimes))
+		for i, d := range blockTimes {
+			seconds[i] = time.Duration(d).Seconds()
+		}
+		m, _ := json.Marshal(struct {
+			BlockTimes []float64 `json:"block_times"`
+			Difficulty []int     `json:"difficulty"`
+		}{
+			BlockTimes: seconds,
+			Difficulty: bc.DifficultyHistory(),
+		})
+		utils.SetContentType(w, utils.ContentTypeJSON)
+		io.WriteString(w, string(m[:]))
+	default:
+		bcs.logger.Error("invalid http method", "method", req.Method, "path", req.URL.Path)
+		utils.MethodNotAllowed(w, http.MethodGet)
+	}
+}
+
+// Mempool is api to return summary of the transaction pool.
+func (bcs *BlockchainServer) Mempool(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		summary := bcs.GetBlockchain().MempoolSummary()
+		m, _ := json.Marshal(struct {
+			block.MempoolSummary
+			OldestAge float64 `json:"oldest_age"`
+		}{
+			MempoolSummary: summary,
+			OldestAge:      summary.OldestAge.Seconds(),
+		})
+		utils.SetContentType(w, utils.ContentTypeJSON)
+		io.WriteString(w, string(m[:]))
+	default:
+		bcs.logger.Error("invalid http method", "method", req.Method, "path", req.URL.Path)
+		utils.MethodNotAllowed(w, http.MethodGet)
+	}
+}
+
+// Peers is api to return known peers with their last seen time, ban status
+// and score (GET), or to add a peer by address (POST).
+func (bcs *BlockchainServer) Peers(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		var p struct {
+			Address string `json:"address"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&p); err != nil {
+			bcs.logger.Error("decode peer", "error", err)
+			utils.WriteRequestError(w, err)
+			return
+		}
+		if err := bcs.GetBlockchain().AddPeer(p.Address); err != nil {
+			bcs.logger.Error("add peer", "peer", p.Address, "error", err)
+			status := http.StatusBadRequest
+			if errors.Is(err, block.ErrPeerDuplicate) {
+				status = http.StatusConflict
+			}
+			utils.WriteError(w, status, block.ErrorCode(err), err.Error())
+			return
+		}
+		utils.SetContentType(w, utils.ContentTypeJSON)
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, string(utils.JSONStatus("success")))
+	case http.MethodGet:
+		peers := bcs.GetBlockchain().Peers()
+		m, _ := json.Marshal(struct {
+			Peers []block.PeerInfo `json:"peers"`
+		}{
+			Peers: peers,
+		})
+		utils.SetContentType(w, utils.ContentTypeJSON)
+		io.WriteString(w, string(m[:]))
+	default:
+		bcs.logger.Error("invalid http method", "method", req.Method, "path", req.URL.Path)
+		utils.MethodNotAllowed(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+// KeyFormat is api describing how sender_public_key and signature of
+// transactions are encoded and what is signed.
+func (bcs *BlockchainServer) KeyFormat(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		chainID := bcs.GetBlockchain().ChainID()
+		m, _ := json.Marshal(struct {
+			Curve          string   `json:"curve"`
+			PublicKey      []string `json:"public_key"`
+			Signature      string   `json:"signature"`
+			SignedMessage  string   `json:"signed_message"`
+			ChainID        string   `json:"chain_id"`
+			ExamplePayload string   `json:"example_payload"`
+		}{
+			Curve: "P-256",
+			PublicKey: []string{
+				fmt.Sprintf("%d hex characters: X then Y, each 32 byte big endian zero padded", utils.PublicKeyStringLength),
+				fmt.Sprintf("%d hex characters: compressed SEC1", utils.CompressedPublicKeyStringLength),
+			},
+			Signature:      "128 hex characters: R then S, each 32 byte big endian zero padded",
+			SignedMessage:  "sha256 of the canonical payload, like example_payload for sender S, recipient R, value 1.5 and timestamp 1700000000000000000",
+			ChainID:        chainID,
+			ExamplePayload: string(utils.CanonicalSignedTransaction(chainID, "S", "R", 1.5, 1700000000000000000)),
+		})
+		utils.SetContentType(w, utils.ContentTypeJSON)
+		io.WriteString(w, string(m))
+	default:
+		bcs.logger.Error("invalid http method", "method", req.Method, "path", req.URL.Path)
+		utils.MethodNotAllowed(w, http.MethodGet)
+	}
+}
+
+// RichList is api to return addresses with the highest balances,
+// up to limit query parameter.
+func (bcs *BlockchainServer) RichList(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		limit := DefaultRichListLimit
+		if s := req.URL.Query().Get("limit"); s != "" {
+			var err error
+			limit, err = strconv.Atoi(s)
+			if err != nil || limit <= 0 {
+				bcs.logger.Error("rich list limit", "limit", s)
+				utils.WriteError(w, http.StatusBadRequest, utils.ErrorCodeInvalidRequest, "limit must be a positive integer")
+				return
+			}
+		}
+		if limit > MaxRichListLimit {
+			limit = MaxRichListLimit
+		}
+		m, _ := json.Marshal(bcs.GetBlockchain().RichList(limit))
+		utils.SetContentType(w, utils.ContentTypeJSON)
+		io.WriteString(w, string(m))
+	default:
+		bcs.logger.Error("invalid http method", "method", req.Method, "path", req.URL.Path)
+		utils.MethodNotAllowed(w, http.MethodGet)
+	}
+}
+
+// VerifyMessage is api to verify a message signed with wallet.SignMessage,
+// and that the key owns blockchain_address when it's given.
+func (bcs *BlockchainServer) VerifyMessage(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		var vr struct {
+			PublicKey         *string `json:"public_key"`
+			Message           *string `json:"message"`
+			Signature         *string `json:"signature"`
+			BlockchainAddress string  `json:"blockchain_address"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&vr); err != nil {
+			bcs.logger.Error("decode verify message request", "error", err)
+			utils.WriteRequestError(w, err)
+			return
+		}
+		if vr.PublicKey == nil || vr.Message == nil || vr.Signature == nil {
+			utils.WriteError(w, http.StatusBadRequest, block.ErrorCodeMissingFields, block.ErrMissingFields.Error())
+			return
+		}
+		publicKey, err := wallet.DecodePublicKey(*vr.PublicKey)
+		if err != nil {
+			utils.WriteError(w, http.StatusBadRequest, utils.ErrorCodeInvalidRequest, err.Error())
+			return
+		}
+		if _, err := hex.DecodeString(*vr.Signature); err != nil || len(*vr.Signature) != 128 {
+			utils.WriteError(w, http.StatusBadRequest, utils.ErrorCodeInvalidRequest, "signature must be 128 hex characters")
+			return
+		}
+
+		address := wallet.BlockchainAddressOf(publicKey)
+		valid := wallet.VerifyMessage(publicKey, []byte(*vr.Message), utils.SignatureFromString(*vr.Signature)) &&
+			(vr.BlockchainAddress == "" || vr.BlockchainAddress == address)
+		m, _ := json.Marshal(struct {
+			Valid             bool   `json:"valid"`
+			BlockchainAddress string `json:"blockchain_address"`
+		}{
+			Valid:             valid,
+			BlockchainAddress: address,
+		})
+		utils.SetContentType(w, utils.ContentTypeJSON)
+		io.WriteString(w, string(m))
+	default:
+		bcs.logger.Error("invalid http method", "method", req.Method, "path", req.URL.Path)
+		utils.MethodNotAllowed(w, http.MethodPost)
+	}
+}
+
+// Health is api for peers to check this node is up.
+func (bcs *BlockchainServer) Health(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		utils.SetContentType(w, utils.ContentTypeJSON)
+		io.WriteString(w, string(utils.JSONStatus("ok")))
+	default:
+		bcs.logger.Error("invalid http method", "method", req.Method, "path", req.URL.Path)
+		utils.MethodNotAllowed(w, http.MethodGet)
 	}
 }
 
@@ -201,30 +1049,83 @@ func (bcs *BlockchainServer) Amount(w http.ResponseWriter, req *http.Request) {
 func (bcs *BlockchainServer) Consensus(w http.ResponseWriter, req *http.Request) {
 	switch req.Method {
 	case http.MethodPut:
+		utils.SetContentType(w, utils.ContentTypeJSON)
+		if !bcs.verifyAnnouncement(w, req) {
+			return
+		}
 		bc := bcs.GetBlockchain()
 		replaced := bc.ResolveConflicts()
 
-		w.Header().Add("Content-Type", "application/json")
 		if replaced {
 			io.WriteString(w, string(utils.JSONStatus("success")))
 		} else {
 			io.WriteString(w, string(utils.JSONStatus("fail")))
 		}
 	default:
-		log.Printf("ERROR: Invalid HTTP Method")
-		w.WriteHeader(http.StatusBadRequest)
+		bcs.logger.Error("invalid http method", "method", req.Method, "path", req.URL.Path)
+		utils.MethodNotAllowed(w, http.MethodPut)
 	}
 }
 
+// Handler is to return BlockchainServer's http handler.
+func (bcs *BlockchainServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/", utils.Gzip(http.HandlerFunc(bcs.GetChain)))
+	mux.Handle("/chain", utils.Gzip(http.HandlerFunc(bcs.GetChain)))
+	mux.HandleFunc("/transactions", bcs.Transactions)
+	mux.HandleFunc("/transactions/batch", bcs.TransactionsBatch)
+	mux.HandleFunc("/transactions/multisig", bcs.TransactionsMultisig)
+	mux.HandleFunc("/transaction", bcs.Transaction)
+	mux.HandleFunc("/receipt", bcs.Receipt)
+	mux.HandleFunc("/merkleproof", bcs.MerkleProof)
+	mux.HandleFunc("/mine", bcs.Mine)
+	mux.HandleFunc("/mine/start", bcs.StartMine)
+	mux.HandleFunc("/amount", bcs.Amount)
+	mux.HandleFunc("/balance", bcs.Balance)
+	mux.HandleFunc("/consensus", bcs.Consensus)
+	mux.HandleFunc("/block", bcs.Block)
+	mux.Handle("/headers", utils.Gzip(http.HandlerFunc(bcs.Headers)))
+	mux.HandleFunc("/decode", bcs.Decode)
+	mux.HandleFunc("/stats", bcs.Stats)
+	mux.HandleFunc("/tip", bcs.Tip)
+	mux.Handle("/history", utils.Gzip(http.HandlerFunc(bcs.History)))
+	mux.HandleFunc("/mempool", bcs.Mempool)
+	mux.HandleFunc("/peers", bcs.Peers)
+	mux.HandleFunc("/health", bcs.Health)
+	mux.HandleFunc("/keyformat", bcs.KeyFormat)
+	mux.HandleFunc("/richlist", bcs.RichList)
+	mux.HandleFunc("/verify-message", bcs.VerifyMessage)
+	mux.HandleFunc("/rpc", bcs.RPC)
+	mux.HandleFunc("/ws/blocks", bcs.WsBlocks)
+	mux.Handle("/metrics", bcs.Metrics())
+	return utils.RateLimit(bcs.limiter, utils.LimitBody(bcs.maxBodyBytes, mux))
+}
+
 // Run is to run server.
 func (bcs *BlockchainServer) Run() {
 	bcs.GetBlockchain().Run()
 
-	http.HandleFunc("/", bcs.GetChain)
-	http.HandleFunc("/transactions", bcs.Transactions)
-	http.HandleFunc("/mine", bcs.Mine)
-	http.HandleFunc("/mine/start", bcs.StartMine)
-	http.HandleFunc("/amount", bcs.Amount)
-	http.HandleFunc("/consensus", bcs.Consensus)
-	log.Fatal(http.ListenAndServe("0.0.0.0:"+strconv.Itoa(int(bcs.Port())), nil))
+	addr := net.JoinHostPort(bcs.Host(), strconv.Itoa(int(bcs.Port())))
+	bcs.muxServer.Lock()
+	bcs.server = &http.Server{Addr: addr, Handler: bcs.Handler()}
+	bcs.muxServer.Unlock()
+	err := utils.ListenAndServe(bcs.server, bcs.tlsCertFile, bcs.tlsKeyFile, bcs.tlsSelfSigned)
+	if err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+}
+
+// Shutdown is to cancel requests to peers, stop accepting requests and wait
+// for active ones until ctx is done.
+func (bcs *BlockchainServer) Shutdown(ctx context.Context) error {
+	bcs.muxServer.Lock()
+	server := bcs.server
+	bcs.muxServer.Unlock()
+	if bc, ok := cache["blockchain"]; ok {
+		bc.Close()
+	}
+	if server == nil {
+		return nil
+	}
+	return server.Shutdown(ctx)
 }
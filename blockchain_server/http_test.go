@@ -0,0 +1,1441 @@
+package blockchain_server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goblockchain/block"
+	"goblockchain/utils"
+	"goblockchain/wallet"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestServer is to return BlockchainServer of a new Blockchain and its
+// httptest server. configure is applied before the handler is built.
+func newTestServer(tb testing.TB, configure ...func(*BlockchainServer)) (*BlockchainServer, *httptest.Server) {
+	tb.Helper()
+	bcs := NewBlockchainServer(0)
+	bcs.SetLogger(utils.NewNopLogger())
+	tb.Cleanup(func() { delete(cache, "blockchain") })
+	bcs.SetBlockchain(block.NewBlockchain(wallet.NewWallet().BlockchainAddress(), 0))
+	for _, c := range configure {
+		c(bcs)
+	}
+	ts := httptest.NewServer(bcs.Handler())
+	tb.Cleanup(ts.Close)
+	return bcs, ts
+}
+
+func TestBlockHub(t *testing.T) {
+	hub := NewBlockHub()
+	fast := hub.Subscribe()
+	slow := hub.Subscribe()
+	b := block.NewBlock(0, [32]byte{}, nil, 0)
+	want, _ := b.MarshalJSON()
+
+	for i := 0; i < BlockSubscriberBufferSize; i++ {
+		hub.Publish(b)
+		if m := <-fast; string(m) != string(want) {
+			t.Fatalf("published %s, want %s", m, want)
+		}
+	}
+	// slow's buffer is full now, so it's dropped instead of blocking.
+	hub.Publish(b)
+	if m := <-fast; string(m) != string(want) {
+		t.Fatalf("published %s, want %s", m, want)
+	}
+	n := 0
+	for range slow {
+		n++
+	}
+	if n != BlockSubscriberBufferSize {
+		t.Errorf("slow subscriber got %d blocks before being dropped, want %d", n, BlockSubscriberBufferSize)
+	}
+
+	hub.Unsubscribe(fast)
+	if _, ok := <-fast; ok {
+		t.Error("unsubscribed channel isn't closed")
+	}
+	hub.Unsubscribe(fast)
+	hub.Publish(b)
+}
+
+func TestMetricsRegistry(t *testing.T) {
+	m := NewMetricsRegistry()
+	m.SetChainHeight(3)
+	m.SetTransactionPoolSize(2)
+	m.IncMinedBlocks()
+	m.IncMinedBlocks()
+	m.ObserveProofOfWork(1500 * time.Millisecond)
+	m.IncRejectedTransactions(block.RejectReasonInvalidSignature)
+	m.IncRejectedTransactions(block.RejectReasonInsufficientBalance)
+	m.IncRejectedTransactions(block.RejectReasonInvalidSignature)
+
+	if m.ChainHeight() != 3 || m.TransactionPoolSize() != 2 || m.MinedBlocks() != 2 ||
+		m.RejectedTransactions(block.RejectReasonInvalidSignature) != 2 {
+		t.Errorf("registry = %+v", m)
+	}
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, line := range []string{
+		"blockchain_chain_height 3",
+		"blockchain_transaction_pool_size 2",
+		"blockchain_mined_blocks_total 2",
+		"blockchain_proof_of_work_seconds 1.5",
+		`blockchain_rejected_transactions_total{reason="insufficient_balance"} 1`,
+		`blockchain_rejected_transactions_total{reason="invalid_signature"} 2`,
+	} {
+		if !strings.Contains(rec.Body.String(), line+"\n") {
+			t.Errorf("metrics miss %q:\n%s", line, rec.Body)
+		}
+	}
+}
+
+// peerRequest is to return request to url signed by peer's node identity,
+// as nodes send announcements to each other.
+func peerRequest(peer *block.Blockchain, method string, url string, body []byte) *http.Request {
+	req, _ := http.NewRequest(method, url, bytes.NewReader(body))
+	peer.SignRequest(req, body)
+	return req
+}
+
+func TestSetBlockchain(t *testing.T) {
+	bcs, ts := newTestServer(t)
+	bc := block.NewBlockchain(wallet.NewWallet().BlockchainAddress(), 0, block.WithChainID("testnet"))
+	bcs.SetBlockchain(bc)
+	bc.Mining()
+
+	resp, err := http.Get(ts.URL + "/chain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var got struct {
+		Chain []*block.Block `json:"chain"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Chain) != 2 || got.Chain[1].Hash() != bc.LastBlock().Hash() {
+		t.Errorf("served %d blocks, want the set chain's 2", len(got.Chain))
+	}
+
+	// Shutdown before Run has nothing to stop, but cancels requests to peers.
+	if err := bcs.Shutdown(context.Background()); err != nil {
+		t.Errorf("shutdown: %v", err)
+	}
+	if err := bc.AddPeer(strings.TrimPrefix(ts.URL, "http://")); !errors.Is(err, block.ErrPeerUnreachable) {
+		t.Errorf("add peer after shutdown: %v, want ErrPeerUnreachable", err)
+	}
+}
+
+func TestPostBlock(t *testing.T) {
+	bcs, ts := newTestServer(t)
+	bc := bcs.GetBlockchain()
+	nonce, _ := bc.ProofOfWork()
+	next, _ := json.Marshal(block.NewBlock(nonce, bc.LastBlock().Hash(), bc.CopyTransactionPool(), bc.CurrentDifficulty()))
+
+	tests := []struct {
+		name string
+		body []byte
+		want int
+	}{
+		{"next block", next, http.StatusCreated},
+		{"block not extending tip", next, http.StatusConflict},
+		{"malformed block", []byte(`{"previous_hash":"xyz"}`), http.StatusBadRequest},
+	}
+	peer := block.NewBlockchain(wallet.NewWallet().BlockchainAddress(), 0)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.DefaultClient.Do(peerRequest(peer, http.MethodPost, ts.URL+"/block", tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+	if n := len(bc.Chain()); n != 2 {
+		t.Errorf("chain length = %d, want 2", n)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	bcs, ts := newTestServer(t)
+	bc := bcs.GetBlockchain()
+	bc.Mining()
+	mined, _ := json.Marshal(bc.LastBlock())
+
+	resp, err := http.Post(ts.URL+"/decode", "application/json", bytes.NewReader(mined))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var d struct {
+		Hash       string `json:"hash"`
+		ValidProof bool   `json:"valid_proof"`
+		ExtendsTip bool   `json:"extends_tip"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&d); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || d.Hash != utils.HashToHex(bc.LastBlock().Hash()) || !d.ValidProof || d.ExtendsTip {
+		t.Errorf("status %d, description %+v", resp.StatusCode, d)
+	}
+
+	resp, err = http.Post(ts.URL+"/decode", "application/json", strings.NewReader(`{"previous_hash":"xyz"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("malformed block: status = %d, want 400", resp.StatusCode)
+	}
+	if code := errorCode(t, resp.Body); code != block.ErrorCodeMalformedBlock {
+		t.Errorf("error code = %q, want %q", code, block.ErrorCodeMalformedBlock)
+	}
+
+	resp, err = http.Get(ts.URL + "/decode")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("GET: status = %d, want 405", resp.StatusCode)
+	}
+}
+
+func TestAnnouncements(t *testing.T) {
+	bcs, ts := newTestServer(t)
+	bc := bcs.GetBlockchain()
+	peer := block.NewBlockchain(wallet.NewWallet().BlockchainAddress(), 0)
+	stranger := block.NewBlockchain(wallet.NewWallet().BlockchainAddress(), 0)
+
+	// The signature of one body is sent with another.
+	forged, _ := http.NewRequest(http.MethodPost, ts.URL+"/block", strings.NewReader(`{"nonce":1}`))
+	forged.Header = peerRequest(peer, http.MethodPost, ts.URL+"/block", []byte(`{}`)).Header
+	unsignedConsensus, _ := http.NewRequest(http.MethodPut, ts.URL+"/consensus", nil)
+	tests := []struct {
+		name    string
+		req     *http.Request
+		trusted []string
+		status  int
+		code    string
+	}{
+		{"forged signature", forged, nil, http.StatusUnauthorized, block.ErrorCodeInvalidAnnouncement},
+		{"unsigned consensus", unsignedConsensus, nil, http.StatusUnauthorized, block.ErrorCodeInvalidAnnouncement},
+		{"unknown identity", peerRequest(stranger, http.MethodPut, ts.URL+"/consensus", nil), []string{peer.NodeID()}, http.StatusUnauthorized, block.ErrorCodeUnknownNode},
+		{"trusted consensus", peerRequest(peer, http.MethodPut, ts.URL+"/consensus", nil), []string{peer.NodeID()}, http.StatusOK, ""},
+		{"signed consensus", peerRequest(peer, http.MethodPut, ts.URL+"/consensus", nil), nil, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bc.SetTrustedNodes(tt.trusted)
+			resp, err := http.DefaultClient.Do(tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if tt.code != "" {
+				if code := errorCode(t, resp.Body); code != tt.code {
+					t.Errorf("error code = %q, want %q", code, tt.code)
+				}
+			}
+		})
+	}
+
+	// A captured announcement is accepted once.
+	bc.SetTrustedNodes(nil)
+	replayed := peerRequest(peer, http.MethodPut, ts.URL+"/consensus", nil)
+	for i, want := range []int{http.StatusOK, http.StatusUnauthorized} {
+		resp, err := http.DefaultClient.Do(replayed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("send %d: status = %d, want %d", i, resp.StatusCode, want)
+		}
+	}
+
+	// Unauthenticated requests aren't scored, so they can't get nodes
+	// sharing the sender's host banned.
+	if peers := bc.Peers(); len(peers) != 0 {
+		t.Errorf("peers = %+v, want rejected announcements unscored", peers)
+	}
+}
+
+func TestTip(t *testing.T) {
+	bcs, ts := newTestServer(t)
+	bc := bcs.GetBlockchain()
+	_, genesis := bc.Tip()
+	bc.Mining()
+	_, tip := bc.Tip()
+
+	get := func(query string) (int, string, time.Duration) {
+		t.Helper()
+		start := time.Now()
+		resp, err := http.Get(ts.URL + "/tip" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("status = %d", resp.StatusCode)
+		}
+		var got struct {
+			Length int    `json:"length"`
+			Hash   string `json:"hash"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		return got.Length, got.Hash, time.Since(start)
+	}
+
+	if length, hash, took := get("?wait=5s&known=" + utils.HashToHex(genesis)); length != 2 || hash != utils.HashToHex(tip) || took > time.Second {
+		t.Errorf("stale known = %d, %s after %v; want tip at once", length, hash, took)
+	}
+	if _, hash, took := get("?wait=50ms&known=" + utils.HashToHex(tip)); hash != utils.HashToHex(tip) || took < 50*time.Millisecond {
+		t.Errorf("current known = %s after %v; want tip after the wait", hash, took)
+	}
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		bc.Mining()
+	}()
+	if length, hash, took := get("?wait=5s&known=" + utils.HashToHex(tip)); length != 3 || hash == utils.HashToHex(tip) || took > 4*time.Second {
+		t.Errorf("block mined while waiting = %d, %s after %v; want new tip", length, hash, took)
+	}
+
+	resp, err := http.Get(ts.URL + "/tip?wait=soon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest || errorCode(t, resp.Body) != utils.ErrorCodeInvalidRequest {
+		t.Errorf("invalid wait: status = %d", resp.StatusCode)
+	}
+}
+
+func TestMerkleProof(t *testing.T) {
+	bcs, ts := newTestServer(t)
+	bc := bcs.GetBlockchain()
+	bc.Mining()
+	hash := utils.HashToHex(bc.LastBlock().Transactions()[0].Hash())
+
+	tests := []struct {
+		query  string
+		status int
+		code   string
+	}{
+		{"?block=1&tx=" + hash, http.StatusOK, ""},
+		{"?block=0&tx=" + hash, http.StatusNotFound, block.ErrorCodeTransactionNotFound},
+		{"?block=5&tx=" + hash, http.StatusNotFound, block.ErrorCodeHeightOutOfRange},
+		{"?block=x&tx=" + hash, http.StatusBadRequest, utils.ErrorCodeInvalidRequest},
+		{"?block=1&tx=xyz", http.StatusBadRequest, utils.ErrorCodeInvalidRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			resp, err := http.Get(ts.URL + "/merkleproof" + tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if tt.code != "" {
+				if code := errorCode(t, resp.Body); code != tt.code {
+					t.Errorf("error code = %q, want %q", code, tt.code)
+				}
+				return
+			}
+			var got struct {
+				MerkleRoot string                  `json:"merkle_root"`
+				Proof      []block.MerkleProofStep `json:"proof"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			root, _ := utils.HexToHash(got.MerkleRoot)
+			txHash, _ := utils.HexToHash(hash)
+			if root != bc.LastBlock().MerkleRoot() || !block.VerifyMerkleProof(txHash, got.Proof, root) {
+				t.Errorf("served proof doesn't verify against the block's root")
+			}
+		})
+	}
+}
+
+func TestGetChainPage(t *testing.T) {
+	bcs, ts := newTestServer(t)
+	bc := bcs.GetBlockchain()
+	poolTransaction(t, bc, fundedWallet(bc), "1Alice", 1)
+	bc.Mining()
+	bc.Mining()
+
+	next := func(n int) *int { return &n }
+	tests := []struct {
+		query      string
+		wantStatus int
+		wantBlocks int
+		wantNext   *int
+	}{
+		{"from=1&limit=2", http.StatusOK, 2, next(3)},
+		{"from=3", http.StatusOK, 1, nil},
+		{"from=4", http.StatusOK, 0, nil},
+		{"address=1Alice", http.StatusOK, 1, nil},
+		{"limit=1000", http.StatusOK, 4, nil},
+		{"from=5", http.StatusBadRequest, 0, nil},
+		{"from=-1", http.StatusBadRequest, 0, nil},
+		{"from=x", http.StatusBadRequest, 0, nil},
+		{"limit=0", http.StatusBadRequest, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			resp, err := http.Get(ts.URL + "/chain?" + tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var page struct {
+				Chain []json.RawMessage `json:"chain"`
+				Next  *int              `json:"next"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+				t.Fatal(err)
+			}
+			if len(page.Chain) != tt.wantBlocks {
+				t.Errorf("blocks = %d, want %d", len(page.Chain), tt.wantBlocks)
+			}
+			if (page.Next == nil) != (tt.wantNext == nil) || (page.Next != nil && *page.Next != *tt.wantNext) {
+				t.Errorf("next = %v, want %v", page.Next, tt.wantNext)
+			}
+		})
+	}
+}
+
+func TestHeaders(t *testing.T) {
+	bcs, ts := newTestServer(t)
+	bc := bcs.GetBlockchain()
+	for i := 0; i < 3; i++ {
+		bc.Mining()
+	}
+
+	tests := []struct {
+		query       string
+		wantStatus  int
+		wantHeaders int
+	}{
+		{"", http.StatusOK, 4},
+		{"from=2", http.StatusOK, 2},
+		{"from=4", http.StatusOK, 0},
+		{"from=-1", http.StatusBadRequest, 0},
+		{"from=x", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			resp, err := http.Get(ts.URL + "/headers?" + tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+			var headers []block.BlockHeader
+			if err := json.NewDecoder(resp.Body).Decode(&headers); err != nil {
+				t.Fatal(err)
+			}
+			if len(headers) != tt.wantHeaders || !bc.ValidHeaders(headers) {
+				t.Errorf("%d headers, valid %v", len(headers), bc.ValidHeaders(headers))
+			}
+			if len(headers) > 0 && headers[len(headers)-1].Hash() != bc.LastBlock().Hash() {
+				t.Error("last header isn't the tip's")
+			}
+		})
+	}
+
+	resp, err := http.Post(ts.URL+"/headers", "application/json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("POST: status = %d, want 405", resp.StatusCode)
+	}
+}
+
+func TestGetTransaction(t *testing.T) {
+	bcs, ts := newTestServer(t)
+	bc := bcs.GetBlockchain()
+	poolTransaction(t, bc, fundedWallet(bc), "1Alice", 1)
+	id := fmt.Sprintf("%x", bc.TransactionPool()[0].Hash())
+
+	get := func(id string) (int, map[string]interface{}) {
+		t.Helper()
+		resp, err := http.Get(ts.URL + "/transaction?id=" + id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var body map[string]interface{}
+		json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body
+	}
+
+	if status, body := get(id); status != http.StatusOK || body["status"] != "pending" || body["block_index"] != nil {
+		t.Errorf("pending: status %d, body %v", status, body)
+	}
+	bc.Mining()
+	bc.Mining()
+	if status, body := get(id); status != http.StatusOK || body["status"] != "confirmed" ||
+		body["block_index"] != 2.0 || body["confirmations"] != 2.0 {
+		t.Errorf("confirmed: status %d, body %v", status, body)
+	}
+	if status, _ := get(fmt.Sprintf("%x", [32]byte{1})); status != http.StatusNotFound {
+		t.Errorf("unknown id: status %d, want 404", status)
+	}
+	if status, _ := get("xyz"); status != http.StatusBadRequest {
+		t.Errorf("malformed id: status %d, want 400", status)
+	}
+}
+
+func TestStats(t *testing.T) {
+	_, ts := newTestServer(t)
+	resp, err := http.Get(ts.URL + "/stats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var stats map[string]float64
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats["difficulty"] != block.MiningDifficulty || stats["average_block_time"] != 0 || stats["estimated_hashrate"] != 0 {
+		t.Errorf("stats of new chain = %v", stats)
+	}
+}
+
+func TestPostBlockFromBannedPeer(t *testing.T) {
+	post := func(t *testing.T, url string, sender *block.Blockchain, body []byte) int {
+		t.Helper()
+		req := peerRequest(sender, http.MethodPost, url+"/block", body)
+		// The origin header can't move the ban to another peer.
+		req.Header.Set(block.OriginNodeHeader, "10.0.0.1:5000")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	nextBlock := func(bc *block.Blockchain) []byte {
+		nonce, _ := bc.ProofOfWork()
+		next, _ := json.Marshal(block.NewBlock(nonce, bc.LastBlock().Hash(), bc.CopyTransactionPool(), bc.CurrentDifficulty()))
+		return next
+	}
+
+	t.Run("untrusted", func(t *testing.T) {
+		bcs, ts := newTestServer(t)
+		bc := bcs.GetBlockchain()
+		for !bc.IsBanned("127.0.0.1") {
+			bc.PenalizePeer("127.0.0.1", block.PeerInvalidPenalty)
+		}
+		// Without trusted nodes the ban is by IP, so a new node ID
+		// doesn't shed it.
+		next := nextBlock(bc)
+		for i := 0; i < 2; i++ {
+			sender := block.NewBlockchain(wallet.NewWallet().BlockchainAddress(), 0)
+			if got := post(t, ts.URL, sender, next); got != http.StatusForbidden {
+				t.Errorf("new identity %d: status = %d, want 403", i, got)
+			}
+		}
+	})
+
+	t.Run("trusted", func(t *testing.T) {
+		bcs, ts := newTestServer(t)
+		bc := bcs.GetBlockchain()
+		peer := block.NewBlockchain(wallet.NewWallet().BlockchainAddress(), 0)
+		other := block.NewBlockchain(wallet.NewWallet().BlockchainAddress(), 0)
+		bc.SetTrustedNodes([]string{peer.NodeID(), other.NodeID()})
+		for !bc.IsBanned(peer.NodeID()) {
+			bc.PenalizePeer(peer.NodeID(), block.PeerInvalidPenalty)
+		}
+		// With trusted nodes the ban is by node ID, so another node on
+		// the same host isn't banned with it.
+		next := nextBlock(bc)
+		if got := post(t, ts.URL, peer, next); got != http.StatusForbidden {
+			t.Errorf("banned peer: status = %d, want 403", got)
+		}
+		if got := post(t, ts.URL, other, next); got != http.StatusCreated {
+			t.Errorf("other peer: status = %d, want 201", got)
+		}
+	})
+}
+
+func TestGetChainETag(t *testing.T) {
+	bcs, ts := newTestServer(t)
+	get := func(query, ifNoneMatch string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, ts.URL+"/chain"+query, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	etag := get("", "").Header.Get("ETag")
+	height, tipHash := bcs.GetBlockchain().Tip()
+	if want := fmt.Sprintf(`"%d-%x-0"`, height, tipHash); strings.TrimPrefix(etag, "W/") != want {
+		t.Fatalf("ETag = %s, want %s of the tip", etag, want)
+	}
+	// The client accepts gzip, so the ETag is weak.
+	strong := strings.TrimPrefix(etag, "W/")
+	for _, ifNoneMatch := range []string{etag, strong, `"other", ` + etag, "*"} {
+		if resp := get("", ifNoneMatch); resp.StatusCode != http.StatusNotModified {
+			t.Errorf("If-None-Match %s: status = %d, want 304", ifNoneMatch, resp.StatusCode)
+		}
+	}
+	if resp := get("?limit=1", etag); resp.StatusCode != http.StatusOK {
+		t.Errorf("page with chain's ETag: status = %d, want 200", resp.StatusCode)
+	}
+	pageETag := get("?limit=1", "").Header.Get("ETag")
+	if resp := get("?limit=1", pageETag); resp.StatusCode != http.StatusNotModified {
+		t.Errorf("page with its ETag: status = %d, want 304", resp.StatusCode)
+	}
+	if resp := get("?limit=2", pageETag); resp.StatusCode != http.StatusOK {
+		t.Errorf("other page with page's ETag: status = %d, want 200", resp.StatusCode)
+	}
+
+	bcs.GetBlockchain().Mining()
+	resp := get("", etag)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("after mining: status = %d, want 200", resp.StatusCode)
+	}
+	if resp.Header.Get("ETag") == etag {
+		t.Error("ETag unchanged after mining")
+	}
+}
+
+// transactionRequest is to return request of transaction of value from w to
+// recipient signed by w for network chainID.
+func transactionRequest(chainID string, w *wallet.Wallet, recipient string, value float32) *block.TransactionRequest {
+	t := wallet.NewTransaction(w.PrivateKey(), w.PublicKey(), w.BlockchainAddress(), recipient, value)
+	t.SetChainID(chainID)
+	sender, publicKey, signature := w.BlockchainAddress(), w.PublicKeyStr(), t.GenerateSignature().String()
+	timestamp := t.Timestamp()
+	return &block.TransactionRequest{
+		SenderBlockchainAddress:    &sender,
+		RecipientBlockchainAddress: &recipient,
+		SenderPublicKey:            &publicKey,
+		Value:                      &value,
+		Timestamp:                  &timestamp,
+		Signature:                  &signature,
+	}
+}
+
+// fundedWallet is to return a wallet that bc has mined a reward to.
+func fundedWallet(bc *block.Blockchain) *wallet.Wallet {
+	w := wallet.NewWallet()
+	bc.SetBlockchainAddress(w.BlockchainAddress())
+	bc.Mining()
+	return w
+}
+
+// poolTransaction is to add a transaction signed by w to bc's pool.
+func poolTransaction(tb testing.TB, bc *block.Blockchain, w *wallet.Wallet, recipient string, value float32) {
+	tb.Helper()
+	if err := bc.SubmitTransactionRequest(transactionRequest(bc.ChainID(), w, recipient, value), false); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+func TestTransactionsBatch(t *testing.T) {
+	bcs, ts := newTestServer(t)
+	bc := bcs.GetBlockchain()
+	alice := wallet.NewWallet()
+	bc.SetBlockchainAddress(alice.BlockchainAddress())
+	bc.Mining()
+
+	forged := transactionRequest(bc.ChainID(), alice, "1Bob", 0.5)
+	*forged.Value = 0.9
+	missing := transactionRequest(bc.ChainID(), alice, "1Bob", 0.5)
+	missing.Signature = nil
+	requests := []*block.TransactionRequest{
+		transactionRequest(bc.ChainID(), alice, "1Bob", 0.5),
+		forged,
+		missing,
+		transactionRequest(bc.ChainID(), alice, "1Bob", 100),
+	}
+	body, _ := json.Marshal(requests)
+	resp, err := http.Post(ts.URL+"/transactions/batch", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusMultiStatus {
+		t.Fatalf("status = %d, want 207", resp.StatusCode)
+	}
+	var got struct {
+		Results []struct {
+			Index  int    `json:"index"`
+			Status string `json:"status"`
+			Reason string `json:"reason"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"accepted", block.ErrInvalidSignature.Error(), block.ErrMissingFields.Error(), block.ErrInsufficientBalance.Error()}
+	if len(got.Results) != len(want) {
+		t.Fatalf("results = %+v", got.Results)
+	}
+	for i, r := range got.Results {
+		if r.Index != i || (r.Status == "accepted") != (i == 0) || (i > 0 && r.Reason != want[i]) {
+			t.Errorf("result %d = %+v, want %s", i, r, want[i])
+		}
+	}
+	if n := len(bc.TransactionPool()); n != 1 {
+		t.Errorf("pool size = %d, want 1", n)
+	}
+
+	resp, err = http.Post(ts.URL+"/transactions/batch", "application/json", strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("malformed batch: status = %d, want 400", resp.StatusCode)
+	}
+}
+
+func TestPostTransactionCompressedKey(t *testing.T) {
+	bcs, ts := newTestServer(t)
+	bc := bcs.GetBlockchain()
+	alice := wallet.NewWallet()
+	bc.SetBlockchainAddress(alice.BlockchainAddress())
+	bc.Mining()
+
+	tr := transactionRequest(bc.ChainID(), alice, "1Bob", 0.5)
+	compressed := alice.PublicKeyCompressedStr()
+	tr.SenderPublicKey = &compressed
+	body, _ := json.Marshal(tr)
+	resp, err := http.Post(ts.URL+"/transactions", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("status = %d, want 201", resp.StatusCode)
+	}
+}
+
+func TestMempool(t *testing.T) {
+	bcs, ts := newTestServer(t)
+	bc := bcs.GetBlockchain()
+	poolTransaction(t, bc, fundedWallet(bc), "1Alice", 0.5)
+	resp, err := http.Get(ts.URL + "/mempool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var summary map[string]float64
+	if err := json.NewDecoder(resp.Body).Decode(&summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary["count"] != 1 || summary["total_value"] != 0.5 || summary["oldest_age"] < 0 {
+		t.Errorf("summary = %v", summary)
+	}
+	if _, ok := summary["oldest_age"]; !ok {
+		t.Error("oldest_age missing")
+	}
+}
+
+func TestHistory(t *testing.T) {
+	bcs, ts := newTestServer(t)
+	bcs.GetBlockchain().Mining()
+	resp, err := http.Get(ts.URL + "/history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var history struct {
+		BlockTimes []float64 `json:"block_times"`
+		Difficulty []int     `json:"difficulty"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&history); err != nil {
+		t.Fatal(err)
+	}
+	if len(history.BlockTimes) != 1 || len(history.Difficulty) != 2 || history.Difficulty[1] != block.MiningDifficulty {
+		t.Errorf("history = %+v", history)
+	}
+}
+
+func TestAddressHistory(t *testing.T) {
+	bcs, ts := newTestServer(t)
+	bc := bcs.GetBlockchain()
+	miner := bc.BlockchainAddress()
+	for i := 0; i < 3; i++ {
+		bc.Mining()
+	}
+	total := len(bc.History(miner, 0, 100))
+
+	tests := []struct {
+		query   string
+		status  int
+		entries int
+		next    int
+	}{
+		{"&limit=2", http.StatusOK, 2, 2},
+		{fmt.Sprintf("&from=2&limit=%d", total), http.StatusOK, total - 2, -1},
+		{"&limit=x", http.StatusBadRequest, 0, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			resp, err := http.Get(ts.URL + "/history?address=" + miner + tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if tt.status != http.StatusOK {
+				if code := errorCode(t, resp.Body); code != utils.ErrorCodeInvalidRequest {
+					t.Errorf("error code = %q, want %q", code, utils.ErrorCodeInvalidRequest)
+				}
+				return
+			}
+			var got struct {
+				Address      string               `json:"address"`
+				Transactions []block.HistoryEntry `json:"transactions"`
+				Next         *int                 `json:"next"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Address != miner || len(got.Transactions) != tt.entries {
+				t.Errorf("got %d entries for %s, want %d", len(got.Transactions), got.Address, tt.entries)
+			}
+			next := -1
+			if got.Next != nil {
+				next = *got.Next
+			}
+			if next != tt.next {
+				t.Errorf("next = %d, want %d", next, tt.next)
+			}
+		})
+	}
+}
+
+func TestGzipResponses(t *testing.T) {
+	_, ts := newTestServer(t)
+	for _, path := range []string{"/chain", "/history"} {
+		t.Run(path, func(t *testing.T) {
+			// The client asks for gzip and decompresses transparently.
+			resp, err := http.Get(ts.URL + path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			var v map[string]interface{}
+			if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+				t.Fatal(err)
+			}
+			if !resp.Uncompressed {
+				t.Error("response wasn't gzipped")
+			}
+		})
+	}
+}
+
+func TestTransactionsMethodNotAllowed(t *testing.T) {
+	_, ts := newTestServer(t)
+	for _, method := range []string{http.MethodPatch, http.MethodHead, http.MethodOptions, http.MethodDelete} {
+		t.Run(method, func(t *testing.T) {
+			req, _ := http.NewRequest(method, ts.URL+"/transactions", nil)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusMethodNotAllowed {
+				t.Fatalf("status = %d, want 405", resp.StatusCode)
+			}
+			if got, want := resp.Header.Get("Allow"), "GET, POST, PUT"; got != want {
+				t.Errorf("Allow = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestTransactionsBodyTooLarge(t *testing.T) {
+	const limit = 256
+	request := `{"sender_blockchain_address":"` + strings.Repeat("1", limit) + `"}`
+	tests := []struct {
+		name    string
+		body    string
+		chunked bool
+		want    int
+	}{
+		{"malformed body", `{`, false, http.StatusBadRequest},
+		{"declared over limit", request, false, http.StatusRequestEntityTooLarge},
+		{"chunked over limit", request, true, http.StatusRequestEntityTooLarge},
+		{"chunked malformed body", `{`, true, http.StatusBadRequest},
+	}
+	_, ts := newTestServer(t, func(bcs *BlockchainServer) {
+		bcs.SetMaxBodyBytes(limit)
+	})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body io.Reader = strings.NewReader(tt.body)
+			if tt.chunked {
+				// A reader of unknown length is sent chunked.
+				body = io.MultiReader(body)
+			}
+			resp, err := http.Post(ts.URL+"/transactions", "application/json", body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+// errorCode is to return code of json error reply body.
+func errorCode(tb testing.TB, body io.Reader) string {
+	tb.Helper()
+	var v struct {
+		Error struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(body).Decode(&v); err != nil {
+		tb.Fatal(err)
+	}
+	return v.Error.Code
+}
+
+func TestTransactionsErrorCodes(t *testing.T) {
+	bcs, ts := newTestServer(t)
+	bc := bcs.GetBlockchain()
+	alice := wallet.NewWallet()
+	bc.SetBlockchainAddress(alice.BlockchainAddress())
+	bc.Mining()
+	bc.SetRequireKnownRecipient(true)
+
+	forged := transactionRequest(bc.ChainID(), alice, "1Bob", 0.5)
+	*forged.Value = 0.9
+	missing := transactionRequest(bc.ChainID(), alice, "1Bob", 0.5)
+	missing.Signature = nil
+	tests := []struct {
+		name string
+		body interface{}
+		code string
+	}{
+		{"invalid signature", forged, block.ErrorCodeInvalidSignature},
+		{"missing fields", missing, block.ErrorCodeMissingFields},
+		{"insufficient balance", transactionRequest(bc.ChainID(), alice, "1Bob", 100), block.ErrorCodeInsufficientBalance},
+		{"unknown recipient", transactionRequest(bc.ChainID(), alice, "1Bob", 0.5), block.ErrorCodeUnknownRecipient},
+		{"malformed json", "{", utils.ErrorCodeInvalidRequest},
+	}
+	peer := block.NewBlockchain(wallet.NewWallet().BlockchainAddress(), 0)
+	for _, method := range []string{http.MethodPost, http.MethodPut} {
+		for _, tt := range tests {
+			t.Run(method+" "+tt.name, func(t *testing.T) {
+				body, _ := json.Marshal(tt.body)
+				if s, ok := tt.body.(string); ok {
+					body = []byte(s)
+				}
+				req, _ := http.NewRequest(method, ts.URL+"/transactions", bytes.NewReader(body))
+				if method == http.MethodPut {
+					req = peerRequest(peer, method, ts.URL+"/transactions", body)
+				}
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer resp.Body.Close()
+				if resp.StatusCode != http.StatusBadRequest {
+					t.Errorf("status = %d, want 400", resp.StatusCode)
+				}
+				if code := errorCode(t, resp.Body); code != tt.code {
+					t.Errorf("error code = %q, want %q", code, tt.code)
+				}
+			})
+		}
+	}
+}
+
+func TestTransactionsContentType(t *testing.T) {
+	bcs, ts := newTestServer(t)
+	bc := bcs.GetBlockchain()
+	alice := fundedWallet(bc)
+	valid, _ := json.Marshal(transactionRequest(bc.ChainID(), alice, "1Bob", 0.5))
+	forged := transactionRequest(bc.ChainID(), alice, "1Bob", 0.5)
+	*forged.Value = 0.9
+	invalid, _ := json.Marshal(forged)
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		want   int
+	}{
+		{"get pool", http.MethodGet, "", http.StatusOK},
+		{"post transaction", http.MethodPost, string(valid), http.StatusCreated},
+		{"invalid signature", http.MethodPost, string(invalid), http.StatusBadRequest},
+		{"malformed json", http.MethodPost, "{", http.StatusBadRequest},
+		{"method not allowed", http.MethodPatch, "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tt.method, ts.URL+"/transactions", strings.NewReader(tt.body))
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+			if ct := resp.Header.Get("Content-Type"); ct != utils.ContentTypeJSON {
+				t.Errorf("Content-Type = %q, want %q", ct, utils.ContentTypeJSON)
+			}
+		})
+	}
+}
+
+// suppressLogger is utils.Logger counting transactions added without being
+// broadcast.
+type suppressLogger struct {
+	utils.NopLogger
+	suppressed int
+	mux        sync.Mutex
+}
+
+func (l *suppressLogger) Debug(msg string, args ...interface{}) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	for i := 0; i+1 < len(args); i += 2 {
+		if args[i] == "status" && args[i+1] == "suppressed" {
+			l.suppressed++
+		}
+	}
+}
+
+func TestTransactionsFromPeerNotBroadcast(t *testing.T) {
+	bcs, ts := newTestServer(t)
+	bc := bcs.GetBlockchain()
+	alice := wallet.NewWallet()
+	bc.SetBlockchainAddress(alice.BlockchainAddress())
+	bc.Mining()
+	logger := &suppressLogger{}
+	bc.SetLogger(logger)
+
+	tests := []struct {
+		name       string
+		method     string
+		originNode string
+		suppressed bool
+	}{
+		{"user", http.MethodPost, "", false},
+		{"peer", http.MethodPut, "", true},
+		{"peer posting", http.MethodPost, "127.0.0.1:5001", true},
+	}
+	peer := block.NewBlockchain(wallet.NewWallet().BlockchainAddress(), 0)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger.mux.Lock()
+			logger.suppressed = 0
+			logger.mux.Unlock()
+			body, _ := json.Marshal(transactionRequest(bc.ChainID(), alice, "1Bob", 0.1))
+			req, _ := http.NewRequest(tt.method, ts.URL+"/transactions", bytes.NewReader(body))
+			if tt.suppressed {
+				req = peerRequest(peer, tt.method, ts.URL+"/transactions", body)
+			}
+			if tt.originNode != "" {
+				req.Header.Set(block.OriginNodeHeader, tt.originNode)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode >= 300 {
+				t.Fatalf("status = %d", resp.StatusCode)
+			}
+			logger.mux.Lock()
+			defer logger.mux.Unlock()
+			if got := logger.suppressed == 1; got != tt.suppressed {
+				t.Errorf("broadcast suppressed = %v, want %v", got, tt.suppressed)
+			}
+		})
+	}
+}
+
+func TestPeers(t *testing.T) {
+	bcs, ts := newTestServer(t)
+	bcs.GetBlockchain().PenalizePeer("10.0.0.1:5000", block.PeerInvalidPenalty)
+	resp, err := http.Get(ts.URL + "/peers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var got struct {
+		Peers []block.PeerInfo `json:"peers"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Peers) != 1 || got.Peers[0].Address != "10.0.0.1:5000" || got.Peers[0].Score != -block.PeerInvalidPenalty {
+		t.Errorf("peers = %+v", got.Peers)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, ts.URL+"/peers", nil)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE status = %d, want 405", resp.StatusCode)
+	}
+}
+
+func TestPostPeers(t *testing.T) {
+	_, peer := newTestServer(t)
+	_, ts := newTestServer(t)
+	address := strings.TrimPrefix(peer.URL, "http://")
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		code   string
+	}{
+		{"added", `{"address":"` + address + `"}`, http.StatusCreated, ""},
+		{"duplicate", `{"address":"` + address + `"}`, http.StatusConflict, block.ErrorCodePeerDuplicate},
+		{"invalid address", `{"address":"no-port"}`, http.StatusBadRequest, block.ErrorCodeInvalidPeer},
+		{"malformed json", `{`, http.StatusBadRequest, utils.ErrorCodeInvalidRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Post(ts.URL+"/peers", "application/json", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if tt.code != "" {
+				if code := errorCode(t, resp.Body); code != tt.code {
+					t.Errorf("code = %q, want %q", code, tt.code)
+				}
+			}
+		})
+	}
+}
+
+func TestMineToAddress(t *testing.T) {
+	bcs, ts := newTestServer(t)
+	address := wallet.NewWallet().BlockchainAddress()
+	resp, err := http.Get(ts.URL + "/mine?address=" + address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if got := bcs.GetBlockchain().CalculateTotalAmount(address); got != block.MiningReward {
+		t.Errorf("balance = %v, want the reward", got)
+	}
+
+	resp, err = http.Get(ts.URL + "/mine?address=1Typo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("invalid address status = %d, want 400", resp.StatusCode)
+	}
+	if code := errorCode(t, resp.Body); code != utils.ErrorCodeInvalidRequest {
+		t.Errorf("error code = %q, want %q", code, utils.ErrorCodeInvalidRequest)
+	}
+}
+
+func TestMineExtraNonce(t *testing.T) {
+	bcs, ts := newTestServer(t)
+	resp, err := http.Get(ts.URL + "/mine?extra_nonce=7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if got := bcs.GetBlockchain().LastBlock().ExtraNonce(); got != 7 {
+		t.Errorf("extra nonce = %d, want 7", got)
+	}
+
+	for _, s := range []string{"-1", "x"} {
+		resp, err := http.Get(ts.URL + "/mine?extra_nonce=" + s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("extra_nonce=%s: status = %d, want 400", s, resp.StatusCode)
+		}
+		if code := errorCode(t, resp.Body); code != utils.ErrorCodeInvalidRequest {
+			t.Errorf("extra_nonce=%s: error code = %q", s, code)
+		}
+		resp.Body.Close()
+	}
+}
+
+func TestKeyFormat(t *testing.T) {
+	bcs, ts := newTestServer(t)
+	resp, err := http.Get(ts.URL + "/keyformat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var got struct {
+		Curve          string `json:"curve"`
+		ChainID        string `json:"chain_id"`
+		ExamplePayload string `json:"example_payload"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	chainID := bcs.GetBlockchain().ChainID()
+	want := string(utils.CanonicalSignedTransaction(chainID, "S", "R", 1.5, 1700000000000000000))
+	if got.Curve != "P-256" || got.ChainID != chainID || got.ExamplePayload != want {
+		t.Errorf("key format = %+v, want example payload %s", got, want)
+	}
+}
+
+func TestRichList(t *testing.T) {
+	bcs, ts := newTestServer(t)
+	bc := bcs.GetBlockchain()
+	for i := 0; i < 3; i++ {
+		bc.MineTo(wallet.NewWallet().BlockchainAddress(), 0)
+	}
+
+	tests := []struct {
+		query  string
+		status int
+		count  int
+	}{
+		{"", http.StatusOK, 3},
+		{"?limit=2", http.StatusOK, 2},
+		{"?limit=1000", http.StatusOK, 3},
+		{"?limit=0", http.StatusBadRequest, 0},
+		{"?limit=x", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			resp, err := http.Get(ts.URL + "/richlist" + tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if tt.status != http.StatusOK {
+				if code := errorCode(t, resp.Body); code != utils.ErrorCodeInvalidRequest {
+					t.Errorf("error code = %q", code)
+				}
+				return
+			}
+			var list []block.AddressBalance
+			if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+				t.Fatal(err)
+			}
+			if len(list) != tt.count {
+				t.Errorf("listed %d addresses, want %d", len(list), tt.count)
+			}
+		})
+	}
+}
+
+func TestVerifyMessage(t *testing.T) {
+	_, ts := newTestServer(t)
+	w := wallet.NewWallet()
+	s, err := w.SignMessage([]byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		body    string
+		status  int
+		valid   bool
+		errCode string
+	}{
+		{"valid", fmt.Sprintf(`{"public_key":%q,"message":"hello","signature":%q}`, w.PublicKeyStr(), s), http.StatusOK, true, ""},
+		{"owner", fmt.Sprintf(`{"public_key":%q,"message":"hello","signature":%q,"blockchain_address":%q}`, w.PublicKeyStr(), s, w.BlockchainAddress()), http.StatusOK, true, ""},
+		{"other address", fmt.Sprintf(`{"public_key":%q,"message":"hello","signature":%q,"blockchain_address":%q}`, w.PublicKeyStr(), s, wallet.NewWallet().BlockchainAddress()), http.StatusOK, false, ""},
+		{"other message", fmt.Sprintf(`{"public_key":%q,"message":"bye","signature":%q}`, w.PublicKeyStr(), s), http.StatusOK, false, ""},
+		{"missing fields", `{"message":"hello"}`, http.StatusBadRequest, false, block.ErrorCodeMissingFields},
+		{"bad signature", fmt.Sprintf(`{"public_key":%q,"message":"hello","signature":"zz"}`, w.PublicKeyStr()), http.StatusBadRequest, false, utils.ErrorCodeInvalidRequest},
+		{"bad json", `{`, http.StatusBadRequest, false, utils.ErrorCodeInvalidRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Post(ts.URL+"/verify-message", "application/json", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if tt.errCode != "" {
+				if code := errorCode(t, resp.Body); code != tt.errCode {
+					t.Errorf("error code = %q, want %q", code, tt.errCode)
+				}
+				return
+			}
+			var got struct {
+				Valid             bool   `json:"valid"`
+				BlockchainAddress string `json:"blockchain_address"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Valid != tt.valid || got.BlockchainAddress != w.BlockchainAddress() {
+				t.Errorf("got %+v, want valid %v for %s", got, tt.valid, w.BlockchainAddress())
+			}
+		})
+	}
+}
+
+func TestReceipt(t *testing.T) {
+	bcs, ts := newTestServer(t)
+	bc := bcs.GetBlockchain()
+	bc.Mining()
+	confirmed := fmt.Sprintf("%x", bc.LastBlock().Transactions()[0].Hash())
+
+	tests := []struct {
+		name   string
+		tx     string
+		want   int
+		status string
+		code   string
+	}{
+		{"confirmed", confirmed, http.StatusOK, block.ReceiptStatusConfirmed, ""},
+		{"unknown", fmt.Sprintf("%x", [32]byte{1}), http.StatusNotFound, "", block.ErrorCodeTransactionNotFound},
+		{"invalid hash", "xyz", http.StatusBadRequest, "", utils.ErrorCodeInvalidRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(ts.URL + "/receipt?tx=" + tt.tx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+			if tt.code != "" {
+				if code := errorCode(t, resp.Body); code != tt.code {
+					t.Errorf("error code = %q, want %q", code, tt.code)
+				}
+				return
+			}
+			var got map[string]interface{}
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got["status"] != tt.status || got["transaction_hash"] != tt.tx {
+				t.Errorf("receipt = %v", got)
+			}
+		})
+	}
+}
+
+func TestBalance(t *testing.T) {
+	bcs, ts := newTestServer(t)
+	bc := bcs.GetBlockchain()
+	bc.SetCoinbaseMaturity(0)
+	miner := bc.BlockchainAddress()
+	bc.Mining()
+	bc.Mining()
+
+	tests := []struct {
+		query   string
+		status  int
+		balance float32
+		height  int
+		code    string
+	}{
+		{"?address=" + miner, http.StatusOK, 2 * block.MiningReward, 2, ""},
+		{"?address=" + miner + "&height=1", http.StatusOK, block.MiningReward, 1, ""},
+		{"?address=" + miner + "&height=3", http.StatusBadRequest, 0, 0, block.ErrorCodeHeightOutOfRange},
+		{"?address=" + miner + "&height=x", http.StatusBadRequest, 0, 0, utils.ErrorCodeInvalidRequest},
+		{"", http.StatusBadRequest, 0, 0, utils.ErrorCodeInvalidRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			resp, err := http.Get(ts.URL + "/balance" + tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if tt.code != "" {
+				if code := errorCode(t, resp.Body); code != tt.code {
+					t.Errorf("error code = %q, want %q", code, tt.code)
+				}
+				return
+			}
+			var got struct {
+				Height  int     `json:"height"`
+				Balance float32 `json:"balance"`
+			}
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Height != tt.height || got.Balance != tt.balance {
+				t.Errorf("got %+v, want balance %v at %d", got, tt.balance, tt.height)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package blockchain_server
+
+import (
+	"fmt"
+	"goblockchain/utils"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MetricsRegistry is block.Metrics served in Prometheus text format.
+type MetricsRegistry struct {
+	chainHeight          int
+	transactionPoolSize  int
+	minedBlocks          uint64
+	proofOfWorkSeconds   float64
+	rejectedTransactions map[string]uint64
+	mux                  sync.Mutex
+}
+
+// NewMetricsRegistry is to return new MetricsRegistry struct.
+func NewMetricsRegistry() *MetricsRegistry {
+	return &MetricsRegistry{rejectedTransactions: make(map[string]uint64)}
+}
+
+// SetChainHeight is to set chain height gauge.
+func (m *MetricsRegistry) SetChainHeight(height int) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.chainHeight = height
+}
+
+// SetTransactionPoolSize is to set transaction pool size gauge.
+func (m *MetricsRegistry) SetTransactionPoolSize(size int) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.transactionPoolSize = size
+}
+
+// IncMinedBlocks is to increment mined blocks counter.
+func (m *MetricsRegistry) IncMinedBlocks() {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.minedBlocks++
+}
+
+// ObserveProofOfWork is to set last proof of work duration gauge.
+func (m *MetricsRegistry) ObserveProofOfWork(d time.Duration) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.proofOfWorkSeconds = d.Seconds()
+}
+
+// IncRejectedTransactions is to increment rejected transactions counter by reason.
+func (m *MetricsRegistry) IncRejectedTransactions(reason string) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.rejectedTransactions[reason]++
+}
+
+// ChainHeight is to return chain height gauge.
+func (m *MetricsRegistry) ChainHeight() int {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	return m.chainHeight
+}
+
+// TransactionPoolSize is to return transaction pool size gauge.
+func (m *MetricsRegistry) TransactionPoolSize() int {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	return m.transactionPoolSize
+}
+
+// MinedBlocks is to return mined blocks counter.
+func (m *MetricsRegistry) MinedBlocks() uint64 {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	return m.minedBlocks
+}
+
+// RejectedTransactions is to return rejected transactions counter of reason.
+func (m *MetricsRegistry) RejectedTransactions(reason string) uint64 {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	return m.rejectedTransactions[reason]
+}
+
+// WriteTo is to write metrics in Prometheus text exposition format.
+func (m *MetricsRegistry) WriteTo(w io.Writer) (int64, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	var n int64
+	write := func(format string, a ...interface{}) error {
+		c, err := fmt.Fprintf(w, format, a...)
+		n += int64(c)
+		return err
+	}
+
+	if err := write("# HELP blockchain_chain_height Number of blocks in the chain.\n"+
+		"# TYPE blockchain_chain_height gauge\nblockchain_chain_height %d\n", m.chainHeight); err != nil {
+		return n, err
+	}
+	if err := write("# HELP blockchain_transaction_pool_size Number of transactions in the pool.\n"+
+		"# TYPE blockchain_transaction_pool_size gauge\nblockchain_transaction_pool_size %d\n", m.transactionPoolSize); err != nil {
+		return n, err
+	}
+	if err := write("# HELP blockchain_mined_blocks_total Number of blocks mined by this node.\n"+
+		"# TYPE blockchain_mined_blocks_total counter\nblockchain_mined_blocks_total %d\n", m.minedBlocks); err != nil {
+		return n, err
+	}
+	if err := write("# HELP blockchain_proof_of_work_seconds Duration of the last proof of work.\n"+
+		"# TYPE blockchain_proof_of_work_seconds gauge\nblockchain_proof_of_work_seconds %g\n", m.proofOfWorkSeconds); err != nil {
+		return n, err
+	}
+	if err := write("# HELP blockchain_rejected_transactions_total Number of rejected transactions by reason.\n" +
+		"# TYPE blockchain_rejected_transactions_total counter\n"); err != nil {
+		return n, err
+	}
+	reasons := make([]string, 0, len(m.rejectedTransactions))
+	for reason := range m.rejectedTransactions {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		if err := write("blockchain_rejected_transactions_total{reason=%q} %d\n", reason, m.rejectedTransactions[reason]); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// ServeHTTP is api to return metrics.
+func (m *MetricsRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	utils.SetContentType(w, "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
@@ -0,0 +1,204 @@
+package blockchain_server
+
+import (
+	"bytes"
+	"encoding/json"
+	"goblockchain/block"
+	"goblockchain/utils"
+	"io"
+	"net/http"
+)
+
+// JSON-RPC 2.0 error codes.
+const (
+	RPCErrorParse          = -32700
+	RPCErrorInvalidRequest = -32600
+	RPCErrorMethodNotFound = -32601
+	RPCErrorInvalidParams  = -32602
+	RPCErrorInternal       = -32603
+	// RPCErrorRejected is code of errors returned by Blockchain, whose
+	// machine readable code is the error data.
+	RPCErrorRejected = -32000
+)
+
+// rpcRequest is JSON-RPC 2.0 request.
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// rpcResponse is JSON-RPC 2.0 response.
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// rpcError is JSON-RPC 2.0 error object.
+type rpcError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// rpcMethods is to return methods served by /rpc, each sharing the
+// Blockchain method of its REST handler.
+func (bcs *BlockchainServer) rpcMethods() map[string]func(params json.RawMessage) (interface{}, *rpcError) {
+	return map[string]func(params json.RawMessage) (interface{}, *rpcError){
+		"bc_getChain":        bcs.rpcGetChain,
+		"bc_sendTransaction": bcs.rpcSendTransaction,
+		"bc_getBalance":      bcs.rpcGetBalance,
+		"bc_mine":            bcs.rpcMine,
+	}
+}
+
+// rpcGetChain is bc_getChain, the chain as served by /chain.
+func (bcs *BlockchainServer) rpcGetChain(params json.RawMessage) (interface{}, *rpcError) {
+	return bcs.GetBlockchain(), nil
+}
+
+// rpcSendTransaction is bc_sendTransaction of a signed transaction request
+// as posted to /transactions.
+func (bcs *BlockchainServer) rpcSendTransaction(params json.RawMessage) (interface{}, *rpcError) {
+	var tr block.TransactionRequest
+	if err := json.Unmarshal(params, &tr); err != nil {
+		return nil, &rpcError{Code: RPCErrorInvalidParams, Message: err.Error()}
+	}
+	if err := bcs.GetBlockchain().SubmitTransactionRequest(&tr, true); err != nil {
+		return nil, &rpcError{Code: RPCErrorRejected, Message: err.Error(), Data: block.ErrorCode(err)}
+	}
+	return "success", nil
+}
+
+// rpcGetBalance is bc_getBalance of {"address": ...}.
+func (bcs *BlockchainServer) rpcGetBalance(params json.RawMessage) (interface{}, *rpcError) {
+	var p struct {
+		Address string `json:"address"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil || p.Address == "" {
+		return nil, &rpcError{Code: RPCErrorInvalidParams, Message: "address is required"}
+	}
+	return bcs.GetBlockchain().CalculateTotalAmount(p.Address), nil
+}
+
+// rpcMine is bc_mine, paying the reward to the optional address and
+// mining with the optional extra_nonce like /mine.
+func (bcs *BlockchainServer) rpcMine(params json.RawMessage) (interface{}, *rpcError) {
+	bc := bcs.GetBlockchain()
+	var p struct {
+		Address    string `json:"address"`
+		ExtraNonce uint64 `json:"extra_nonce"`
+	}
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &rpcError{Code: RPCErrorInvalidParams, Message: err.Error()}
+		}
+	}
+	if p.Address == "" {
+		p.Address = bc.BlockchainAddress()
+	} else if !utils.ValidBlockchainAddress(p.Address) {
+		return nil, &rpcError{Code: RPCErrorInvalidParams, Message: "invalid address"}
+	}
+	if !bc.MineTo(p.Address, p.ExtraNonce) {
+		return nil, &rpcError{Code: RPCErrorRejected, Message: "mining failed", Data: ErrorCodeMiningFailed}
+	}
+	return "success", nil
+}
+
+// call is to run JSON-RPC request raw, returning nil for notifications.
+func (bcs *BlockchainServer) call(raw json.RawMessage) *rpcResponse {
+	var r rpcRequest
+	err := json.Unmarshal(raw, &r)
+	if err != nil {
+		return &rpcResponse{JSONRPC: "2.0", Error: &rpcError{Code: RPCErrorInvalidRequest, Message: "invalid request"}, ID: json.RawMessage("null")}
+	}
+	id := r.ID
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	if r.JSONRPC != "2.0" || r.Method == "" {
+		return &rpcResponse{JSONRPC: "2.0", Error: &rpcError{Code: RPCErrorInvalidRequest, Message: `jsonrpc must be "2.0" and method is required`}, ID: id}
+	}
+
+	method, ok := bcs.rpcMethods()[r.Method]
+	var resp *rpcResponse
+	if !ok {
+		resp = &rpcResponse{JSONRPC: "2.0", Error: &rpcError{Code: RPCErrorMethodNotFound, Message: "method not found: " + r.Method}, ID: id}
+	} else {
+		resp = &rpcResponse{JSONRPC: "2.0", ID: id}
+		result, rpcErr := method(r.Params)
+		if rpcErr == nil {
+			resp.Result, err = json.Marshal(result)
+			if err != nil {
+				rpcErr = &rpcError{Code: RPCErrorInternal, Message: err.Error()}
+			}
+		}
+		resp.Error = rpcErr
+	}
+	if r.ID == nil {
+		return nil
+	}
+	return resp
+}
+
+// RPC is api to serve JSON-RPC 2.0 requests, single or batched, to the
+// methods of rpcMethods.
+func (bcs *BlockchainServer) RPC(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodPost:
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			bcs.logger.Error("read rpc request", "error", err)
+			utils.WriteRequestError(w, err)
+			return
+		}
+		utils.SetContentType(w, utils.ContentTypeJSON)
+
+		body = bytes.TrimSpace(body)
+		if len(body) > 0 && body[0] == '[' {
+			var batch []json.RawMessage
+			if err := json.Unmarshal(body, &batch); err != nil {
+				m, _ := json.Marshal(&rpcResponse{JSONRPC: "2.0", Error: &rpcError{Code: RPCErrorParse, Message: err.Error()}, ID: json.RawMessage("null")})
+				io.WriteString(w, string(m))
+				return
+			}
+			if len(batch) == 0 {
+				m, _ := json.Marshal(&rpcResponse{JSONRPC: "2.0", Error: &rpcError{Code: RPCErrorInvalidRequest, Message: "empty batch"}, ID: json.RawMessage("null")})
+				io.WriteString(w, string(m))
+				return
+			}
+			responses := make([]*rpcResponse, 0, len(batch))
+			for _, raw := range batch {
+				if resp := bcs.call(raw); resp != nil {
+					responses = append(responses, resp)
+				}
+			}
+			if len(responses) == 0 {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			m, _ := json.Marshal(responses)
+			io.WriteString(w, string(m))
+			return
+		}
+
+		if !json.Valid(body) {
+			m, _ := json.Marshal(&rpcResponse{JSONRPC: "2.0", Error: &rpcError{Code: RPCErrorParse, Message: "parse error"}, ID: json.RawMessage("null")})
+			io.WriteString(w, string(m))
+			return
+		}
+		resp := bcs.call(body)
+		if resp == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		m, _ := json.Marshal(resp)
+		io.WriteString(w, string(m))
+	default:
+		bcs.logger.Error("invalid http method", "method", req.Method, "path", req.URL.Path)
+		utils.MethodNotAllowed(w, http.MethodPost)
+	}
+}
@@ -0,0 +1,109 @@
+package blockchain_server
+
+import (
+	"encoding/json"
+	"goblockchain/block"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRPC(t *testing.T) {
+	bcs, ts := newTestServer(t)
+	bc := bcs.GetBlockchain()
+	bc.SetCoinbaseMaturity(0)
+	miner := bc.BlockchainAddress()
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		result string
+		code   int
+	}{
+		{"mine", `{"jsonrpc":"2.0","method":"bc_mine","id":1}`, http.StatusOK, `"success"`, 0},
+		{"balance", `{"jsonrpc":"2.0","method":"bc_getBalance","params":{"address":"` + miner + `"},"id":2}`, http.StatusOK, "1", 0},
+		{"missing address", `{"jsonrpc":"2.0","method":"bc_getBalance","params":{},"id":3}`, http.StatusOK, "", RPCErrorInvalidParams},
+		{"invalid mine address", `{"jsonrpc":"2.0","method":"bc_mine","params":{"address":"x"},"id":4}`, http.StatusOK, "", RPCErrorInvalidParams},
+		{"unknown method", `{"jsonrpc":"2.0","method":"bc_nope","id":5}`, http.StatusOK, "", RPCErrorMethodNotFound},
+		{"wrong version", `{"jsonrpc":"1.0","method":"bc_mine","id":6}`, http.StatusOK, "", RPCErrorInvalidRequest},
+		{"parse error", `{"jsonrpc":`, http.StatusOK, "", RPCErrorParse},
+		{"empty batch", `[]`, http.StatusOK, "", RPCErrorInvalidRequest},
+		{"notification", `{"jsonrpc":"2.0","method":"bc_getChain"}`, http.StatusNoContent, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Post(ts.URL+"/rpc", "application/json", strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if tt.status == http.StatusNoContent {
+				return
+			}
+			var got rpcResponse
+			if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+				t.Fatal(err)
+			}
+			if tt.code != 0 {
+				if got.Error == nil || got.Error.Code != tt.code {
+					t.Errorf("error = %+v, want code %d", got.Error, tt.code)
+				}
+				return
+			}
+			if got.Error != nil || string(got.Result) != tt.result {
+				t.Errorf("result = %s, error %+v; want %s", got.Result, got.Error, tt.result)
+			}
+		})
+	}
+}
+
+func TestRPCBatch(t *testing.T) {
+	bcs, ts := newTestServer(t)
+	body := `[
+		{"jsonrpc":"2.0","method":"bc_getChain","id":"chain"},
+		{"jsonrpc":"2.0","method":"bc_getChain"},
+		{"jsonrpc":"2.0","method":"bc_sendTransaction","params":{},"id":"send"}
+	]`
+	resp, err := http.Post(ts.URL+"/rpc", "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var got []rpcResponse
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	// The notification gets no response.
+	if len(got) != 2 {
+		t.Fatalf("got %d responses, want 2", len(got))
+	}
+	if string(got[0].ID) != `"chain"` || got[0].Error != nil {
+		t.Errorf("bc_getChain = %+v", got[0])
+	}
+	var chain struct {
+		Chain []json.RawMessage `json:"chain"`
+	}
+	if err := json.Unmarshal(got[0].Result, &chain); err != nil || len(chain.Chain) != bcs.GetBlockchain().Height() {
+		t.Errorf("bc_getChain result has %d blocks, error %v", len(chain.Chain), err)
+	}
+	if string(got[1].ID) != `"send"` || got[1].Error == nil || got[1].Error.Code != RPCErrorRejected ||
+		got[1].Error.Data != block.ErrorCodeMissingFields {
+		t.Errorf("bc_sendTransaction without fields = %+v, error %+v", got[1], got[1].Error)
+	}
+}
+
+func TestRPCMethodNotAllowed(t *testing.T) {
+	_, ts := newTestServer(t)
+	resp, err := http.Get(ts.URL + "/rpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,98 @@
+package blockchain_server
+
+import (
+	"goblockchain/block"
+	"goblockchain/utils"
+	"net/http"
+	"sync"
+)
+
+// BlockSubscriberBufferSize is per client send buffer size.
+// A client which falls further behind is disconnected.
+const BlockSubscriberBufferSize = 16
+
+// BlockHub is hub to push new blocks to websocket subscribers.
+type BlockHub struct {
+	subscribers map[chan []byte]struct{}
+	mux         sync.Mutex
+}
+
+// NewBlockHub is to return new BlockHub struct.
+func NewBlockHub() *BlockHub {
+	return &BlockHub{subscribers: make(map[chan []byte]struct{})}
+}
+
+// Subscribe is to add new subscriber channel.
+func (h *BlockHub) Subscribe() chan []byte {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	ch := make(chan []byte, BlockSubscriberBufferSize)
+	h.subscribers[ch] = struct{}{}
+	return ch
+}
+
+// Unsubscribe is to remove subscriber channel and close it.
+func (h *BlockHub) Unsubscribe(ch chan []byte) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	if _, ok := h.subscribers[ch]; ok {
+		delete(h.subscribers, ch)
+		close(ch)
+	}
+}
+
+// Publish is to send block json to all subscribers without blocking.
+// Slow subscribers whose buffer is full are dropped.
+func (h *BlockHub) Publish(b *block.Block) {
+	m, err := b.MarshalJSON()
+	if err != nil {
+		return
+	}
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	for ch := range h.subscribers {
+		select {
+		case ch <- m:
+		default:
+			delete(h.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// WsBlocks is api to stream new blocks over websocket.
+func (bcs *BlockchainServer) WsBlocks(w http.ResponseWriter, req *http.Request) {
+	conn, err := utils.UpgradeWebSocket(w, req)
+	if err != nil {
+		bcs.logger.Error("websocket upgrade", "error", err)
+		return
+	}
+	ch := bcs.hub.Subscribe()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	defer conn.Close()
+	defer bcs.hub.Unsubscribe(ch)
+	for {
+		select {
+		case m, ok := <-ch:
+			if !ok {
+				bcs.logger.Info("websocket subscriber dropped", "remote", req.RemoteAddr)
+				return
+			}
+			if err := conn.WriteMessage(utils.WebSocketOpText, m); err != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
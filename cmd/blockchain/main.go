@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"goblockchain/block"
+	"goblockchain/blockchain_server"
+	"goblockchain/utils"
+	"goblockchain/wallet"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ShutdownTimeout is how long shutdown waits for active requests.
+const ShutdownTimeout = 10 * time.Second
+
+func init() {
+	log.SetPrefix("Blockchain: ")
+}
+
+func main() {
+	port := flag.Uint("port", 5000, "TCP Port Number for Blockchain Server")
+	host := flag.String("host", blockchain_server.DefaultHost, "Host to bind for Blockchain Server")
+	rate := flag.Float64("rate", 0, "Requests per second allowed per client IP (0 disables)")
+	burst := flag.Int("burst", 10, "Burst of requests allowed per client IP")
+	chainID := flag.String("chain_id", "", "Network chain ID transactions are signed for")
+	tlsCert := flag.String("tls_cert", "", "TLS certificate file")
+	tlsKey := flag.String("tls_key", "", "TLS private key file")
+	tlsSelfSigned := flag.Bool("tls_self_signed", false, "Serve TLS with a self signed certificate for local development")
+	db := flag.String("db", "", "File the chain is loaded from and saved to on exit (empty keeps it in memory)")
+	persistInterval := flag.Duration("persist_interval", block.DefaultAutoPersistInterval, "Interval -db is saved at besides after new blocks (0 only saves on exit)")
+	difficulty := flag.Int("difficulty", block.MiningDifficulty, "Proof of work difficulty in leading hex zeros")
+	targetBlockTime := flag.Duration("target_block_time", 0, "Block time difficulty is retargeted toward (0 keeps -difficulty)")
+	retargetWindow := flag.Int("retarget_window", 10, "Blocks the average block time is taken over for retargeting")
+	maxRetargetSteps := flag.Int("max_retarget_steps", block.DefaultMaxRetargetSteps, "Max difficulty steps, 16 times each, one retarget moves")
+	miningWorkers := flag.Int("mining_workers", 1, "Goroutines proof of work runs on")
+	miningDutyCycle := flag.Float64("mining_duty_cycle", 1, "Fraction of time, in (0, 1], proof of work runs")
+	peers := flag.String("peers", "", "Comma separated peers (host:port) to connect to besides scanned neighbors")
+	trustedNodes := flag.String("trusted_nodes", "", "Comma separated node IDs announcements are accepted from (empty, the default, accepts any validly signed node)")
+	genesisTimestamp := flag.Int64("genesis_timestamp", 0, "Genesis block timestamp in unix nanoseconds (0 uses start time)")
+	genesisHash := flag.String("genesis_hash", "", "Expected genesis block hash in hex; start fails on mismatch")
+	requireKnownRecipient := flag.Bool("require_known_recipient", false, "Reject transactions to addresses never seen in the chain")
+	dustThreshold := flag.Float64("dust_threshold", 0, "Reject transactions of value below it (0 disables)")
+	maxPendingValue := flag.Float64("max_pending_value", 0, "Total value a sender may have pending in the pool (0 disables)")
+	maxPendingCount := flag.Int("max_pending_count", 0, "Transactions a sender may have pending in the pool (0 disables)")
+	mempoolTTL := flag.Duration("mempool_ttl", 0, "How long transactions may wait in the pool before eviction (0 keeps them)")
+	rebroadcast := flag.Duration("rebroadcast_interval", block.DefaultRebroadcastInterval, "Interval pending transactions are broadcast again at (0 disables)")
+	maxBody := flag.Int64("max_body", utils.DefaultMaxBodyBytes, "Max request body bytes (0 disables)")
+	flag.Parse()
+	app := blockchain_server.NewBlockchainServer(uint16(*port))
+	app.SetHost(*host)
+	app.SetTLS(*tlsCert, *tlsKey)
+	app.SetSelfSignedTLS(*tlsSelfSigned)
+	app.SetMaxBodyBytes(*maxBody)
+	if *rate > 0 {
+		app.SetRateLimit(*rate, *burst)
+	}
+
+	opts := []block.Option{block.WithDifficulty(*difficulty)}
+	// The chain ID saved with -db is kept unless -chain_id is given.
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "chain_id" {
+			opts = append(opts, block.WithChainID(*chainID))
+		}
+	})
+	if *peers != "" {
+		opts = append(opts, block.WithPeers(strings.Split(*peers, ",")...))
+	}
+	if *genesisTimestamp != 0 {
+		opts = append(opts, block.WithGenesisTimestamp(*genesisTimestamp))
+	}
+	if *genesisHash != "" {
+		hash, err := utils.HexToHash(*genesisHash)
+		if err != nil {
+			log.Fatalf("genesis_hash: %v", err)
+		}
+		opts = append(opts, block.WithGenesisHash(hash))
+	}
+	minersWallet := wallet.NewWallet()
+	bc, err := block.LoadFromFile(*db, opts...)
+	switch {
+	case err == nil:
+		bc.SetBlockchainAddress(minersWallet.BlockchainAddress())
+		bc.SetPort(uint16(*port))
+		log.Printf("loaded %d blocks from %s", bc.Height(), *db)
+	case *db == "" || errors.Is(err, os.ErrNotExist):
+		bc = block.NewBlockchain(minersWallet.BlockchainAddress(), uint16(*port), opts...)
+		if err := bc.CheckGenesis(); err != nil {
+			log.Fatal(err)
+		}
+	default:
+		log.Fatal(err)
+	}
+	bc.SetRequireKnownRecipient(*requireKnownRecipient)
+	bc.SetDustThreshold(float32(*dustThreshold))
+	bc.SetMaxPendingPerSender(float32(*maxPendingValue), *maxPendingCount)
+	bc.SetTargetBlockTime(*targetBlockTime, *retargetWindow)
+	bc.SetMaxRetargetSteps(*maxRetargetSteps)
+	bc.SetMiningWorkers(*miningWorkers)
+	bc.SetMiningDutyCycle(*miningDutyCycle)
+	bc.SetMempoolTTL(*mempoolTTL)
+	bc.SetRebroadcastInterval(*rebroadcast)
+	if *trustedNodes != "" {
+		bc.SetTrustedNodes(strings.Split(*trustedNodes, ","))
+	}
+	if *db != "" && *persistInterval > 0 {
+		bc.EnableAutoPersist(*db, *persistInterval)
+	}
+	app.SetChainID(bc.ChainID())
+	app.SetBlockchain(bc)
+	app.Logger().Info("miner wallet",
+		"private_key", minersWallet.PrivateKeyStr(),
+		"public_key", minersWallet.PublicKeyStr(),
+		"blockchain_address", minersWallet.BlockchainAddress())
+	app.Logger().Info("node identity", "node_id", bc.NodeID())
+
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+		ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+		defer cancel()
+		if err := app.Shutdown(ctx); err != nil {
+			log.Printf("shutdown: %v", err)
+		}
+		if *db != "" {
+			if err := bc.SaveToFile(*db); err != nil {
+				log.Fatal(err)
+			}
+			log.Printf("saved %d blocks to %s", bc.Height(), *db)
+		}
+		os.Exit(0)
+	}()
+
+	app.Run()
+	// Run returns once shutdown begins; wait for the chain to be saved.
+	select {}
+}
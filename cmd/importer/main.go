@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"goblockchain/block"
+	"goblockchain/utils"
+	"goblockchain/wallet"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	log.SetPrefix("Importer: ")
+}
+
+// row is transaction row read from import file.
+type row struct {
+	line      int
+	sender    string
+	recipient string
+	value     string
+	publicKey string
+	signature string
+	timestamp string
+}
+
+// jsonRow is transaction row of json import file.
+type jsonRow struct {
+	Sender    string      `json:"sender_blockchain_address"`
+	Recipient string      `json:"recipient_blockchain_address"`
+	Value     json.Number `json:"value"`
+	PublicKey string      `json:"sender_public_key"`
+	Signature string      `json:"signature"`
+	Timestamp json.Number `json:"timestamp"`
+}
+
+// readCSV is to read rows of sender,recipient,value[,public_key,signature,timestamp].
+// A first row starting with "sender" is skipped as header.
+func readCSV(r io.Reader) ([]*row, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]*row, 0, len(records))
+	for i, rec := range records {
+		if i == 0 && len(rec) > 0 && strings.HasPrefix(rec[0], "sender") {
+			continue
+		}
+		rw := &row{line: i + 1}
+		fields := []*string{&rw.sender, &rw.recipient, &rw.value, &rw.publicKey, &rw.signature, &rw.timestamp}
+		for j := 0; j < len(rec) && j < len(fields); j++ {
+			*fields[j] = strings.TrimSpace(rec[j])
+		}
+		rows = append(rows, rw)
+	}
+	return rows, nil
+}
+
+// readJSON is to read rows of a json array of transactions.
+func readJSON(r io.Reader) ([]*row, error) {
+	var jrs []jsonRow
+	if err := json.NewDecoder(r).Decode(&jrs); err != nil {
+		return nil, err
+	}
+	rows := make([]*row, len(jrs))
+	for i, jr := range jrs {
+		rows[i] = &row{
+			line:      i + 1,
+			sender:    jr.Sender,
+			recipient: jr.Recipient,
+			value:     jr.Value.String(),
+			publicKey: jr.PublicKey,
+			signature: jr.Signature,
+			timestamp: jr.Timestamp.String(),
+		}
+	}
+	return rows, nil
+}
+
+// transactionRequest is to build signed transaction request of row, signing
+// with signer when the row has no signature. A signed row must have the
+// timestamp it was signed with.
+func transactionRequest(rw *row, signer *wallet.Wallet, chainID string) (*block.TransactionRequest, error) {
+	if rw.sender == "" || rw.recipient == "" || rw.value == "" {
+		return nil, errors.New("missing field(s)")
+	}
+	value64, err := strconv.ParseFloat(rw.value, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value %q", rw.value)
+	}
+	if value64 <= 0 {
+		return nil, fmt.Errorf("value %q must be positive", rw.value)
+	}
+	value := float32(value64)
+
+	publicKey, signature := rw.publicKey, rw.signature
+	var timestamp int64
+	if signature != "" {
+		if timestamp, err = strconv.ParseInt(rw.timestamp, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid timestamp %q", rw.timestamp)
+		}
+	} else {
+		if signer == nil {
+			return nil, errors.New("missing signature and no signing key given")
+		}
+		if signer.BlockchainAddress() != rw.sender {
+			return nil, errors.New("sender doesn't match signing key")
+		}
+		t := wallet.NewTransaction(signer.PrivateKey(), signer.PublicKey(), rw.sender, rw.recipient, value)
+		t.SetChainID(chainID)
+		publicKey = signer.PublicKeyStr()
+		signature = t.GenerateSignature().String()
+		timestamp = t.Timestamp()
+	}
+
+	tr := &block.TransactionRequest{
+		SenderBlockchainAddress:    &rw.sender,
+		RecipientBlockchainAddress: &rw.recipient,
+		SenderPublicKey:            &publicKey,
+		Value:                      &value,
+		Timestamp:                  &timestamp,
+		Signature:                  &signature,
+	}
+	if !tr.Validate() {
+		return nil, errors.New("malformed public key or signature")
+	}
+	return tr, nil
+}
+
+// submit is to post transaction request to node's /transactions.
+func submit(node string, tr *block.TransactionRequest) error {
+	m, _ := json.Marshal(tr)
+	resp, err := http.Post(node+"/transactions", "application/json", bytes.NewBuffer(m))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func main() {
+	file := flag.String("file", "", "CSV or JSON file of transactions to import")
+	node := flag.String("node", "http://127.0.0.1:5000", "Blockchain node to submit transactions to")
+	privateKey := flag.String("private_key", "", "Private key signing rows without signature")
+	publicKey := flag.String("public_key", "", "Public key of -private_key")
+	chainID := flag.String("chain_id", "", "Network chain ID transactions are signed for")
+	dryRun := flag.Bool("dry_run", false, "Validate rows without submitting")
+	flag.Parse()
+
+	if *file == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	var rows []*row
+	if strings.EqualFold(filepath.Ext(*file), ".json") {
+		rows, err = readJSON(f)
+	} else {
+		rows, err = readCSV(f)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var signer *wallet.Wallet
+	if *privateKey != "" && *publicKey != "" {
+		pub := utils.PublicKeyFromString(*publicKey)
+		signer = wallet.NewWalletFromKey(utils.PrivateKeyFromString(*privateKey, pub))
+	}
+
+	var succeeded, failed int
+	for _, rw := range rows {
+		tr, err := transactionRequest(rw, signer, *chainID)
+		if err == nil && !*dryRun {
+			err = submit(*node, tr)
+		}
+		if err != nil {
+			failed++
+			fmt.Printf("line %d: fail: %v\n", rw.line, err)
+			continue
+		}
+		succeeded++
+		fmt.Printf("line %d: ok\n", rw.line)
+	}
+	fmt.Printf("succeeded %d, failed %d\n", succeeded, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
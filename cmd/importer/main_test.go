@@ -0,0 +1,108 @@
+package main
+
+import (
+	"goblockchain/block"
+	"goblockchain/utils"
+	"goblockchain/wallet"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestReadCSV(t *testing.T) {
+	rows, err := readCSV(strings.NewReader("sender,recipient,value\n1A, 1B ,1.5\n1C,1D,2,pub,sig,7\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("rows = %d, want 2", len(rows))
+	}
+	if r := rows[0]; r.line != 2 || r.sender != "1A" || r.recipient != "1B" || r.value != "1.5" || r.signature != "" {
+		t.Errorf("row 0 = %+v", *r)
+	}
+	if r := rows[1]; r.publicKey != "pub" || r.signature != "sig" || r.timestamp != "7" {
+		t.Errorf("row 1 = %+v", *r)
+	}
+}
+
+func TestReadJSON(t *testing.T) {
+	rows, err := readJSON(strings.NewReader(`[{"sender_blockchain_address":"1A","recipient_blockchain_address":"1B","value":0.25,"timestamp":7}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].line != 1 || rows[0].value != "0.25" || rows[0].timestamp != "7" {
+		t.Errorf("rows = %+v", rows)
+	}
+	if _, err := readJSON(strings.NewReader(`{}`)); err == nil {
+		t.Error("object accepted")
+	}
+}
+
+func TestTransactionRequest(t *testing.T) {
+	signer := wallet.NewWallet()
+	bc := block.NewBlockchain("", 0, block.WithChainID("testnet"),
+		block.WithGenesisAllocations(map[string]float32{signer.BlockchainAddress(): 10}))
+	bc.SetLogger(utils.NewNopLogger())
+
+	tr, err := transactionRequest(&row{sender: signer.BlockchainAddress(), recipient: "1B", value: "1.5"}, signer, "testnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey := utils.PublicKeyFromString(*tr.SenderPublicKey)
+	signature := utils.SignatureFromString(*tr.Signature)
+	if !bc.AddTransaction(*tr.SenderBlockchainAddress, *tr.RecipientBlockchainAddress, *tr.Value, *tr.Timestamp, publicKey, signature) {
+		t.Error("signed row rejected by node")
+	}
+
+	// A row signed elsewhere carries its signature and timestamp.
+	presigned := &row{sender: signer.BlockchainAddress(), recipient: "1B", value: "1.5",
+		publicKey: *tr.SenderPublicKey, signature: *tr.Signature, timestamp: strconv.FormatInt(*tr.Timestamp, 10)}
+	if got, err := transactionRequest(presigned, nil, "testnet"); err != nil || *got.Timestamp != *tr.Timestamp {
+		t.Errorf("presigned row = %v, %v", got, err)
+	}
+
+	tests := []struct {
+		name   string
+		row    row
+		signer *wallet.Wallet
+	}{
+		{"missing value", row{sender: "1A", recipient: "1B"}, signer},
+		{"invalid value", row{sender: "1A", recipient: "1B", value: "x"}, signer},
+		{"negative value", row{sender: "1A", recipient: "1B", value: "-1"}, signer},
+		{"no signing key", row{sender: "1A", recipient: "1B", value: "1"}, nil},
+		{"sender isn't signer", row{sender: "1A", recipient: "1B", value: "1"}, signer},
+		{"malformed signature", row{sender: "1A", recipient: "1B", value: "1", publicKey: "pub", signature: "sig", timestamp: "7"}, nil},
+		{"signature without timestamp", row{sender: "1A", recipient: "1B", value: "1", publicKey: "pub", signature: "sig"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := transactionRequest(&tt.row, tt.signer, ""); err == nil {
+				t.Error("row accepted")
+			}
+		})
+	}
+}
+
+func TestSubmit(t *testing.T) {
+	status := http.StatusCreated
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/transactions" || req.Method != http.MethodPost {
+			t.Errorf("%s %s", req.Method, req.URL.Path)
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(`{"message":"fail"}`))
+	}))
+	defer ts.Close()
+
+	signer := wallet.NewWallet()
+	tr, _ := transactionRequest(&row{sender: signer.BlockchainAddress(), recipient: "1B", value: "1"}, signer, "")
+	if err := submit(ts.URL, tr); err != nil {
+		t.Errorf("created: %v", err)
+	}
+	status = http.StatusBadRequest
+	if err := submit(ts.URL, tr); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("bad request: error = %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+
+	"github.com/btcsuite/btcutil/base58"
+	"golang.org/x/crypto/ripemd160"
+)
+
+// AddressFromPublicKey is to return blockchain address of publicKey: base58
+// of version byte 0, ripemd160 of sha256 of X and Y, and a 4 byte double
+// sha256 checksum.
+func AddressFromPublicKey(publicKey *ecdsa.PublicKey) string {
+	h2 := sha256.New()
+	h2.Write(publicKey.X.Bytes())
+	h2.Write(publicKey.Y.Bytes())
+	digets2 := h2.Sum(nil)
+
+	h3 := ripemd160.New()
+	h3.Write(digets2)
+	digest3 := h3.Sum(nil)
+
+	vd4 := make([]byte, 21)
+	vd4[0] = 0x00
+	copy(vd4[1:], digest3[:])
+
+	h5 := sha256.New()
+	h5.Write(vd4)
+	digest5 := h5.Sum(nil)
+
+	h6 := sha256.New()
+	h6.Write(digest5)
+	digest6 := h6.Sum(nil)
+
+	chsum := digest6[:4]
+
+	dc8 := make([]byte, 25)
+	copy(dc8[:21], vd4[:])
+	copy(dc8[21:], chsum[:])
+
+	return base58.Encode(dc8)
+}
+
+// ValidBlockchainAddress is to check address is base58 of a version byte,
+// a 20 byte hash and a matching 4 byte double sha256 checksum.
+func ValidBlockchainAddress(address string) bool {
+	b := base58.Decode(address)
+	if len(b) != 25 {
+		return false
+	}
+	digest := sha256.Sum256(b[:21])
+	checksum := sha256.Sum256(digest[:])
+	return bytes.Equal(b[21:], checksum[:4])
+}
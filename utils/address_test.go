@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"testing"
+
+	"github.com/btcsuite/btcutil/base58"
+)
+
+func TestValidBlockchainAddress(t *testing.T) {
+	payload := append([]byte{0x00}, make([]byte, 20)...)
+	payload[1] = 0x42
+	digest := sha256.Sum256(payload)
+	checksum := sha256.Sum256(digest[:])
+	valid := base58.Encode(append(payload, checksum[:4]...))
+	badChecksum := base58.Encode(append(payload, 0, 0, 0, 0))
+
+	tests := []struct {
+		address string
+		want    bool
+	}{
+		{valid, true},
+		{badChecksum, false},
+		{valid[:len(valid)-1], false},
+		{"1Miner", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := ValidBlockchainAddress(tt.address); got != tt.want {
+			t.Errorf("ValidBlockchainAddress(%q) = %v, want %v", tt.address, got, tt.want)
+		}
+	}
+}
+
+func TestAddressFromPublicKey(t *testing.T) {
+	a, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	b, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	address := AddressFromPublicKey(&a.PublicKey)
+	if !ValidBlockchainAddress(address) {
+		t.Errorf("address %q isn't valid", address)
+	}
+	if AddressFromPublicKey(&a.PublicKey) != address || AddressFromPublicKey(&b.PublicKey) == address {
+		t.Error("address isn't a function of the key only")
+	}
+}
@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strconv"
+)
+
+// Canonical serialization is the byte format fed into hashes and signatures.
+// It is JSON with a fixed field order and no insignificant whitespace:
+//
+//   - strings are encoded as by encoding/json (HTML characters escaped)
+//   - integers are base 10 without leading zeros
+//   - float32 values are the shortest decimal that round trips to the same
+//     float32, in plain notation without exponent (strconv 'f', -1, 32)
+//   - byte arrays such as hashes are lower case hex strings
+//
+// A transaction is
+//
+//	{"sender_blockchain_address":S,"recipient_blockchain_address":R,"value":V,"timestamp":T}
+//
+// where T is the transaction's unix time in nanoseconds, so repeating a
+// payment gives a new transaction. A signed transaction payload on a network with chain ID C is
+//
+//	{"chain_id":C,"sender_blockchain_address":S,"recipient_blockchain_address":R,"value":V,"timestamp":T}
+//
+// or the plain transaction when the chain ID is empty,
+// and a block header, which commits to transactions by merkle root, is
+//
+//	{"timestamp":T,"difficulty":D,"nonce":N,"previous_hash":H,"merkle_root":M}
+//
+// or, when the block was mined with a non-zero extra nonce E,
+//
+//	{"timestamp":T,"difficulty":D,"nonce":N,"extra_nonce":E,"previous_hash":H,"merkle_root":M}
+
+// CanonicalString is to append canonical string encoding.
+func CanonicalString(buf *bytes.Buffer, s string) {
+	m, _ := json.Marshal(s)
+	buf.Write(m)
+}
+
+// CanonicalFloat32 is to append canonical float32 encoding.
+// NaN and infinities are encoded as 0 as they are not valid values.
+func CanonicalFloat32(buf *bytes.Buffer, f float32) {
+	buf.WriteString(FormatValue(f))
+}
+
+// FormatValue is to return canonical float32 encoding of value, the shortest
+// plain decimal parsing back to the same float32, so 0.1 is "0.1" and
+// 1e-7 is "0.0000001".
+func FormatValue(f float32) string {
+	if math.IsNaN(float64(f)) || math.IsInf(float64(f), 0) {
+		f = 0
+	}
+	return strconv.FormatFloat(float64(f), 'f', -1, 32)
+}
+
+// CanonicalTransaction is to return canonical transaction bytes which are
+// hashed and signed by wallets and verified by nodes.
+func CanonicalTransaction(sender string, recipient string, value float32, timestamp int64) []byte {
+	var buf bytes.Buffer
+	WriteCanonicalTransaction(&buf, sender, recipient, value, timestamp)
+	return buf.Bytes()
+}
+
+// CanonicalSignedTransaction is to return canonical bytes of transaction
+// signed for network chainID, so the signature isn't valid on other networks.
+func CanonicalSignedTransaction(chainID string, sender string, recipient string, value float32, timestamp int64) []byte {
+	if chainID == "" {
+		return CanonicalTransaction(sender, recipient, value, timestamp)
+	}
+	var buf bytes.Buffer
+	buf.WriteString(`{"chain_id":`)
+	CanonicalString(&buf, chainID)
+	buf.WriteString(`,`)
+	m := CanonicalTransaction(sender, recipient, value, timestamp)
+	buf.Write(m[1:])
+	return buf.Bytes()
+}
+
+// WriteCanonicalTransaction is to append canonical transaction bytes.
+func WriteCanonicalTransaction(buf *bytes.Buffer, sender string, recipient string, value float32, timestamp int64) {
+	buf.WriteString(`{"sender_blockchain_address":`)
+	CanonicalString(buf, sender)
+	buf.WriteString(`,"recipient_blockchain_address":`)
+	CanonicalString(buf, recipient)
+	buf.WriteString(`,"value":`)
+	CanonicalFloat32(buf, value)
+	buf.WriteString(`,"timestamp":`)
+	buf.WriteString(strconv.FormatInt(timestamp, 10))
+	buf.WriteString(`}`)
+}
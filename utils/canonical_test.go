@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestCanonicalFloat32(t *testing.T) {
+	tests := []struct {
+		value float32
+		want  string
+	}{
+		{0, "0"},
+		{1, "1"},
+		{0.1, "0.1"},
+		{1e-7, "0.0000001"},
+		{123456.7, "123456.7"},
+		{1e10, "10000000000"},
+		{-2.5, "-2.5"},
+		{float32(math.NaN()), "0"},
+		{float32(math.Inf(1)), "0"},
+		{float32(math.Inf(-1)), "0"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if CanonicalFloat32(&buf, tt.value); buf.String() != tt.want {
+			t.Errorf("CanonicalFloat32(%v) = %q, want %q", tt.value, buf.String(), tt.want)
+		}
+	}
+}
+
+func TestCanonicalTransaction(t *testing.T) {
+	want := `{"sender_blockchain_address":"1A","recipient_blockchain_address":"1B\u003c","value":0.5,"timestamp":1700000000000000000}`
+	if got := string(CanonicalTransaction("1A", "1B<", 0.5, 1700000000000000000)); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestCanonicalSignedTransaction(t *testing.T) {
+	tests := []struct {
+		name    string
+		chainID string
+		want    string
+	}{
+		{"default network", "", `{"sender_blockchain_address":"1A","recipient_blockchain_address":"1B","value":0.5,"timestamp":7}`},
+		{"chain id", "testnet", `{"chain_id":"testnet","sender_blockchain_address":"1A","recipient_blockchain_address":"1B","value":0.5,"timestamp":7}`},
+		{"escaped chain id", `a"b`, `{"chain_id":"a\"b","sender_blockchain_address":"1A","recipient_blockchain_address":"1B","value":0.5,"timestamp":7}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(CanonicalSignedTransaction(tt.chainID, "1A", "1B", 0.5, 7)); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
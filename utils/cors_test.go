@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORS(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	tests := []struct {
+		name          string
+		allowed       []string
+		method        string
+		origin        string
+		requestMethod string
+		wantStatus    int
+		wantOrigin    string
+		wantMethods   string
+	}{
+		{"preflight", []string{"http://ui.example"}, http.MethodOptions, "http://ui.example", http.MethodPost,
+			http.StatusNoContent, "http://ui.example", "GET, POST, PUT, DELETE, OPTIONS"},
+		{"preflight any origin", []string{"*"}, http.MethodOptions, "http://other.example", http.MethodPost,
+			http.StatusNoContent, "http://other.example", "GET, POST, PUT, DELETE, OPTIONS"},
+		{"preflight disallowed origin", []string{"http://ui.example"}, http.MethodOptions, "http://evil.example", http.MethodPost,
+			http.StatusOK, "", ""},
+		{"options without preflight", []string{"http://ui.example"}, http.MethodOptions, "http://ui.example", "",
+			http.StatusOK, "http://ui.example", ""},
+		{"simple request", []string{"http://ui.example"}, http.MethodGet, "http://ui.example", "",
+			http.StatusOK, "http://ui.example", ""},
+		{"same origin", []string{"http://ui.example"}, http.MethodGet, "", "",
+			http.StatusOK, "", ""},
+		{"no allowed origins", nil, http.MethodGet, "http://ui.example", "",
+			http.StatusOK, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/wallet", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.requestMethod != "" {
+				req.Header.Set("Access-Control-Request-Method", tt.requestMethod)
+			}
+			rec := httptest.NewRecorder()
+			CORS(tt.allowed, next).ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Methods"); got != tt.wantMethods {
+				t.Errorf("Access-Control-Allow-Methods = %q, want %q", got, tt.wantMethods)
+			}
+			if tt.wantOrigin != "" && rec.Header().Get("Vary") != "Origin" {
+				t.Errorf("Vary = %q, want Origin", rec.Header().Get("Vary"))
+			}
+		})
+	}
+}
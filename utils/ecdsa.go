@@ -4,10 +4,17 @@ import (
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"math/big"
 )
 
+// Public key hex string lengths.
+const (
+	PublicKeyStringLength           = 128
+	CompressedPublicKeyStringLength = 66
+)
+
 // Signature is signature struct.
 type Signature struct {
 	R *big.Int
@@ -41,6 +48,45 @@ func PublicKeyFromString(s string) *ecdsa.PublicKey {
 	return &ecdsa.PublicKey{Curve: elliptic.P256(), X: &x, Y: &y}
 }
 
+// PublicKeyString is convert PublicKey to 128 hex characters of X and Y,
+// each zero padded to 32 bytes. It's the encoding APIs expect.
+func PublicKeyString(publicKey *ecdsa.PublicKey) string {
+	return fmt.Sprintf("%064x%064x", publicKey.X.Bytes(), publicKey.Y.Bytes())
+}
+
+// CompressedPublicKeyString is convert PublicKey to 33 byte compressed SEC1 hex string.
+func CompressedPublicKeyString(publicKey *ecdsa.PublicKey) string {
+	return hex.EncodeToString(elliptic.MarshalCompressed(publicKey.Curve, publicKey.X, publicKey.Y))
+}
+
+// PublicKeyFromCompressedString is convert 33 byte compressed SEC1 hex string to PublicKey.
+func PublicKeyFromCompressedString(s string) (*ecdsa.PublicKey, error) {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	x, y := elliptic.UnmarshalCompressed(elliptic.P256(), b)
+	if x == nil {
+		return nil, errors.New("invalid compressed public key")
+	}
+	return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+}
+
+// ParsePublicKey is convert either 128 hex X||Y or 66 hex compressed string to PublicKey.
+func ParsePublicKey(s string) (*ecdsa.PublicKey, error) {
+	switch len(s) {
+	case PublicKeyStringLength:
+		if _, err := hex.DecodeString(s); err != nil {
+			return nil, err
+		}
+		return PublicKeyFromString(s), nil
+	case CompressedPublicKeyStringLength:
+		return PublicKeyFromCompressedString(s)
+	default:
+		return nil, fmt.Errorf("invalid public key length %d", len(s))
+	}
+}
+
 // PrivateKeyFromString is conver string to PrivateKey.
 func PrivateKeyFromString(s string, publicKey *ecdsa.PublicKey) *ecdsa.PrivateKey {
 	b, _ := hex.DecodeString(s[:])
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParsePublicKey(t *testing.T) {
+	privateKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	publicKey := &privateKey.PublicKey
+	uncompressed := fmt.Sprintf("%064x%064x", publicKey.X.Bytes(), publicKey.Y.Bytes())
+	compressed := CompressedPublicKeyString(publicKey)
+	if len(compressed) != CompressedPublicKeyStringLength {
+		t.Fatalf("compressed key length = %d", len(compressed))
+	}
+
+	for _, s := range []string{uncompressed, compressed} {
+		got, err := ParsePublicKey(s)
+		if err != nil {
+			t.Fatalf("ParsePublicKey(%s): %v", s, err)
+		}
+		if got.X.Cmp(publicKey.X) != 0 || got.Y.Cmp(publicKey.Y) != 0 {
+			t.Errorf("ParsePublicKey(%s) = another key", s)
+		}
+	}
+
+	tests := []struct {
+		name string
+		s    string
+	}{
+		{"empty", ""},
+		{"truncated", uncompressed[:127]},
+		{"not hex", strings.Repeat("x", PublicKeyStringLength)},
+		{"compressed not hex", "02" + strings.Repeat("x", 64)},
+		{"compressed bad prefix", "05" + compressed[2:]},
+		{"compressed not on curve", "02" + strings.Repeat("f", 64)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParsePublicKey(tt.s); err == nil {
+				t.Errorf("ParsePublicKey(%q) succeeded", tt.s)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"compress/gzip"
+	"net/http"
+	"strings"
+)
+
+// AcceptsGzip is to check request's Accept-Encoding allows gzip.
+func AcceptsGzip(req *http.Request) bool {
+	for _, part := range strings.Split(req.Header.Get("Accept-Encoding"), ",") {
+		fields := strings.Split(part, ";")
+		coding := strings.TrimSpace(fields[0])
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+		q := "1"
+		for _, param := range fields[1:] {
+			if v := strings.TrimSpace(param); strings.HasPrefix(v, "q=") {
+				q = strings.TrimPrefix(v, "q=")
+			}
+		}
+		return strings.Trim(q, "0.") != ""
+	}
+	return false
+}
+
+// Gzip is middleware to gzip response bodies for clients accepting gzip.
+// Responses without a body, like 304, are left alone.
+func Gzip(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !AcceptsGzip(req) {
+			next.ServeHTTP(w, req)
+			return
+		}
+		gw := &gzipResponseWriter{ResponseWriter: w}
+		defer gw.Close()
+		next.ServeHTTP(gw, req)
+	})
+}
+
+// gzipResponseWriter is http.ResponseWriter compressing the body once the
+// status is known to allow one. A strong ETag is made weak when compressing,
+// as the compressed bytes differ from the ones it names.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz          *gzip.Writer
+	wroteHeader bool
+}
+
+func (w *gzipResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if code != http.StatusNoContent && code != http.StatusNotModified {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Del("Content-Length")
+		if etag := w.Header().Get("ETag"); strings.HasPrefix(etag, `"`) {
+			w.Header().Set("ETag", "W/"+etag)
+		}
+		w.gz = gzip.NewWriter(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.gz == nil {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.gz.Write(b)
+}
+
+// Close is to flush the gzip stream, if the body was compressed.
+func (w *gzipResponseWriter) Close() error {
+	if w.gz == nil {
+		return nil
+	}
+	return w.gz.Close()
+}
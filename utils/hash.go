@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+)
+
+// HashToHex is to return lower case hex of hash.
+func HashToHex(hash [32]byte) string {
+	return hex.EncodeToString(hash[:])
+}
+
+// HexToHash is to return hash of 64 hex characters.
+func HexToHash(s string) ([32]byte, error) {
+	var hash [32]byte
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return hash, err
+	}
+	if len(b) != len(hash) {
+		return hash, fmt.Errorf("invalid hash length %d", len(b))
+	}
+	copy(hash[:], b)
+	return hash, nil
+}
+
+// HashToBigInt is to return hash as big endian unsigned integer.
+func HashToBigInt(hash [32]byte) *big.Int {
+	return new(big.Int).SetBytes(hash[:])
+}
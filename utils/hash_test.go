@@ -0,0 +1,34 @@
+package utils
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestHexToHash(t *testing.T) {
+	want := [32]byte{0xab, 0xcd}
+	got, err := HexToHash(HashToHex(want))
+	if err != nil || got != want {
+		t.Errorf("HexToHash = %x, %v, want %x", got, err, want)
+	}
+	if s := HashToHex(want); s != "abcd"+strings.Repeat("0", 60) {
+		t.Errorf("HashToHex = %q", s)
+	}
+	for _, s := range []string{"zz", "abcd", HashToHex(want) + "00"} {
+		if _, err := HexToHash(s); err == nil {
+			t.Errorf("HexToHash(%q) succeeded", s)
+		}
+	}
+}
+
+func TestHashToBigInt(t *testing.T) {
+	hash := [32]byte{31: 1}
+	if got := HashToBigInt(hash); got.Cmp(big.NewInt(1)) != 0 {
+		t.Errorf("HashToBigInt = %v, want 1", got)
+	}
+	hash = [32]byte{0: 1}
+	if got, want := HashToBigInt(hash), new(big.Int).Lsh(big.NewInt(1), 248); got.Cmp(want) != 0 {
+		t.Errorf("HashToBigInt = %v, want %v", got, want)
+	}
+}
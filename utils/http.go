@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DefaultMaxBodyBytes is default limit of request body size.
+const DefaultMaxBodyBytes = 1 << 20
+
+// Codes of request errors written by WriteError.
+const (
+	ErrorCodeInvalidRequest   = "invalid_request"
+	ErrorCodeNotFound         = "not_found"
+	ErrorCodeMethodNotAllowed = "method_not_allowed"
+	ErrorCodeBodyTooLarge     = "body_too_large"
+	ErrorCodeRateLimited      = "rate_limited"
+	ErrorCodeUpstream         = "upstream_error"
+	ErrorCodeInternal         = "internal_error"
+)
+
+// Content types of responses, set with SetContentType.
+const (
+	ContentTypeJSON = "application/json; charset=utf-8"
+	ContentTypeHTML = "text/html; charset=utf-8"
+)
+
+// ErrBodyTooLarge is error reading request body cut off by LimitBody.
+var ErrBodyTooLarge = errors.New("request body too large")
+
+type errorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// WriteError is to reply status with json error envelope
+// {"error":{"code":"...","message":"..."}}.
+func WriteError(w http.ResponseWriter, status int, code string, message string) {
+	m, _ := json.Marshal(struct {
+		Error errorBody `json:"error"`
+	}{
+		Error: errorBody{Code: code, Message: message},
+	})
+	SetContentType(w, ContentTypeJSON)
+	w.WriteHeader(status)
+	w.Write(m)
+}
+
+// SetContentType is to set Content-Type of response, replacing one set
+// before so an error reply doesn't keep the type of the success body.
+func SetContentType(w http.ResponseWriter, contentType string) {
+	w.Header().Set("Content-Type", contentType)
+}
+
+// WriteRequestError is to reply error reading or decoding request body,
+// 413 if LimitBody cut the body off, otherwise 400.
+func WriteRequestError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrBodyTooLarge) {
+		WriteError(w, http.StatusRequestEntityTooLarge, ErrorCodeBodyTooLarge, err.Error())
+		return
+	}
+	WriteError(w, http.StatusBadRequest, ErrorCodeInvalidRequest, err.Error())
+}
+
+// MethodNotAllowed is to reply 405 with Allow header of allowed methods.
+func MethodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	WriteError(w, http.StatusMethodNotAllowed, ErrorCodeMethodNotAllowed, "method not allowed")
+}
+
+// LimitBody is middleware to reply 413 to requests declaring a body over
+// limit bytes, and to cut off longer bodies of unknown length while read,
+// failing the read with ErrBodyTooLarge. A limit of 0 or less disables limiting.
+func LimitBody(limit int64, next http.Handler) http.Handler {
+	if limit <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.ContentLength > limit {
+			WriteError(w, http.StatusRequestEntityTooLarge, ErrorCodeBodyTooLarge, "request body too large")
+			return
+		}
+		req.Body = &limitedBody{ReadCloser: http.MaxBytesReader(w, req.Body, limit), limit: limit}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// limitedBody is body cut off by http.MaxBytesReader, whose error of going
+// over limit is replaced by ErrBodyTooLarge.
+type limitedBody struct {
+	io.ReadCloser
+	limit int64
+	read  int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if err != nil && err != io.EOF && b.read >= b.limit {
+		err = ErrBodyTooLarge
+	}
+	return n, err
+}
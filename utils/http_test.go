@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// readingHandler is to read the whole request body, replying like handlers
+// decoding a body do.
+var readingHandler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+	if _, err := io.ReadAll(req.Body); err != nil {
+		WriteRequestError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+})
+
+func TestLimitBody(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   int64
+		size    int
+		chunked bool
+		want    int
+	}{
+		{"under limit", 16, 16, false, http.StatusOK},
+		{"declared over limit", 16, 17, false, http.StatusRequestEntityTooLarge},
+		{"chunked under limit", 16, 16, true, http.StatusOK},
+		{"chunked over limit", 16, 17, true, http.StatusRequestEntityTooLarge},
+		{"chunked far over limit", 16, 1 << 16, true, http.StatusRequestEntityTooLarge},
+		{"limit disabled", 0, 1 << 16, true, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("a", tt.size)))
+			if tt.chunked {
+				req.ContentLength = -1
+			}
+			rec := httptest.NewRecorder()
+			LimitBody(tt.limit, readingHandler).ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if tt.want == http.StatusRequestEntityTooLarge {
+				assertErrorCode(t, rec, ErrorCodeBodyTooLarge)
+			}
+		})
+	}
+}
+
+func TestMethodNotAllowed(t *testing.T) {
+	tests := []struct {
+		allowed []string
+		want    string
+	}{
+		{[]string{http.MethodGet}, "GET"},
+		{[]string{http.MethodGet, http.MethodPost}, "GET, POST"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		MethodNotAllowed(rec, tt.allowed...)
+		if rec.Code != http.StatusMethodNotAllowed {
+			t.Errorf("status = %d, want 405", rec.Code)
+		}
+		if got := rec.Header().Get("Allow"); got != tt.want {
+			t.Errorf("Allow = %q, want %q", got, tt.want)
+		}
+		assertErrorCode(t, rec, ErrorCodeMethodNotAllowed)
+	}
+}
+
+func TestWriteRequestError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+		code string
+	}{
+		{ErrBodyTooLarge, http.StatusRequestEntityTooLarge, ErrorCodeBodyTooLarge},
+		{fmt.Errorf("decode: %w", ErrBodyTooLarge), http.StatusRequestEntityTooLarge, ErrorCodeBodyTooLarge},
+		{io.ErrUnexpectedEOF, http.StatusBadRequest, ErrorCodeInvalidRequest},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		WriteRequestError(rec, tt.err)
+		if rec.Code != tt.want {
+			t.Errorf("%v: status = %d, want %d", tt.err, rec.Code, tt.want)
+		}
+		assertErrorCode(t, rec, tt.code)
+	}
+}
+
+// assertErrorCode is to check rec is a json error reply with code.
+func assertErrorCode(t *testing.T, rec *httptest.ResponseRecorder, code string) {
+	t.Helper()
+	if ct := rec.Header().Get("Content-Type"); ct != ContentTypeJSON {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	var v struct {
+		Error errorBody `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &v); err != nil {
+		t.Fatalf("decode %s: %v", rec.Body.Bytes(), err)
+	}
+	if v.Error.Code != code {
+		t.Errorf("error code = %q, want %q", v.Error.Code, code)
+	}
+}
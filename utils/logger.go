@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Logger is leveled logger interface with key value fields.
+// *slog.Logger satisfies it.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+}
+
+// StdLogger is Logger writing to the standard logger.
+type StdLogger struct{}
+
+// NewStdLogger is to return new StdLogger.
+func NewStdLogger() *StdLogger {
+	return &StdLogger{}
+}
+
+// Debug is to log at debug level.
+func (l *StdLogger) Debug(msg string, args ...interface{}) {
+	l.output("DEBUG", msg, args)
+}
+
+// Info is to log at info level.
+func (l *StdLogger) Info(msg string, args ...interface{}) {
+	l.output("INFO", msg, args)
+}
+
+// Warn is to log at warn level.
+func (l *StdLogger) Warn(msg string, args ...interface{}) {
+	l.output("WARN", msg, args)
+}
+
+// Error is to log at error level.
+func (l *StdLogger) Error(msg string, args ...interface{}) {
+	l.output("ERROR", msg, args)
+}
+
+func (l *StdLogger) output(level string, msg string, args []interface{}) {
+	var sb strings.Builder
+	sb.WriteString(level)
+	sb.WriteString(" ")
+	sb.WriteString(msg)
+	for i := 0; i < len(args); i += 2 {
+		if i+1 < len(args) {
+			fmt.Fprintf(&sb, " %v=%v", args[i], args[i+1])
+		} else {
+			fmt.Fprintf(&sb, " !BADKEY=%v", args[i])
+		}
+	}
+	_ = log.Output(3, sb.String())
+}
+
+// NopLogger is Logger discarding all logs.
+type NopLogger struct{}
+
+// NewNopLogger is to return new NopLogger.
+func NewNopLogger() *NopLogger {
+	return &NopLogger{}
+}
+
+// Debug is to discard log.
+func (l *NopLogger) Debug(msg string, args ...interface{}) {}
+
+// Info is to discard log.
+func (l *NopLogger) Info(msg string, args ...interface{}) {}
+
+// Warn is to discard log.
+func (l *NopLogger) Warn(msg string, args ...interface{}) {}
+
+// Error is to discard log.
+func (l *NopLogger) Error(msg string, args ...interface{}) {}
@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"testing"
+)
+
+func TestStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	flags := log.Flags()
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+	defer func() {
+		log.SetOutput(os.Stderr)
+		log.SetFlags(flags)
+	}()
+
+	tests := []struct {
+		name string
+		log  func(l Logger)
+		want string
+	}{
+		{"debug", func(l Logger) { l.Debug("sync", "action", "sync") }, "DEBUG sync action=sync\n"},
+		{"info", func(l Logger) { l.Info("mining", "action", "mining", "status", "success") }, "INFO mining action=mining status=success\n"},
+		{"warn", func(l Logger) { l.Warn("peer") }, "WARN peer\n"},
+		{"error", func(l Logger) { l.Error("add transaction", "reason", "invalid signature") }, "ERROR add transaction reason=invalid signature\n"},
+		{"odd fields", func(l Logger) { l.Info("odd", "action") }, "INFO odd !BADKEY=action\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf.Reset()
+			tt.log(NewStdLogger())
+			if buf.String() != tt.want {
+				t.Errorf("got %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestNopLogger(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	var l Logger = NewNopLogger()
+	l.Debug("debug")
+	l.Info("info", "action", "mining")
+	l.Warn("warn")
+	l.Error("error")
+	if buf.Len() != 0 {
+		t.Errorf("NopLogger wrote %q", buf.String())
+	}
+}
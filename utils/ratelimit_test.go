@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	tests := []struct {
+		name     string
+		limiter  *RateLimiter
+		requests int
+		allowed  int
+	}{
+		{"nil limiter allows all", nil, 10, 10},
+		{"burst is allowed at once", NewRateLimiter(0.001, 3), 3, 3},
+		{"requests over burst are rejected", NewRateLimiter(0.001, 3), 10, 3},
+		{"burst of one", NewRateLimiter(0.001, 1), 5, 1},
+		{"zero burst rejects all", NewRateLimiter(0.001, 0), 5, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := RateLimit(tt.limiter, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			allowed := 0
+			for i := 0; i < tt.requests; i++ {
+				rec := httptest.NewRecorder()
+				req := httptest.NewRequest(http.MethodPost, "/wallet", nil)
+				req.RemoteAddr = "192.0.2.1:1234"
+				h.ServeHTTP(rec, req)
+				switch rec.Code {
+				case http.StatusOK:
+					allowed++
+				case http.StatusTooManyRequests:
+					if rec.Header().Get("Retry-After") == "" {
+						t.Errorf("429 without Retry-After")
+					}
+				default:
+					t.Fatalf("status = %d", rec.Code)
+				}
+			}
+			if allowed != tt.allowed {
+				t.Errorf("allowed = %d, want %d", allowed, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestRateLimitPerClientIP(t *testing.T) {
+	h := RateLimit(NewRateLimiter(0.001, 1), http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	tests := []struct {
+		remoteAddr string
+		want       int
+	}{
+		{"192.0.2.1:1234", http.StatusOK},
+		{"192.0.2.1:5678", http.StatusTooManyRequests},
+		{"192.0.2.2:1234", http.StatusOK},
+		{"[2001:db8::1]:1234", http.StatusOK},
+		{"[2001:db8::1]:5678", http.StatusTooManyRequests},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/transactions", nil)
+		req.RemoteAddr = tt.remoteAddr
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.remoteAddr, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestRateLimiterRefills(t *testing.T) {
+	rl := NewRateLimiter(1000, 1)
+	if !rl.Allow("a") {
+		t.Fatal("first request rejected")
+	}
+	rl.buckets["a"].last = rl.buckets["a"].last.Add(-2 * time.Millisecond)
+	if !rl.Allow("a") {
+		t.Error("request after refill rejected")
+	}
+}
@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/http"
+	"time"
+)
+
+// SelfSignedCertificateValidity is how long self signed certificates are valid.
+const SelfSignedCertificateValidity = 365 * 24 * time.Hour
+
+// SelfSignedCertificate is to return new self signed certificate for hosts,
+// for local development only.
+func SelfSignedCertificate(hosts ...string) (tls.Certificate, error) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"goblockchain"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(SelfSignedCertificateValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &privateKey.PublicKey, privateKey)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: privateKey}, nil
+}
+
+// TLSConfig is to return TLS config serving certFile and keyFile when
+// they're set, or a self signed certificate for localhost when selfSigned is
+// true, or nil to serve plain HTTP.
+func TLSConfig(certFile, keyFile string, selfSigned bool) (*tls.Config, error) {
+	var cert tls.Certificate
+	var err error
+	switch {
+	case certFile != "" && keyFile != "":
+		cert, err = tls.LoadX509KeyPair(certFile, keyFile)
+	case selfSigned:
+		cert, err = SelfSignedCertificate("localhost", "127.0.0.1", "::1")
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}}, nil
+}
+
+// ListenAndServe is to run server over TLS configured by TLSConfig, or
+// over plain HTTP when it's nil.
+func ListenAndServe(server *http.Server, certFile, keyFile string, selfSigned bool) error {
+	config, err := TLSConfig(certFile, keyFile, selfSigned)
+	if err != nil {
+		return err
+	}
+	if config == nil {
+		return server.ListenAndServe()
+	}
+	server.TLSConfig = config
+	return server.ListenAndServeTLS("", "")
+}
@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSelfSignedCertificate(t *testing.T) {
+	cert, err := SelfSignedCertificate("localhost", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, host := range []string{"localhost", "127.0.0.1"} {
+		if err := leaf.VerifyHostname(host); err != nil {
+			t.Errorf("VerifyHostname(%s): %v", host, err)
+		}
+	}
+	if err := leaf.VerifyHostname("example.com"); err == nil {
+		t.Error("certificate valid for example.com")
+	}
+	if time.Until(leaf.NotAfter) < SelfSignedCertificateValidity-time.Minute || time.Now().Before(leaf.NotBefore) {
+		t.Errorf("validity %v to %v", leaf.NotBefore, leaf.NotAfter)
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	if config, err := TLSConfig("", "", false); config != nil || err != nil {
+		t.Errorf("plain HTTP config = %v, %v", config, err)
+	}
+	if config, err := TLSConfig("", "", true); err != nil || len(config.Certificates) != 1 {
+		t.Errorf("self signed config = %v, %v", config, err)
+	}
+
+	certFile, keyFile := writeTestCertificate(t)
+	if config, err := TLSConfig(certFile, keyFile, true); err != nil || len(config.Certificates) != 1 {
+		t.Errorf("file config = %v, %v", config, err)
+	}
+	if _, err := TLSConfig(certFile, certFile, false); err == nil {
+		t.Error("certificate accepted as key")
+	}
+}
+
+// writeTestCertificate is to write a self signed certificate for
+// 127.0.0.1 and its key to PEM files in a temporary directory.
+func writeTestCertificate(tb testing.TB) (string, string) {
+	tb.Helper()
+	cert, err := SelfSignedCertificate("127.0.0.1")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	dir := tb.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}), 0600)
+	return certFile, keyFile
+}
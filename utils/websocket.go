@@ -0,0 +1,177 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// WebSocket opcodes.
+const (
+	WebSocketOpText  = 0x1
+	WebSocketOpClose = 0x8
+	WebSocketOpPing  = 0x9
+	WebSocketOpPong  = 0xA
+)
+
+const webSocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// WebSocketMaxFrameSize is max payload size of a frame read from client.
+const WebSocketMaxFrameSize = 1 << 16
+
+// ErrWebSocketHandshake is returned for an invalid upgrade request.
+var ErrWebSocketHandshake = errors.New("websocket: invalid handshake request")
+
+// WebSocketConn is server side websocket connection.
+type WebSocketConn struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+	mux  sync.Mutex
+}
+
+// UpgradeWebSocket is to upgrade http request to websocket connection.
+func UpgradeWebSocket(w http.ResponseWriter, req *http.Request) (*WebSocketConn, error) {
+	if req.Method != http.MethodGet ||
+		!headerContains(req.Header, "Connection", "upgrade") ||
+		!headerContains(req.Header, "Upgrade", "websocket") ||
+		req.Header.Get("Sec-WebSocket-Version") != "13" {
+		http.Error(w, "websocket upgrade required", http.StatusBadRequest)
+		return nil, ErrWebSocketHandshake
+	}
+	key := req.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, ErrWebSocketHandshake
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return nil, ErrWebSocketHandshake
+	}
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	h := sha1.New()
+	h.Write([]byte(key + webSocketGUID))
+	accept := base64.StdEncoding.EncodeToString(h.Sum(nil))
+
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	rw.WriteString("Upgrade: websocket\r\n")
+	rw.WriteString("Connection: Upgrade\r\n")
+	rw.WriteString("Sec-WebSocket-Accept: " + accept + "\r\n\r\n")
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &WebSocketConn{conn: conn, rw: rw}, nil
+}
+
+func headerContains(h http.Header, name string, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// WriteMessage is to write single frame message.
+func (c *WebSocketConn) WriteMessage(opcode byte, payload []byte) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	header := []byte{0x80 | opcode}
+	n := len(payload)
+	switch {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xFFFF:
+		header = append(header, 126, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(n))
+	default:
+		header = append(header, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(n))
+	}
+	if _, err := c.rw.Write(header); err != nil {
+		return err
+	}
+	if _, err := c.rw.Write(payload); err != nil {
+		return err
+	}
+	return c.rw.Flush()
+}
+
+// ReadMessage is to read single frame message from client.
+// Ping is answered and close is echoed and reported as io.EOF.
+func (c *WebSocketConn) ReadMessage() (byte, []byte, error) {
+	for {
+		var head [2]byte
+		if _, err := io.ReadFull(c.rw, head[:]); err != nil {
+			return 0, nil, err
+		}
+		opcode := head[0] & 0x0F
+		masked := head[1]&0x80 != 0
+		n := uint64(head[1] & 0x7F)
+		switch n {
+		case 126:
+			var ext [2]byte
+			if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+				return 0, nil, err
+			}
+			n = uint64(binary.BigEndian.Uint16(ext[:]))
+		case 127:
+			var ext [8]byte
+			if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+				return 0, nil, err
+			}
+			n = binary.BigEndian.Uint64(ext[:])
+		}
+		if n > WebSocketMaxFrameSize {
+			return 0, nil, errors.New("websocket: frame too large")
+		}
+		var mask [4]byte
+		if masked {
+			if _, err := io.ReadFull(c.rw, mask[:]); err != nil {
+				return 0, nil, err
+			}
+		}
+		payload := make([]byte, n)
+		if _, err := io.ReadFull(c.rw, payload); err != nil {
+			return 0, nil, err
+		}
+		if masked {
+			for i := range payload {
+				payload[i] ^= mask[i%4]
+			}
+		}
+
+		switch opcode {
+		case WebSocketOpPing:
+			if err := c.WriteMessage(WebSocketOpPong, payload); err != nil {
+				return 0, nil, err
+			}
+		case WebSocketOpPong:
+		case WebSocketOpClose:
+			_ = c.WriteMessage(WebSocketOpClose, nil)
+			return opcode, payload, io.EOF
+		default:
+			return opcode, payload, nil
+		}
+	}
+}
+
+// Close is to close underlying connection.
+func (c *WebSocketConn) Close() error {
+	return c.conn.Close()
+}
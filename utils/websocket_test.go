@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// dialWebSocket is to open websocket connection to url of server, returning
+// the connection and its reader positioned after the handshake response.
+func dialWebSocket(tb testing.TB, url string) (net.Conn, *bufio.Reader) {
+	tb.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { conn.Close() })
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	if err := req.Write(conn); err != nil {
+		tb.Fatal(err)
+	}
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		tb.Fatalf("status = %d, want 101", resp.StatusCode)
+	}
+	// Accept key of the sample nonce in RFC 6455.
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		tb.Fatalf("Sec-WebSocket-Accept = %q", got)
+	}
+	return conn, r
+}
+
+// writeClientFrame is to write masked frame as clients must.
+func writeClientFrame(tb testing.TB, w io.Writer, opcode byte, payload []byte) {
+	tb.Helper()
+	mask := [4]byte{1, 2, 3, 4}
+	frame := []byte{0x80 | opcode, 0x80 | byte(len(payload))}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := w.Write(frame); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// readServerFrame is to read unmasked frame sent by server.
+func readServerFrame(tb testing.TB, r io.Reader) (byte, []byte) {
+	tb.Helper()
+	var head [2]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		tb.Fatal(err)
+	}
+	n := int(head[1] & 0x7F)
+	if n == 126 {
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			tb.Fatal(err)
+		}
+		n = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		tb.Fatal(err)
+	}
+	return head[0] & 0x0F, payload
+}
+
+func TestWebSocket(t *testing.T) {
+	closed := make(chan error, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		conn, err := UpgradeWebSocket(w, req)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			opcode, payload, err := conn.ReadMessage()
+			if err != nil {
+				closed <- err
+				return
+			}
+			if err := conn.WriteMessage(opcode, payload); err != nil {
+				return
+			}
+		}
+	}))
+	defer ts.Close()
+	conn, r := dialWebSocket(t, ts.URL)
+
+	writeClientFrame(t, conn, WebSocketOpText, []byte("hello"))
+	if opcode, payload := readServerFrame(t, r); opcode != WebSocketOpText || string(payload) != "hello" {
+		t.Errorf("echo = (%x, %q)", opcode, payload)
+	}
+
+	long := strings.Repeat("x", 300)
+	writeClientFrame(t, conn, WebSocketOpPing, []byte("ping"))
+	if opcode, payload := readServerFrame(t, r); opcode != WebSocketOpPong || string(payload) != "ping" {
+		t.Errorf("ping answered with (%x, %q)", opcode, payload)
+	}
+	c := &WebSocketConn{conn: conn, rw: bufio.NewReadWriter(r, bufio.NewWriter(conn))}
+	// ReadMessage accepts unmasked frames too, so WriteMessage can send a
+	// frame with a 16 bit length for the server to echo back.
+	if err := c.WriteMessage(WebSocketOpText, []byte(long)); err != nil {
+		t.Fatal(err)
+	}
+	if _, payload := readServerFrame(t, r); string(payload) != long {
+		t.Errorf("echo of %d bytes = %d bytes", len(long), len(payload))
+	}
+
+	writeClientFrame(t, conn, WebSocketOpClose, nil)
+	if opcode, _ := readServerFrame(t, r); opcode != WebSocketOpClose {
+		t.Errorf("close answered with %x", opcode)
+	}
+	if err := <-closed; err != io.EOF {
+		t.Errorf("ReadMessage after close = %v, want io.EOF", err)
+	}
+}
+
+func TestUpgradeWebSocketRejectsPlainRequest(t *testing.T) {
+	rec := httptest.NewRecorder()
+	if _, err := UpgradeWebSocket(rec, httptest.NewRequest(http.MethodGet, "/ws/blocks", nil)); err != ErrWebSocketHandshake {
+		t.Errorf("error = %v, want ErrWebSocketHandshake", err)
+	}
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+}
@@ -8,9 +8,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"goblockchain/utils"
-
-	"github.com/btcsuite/btcutil/base58"
-	"golang.org/x/crypto/ripemd160"
+	"time"
 )
 
 // Wallet is wallet struct.
@@ -22,44 +20,24 @@ type Wallet struct {
 
 // NewWallet is to return new wallet struct.
 func NewWallet() *Wallet {
-	w := new(Wallet)
 	privateKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	return NewWalletFromKey(privateKey)
+}
+
+// NewWalletFromKey is to return wallet struct of existing private key.
+func NewWalletFromKey(privateKey *ecdsa.PrivateKey) *Wallet {
+	w := new(Wallet)
 	w.privateKey = privateKey
 	w.publicKey = &w.privateKey.PublicKey
-
-	h2 := sha256.New()
-	h2.Write(w.publicKey.X.Bytes())
-	h2.Write(w.publicKey.Y.Bytes())
-	digets2 := h2.Sum(nil)
-
-	h3 := ripemd160.New()
-	h3.Write(digets2)
-	digest3 := h3.Sum(nil)
-
-	vd4 := make([]byte, 21)
-	vd4[0] = 0x00
-	copy(vd4[1:], digest3[:])
-
-	h5 := sha256.New()
-	h5.Write(vd4)
-	digest5 := h5.Sum(nil)
-
-	h6 := sha256.New()
-	h6.Write(digest5)
-	digest6 := h6.Sum(nil)
-
-	chsum := digest6[:4]
-
-	dc8 := make([]byte, 25)
-	copy(dc8[:21], vd4[:])
-	copy(dc8[21:], chsum[:])
-
-	address := base58.Encode(dc8)
-	w.blockchainAddress = address
-
+	w.blockchainAddress = BlockchainAddressOf(w.publicKey)
 	return w
 }
 
+// BlockchainAddressOf is to return blockchain address of publicKey.
+func BlockchainAddressOf(publicKey *ecdsa.PublicKey) string {
+	return utils.AddressFromPublicKey(publicKey)
+}
+
 // PrivateKey is to return Wallet's privateKey
 func (w *Wallet) PrivateKey() *ecdsa.PrivateKey {
 	return w.privateKey
@@ -77,7 +55,24 @@ func (w *Wallet) PublicKey() *ecdsa.PublicKey {
 
 // PublicKeyStr is to return Wallet's publicKey string
 func (w *Wallet) PublicKeyStr() string {
-	return fmt.Sprintf("%064x%064x", w.publicKey.X.Bytes(), w.publicKey.Y.Bytes())
+	return EncodePublicKey(w.publicKey)
+}
+
+// EncodePublicKey is to return publicKey in the encoding nodes expect as
+// sender_public_key, 128 hex characters of X and Y.
+func EncodePublicKey(publicKey *ecdsa.PublicKey) string {
+	return utils.PublicKeyString(publicKey)
+}
+
+// DecodePublicKey is to return public key of EncodePublicKey string or
+// 66 hex characters compressed SEC1 key, as nodes decode sender_public_key.
+func DecodePublicKey(s string) (*ecdsa.PublicKey, error) {
+	return utils.ParsePublicKey(s)
+}
+
+// PublicKeyCompressedStr is to return Wallet's compressed SEC1 publicKey string
+func (w *Wallet) PublicKeyCompressedStr() string {
+	return utils.CompressedPublicKeyString(w.publicKey)
 }
 
 // BlockchainAddress is to return Wallet's blockchain address.
@@ -105,17 +100,35 @@ type Transaction struct {
 	senderBlockchainAddress    string
 	recipientBlockchainAddress string
 	value                      float32
+	timestamp                  int64
+	chainID                    string
 }
 
 // NewTransaction is to return new Transaction struct.
 func NewTransaction(privateKey *ecdsa.PrivateKey, publicKey *ecdsa.PublicKey, sender string, recipient string, value float32) *Transaction {
-	return &Transaction{privateKey, publicKey, sender, recipient, value}
+	return &Transaction{privateKey, publicKey, sender, recipient, value, time.Now().UnixNano(), ""}
+}
+
+// Timestamp is to return unix time in nanoseconds the transaction is signed with.
+func (t *Transaction) Timestamp() int64 {
+	return t.timestamp
+}
+
+// SetTimestamp is set the transaction's timestamp, so every signer of a
+// multisig transaction signs the same payload.
+func (t *Transaction) SetTimestamp(timestamp int64) {
+	t.timestamp = timestamp
+}
+
+// SetChainID is set network chain ID the transaction is signed for.
+func (t *Transaction) SetChainID(chainID string) {
+	t.chainID = chainID
 }
 
 // GenerateSignature is to generate Signature method.
 func (t *Transaction) GenerateSignature() *utils.Signature {
-	m, _ := json.Marshal(t)
-	h := sha256.Sum256(m)
+	h := sha256.Sum256(utils.CanonicalSignedTransaction(t.chainID,
+		t.senderBlockchainAddress, t.recipientBlockchainAddress, t.value, t.timestamp))
 	r, s, _ := ecdsa.Sign(rand.Reader, t.senderPrivateKey, h[:])
 	return &utils.Signature{R: r, S: s}
 }
@@ -126,24 +139,33 @@ func (t *Transaction) MarshalJSON() ([]byte, error) {
 		Sender    string  `json:"sender_blockchain_address"`
 		Recipient string  `json:"recipient_blockchain_address"`
 		Value     float32 `json:"value"`
+		Timestamp int64   `json:"timestamp"`
 	}{
 		Sender:    t.senderBlockchainAddress,
 		Recipient: t.recipientBlockchainAddress,
 		Value:     t.value,
+		Timestamp: t.timestamp,
 	})
 }
 
 // TransactionRequest is TransactionRequest struct.
+// Wallet is label of a wallet kept by the wallet server to sign with
+// instead of the sender fields, unlocked by WalletPassphrase.
 type TransactionRequest struct {
 	SenderPrivateKey           *string `json:"sender_private_key"`
 	SenderBlockchainAddress    *string `json:"sender_blockchain_address"`
 	RecipientBlockchainAddress *string `json:"recipient_blockchain_address"`
 	SenderPublicKey            *string `json:"sender_public_key"`
 	Value                      *string `json:"value"`
+	Wallet                     *string `json:"wallet,omitempty"`
+	WalletPassphrase           *string `json:"wallet_passphrase,omitempty"`
 }
 
 // Validate is to validate request transaction data.
 func (tr *TransactionRequest) Validate() bool {
+	if tr.Wallet != nil {
+		return tr.WalletPassphrase != nil && tr.RecipientBlockchainAddress != nil && tr.Value != nil
+	}
 	if tr.SenderPrivateKey == nil ||
 		tr.SenderBlockchainAddress == nil ||
 		tr.RecipientBlockchainAddress == nil ||
@@ -153,3 +175,28 @@ func (tr *TransactionRequest) Validate() bool {
 	}
 	return true
 }
+
+// MessagePrefix is prepended to messages before they're hashed and signed,
+// so a signed message can never be a valid transaction signature.
+const MessagePrefix = "goblockchain signed message:\n"
+
+// messageHash is to return sha256 of MessagePrefix and msg.
+func messageHash(msg []byte) [32]byte {
+	return sha256.Sum256(append([]byte(MessagePrefix), msg...))
+}
+
+// SignMessage is to sign arbitrary msg to prove ownership of Wallet's address.
+func (w *Wallet) SignMessage(msg []byte) (*utils.Signature, error) {
+	h := messageHash(msg)
+	r, s, err := ecdsa.Sign(rand.Reader, w.privateKey, h[:])
+	if err != nil {
+		return nil, err
+	}
+	return &utils.Signature{R: r, S: s}, nil
+}
+
+// VerifyMessage is to verify signature of msg made by SignMessage with pub's private key.
+func VerifyMessage(pub *ecdsa.PublicKey, msg []byte, s *utils.Signature) bool {
+	h := messageHash(msg)
+	return ecdsa.Verify(pub, h[:], s.R, s.S)
+}
@@ -0,0 +1,44 @@
+package wallet
+
+import "testing"
+
+func TestEncodeDecodePublicKey(t *testing.T) {
+	w := NewWallet()
+	encoded := EncodePublicKey(w.PublicKey())
+	if len(encoded) != 128 || encoded != w.PublicKeyStr() {
+		t.Fatalf("encoded = %q, want 128 hex characters matching PublicKeyStr", encoded)
+	}
+	for _, s := range []string{encoded, w.PublicKeyCompressedStr()} {
+		pk, err := DecodePublicKey(s)
+		if err != nil {
+			t.Fatalf("decode %q: %v", s, err)
+		}
+		if pk.X.Cmp(w.PublicKey().X) != 0 || pk.Y.Cmp(w.PublicKey().Y) != 0 {
+			t.Errorf("decoded %q to another key", s)
+		}
+	}
+	if _, err := DecodePublicKey("zz"); err == nil {
+		t.Error("decoded invalid key")
+	}
+}
+
+func TestSignMessage(t *testing.T) {
+	w := NewWallet()
+	if got := BlockchainAddressOf(w.PublicKey()); got != w.BlockchainAddress() {
+		t.Errorf("address of public key = %q, want %q", got, w.BlockchainAddress())
+	}
+	msg := []byte("hello")
+	s, err := w.SignMessage(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !VerifyMessage(w.PublicKey(), msg, s) {
+		t.Error("signature not verified")
+	}
+	if VerifyMessage(w.PublicKey(), []byte("hello!"), s) {
+		t.Error("signature verified another message")
+	}
+	if VerifyMessage(NewWallet().PublicKey(), msg, s) {
+		t.Error("signature verified with another key")
+	}
+}
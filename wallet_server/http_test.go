@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"goblockchain/block"
+	"goblockchain/blockchain_server"
+	"goblockchain/utils"
+	"goblockchain/wallet"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestServer is to return WalletServer with gateway and its httptest server.
+func newTestServer(tb testing.TB, gateway string, configure ...func(*WalletServer)) (*WalletServer, *httptest.Server) {
+	tb.Helper()
+	ws := NewWalletServer(0, gateway)
+	for _, c := range configure {
+		c(ws)
+	}
+	ts := httptest.NewServer(ws.Handler())
+	tb.Cleanup(ts.Close)
+	return ws, ts
+}
+
+func TestWalletCORS(t *testing.T) {
+	const origin = "http://ui.example"
+	_, ts := newTestServer(t, "", func(ws *WalletServer) {
+		ws.SetAllowedOrigins([]string{origin})
+	})
+
+	req, _ := http.NewRequest(http.MethodOptions, ts.URL+"/wallet", nil)
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("preflight status = %d, want 204", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Access-Control-Allow-Origin"); got != origin {
+		t.Errorf("preflight Access-Control-Allow-Origin = %q", got)
+	}
+
+	req, _ = http.NewRequest(http.MethodPost, ts.URL+"/wallet", nil)
+	req.Header.Set("Origin", origin)
+	resp, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if got := resp.Header.Get("Access-Control-Allow-Origin"); got != origin {
+		t.Errorf("Access-Control-Allow-Origin = %q", got)
+	}
+}
+
+func TestDefaultHost(t *testing.T) {
+	ws := NewWalletServer(8080, "")
+	if ws.Host() != DefaultHost {
+		t.Errorf("host = %q, want %q", ws.Host(), DefaultHost)
+	}
+	ws.SetHost("127.0.0.1")
+	if ws.Host() != "127.0.0.1" {
+		t.Errorf("host = %q", ws.Host())
+	}
+}
+
+func TestWalletRateLimit(t *testing.T) {
+	_, ts := newTestServer(t, "", func(ws *WalletServer) {
+		ws.SetRateLimit(0.001, 2)
+	})
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		resp, err := http.Post(ts.URL+"/wallet", "application/json", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Errorf("request %d: status = %d, want %d", i, resp.StatusCode, want)
+		}
+	}
+}
+
+// postWallet is to POST /wallet through client and return the status code.
+func postWallet(tb testing.TB, client *http.Client, url string) int {
+	tb.Helper()
+	resp, err := client.Post(url+"/wallet", "application/json", nil)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestWalletTLS(t *testing.T) {
+	ws := NewWalletServer(0, "")
+	ts := httptest.NewTLSServer(ws.Handler())
+	defer ts.Close()
+
+	if code := postWallet(t, ts.Client(), ts.URL); code != http.StatusOK {
+		t.Errorf("POST /wallet over TLS = %d", code)
+	}
+}
+
+func TestWalletSelfSignedTLS(t *testing.T) {
+	ws := NewWalletServer(0, "")
+	ws.SetSelfSignedTLS(true)
+	config, err := utils.TLSConfig(ws.tlsCertFile, ws.tlsKeyFile, ws.tlsSelfSigned)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewUnstartedServer(ws.Handler())
+	ts.TLS = config
+	ts.StartTLS()
+	defer ts.Close()
+
+	if _, err := http.DefaultClient.Post(ts.URL+"/wallet", "application/json", nil); err == nil {
+		t.Error("self signed certificate trusted by default client")
+	}
+
+	leaf, err := x509.ParseCertificate(config.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	if code := postWallet(t, client, ts.URL); code != http.StatusOK {
+		t.Errorf("POST /wallet over self signed TLS = %d", code)
+	}
+}
+
+func TestCreateTransactionInvalidSignature(t *testing.T) {
+	bcs := blockchain_server.NewBlockchainServer(0)
+	bcs.SetLogger(utils.NewNopLogger())
+	sender := wallet.NewWallet()
+	bc := block.NewBlockchain(sender.BlockchainAddress(), 0)
+	bcs.SetBlockchain(bc)
+	bc.Mining()
+	node := httptest.NewServer(bcs.Handler())
+	defer node.Close()
+	_, ts := newTestServer(t, node.URL, func(ws *WalletServer) {
+		ws.SetLogger(utils.NewNopLogger())
+	})
+
+	// Signed with another wallet's private key, so the node rejects it.
+	body, _ := json.Marshal(map[string]string{
+		"sender_private_key":           wallet.NewWallet().PrivateKeyStr(),
+		"sender_blockchain_address":    sender.BlockchainAddress(),
+		"recipient_blockchain_address": "1Bob",
+		"sender_public_key":            sender.PublicKeyStr(),
+		"value":                        "0.5",
+	})
+	resp, err := http.Post(ts.URL+"/transaction", "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", resp.StatusCode)
+	}
+	var v struct {
+		Error struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Error.Code != block.ErrorCodeInvalidSignature {
+		t.Errorf("error code = %q, want %q", v.Error.Code, block.ErrorCodeInvalidSignature)
+	}
+}
+
+func TestWallets(t *testing.T) {
+	_, ts := newTestServer(t, "", func(ws *WalletServer) {
+		ws.SetLogger(utils.NewNopLogger())
+	})
+	do := func(method, url, body string) (int, string) {
+		t.Helper()
+		req, _ := http.NewRequest(method, ts.URL+url, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		if strings.Contains(string(b), "private") {
+			t.Errorf("%s %s response has a private key: %s", method, url, b)
+		}
+		return resp.StatusCode, string(b)
+	}
+
+	addresses := make(map[string]string)
+	for _, label := range []string{"alice", "bob"} {
+		status, body := do(http.MethodPost, "/wallets", `{"label":"`+label+`","passphrase":"secret"}`)
+		var lw LabeledWallet
+		json.Unmarshal([]byte(body), &lw)
+		if status != http.StatusCreated || lw.Label != label || lw.BlockchainAddress == "" {
+			t.Fatalf("create %s: status %d, body %s", label, status, body)
+		}
+		addresses[label] = lw.BlockchainAddress
+	}
+	if addresses["alice"] == addresses["bob"] {
+		t.Error("two wallets share an address")
+	}
+	for label, address := range addresses {
+		status, body := do(http.MethodGet, "/wallets?label="+label, "")
+		var lw LabeledWallet
+		json.Unmarshal([]byte(body), &lw)
+		if status != http.StatusOK || lw.BlockchainAddress != address {
+			t.Errorf("get %s: status %d, body %s", label, status, body)
+		}
+	}
+	status, body := do(http.MethodGet, "/wallets", "")
+	var list []LabeledWallet
+	json.Unmarshal([]byte(body), &list)
+	if status != http.StatusOK || len(list) != 2 {
+		t.Errorf("list: status %d, body %s", status, body)
+	}
+
+	tests := []struct {
+		method string
+		url    string
+		body   string
+		want   int
+	}{
+		{http.MethodPost, "/wallets", `{"label":"alice","passphrase":"secret"}`, http.StatusConflict},
+		{http.MethodPost, "/wallets", `{"label":"a b","passphrase":"secret"}`, http.StatusBadRequest},
+		{http.MethodPost, "/wallets", `{"label":"carol"}`, http.StatusBadRequest},
+		{http.MethodPost, "/wallets", `{"label":`, http.StatusBadRequest},
+		{http.MethodGet, "/wallets?label=carol", "", http.StatusNotFound},
+		{http.MethodDelete, "/wallets", "", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		if status, body := do(tt.method, tt.url, tt.body); status != tt.want {
+			t.Errorf("%s %s %s: status %d, want %d (%s)", tt.method, tt.url, tt.body, status, tt.want, body)
+		}
+	}
+}
+
+func TestCreateTransactionByLabel(t *testing.T) {
+	bcs := blockchain_server.NewBlockchainServer(0)
+	bcs.SetLogger(utils.NewNopLogger())
+	bc := block.NewBlockchain(wallet.NewWallet().BlockchainAddress(), 0)
+	bc.SetLogger(utils.NewNopLogger())
+	bcs.SetBlockchain(bc)
+	node := httptest.NewServer(bcs.Handler())
+	defer node.Close()
+	ws, ts := newTestServer(t, node.URL, func(ws *WalletServer) {
+		ws.SetLogger(utils.NewNopLogger())
+	})
+	alice, err := ws.WalletRegistry().Create("alice", "alice secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc.MineTo(alice.BlockchainAddress, 0)
+
+	for _, tt := range []struct {
+		wallet     string
+		passphrase string
+		want       int
+	}{
+		{"alice", "alice secret", http.StatusOK},
+		{"alice", "wrong", http.StatusUnauthorized},
+		{"carol", "alice secret", http.StatusNotFound},
+	} {
+		body, _ := json.Marshal(map[string]string{
+			"wallet":                       tt.wallet,
+			"wallet_passphrase":            tt.passphrase,
+			"recipient_blockchain_address": "1Bob",
+			"value":                        "0.5",
+		})
+		resp, err := http.Post(ts.URL+"/transaction", "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("wallet %s with %q: status = %d, want %d", tt.wallet, tt.passphrase, resp.StatusCode, tt.want)
+		}
+	}
+	if pool := bc.TransactionPool(); len(pool) != 1 || bc.CalculateTotalAmount("1Bob") != 0 {
+		t.Fatalf("pool = %v, want alice's transaction", pool)
+	}
+	bc.Mining()
+	if got := bc.CalculateTotalAmount("1Bob"); got != 0.5 {
+		t.Errorf("recipient balance = %v, want 0.5", got)
+	}
+}
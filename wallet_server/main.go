@@ -2,7 +2,10 @@ package main
 
 import (
 	"flag"
+	"goblockchain/utils"
 	"log"
+	"os"
+	"strings"
 )
 
 func init() {
@@ -11,9 +14,35 @@ func init() {
 
 func main() {
 	port := flag.Uint("port", 8080, "TCP Port Number for Wallet Server")
+	host := flag.String("host", DefaultHost, "Host to bind for Wallet Server")
 	gateway := flag.String("gateway", "http://127.0.0.1:5000", "Blockchain Gateway")
+	origins := flag.String("origins", "", "Comma separated CORS allowed origins")
+	rate := flag.Float64("rate", 0, "Requests per second allowed per client IP (0 disables)")
+	burst := flag.Int("burst", 10, "Burst of requests allowed per client IP")
+	chainID := flag.String("chain_id", "", "Network chain ID transactions are signed for")
+	tlsCert := flag.String("tls_cert", "", "TLS certificate file")
+	tlsKey := flag.String("tls_key", "", "TLS private key file")
+	tlsSelfSigned := flag.Bool("tls_self_signed", false, "Serve TLS with a self signed certificate for local development")
+	walletsFile := flag.String("wallets_file", "", "File labeled wallets are encrypted to with $WALLETS_PASSPHRASE (empty keeps them in memory)")
+	maxBody := flag.Int64("max_body", utils.DefaultMaxBodyBytes, "Max request body bytes (0 disables)")
 	flag.Parse()
 
 	app := NewWalletServer(uint16(*port), *gateway)
+	app.SetHost(*host)
+	app.SetChainID(*chainID)
+	app.SetTLS(*tlsCert, *tlsKey)
+	app.SetSelfSignedTLS(*tlsSelfSigned)
+	app.SetMaxBodyBytes(*maxBody)
+	if *rate > 0 {
+		app.SetRateLimit(*rate, *burst)
+	}
+	if *walletsFile != "" {
+		if err := app.WalletRegistry().Persist(*walletsFile, os.Getenv("WALLETS_PASSPHRASE")); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *origins != "" {
+		app.SetAllowedOrigins(strings.Split(*origins, ","))
+	}
 	app.Run()
 }
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"goblockchain/utils"
+	"goblockchain/wallet"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// Error codes of WalletRegistry errors.
+const (
+	ErrorCodeWalletExists    = "wallet_exists"
+	ErrorCodeWalletSave      = "wallet_save_failed"
+	ErrorCodeWrongPassphrase = "wrong_passphrase"
+)
+
+// Errors returned by WalletRegistry.
+var (
+	ErrInvalidLabel = errors.New("label must be 1-64 letters, digits, '-', '_' or '.'")
+	ErrWalletExists = errors.New("wallet label already exists")
+	// ErrNoPassphrase is returned creating a wallet without a passphrase.
+	ErrNoPassphrase = errors.New("wallet needs a passphrase")
+	// ErrWalletNotFound is returned unlocking a label not in the registry.
+	ErrWalletNotFound = errors.New("wallet not found")
+	// ErrWrongPassphrase is returned unlocking a wallet with another
+	// passphrase than it was created with.
+	ErrWrongPassphrase = errors.New("wrong wallet passphrase")
+)
+
+// labelPattern is pattern of wallet labels.
+var labelPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// WalletRegistry is wallets kept by the wallet server under user supplied
+// labels, optionally persisted to a passphrase encrypted file. Each wallet
+// has its own passphrase, needed to sign with it.
+type WalletRegistry struct {
+	wallets    map[string]*registryWallet
+	path       string
+	passphrase string
+	mux        sync.RWMutex
+}
+
+// NewWalletRegistry is to return new in-memory WalletRegistry struct.
+func NewWalletRegistry() *WalletRegistry {
+	return &WalletRegistry{wallets: make(map[string]*registryWallet)}
+}
+
+// registryWallet is wallet of the registry with scrypt hash of its passphrase.
+type registryWallet struct {
+	wallet         *wallet.Wallet
+	passphraseSalt []byte
+	passphraseHash []byte
+}
+
+// passphraseKey is to return scrypt key of passphrase and salt.
+func passphraseKey(passphrase string, salt []byte) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+}
+
+// LabeledWallet is public part of a registry wallet served by the API.
+// Private keys never leave the registry.
+type LabeledWallet struct {
+	Label             string `json:"label"`
+	PublicKey         string `json:"public_key"`
+	BlockchainAddress string `json:"blockchain_address"`
+}
+
+// labeled is to return public part of w under label.
+func labeled(label string, w *wallet.Wallet) *LabeledWallet {
+	return &LabeledWallet{Label: label, PublicKey: w.PublicKeyStr(), BlockchainAddress: w.BlockchainAddress()}
+}
+
+// Create is to make a new wallet under label, unlocked by passphrase,
+// failing with ErrInvalidLabel, ErrNoPassphrase or ErrWalletExists. It's
+// saved at once when the registry is persisted.
+func (r *WalletRegistry) Create(label string, passphrase string) (*LabeledWallet, error) {
+	if !labelPattern.MatchString(label) {
+		return nil, ErrInvalidLabel
+	}
+	if passphrase == "" {
+		return nil, ErrNoPassphrase
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	hash, err := passphraseKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if _, ok := r.wallets[label]; ok {
+		return nil, ErrWalletExists
+	}
+	w := wallet.NewWallet()
+	r.wallets[label] = &registryWallet{wallet: w, passphraseSalt: salt, passphraseHash: hash}
+	if r.path != "" {
+		if err := r.save(); err != nil {
+			delete(r.wallets, label)
+			return nil, err
+		}
+	}
+	return labeled(label, w), nil
+}
+
+// Get is to return public part of wallet under label.
+func (r *WalletRegistry) Get(label string) (*LabeledWallet, bool) {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	rw, ok := r.wallets[label]
+	if !ok {
+		return nil, false
+	}
+	return labeled(label, rw.wallet), true
+}
+
+// List is to return public parts of all wallets, sorted by label.
+func (r *WalletRegistry) List() []*LabeledWallet {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	labels := make([]string, 0, len(r.wallets))
+	for label := range r.wallets {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	list := make([]*LabeledWallet, len(labels))
+	for i, label := range labels {
+		list[i] = labeled(label, r.wallets[label].wallet)
+	}
+	return list
+}
+
+// Unlock is to return wallet under label to sign transactions with, failing
+// with ErrWalletNotFound, or ErrWrongPassphrase unless passphrase is the one
+// it was created with.
+func (r *WalletRegistry) Unlock(label string, passphrase string) (*wallet.Wallet, error) {
+	r.mux.RLock()
+	rw, ok := r.wallets[label]
+	r.mux.RUnlock()
+	if !ok {
+		return nil, ErrWalletNotFound
+	}
+	hash, err := passphraseKey(passphrase, rw.passphraseSalt)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare(hash, rw.passphraseHash) != 1 {
+		return nil, ErrWrongPassphrase
+	}
+	return rw.wallet, nil
+}
+
+// encryptedWallets is json of the registry file.
+type encryptedWallets struct {
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// storedWallet is key pair and passphrase hash of a wallet in the
+// encrypted plaintext.
+type storedWallet struct {
+	PrivateKey     string `json:"private_key"`
+	PublicKey      string `json:"public_key"`
+	PassphraseSalt []byte `json:"passphrase_salt"`
+	PassphraseHash []byte `json:"passphrase_hash"`
+}
+
+// Persist is to load wallets from path encrypted with passphrase, if it
+// exists, and save the registry there whenever a wallet is created.
+func (r *WalletRegistry) Persist(path string, passphrase string) error {
+	if passphrase == "" {
+		return errors.New("wallets file needs a passphrase")
+	}
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return err
+	default:
+		var ew encryptedWallets
+		if err := json.Unmarshal(data, &ew); err != nil {
+			return fmt.Errorf("load %s: %w", path, err)
+		}
+		aead, err := walletsCipher(passphrase, ew.Salt)
+		if err != nil {
+			return err
+		}
+		plaintext, err := aead.Open(nil, ew.Nonce, ew.Ciphertext, nil)
+		if err != nil {
+			return fmt.Errorf("load %s: wrong passphrase or corrupt file", path)
+		}
+		var stored map[string]storedWallet
+		if err := json.Unmarshal(plaintext, &stored); err != nil {
+			return fmt.Errorf("load %s: %w", path, err)
+		}
+		for label, sw := range stored {
+			if len(sw.PassphraseSalt) == 0 || len(sw.PassphraseHash) == 0 {
+				return fmt.Errorf("load %s: wallet %q has no passphrase", path, label)
+			}
+			publicKey := utils.PublicKeyFromString(sw.PublicKey)
+			r.wallets[label] = &registryWallet{
+				wallet:         wallet.NewWalletFromKey(utils.PrivateKeyFromString(sw.PrivateKey, publicKey)),
+				passphraseSalt: sw.PassphraseSalt,
+				passphraseHash: sw.PassphraseHash,
+			}
+		}
+	}
+	r.path = path
+	r.passphrase = passphrase
+	return nil
+}
+
+// save is to write the registry encrypted to its path, replacing the file
+// atomically. mux must be held.
+func (r *WalletRegistry) save() error {
+	stored := make(map[string]storedWallet, len(r.wallets))
+	for label, rw := range r.wallets {
+		stored[label] = storedWallet{
+			PrivateKey:     rw.wallet.PrivateKeyStr(),
+			PublicKey:      rw.wallet.PublicKeyStr(),
+			PassphraseSalt: rw.passphraseSalt,
+			PassphraseHash: rw.passphraseHash,
+		}
+	}
+	plaintext, err := json.Marshal(stored)
+	if err != nil {
+		return err
+	}
+	ew := encryptedWallets{Salt: make([]byte, 16)}
+	if _, err := rand.Read(ew.Salt); err != nil {
+		return err
+	}
+	aead, err := walletsCipher(r.passphrase, ew.Salt)
+	if err != nil {
+		return err
+	}
+	ew.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(ew.Nonce); err != nil {
+		return err
+	}
+	ew.Ciphertext = aead.Seal(nil, ew.Nonce, plaintext, nil)
+	m, err := json.Marshal(ew)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), filepath.Base(r.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(m); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), r.path)
+}
+
+// walletsCipher is to return AES-256-GCM keyed by scrypt of passphrase and salt.
+func walletsCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := passphraseKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWalletRegistry(t *testing.T) {
+	r := NewWalletRegistry()
+	alice, err := r.Create("alice", "alice secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := r.Create("bob", "bob secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alice.BlockchainAddress == bob.BlockchainAddress {
+		t.Error("two wallets share an address")
+	}
+	if got, ok := r.Get("alice"); !ok || *got != *alice {
+		t.Errorf("Get(alice) = %+v, %v; want %+v", got, ok, alice)
+	}
+	if _, ok := r.Get("carol"); ok {
+		t.Error("unknown label found")
+	}
+	if list := r.List(); len(list) != 2 || list[0].Label != "alice" || list[1].Label != "bob" {
+		t.Errorf("list = %+v, want alice and bob", list)
+	}
+
+	tests := []struct {
+		label      string
+		passphrase string
+		want       error
+	}{
+		{"alice", "secret", ErrWalletExists},
+		{"", "secret", ErrInvalidLabel},
+		{"a b", "secret", ErrInvalidLabel},
+		{strings.Repeat("a", 65), "secret", ErrInvalidLabel},
+		{"carol", "", ErrNoPassphrase},
+	}
+	for _, tt := range tests {
+		if _, err := r.Create(tt.label, tt.passphrase); !errors.Is(err, tt.want) {
+			t.Errorf("Create(%q, %q) error = %v, want %v", tt.label, tt.passphrase, err, tt.want)
+		}
+	}
+}
+
+func TestWalletRegistryUnlock(t *testing.T) {
+	r := NewWalletRegistry()
+	alice, err := r.Create("alice", "alice secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := r.Unlock("alice", "alice secret")
+	if err != nil || w.BlockchainAddress() != alice.BlockchainAddress {
+		t.Errorf("Unlock(alice) = %v, %v", w, err)
+	}
+	if _, err := r.Unlock("alice", "wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("wrong passphrase: error = %v, want ErrWrongPassphrase", err)
+	}
+	if _, err := r.Unlock("alice", ""); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("empty passphrase: error = %v, want ErrWrongPassphrase", err)
+	}
+	if _, err := r.Unlock("carol", "alice secret"); !errors.Is(err, ErrWalletNotFound) {
+		t.Errorf("unknown label: error = %v, want ErrWalletNotFound", err)
+	}
+}
+
+func TestWalletRegistryPersist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "wallets.json")
+	r := NewWalletRegistry()
+	if err := r.Persist(path, ""); err == nil {
+		t.Error("persisted without a passphrase")
+	}
+	if err := r.Persist(path, "secret"); err != nil {
+		t.Fatal(err)
+	}
+	alice, err := r.Create("alice", "alice secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := NewWalletRegistry()
+	if err := loaded.Persist(path, "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := loaded.Get("alice"); !ok || *got != *alice {
+		t.Errorf("loaded alice = %+v, %v; want %+v", got, ok, alice)
+	}
+	signer, err := loaded.Unlock("alice", "alice secret")
+	if err != nil {
+		t.Fatalf("unlock loaded alice: %v", err)
+	}
+	if original, _ := r.Unlock("alice", "alice secret"); signer.PrivateKeyStr() != original.PrivateKeyStr() {
+		t.Error("loaded wallet has another private key")
+	}
+	if _, err := loaded.Unlock("alice", "secret"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("loaded alice unlocked with the file passphrase: error = %v", err)
+	}
+
+	if err := NewWalletRegistry().Persist(path, "wrong"); err == nil || !strings.Contains(err.Error(), "wrong passphrase") {
+		t.Errorf("wrong passphrase: error = %v", err)
+	}
+}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"bytes"
+	"crypto/ecdsa"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"goblockchain/block"
 	"goblockchain/utils"
@@ -10,6 +12,7 @@ import (
 	"html/template"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"path"
 	"strconv"
@@ -17,15 +20,79 @@ import (
 
 const tempDir = "templates"
 
+// DefaultHost is default host to bind.
+const DefaultHost = "0.0.0.0"
+
 // WalletServer is WalletServer struct.
 type WalletServer struct {
-	port    uint16
-	gateway string
+	host           string
+	port           uint16
+	gateway        string
+	allowedOrigins []string
+	logger         utils.Logger
+	limiter        *utils.RateLimiter
+	chainID        string
+
+	tlsCertFile   string
+	tlsKeyFile    string
+	tlsSelfSigned bool
+
+	maxBodyBytes int64
+
+	wallets *WalletRegistry
 }
 
 // NewWalletServer is to return new wallet server struct.
 func NewWalletServer(port uint16, gateway string) *WalletServer {
-	return &WalletServer{port, gateway}
+	return &WalletServer{host: DefaultHost, port: port, gateway: gateway, logger: utils.NewStdLogger(),
+		maxBodyBytes: utils.DefaultMaxBodyBytes, wallets: NewWalletRegistry()}
+}
+
+// WalletRegistry is to return registry of labeled wallets.
+func (ws *WalletServer) WalletRegistry() *WalletRegistry {
+	return ws.wallets
+}
+
+// SetTLS is set certificate and key files to serve over TLS.
+func (ws *WalletServer) SetTLS(certFile, keyFile string) {
+	ws.tlsCertFile = certFile
+	ws.tlsKeyFile = keyFile
+}
+
+// SetSelfSignedTLS is set serving over TLS with a self signed certificate, for local development.
+func (ws *WalletServer) SetSelfSignedTLS(selfSigned bool) {
+	ws.tlsSelfSigned = selfSigned
+}
+
+// SetMaxBodyBytes is set limit of request body size, replying 413 to larger ones.
+// 0 disables the limit.
+func (ws *WalletServer) SetMaxBodyBytes(limit int64) {
+	ws.maxBodyBytes = limit
+}
+
+// SetRateLimit is set limit of requests per client IP to rate per second with burst.
+func (ws *WalletServer) SetRateLimit(rate float64, burst int) {
+	ws.limiter = utils.NewRateLimiter(rate, burst)
+}
+
+// Logger is return to Wallet logger.
+func (ws *WalletServer) Logger() utils.Logger {
+	return ws.logger
+}
+
+// SetLogger is set Wallet logger.
+func (ws *WalletServer) SetLogger(logger utils.Logger) {
+	ws.logger = logger
+}
+
+// Host is return to Wallet host.
+func (ws *WalletServer) Host() string {
+	return ws.host
+}
+
+// SetHost is set Wallet host to bind.
+func (ws *WalletServer) SetHost(host string) {
+	ws.host = host
 }
 
 // Port is return to Wallet port.
@@ -38,14 +105,41 @@ func (ws *WalletServer) Gateway() string {
 	return ws.gateway
 }
 
+// ChainID is return to network chain ID transactions are signed for.
+func (ws *WalletServer) ChainID() string {
+	return ws.chainID
+}
+
+// SetChainID is set network chain ID transactions are signed for.
+func (ws *WalletServer) SetChainID(chainID string) {
+	ws.chainID = chainID
+}
+
+// AllowedOrigins is return to CORS allowed origins.
+func (ws *WalletServer) AllowedOrigins() []string {
+	return ws.allowedOrigins
+}
+
+// SetAllowedOrigins is set CORS allowed origins. "*" allows any origin.
+func (ws *WalletServer) SetAllowedOrigins(origins []string) {
+	ws.allowedOrigins = origins
+}
+
 // Index is index.
 func (ws *WalletServer) Index(w http.ResponseWriter, req *http.Request) {
 	switch req.Method {
 	case http.MethodGet:
-		t, _ := template.ParseFiles(path.Join(tempDir, "index.html"))
+		t, err := template.ParseFiles(path.Join(tempDir, "index.html"))
+		if err != nil {
+			ws.logger.Error("parse index template", "error", err)
+			utils.WriteError(w, http.StatusInternalServerError, utils.ErrorCodeInternal, "index page unavailable")
+			return
+		}
+		utils.SetContentType(w, utils.ContentTypeHTML)
 		t.Execute(w, "")
 	default:
-		log.Printf("ERROR: Invalid HTTP Method")
+		ws.logger.Error("invalid http method", "method", req.Method, "path", req.URL.Path)
+		utils.MethodNotAllowed(w, http.MethodGet)
 	}
 }
 
@@ -53,17 +147,71 @@ func (ws *WalletServer) Index(w http.ResponseWriter, req *http.Request) {
 func (ws *WalletServer) Wallet(w http.ResponseWriter, req *http.Request) {
 	switch req.Method {
 	case http.MethodPost:
-		w.Header().Add("Content-Type", "application/json")
+		utils.SetContentType(w, utils.ContentTypeJSON)
 		myWallet := wallet.NewWallet()
 		m, _ := myWallet.MarshalJSON()
 		io.WriteString(w, string(m[:]))
 	default:
-		w.WriteHeader(http.StatusBadRequest)
-		log.Println("ERROR: Invalid HTTP Method.")
+		ws.logger.Error("invalid http method", "method", req.Method, "path", req.URL.Path)
+		utils.MethodNotAllowed(w, http.MethodPost)
 	}
 }
 
-// CreateTransaction is api to create transaction.
+// Wallets is api to create a labeled wallet kept by the server with
+// POST {"label": ..., "passphrase": ...}, and to list them, or get the one
+// of the label query parameter, with GET. Private keys are never returned,
+// and signing with a wallet needs its passphrase.
+func (ws *WalletServer) Wallets(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case http.MethodGet:
+		var v interface{} = ws.wallets.List()
+		if label := req.URL.Query().Get("label"); label != "" {
+			lw, ok := ws.wallets.Get(label)
+			if !ok {
+				utils.WriteError(w, http.StatusNotFound, utils.ErrorCodeNotFound, "wallet not found")
+				return
+			}
+			v = lw
+		}
+		m, _ := json.Marshal(v)
+		utils.SetContentType(w, utils.ContentTypeJSON)
+		io.WriteString(w, string(m))
+	case http.MethodPost:
+		var body struct {
+			Label      string `json:"label"`
+			Passphrase string `json:"passphrase"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			ws.logger.Error("decode wallet request", "error", err)
+			utils.WriteRequestError(w, err)
+			return
+		}
+		lw, err := ws.wallets.Create(body.Label, body.Passphrase)
+		switch {
+		case errors.Is(err, ErrInvalidLabel), errors.Is(err, ErrNoPassphrase):
+			utils.WriteError(w, http.StatusBadRequest, utils.ErrorCodeInvalidRequest, err.Error())
+			return
+		case errors.Is(err, ErrWalletExists):
+			utils.WriteError(w, http.StatusConflict, ErrorCodeWalletExists, err.Error())
+			return
+		case err != nil:
+			ws.logger.Error("create wallet", "label", body.Label, "error", err)
+			utils.WriteError(w, http.StatusInternalServerError, ErrorCodeWalletSave, "failed to save wallet")
+			return
+		}
+		m, _ := json.Marshal(lw)
+		utils.SetContentType(w, utils.ContentTypeJSON)
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, string(m))
+	default:
+		ws.logger.Error("invalid http method", "method", req.Method, "path", req.URL.Path)
+		utils.MethodNotAllowed(w, http.MethodGet, http.MethodPost)
+	}
+}
+
+// CreateTransaction is api to create transaction, signed with the sender
+// keys of the request or the registry wallet it names, unlocked by
+// wallet_passphrase.
 func (ws *WalletServer) CreateTransaction(w http.ResponseWriter, req *http.Request) {
 	switch req.Method {
 	case http.MethodPost:
@@ -71,52 +219,89 @@ func (ws *WalletServer) CreateTransaction(w http.ResponseWriter, req *http.Reque
 		var t wallet.TransactionRequest
 		err := decoder.Decode(&t)
 		if err != nil {
-			log.Printf("ERROR: %v", err)
-			io.WriteString(w, string(utils.JSONStatus("fail")))
+			ws.logger.Error("decode transaction request", "error", err)
+			utils.WriteRequestError(w, err)
 			return
 		}
 		if !t.Validate() {
-			log.Println("ERROR: missing field(s)")
-			io.WriteString(w, string(utils.JSONStatus("fail")))
+			ws.logger.Error("validate request", "reason", "missing field(s)")
+			utils.WriteError(w, http.StatusBadRequest, block.ErrorCodeMissingFields, block.ErrMissingFields.Error())
 			return
 		}
 
-		publicKey := utils.PublicKeyFromString(*t.SenderPublicKey)
-		privateKey := utils.PrivateKeyFromString(*t.SenderPrivateKey, publicKey)
+		var privateKey *ecdsa.PrivateKey
+		var publicKey *ecdsa.PublicKey
+		if t.Wallet != nil {
+			signer, err := ws.wallets.Unlock(*t.Wallet, *t.WalletPassphrase)
+			switch {
+			case errors.Is(err, ErrWalletNotFound):
+				utils.WriteError(w, http.StatusNotFound, utils.ErrorCodeNotFound, err.Error())
+				return
+			case errors.Is(err, ErrWrongPassphrase):
+				ws.logger.Error("unlock wallet", "label", *t.Wallet, "error", err)
+				utils.WriteError(w, http.StatusUnauthorized, ErrorCodeWrongPassphrase, err.Error())
+				return
+			case err != nil:
+				ws.logger.Error("unlock wallet", "label", *t.Wallet, "error", err)
+				utils.WriteError(w, http.StatusInternalServerError, utils.ErrorCodeInternal, "failed to unlock wallet")
+				return
+			}
+			privateKey, publicKey = signer.PrivateKey(), signer.PublicKey()
+			sender, publicKeyStr := signer.BlockchainAddress(), signer.PublicKeyStr()
+			t.SenderBlockchainAddress, t.SenderPublicKey = &sender, &publicKeyStr
+		} else {
+			publicKey, err = wallet.DecodePublicKey(*t.SenderPublicKey)
+			if err != nil {
+				ws.logger.Error("parse public key", "error", err)
+				utils.WriteError(w, http.StatusBadRequest, utils.ErrorCodeInvalidRequest, err.Error())
+				return
+			}
+			privateKey = utils.PrivateKeyFromString(*t.SenderPrivateKey, publicKey)
+		}
 		value, err := strconv.ParseFloat(*t.Value, 32)
 		if err != nil {
-			log.Println("ERROR: parse error")
-			io.WriteString(w, string(utils.JSONStatus("fail")))
+			ws.logger.Error("parse value", "error", err)
+			utils.WriteError(w, http.StatusBadRequest, utils.ErrorCodeInvalidRequest, "invalid value")
 			return
 		}
 
 		value32 := float32(value)
 
-		w.Header().Add("Content-Type", "application/json")
-
 		transaction := wallet.NewTransaction(privateKey, publicKey, *t.SenderBlockchainAddress, *t.RecipientBlockchainAddress, value32)
+		transaction.SetChainID(ws.ChainID())
 		signature := transaction.GenerateSignature()
 		signatureStr := signature.String()
+		timestamp := transaction.Timestamp()
 
 		bt := &block.TransactionRequest{
 			SenderBlockchainAddress:    t.SenderBlockchainAddress,
 			RecipientBlockchainAddress: t.RecipientBlockchainAddress,
 			SenderPublicKey:            t.SenderPublicKey,
 			Value:                      &value32,
+			Timestamp:                  &timestamp,
 			Signature:                  &signatureStr,
 		}
 		m, _ := json.Marshal(bt)
 		buf := bytes.NewBuffer(m)
 
-		resp, _ := http.Post(ws.Gateway()+"/transactions", "application/json", buf)
+		resp, err := http.Post(ws.Gateway()+"/transactions", "application/json", buf)
+		if err != nil {
+			ws.logger.Error("post transaction", "gateway", ws.Gateway(), "error", err)
+			utils.WriteError(w, http.StatusBadGateway, utils.ErrorCodeUpstream, err.Error())
+			return
+		}
+		defer resp.Body.Close()
+		utils.SetContentType(w, utils.ContentTypeJSON)
 		if resp.StatusCode == http.StatusCreated {
 			io.WriteString(w, string(utils.JSONStatus("success")))
 			return
 		}
-		io.WriteString(w, string(utils.JSONStatus("fail")))
+		// Pass the node's error envelope through.
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
 	default:
-		w.WriteHeader(http.StatusBadRequest)
-		log.Println("ERROR: Invalid HTTP Method")
+		ws.logger.Error("invalid http method", "method", req.Method, "path", req.URL.Path)
+		utils.MethodNotAllowed(w, http.MethodPost)
 	}
 }
 
@@ -135,19 +320,19 @@ func (ws *WalletServer) WalletAmount(w http.ResponseWriter, req *http.Request) {
 
 		bcsResp, err := client.Do(bcsReq)
 		if err != nil {
-			log.Printf("ERROR: %v", err)
-			io.WriteString(w, string(utils.JSONStatus("fail")))
+			ws.logger.Error("request amount", "endpoint", endpoint, "error", err)
+			utils.WriteError(w, http.StatusBadGateway, utils.ErrorCodeUpstream, err.Error())
 			return
 		}
 
-		w.Header().Add("Content-Type", "application/json")
+		utils.SetContentType(w, utils.ContentTypeJSON)
 		if bcsResp.StatusCode == http.StatusOK {
 			decoder := json.NewDecoder(bcsResp.Body)
 			var bar block.AmountResponse
 			err := decoder.Decode(&bar)
 			if err != nil {
-				log.Printf("ERROR: %v", err)
-				io.WriteString(w, string(utils.JSONStatus("fail")))
+				ws.logger.Error("decode amount response", "error", err)
+				utils.WriteError(w, http.StatusBadGateway, utils.ErrorCodeUpstream, err.Error())
 				return
 			}
 
@@ -160,19 +345,28 @@ func (ws *WalletServer) WalletAmount(w http.ResponseWriter, req *http.Request) {
 			})
 			io.WriteString(w, string(m[:]))
 		} else {
-			io.WriteString(w, string(utils.JSONStatus("fail")))
+			utils.WriteError(w, http.StatusBadGateway, utils.ErrorCodeUpstream, bcsResp.Status)
 		}
 	default:
-		log.Printf("ERROR: Invalid HTTP Method")
-		w.WriteHeader(http.StatusBadRequest)
+		ws.logger.Error("invalid http method", "method", req.Method, "path", req.URL.Path)
+		utils.MethodNotAllowed(w, http.MethodGet)
 	}
 }
 
+// Handler is to return wallet server's http handler.
+func (ws *WalletServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", ws.Index)
+	mux.HandleFunc("/wallet", ws.Wallet)
+	mux.HandleFunc("/wallets", ws.Wallets)
+	mux.HandleFunc("/wallet/amount", ws.WalletAmount)
+	mux.HandleFunc("/transaction", ws.CreateTransaction)
+	return utils.CORS(ws.AllowedOrigins(), utils.RateLimit(ws.limiter, utils.LimitBody(ws.maxBodyBytes, mux)))
+}
+
 // Run is to run wallet server.
 func (ws *WalletServer) Run() {
-	http.HandleFunc("/", ws.Index)
-	http.HandleFunc("/wallet", ws.Wallet)
-	http.HandleFunc("/wallet/amount", ws.WalletAmount)
-	http.HandleFunc("/transaction", ws.CreateTransaction)
-	log.Fatal(http.ListenAndServe("0.0.0.0:"+strconv.Itoa(int(ws.Port())), nil))
+	addr := net.JoinHostPort(ws.Host(), strconv.Itoa(int(ws.Port())))
+	server := &http.Server{Addr: addr, Handler: ws.Handler()}
+	log.Fatal(utils.ListenAndServe(server, ws.tlsCertFile, ws.tlsKeyFile, ws.tlsSelfSigned))
 }